@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/fla/self-programming-ai/pkg/fsm"
@@ -75,6 +76,24 @@ func main() {
 
 	server := web.NewAdvancedVisualizationServer(port)
 
+	// Require a bearer token for mutating API requests when one is configured
+	if token := os.Getenv("FSM_AUTH_TOKEN"); token != "" {
+		server.SetAuthToken(token)
+		fmt.Println("🔒 API authentication enabled")
+	}
+
+	// Allow browser front-ends on other origins, e.g. FSM_CORS_ORIGINS=http://localhost:3000
+	if origins := os.Getenv("FSM_CORS_ORIGINS"); origins != "" {
+		server.SetCORSOrigins(strings.Split(origins, ","))
+	}
+
+	// Keep transition history across restarts
+	if dir := os.Getenv("FSM_HISTORY_DIR"); dir != "" {
+		if err := server.SetHistoryStore(dir); err != nil {
+			log.Fatalf("Failed to open history store: %v", err)
+		}
+	}
+
 	// Register demo machine
 	demoMachine := createDemoMachine()
 	server.RegisterMachine("demo-order", demoMachine)
@@ -107,6 +107,9 @@ func NewVendingMachine(machineID string) *VendingMachine {
 			fsm.State(Idle), fsm.Event(InsertCoin), fsm.State(CoinInserted),
 			vm.createCoinInsertAction(),
 		).
+		// SelectProduct has two guarded branches; under FirstMatch the purchasable
+		// branch is tried first and the out-of-stock branch only when it is rejected
+		SetStateSelectionPolicy(fsm.State(CoinInserted), fsm.FirstMatch).
 		AddTransitionWithCondition(
 			fsm.State(CoinInserted), fsm.Event(SelectProduct), fsm.State(ProductSelected),
 			vm.createProductSelectionCondition(),
@@ -2,4 +2,9 @@ module github.com/fla/self-programming-ai
 
 go 1.21
 
-require gopkg.in/yaml.v2 v2.4.0
+require (
+	github.com/BurntSushi/toml v1.5.0
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require github.com/gorilla/websocket v1.5.3
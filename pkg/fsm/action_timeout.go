@@ -0,0 +1,87 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SetActionTimeout limits how long a transition's actions may run; 0 or less removes the limit
+// A transition whose actions overrun fails with an "ActionTimeout" error routed through
+// OnTransitionError and leaves the state unchanged. Only a CancelableAction can stop when the
+// deadline passes; an overrunning plain TransitionAction is abandoned but keeps running.
+func (sm *StateMachine) SetActionTimeout(d time.Duration) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.actionTimeout = d
+}
+
+// runActionUnsafe runs a transition's actions under the action timeout and the caller's ctx without acquiring locks
+func (sm *StateMachine) runActionUnsafe(parent context.Context, transition Transition) error {
+	from := sm.currentState
+	// Read while the lock is held: an abandoned action keeps running after the caller unlocks,
+	// when SetContext, Restore or a payload send may replace sm.context
+	machineContext := sm.context
+	run := func(ctx context.Context) (err error) {
+		defer func() {
+			if value := recover(); value != nil {
+				err = newPanicError("action", transition, from, value)
+			}
+		}()
+		if transition.Action != nil {
+			if err := transition.Action(from, transition.To, transition.Event, machineContext); err != nil {
+				return err
+			}
+		}
+		if transition.CancelableAction != nil {
+			return transition.CancelableAction(ctx, from, transition.To, transition.Event, machineContext)
+		}
+		return nil
+	}
+
+	if sm.actionTimeout <= 0 && parent.Done() == nil {
+		return run(parent) // Nothing can interrupt the action, so run it inline
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if sm.actionTimeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, sm.actionTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	defer cancel()
+
+	done := make(chan error, 1) // Buffered so an abandoned action can still finish
+	go func() {
+		done <- run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		// An action that gave up because of the deadline or cancellation is reported as such
+		if err == nil || ctx.Err() == nil {
+			return err
+		}
+	case <-ctx.Done():
+	}
+
+	if err := parent.Err(); err != nil {
+		return newCancelledError(transition.Event, err)
+	}
+	return FSMError{
+		Type:    "ActionTimeout",
+		Message: fmt.Sprintf("Action for %s did not finish within %s", transition, sm.actionTimeout),
+		State:   from,
+		Event:   transition.Event,
+	}
+}
+
+// newCancelledError creates an error for an event abandoned because the caller's context ended
+func newCancelledError(event Event, cause error) FSMError {
+	return FSMError{
+		Type:    "TransitionCancelled",
+		Message: fmt.Sprintf("Event '%s' was cancelled: %v", event, cause),
+		Event:   event,
+	}
+}
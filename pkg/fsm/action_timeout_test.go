@@ -0,0 +1,128 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestActionTimeout tests that an overrunning action fails the transition without changing state
+func TestActionTimeout(t *testing.T) {
+	cancelled := make(chan struct{})
+	machine, err := NewBuilderWithHooks().
+		AddTransitionWithCancelableAction("idle", "fetch", "fetched", func(ctx context.Context, from, to State, event Event, data Context) error {
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		}).
+		AddTransitionWithCancelableAction("idle", "quick", "fetched", func(ctx context.Context, from, to State, event Event, data Context) error {
+			data.Set("fetched", true)
+			return nil
+		}).
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	var hookErr error
+	machine.AddHook(OnTransitionError, func(result TransitionResult, context Context) {
+		hookErr = result.Error
+	})
+	machine.(*StateMachine).SetActionTimeout(10 * time.Millisecond)
+
+	result, err := machine.SendEvent("fetch")
+	var fsmErr FSMError
+	if !errors.As(err, &fsmErr) || fsmErr.Type != "ActionTimeout" {
+		t.Fatalf("Expected an ActionTimeout error, got %v", err)
+	}
+	if result.Success || hookErr == nil {
+		t.Errorf("Expected a failed result routed to OnTransitionError, got %+v", result)
+	}
+	if state := machine.CurrentState(); state != "idle" {
+		t.Errorf("Expected the state to stay 'idle', got %s", state)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("Expected the action's context to be cancelled")
+	}
+
+	if _, err := machine.SendEvent("quick"); err != nil {
+		t.Fatalf("Expected a fast action to succeed, got %v", err)
+	}
+	if machine.CurrentState() != "fetched" || machine.GetContext().Get("fetched") != true {
+		t.Errorf("Expected the fast action to complete, got state %s", machine.CurrentState())
+	}
+}
+
+// TestSendEventCtxCancellation tests that cancelling the caller's context aborts the transition cleanly
+func TestSendEventCtxCancellation(t *testing.T) {
+	started := make(chan struct{})
+	machine, err := NewBuilder().
+		AddTransitionWithCancelableAction("running", "drain", "stopped", func(ctx context.Context, from, to State, event Event, data Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}).
+		SetInitialState("running").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	var fsmErr FSMError
+	if _, err := machine.SendEventCtx(ctx, "drain"); !errors.As(err, &fsmErr) || fsmErr.Type != "TransitionCancelled" {
+		t.Fatalf("Expected a TransitionCancelled error, got %v", err)
+	}
+	if state := machine.CurrentState(); state != "running" {
+		t.Errorf("Expected the state to stay 'running', got %s", state)
+	}
+
+	if result, err := machine.SendEventCtx(ctx, "drain"); err == nil || result != nil {
+		t.Errorf("Expected an already cancelled context to be rejected up front, got %v", result)
+	}
+}
+
+// TestAbandonedActionKeepsContext tests that an overrunning action keeps the context it started with
+func TestAbandonedActionKeepsContext(t *testing.T) {
+	release := make(chan struct{})
+	seen := make(chan Context, 1)
+	machine, err := NewBuilder().
+		AddTransition("idle", "work", "done").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	machine.AddTransition(Transition{
+		From: "idle", Event: "work", To: "done",
+		Action: func(from, to State, event Event, data Context) error {
+			<-release // Overruns the timeout and ignores it
+			return nil
+		},
+		CancelableAction: func(ctx context.Context, from, to State, event Event, data Context) error {
+			seen <- data
+			return nil
+		},
+	})
+	machine.(*StateMachine).SetActionTimeout(10 * time.Millisecond)
+	original := machine.GetContext()
+
+	if _, err := machine.SendEvent("work"); !errors.Is(err, FSMError{Type: "ActionTimeout"}) {
+		t.Fatalf("Expected an ActionTimeout, got %v", err)
+	}
+	machine.SetContext(NewContext()) // Replaces the context while the abandoned action still runs
+	close(release)
+
+	if data := <-seen; data != original {
+		t.Error("Expected the abandoned action to keep the context of its transition")
+	}
+}
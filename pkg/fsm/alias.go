@@ -0,0 +1,105 @@
+package fsm
+
+import (
+	"fmt"
+	"sort"
+)
+
+// AddEventAlias makes alias trigger the transitions defined for canonical, which must be defined
+// Sending alias fires canonical's transitions, so results and hooks report canonical. An alias
+// can't be an event with transitions of its own, and aliasing an alias resolves to its canonical
+func (sm *StateMachine) AddEventAlias(alias, canonical Event) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if target, isAlias := sm.aliases[canonical]; isAlias {
+		canonical = target
+	}
+	if alias == canonical {
+		return FSMError{
+			Type:    "InvalidAlias",
+			Message: fmt.Sprintf("Event '%s' cannot be an alias of itself", alias),
+			Event:   alias,
+		}
+	}
+	if _, isAlias := sm.aliases[alias]; isAlias || sm.events[alias] {
+		return FSMError{
+			Type:    "InvalidAlias",
+			Message: fmt.Sprintf("Event '%s' is already defined", alias),
+			Event:   alias,
+		}
+	}
+
+	if !sm.events[canonical] {
+		return FSMError{
+			Type:    "EventNotFound",
+			Message: fmt.Sprintf("Event '%s' is not defined in this FSM", canonical),
+			Event:   canonical,
+		}
+	}
+
+	if sm.aliases == nil {
+		sm.aliases = make(map[Event]Event)
+		sm.aliasesOf = make(map[Event][]Event)
+	}
+	sm.aliases[alias] = canonical
+	aliases := append(sm.aliasesOf[canonical], alias)
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i] < aliases[j] })
+	sm.aliasesOf[canonical] = aliases
+	return nil
+}
+
+// resolveEventUnsafe returns the canonical event an alias stands for, or the event itself
+func (sm *StateMachine) resolveEventUnsafe(event Event) Event {
+	if canonical, isAlias := sm.aliases[event]; isAlias {
+		return canonical
+	}
+	return event
+}
+
+// withAliasesUnsafe adds the aliases of each event to a sorted event list, keeping it sorted
+func (sm *StateMachine) withAliasesUnsafe(events []Event) []Event {
+	if len(sm.aliases) == 0 || len(events) == 0 {
+		return events
+	}
+	expanded := append([]Event(nil), events...)
+	for _, event := range events {
+		expanded = append(expanded, sm.aliasesOf[event]...)
+	}
+	if len(expanded) != len(events) {
+		sort.Slice(expanded, func(i, j int) bool { return expanded[i] < expanded[j] })
+	}
+	return expanded
+}
+
+// AddEventAlias makes alias trigger the transitions defined for canonical
+func (b *FSMBuilder) AddEventAlias(alias, canonical Event) Builder {
+	if _, isAlias := b.machine.aliases[canonical]; !isAlias {
+		b.machine.AddEvent(canonical) // Ensure the canonical event is registered, as AddTransition does
+	}
+	if err := b.machine.AddEventAlias(alias, canonical); err != nil {
+		b.recordError(err)
+	}
+	b.declare("", alias) // Aliases count as declared events in strict mode
+	return b
+}
+
+// AddEventGroup makes each member event trigger the transitions defined for group
+func (b *FSMBuilder) AddEventGroup(group Event, members ...Event) Builder {
+	for _, member := range members {
+		b.AddEventAlias(member, group)
+	}
+	return b
+}
+
+// AddEventAlias makes alias trigger the transitions defined for canonical
+func (b *BuilderWithHooks) AddEventAlias(alias, canonical Event) *BuilderWithHooks {
+	b.FSMBuilder.AddEventAlias(alias, canonical)
+	return b
+}
+
+// AddEventGroup makes each member event trigger the transitions defined for group
+func (b *BuilderWithHooks) AddEventGroup(group Event, members ...Event) *BuilderWithHooks {
+	b.FSMBuilder.AddEventGroup(group, members...)
+	return b
+}
@@ -0,0 +1,116 @@
+package fsm
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestEventAliases tests that aliases and group members trigger their canonical event's transitions
+func TestEventAliases(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("established", "teardown", "closed").
+		AddTransition("established", "ping", "established").
+		AddTransition("closed", "open", "established").
+		AddEventGroup("teardown", "tcp_close", "reset", "abort").
+		AddEventAlias("connect", "open").
+		SetInitialState("established").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	expected := []Event{"abort", "ping", "reset", "tcp_close", "teardown"}
+	if events := machine.GetValidEvents(); !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected valid events %v, got %v", expected, events)
+	}
+	if !machine.CanTransition("reset") {
+		t.Error("Expected reset to be accepted through its group")
+	}
+	if machine.CanTransition("connect") {
+		t.Error("Expected connect to be refused while established")
+	}
+
+	result, err := machine.SendEvent("reset")
+	if err != nil {
+		t.Fatalf("Failed to send reset: %v", err)
+	}
+	if machine.CurrentState() != "closed" {
+		t.Errorf("Expected state closed, got %s", machine.CurrentState())
+	}
+	if result.Event != "teardown" {
+		t.Errorf("Expected the result to report teardown, got %s", result.Event)
+	}
+
+	expected = []Event{"connect", "open"}
+	if events := machine.GetValidEvents(); !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected valid events %v, got %v", expected, events)
+	}
+	if _, err := machine.SendEvent("connect"); err != nil {
+		t.Fatalf("Failed to send connect: %v", err)
+	}
+	if machine.CurrentState() != "established" {
+		t.Errorf("Expected state established, got %s", machine.CurrentState())
+	}
+
+	// The clone keeps the aliases
+	clone, err := machine.Clone()
+	if err != nil {
+		t.Fatalf("Failed to clone FSM: %v", err)
+	}
+	if err := clone.Start("established"); err != nil {
+		t.Fatalf("Failed to start the clone: %v", err)
+	}
+	if _, err := clone.SendEvent("abort"); err != nil {
+		t.Fatalf("Failed to send abort to the clone: %v", err)
+	}
+	if clone.CurrentState() != "closed" {
+		t.Errorf("Expected clone state closed, got %s", clone.CurrentState())
+	}
+}
+
+// TestInvalidEventAliases tests that aliases can't shadow events or themselves
+func TestInvalidEventAliases(t *testing.T) {
+	machine := NewStateMachine()
+	machine.AddState("a")
+	machine.AddState("b")
+	machine.AddEvent("go")
+	machine.AddTransition(Transition{From: "a", Event: "go", To: "b"})
+
+	if err := machine.AddEventAlias("go", "go"); err == nil {
+		t.Error("Expected an error aliasing an event to itself")
+	}
+	if err := machine.AddEventAlias("go", "run"); err == nil {
+		t.Error("Expected an error aliasing an existing event")
+	}
+	if err := machine.AddEventAlias("run", "walk"); err == nil {
+		t.Error("Expected an error aliasing an undefined event")
+	}
+	if err := machine.AddEventAlias("proceed", "go"); err != nil {
+		t.Fatalf("Failed to add alias: %v", err)
+	}
+	if err := machine.AddEventAlias("proceed", "go"); err == nil {
+		t.Error("Expected an error adding an alias twice")
+	}
+
+	// Aliasing an alias resolves to its canonical event
+	if err := machine.AddEventAlias("continue", "proceed"); err != nil {
+		t.Fatalf("Failed to alias an alias: %v", err)
+	}
+	if err := machine.Start("a"); err != nil {
+		t.Fatalf("Failed to start FSM: %v", err)
+	}
+	if _, err := machine.SendEvent("continue"); err != nil {
+		t.Fatalf("Failed to send continue: %v", err)
+	}
+	if machine.CurrentState() != "b" {
+		t.Errorf("Expected state b, got %s", machine.CurrentState())
+	}
+
+	if _, err := NewBuilder().AddTransition("a", "go", "b").AddEventAlias("go", "go").Build(); err == nil {
+		t.Error("Expected the builder to report an invalid alias")
+	}
+	if _, err := NewBuilder().StrictMode().AddStates("a", "b").AddEvent("go").
+		AddEventAlias("proceed", "go").AddTransition("a", "go", "b").Build(); err != nil {
+		t.Errorf("Expected an alias of a declared event to pass strict mode, got %v", err)
+	}
+}
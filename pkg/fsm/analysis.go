@@ -0,0 +1,248 @@
+package fsm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LintIssue describes a modeling problem found by Lint
+type LintIssue struct {
+	Type    string // Classification of the issue (e.g., "UnreachableFinalState")
+	Message string // Human-readable description of the problem
+	State   State  // The state involved in the issue (if applicable)
+}
+
+// String returns a human-readable representation of the issue
+func (i LintIssue) String() string {
+	return fmt.Sprintf("[%s] %s", i.Type, i.Message)
+}
+
+// StructureError lists the structural problems found by ValidateStrict
+type StructureError struct {
+	UnreachableStates []State // States that can't be reached from the initial state
+	DeadEndStates     []State // Non-final states without outgoing transitions
+}
+
+// Error implements the error interface for StructureError
+func (e *StructureError) Error() string {
+	var parts []string
+	if len(e.UnreachableStates) > 0 {
+		parts = append(parts, fmt.Sprintf("unreachable states: %s", joinStates(e.UnreachableStates)))
+	}
+	if len(e.DeadEndStates) > 0 {
+		parts = append(parts, fmt.Sprintf("dead-end states: %s", joinStates(e.DeadEndStates)))
+	}
+	return fmt.Sprintf("FSM Error [InvalidStructure]: %s", strings.Join(parts, "; "))
+}
+
+// NondeterminismError reports a state and event with several transitions that lack a guard
+// Only the first of them could ever fire, so the others are almost certainly a modeling mistake
+type NondeterminismError struct {
+	From    State   // The source state shared by the conflicting transitions
+	Event   Event   // The event shared by the conflicting transitions
+	Targets []State // Target states of the unguarded transitions, in insertion order
+}
+
+// Error implements the error interface for NondeterminismError
+func (e *NondeterminismError) Error() string {
+	return fmt.Sprintf("FSM Error [NondeterministicTransition]: %d unguarded transitions from '%s' on '%s' (targets: %s)",
+		len(e.Targets), e.From, e.Event, joinStates(e.Targets))
+}
+
+// checkDeterminismUnsafe returns a *NondeterminismError for the first (state, event) pair,
+// in lexical order, that has more than one unguarded candidate transition; under the
+// HighestPriority policy unguarded candidates only conflict when they share a priority.
+// Weighted transitions are deliberate branches for SimulateStep and are never reported.
+func (sm *StateMachine) checkDeterminismUnsafe() error {
+	keys := make([]string, 0, len(sm.transitions))
+	for key := range sm.transitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		candidates := sm.transitions[key]
+		byPriority := sm.selectionPolicyUnsafe(candidates[0].From) == HighestPriority
+		unguarded := make(map[int][]State)
+		for _, candidate := range candidates {
+			if candidate.Condition == nil && candidate.Weight == 0 {
+				rank := 0
+				if byPriority {
+					rank = candidate.Priority
+				}
+				unguarded[rank] = append(unguarded[rank], candidate.To)
+			}
+		}
+		for _, candidate := range candidates {
+			rank := 0
+			if byPriority {
+				rank = candidate.Priority
+			}
+			if targets := unguarded[rank]; len(targets) > 1 {
+				return &NondeterminismError{
+					From:    candidates[0].From,
+					Event:   candidates[0].Event,
+					Targets: targets,
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateStrict performs Validate plus reachability and dead-end analysis
+// Every state must be reachable from the initial state and every state that isn't
+// final must have at least one outgoing transition; problems are returned as *StructureError
+func (sm *StateMachine) ValidateStrict() error {
+	if err := sm.Validate(); err != nil {
+		return err
+	}
+
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if sm.initialState == "" {
+		return FSMError{
+			Type:    "NoInitialState",
+			Message: "Cannot analyze reachability: no initial state defined",
+		}
+	}
+
+	reachable := sm.reachableFromUnsafe(sm.initialState)
+	hasOutgoing := make(map[State]bool)
+	for _, transition := range sm.transitionsUnsafe() {
+		hasOutgoing[transition.From] = true
+	}
+	for state := range sm.autoTransitions {
+		hasOutgoing[state] = true
+	}
+
+	structureErr := &StructureError{}
+	for _, state := range sortedStateSet(sm.states) {
+		if !reachable[state] {
+			structureErr.UnreachableStates = append(structureErr.UnreachableStates, state)
+		}
+		if !hasOutgoing[state] && !sm.finalStates[state] {
+			structureErr.DeadEndStates = append(structureErr.DeadEndStates, state)
+		}
+	}
+
+	if len(structureErr.UnreachableStates) > 0 || len(structureErr.DeadEndStates) > 0 {
+		return structureErr
+	}
+	return nil
+}
+
+// Lint reports modeling problems that don't make the machine invalid
+// Final states that can't be reached from the initial state are flagged because a
+// workflow that can never reach its intended exits can never complete
+func (sm *StateMachine) Lint() []LintIssue {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var issues []LintIssue
+
+	if sm.initialState != "" && len(sm.finalStates) > 0 {
+		reachable := sm.reachableFromUnsafe(sm.initialState)
+		for _, state := range sortedStateSet(sm.finalStates) {
+			if !reachable[state] {
+				issues = append(issues, LintIssue{
+					Type:    "UnreachableFinalState",
+					Message: fmt.Sprintf("Final state '%s' is not reachable from initial state '%s'", state, sm.initialState),
+					State:   state,
+				})
+			}
+		}
+	}
+
+	return issues
+}
+
+// ConformsTo reports whether every transition of the machine appears in allowed, a reference
+// specification matched on source, event and target; the unapproved transitions are returned
+// in source, event and target order
+func (sm *StateMachine) ConformsTo(allowed []Transition) (bool, []Transition) {
+	approved := make(map[string]bool, len(allowed))
+	for _, transition := range allowed {
+		approved[edgeKey(transition)] = true
+	}
+
+	var unapproved []Transition
+	for _, transition := range sortedTransitions(sm) {
+		if !approved[edgeKey(transition)] {
+			unapproved = append(unapproved, transition)
+		}
+	}
+	return len(unapproved) == 0, unapproved
+}
+
+// MissingTransitions returns the transitions of a reference specification a machine lacks
+func MissingTransitions(m Machine, required []Transition) []Transition {
+	present := make(map[string]bool)
+	for _, transition := range m.GetTransitions() {
+		present[edgeKey(transition)] = true
+	}
+
+	var missing []Transition
+	for _, transition := range required {
+		if !present[edgeKey(transition)] {
+			missing = append(missing, transition)
+		}
+	}
+	return missing
+}
+
+// reachableFromUnsafe returns every state reachable from start by following transitions
+// Guards are ignored, so the result is the structural reachability of the transition graph
+func (sm *StateMachine) reachableFromUnsafe(start State) map[State]bool {
+	adjacency := make(map[State][]State)
+	for _, transition := range sm.transitionsUnsafe() {
+		adjacency[transition.From] = append(adjacency[transition.From], transition.To)
+		if errorState, routed := sm.errorStateUnsafe(transition.From); routed && transition.HasAction() {
+			adjacency[transition.From] = append(adjacency[transition.From], errorState) // A failing action routes here
+		}
+	}
+	for state, transitions := range sm.autoTransitions {
+		for _, transition := range transitions {
+			adjacency[state] = append(adjacency[state], transition.To)
+		}
+	}
+
+	reachable := map[State]bool{start: true}
+	queue := []State{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range adjacency[current] {
+			if !reachable[next] {
+				reachable[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return reachable
+}
+
+// sortedStateSet returns the members of a state set in lexical order
+func sortedStateSet(set map[State]bool) []State {
+	states := make([]State, 0, len(set))
+	for state, present := range set {
+		if present {
+			states = append(states, state)
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i] < states[j] })
+	return states
+}
+
+// joinStates formats a list of states as a comma separated string
+func joinStates(states []State) string {
+	names := make([]string, len(states))
+	for i, state := range states {
+		names[i] = string(state)
+	}
+	return strings.Join(names, ", ")
+}
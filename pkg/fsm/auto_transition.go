@@ -0,0 +1,150 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultMaxChainDepth is how many auto transitions one entry may chain unless SetMaxChainDepth changes it
+const DefaultMaxChainDepth = 1000
+
+// ChainDepthError reports a cascade of auto transitions stopped after the maximum chain depth
+// A cycle of always-true guards would otherwise hang the caller with the machine locked
+type ChainDepthError struct {
+	Depth int     // Auto transitions taken before the cascade was stopped
+	State State   // State the machine was left in
+	Cycle []State // States the cascade kept looping through, starting and ending with the same state; nil if it never repeated
+}
+
+// Error implements the error interface for ChainDepthError
+func (e *ChainDepthError) Error() string {
+	if len(e.Cycle) == 0 {
+		return fmt.Sprintf("FSM Error [AutoTransitionLoop]: stopped after %d chained auto transitions in '%s'", e.Depth, e.State)
+	}
+	names := make([]string, len(e.Cycle))
+	for i, state := range e.Cycle {
+		names[i] = string(state)
+	}
+	return fmt.Sprintf("FSM Error [AutoTransitionLoop]: stopped after %d chained auto transitions looping through %s",
+		e.Depth, strings.Join(names, " -> "))
+}
+
+// SetMaxChainDepth limits how many auto transitions one SendEvent, Start or Reset may chain
+// Exceeding it fails with a *ChainDepthError; values below 1 restore DefaultMaxChainDepth
+func (sm *StateMachine) SetMaxChainDepth(n int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.maxChainDepth = max(n, 0)
+}
+
+// AddAutoTransition adds an eventless transition taken as soon as from is entered and guard passes
+// Auto transitions are tried in the order they were added once the entry hooks have run, so
+// a state with several of them acts as a choice pseudostate; a nil guard always passes.
+// They report an empty Event in their results and hooks
+func (sm *StateMachine) AddAutoTransition(from, to State, guard TransitionCondition) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if !sm.states[from] {
+		return NewStateNotFoundError(from)
+	}
+	if !sm.states[to] {
+		return NewStateNotFoundError(to)
+	}
+
+	if sm.autoTransitions == nil {
+		sm.autoTransitions = make(map[State][]Transition)
+	}
+	sm.autoTransitions[from] = append(sm.autoTransitions[from], Transition{From: from, To: to, Condition: guard})
+	return nil
+}
+
+// runAutoTransitionsUnsafe takes auto transitions from the current state until none applies
+// Transitions fired along the way would re-enter here, so only the outermost call loops
+func (sm *StateMachine) runAutoTransitionsUnsafe(ctx context.Context) error {
+	if len(sm.autoTransitions) == 0 || sm.autoChaining {
+		return nil
+	}
+	sm.autoChaining = true
+	defer func() { sm.autoChaining = false }()
+
+	limit := sm.maxChainDepth
+	if limit == 0 {
+		limit = DefaultMaxChainDepth
+	}
+	path := []State{sm.currentState}
+	for depth := 0; ; depth++ {
+		transition, ok, err := sm.selectAutoTransitionUnsafe()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		if depth == limit {
+			return &ChainDepthError{Depth: depth, State: sm.currentState, Cycle: trailingCycle(path)}
+		}
+		if _, err := sm.fireTransitionUnsafe(ctx, transition, time.Now()); err != nil {
+			return err
+		}
+		path = append(path, sm.currentState)
+	}
+}
+
+// trailingCycle returns the loop a path ends in, from the previous visit of its last state
+// through the last state itself, or nil if the last state wasn't visited before
+func trailingCycle(path []State) []State {
+	last := path[len(path)-1]
+	for i := len(path) - 2; i >= 0; i-- {
+		if path[i] == last {
+			return append([]State(nil), path[i:]...)
+		}
+	}
+	return nil
+}
+
+// selectAutoTransitionUnsafe returns the first auto transition from the current state whose guard passes
+func (sm *StateMachine) selectAutoTransitionUnsafe() (Transition, bool, error) {
+	for _, transition := range sm.autoTransitions[sm.currentState] {
+		passed, err := sm.guardPassesUnsafe(transition, sm.context)
+		if err != nil {
+			sm.propagatePanicUnsafe(err)
+			return Transition{}, false, err
+		}
+		if passed {
+			return transition, true, nil
+		}
+	}
+	return Transition{}, false, nil
+}
+
+// AddAutoTransition adds an eventless transition taken as soon as from is entered and guard passes
+func (b *FSMBuilder) AddAutoTransition(from, to State, guard TransitionCondition) Builder {
+	b.machine.AddState(from) // Ensure source state is registered in the FSM
+	b.machine.AddState(to)   // Ensure destination state is registered in the FSM
+
+	if err := b.machine.AddAutoTransition(from, to, guard); err != nil {
+		b.recordError(err)
+	}
+	return b
+}
+
+// AddAutoTransition adds an eventless transition taken as soon as from is entered and guard passes
+func (b *BuilderWithHooks) AddAutoTransition(from, to State, guard TransitionCondition) *BuilderWithHooks {
+	b.FSMBuilder.AddAutoTransition(from, to, guard)
+	return b
+}
+
+// SetMaxChainDepth limits how many auto transitions one SendEvent, Start or Reset may chain
+func (b *FSMBuilder) SetMaxChainDepth(n int) Builder {
+	b.machine.SetMaxChainDepth(n) // Configure the limit on the machine being built
+	return b                      // Return builder to enable method chaining
+}
+
+// SetMaxChainDepth limits how many auto transitions one SendEvent, Start or Reset may chain
+func (b *BuilderWithHooks) SetMaxChainDepth(n int) *BuilderWithHooks {
+	b.FSMBuilder.SetMaxChainDepth(n)
+	return b
+}
@@ -0,0 +1,156 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestAutoTransitionChoice tests a choice state routing on a context value set by an entry hook
+func TestAutoTransitionChoice(t *testing.T) {
+	var entered []State
+	machine, err := NewBuilderWithHooks().
+		AddTransition("cart", "checkout", "review").
+		AddAutoTransition("review", "manual_review", func(context Context) bool {
+			total, _ := context.Get("total").(float64)
+			return total >= 1000
+		}).
+		AddAutoTransition("review", "approved", nil).
+		AddTransition("manual_review", "approve", "approved").
+		AddTransition("approved", "reopen", "cart").
+		SetInitialState("cart").
+		AddOnStateEnterHook(func(result TransitionResult, context Context) {
+			entered = append(entered, result.ToState)
+			if result.ToState == "review" && context.Get("total") == nil {
+				context.Set("total", 50.0) // Entry hooks run before the choice is made
+			}
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	if err := machine.ValidateStrict(); err != nil {
+		t.Errorf("Expected the choice state to count as reachable and not a dead end, got %v", err)
+	}
+
+	result, err := machine.SendEvent("checkout")
+	if err != nil {
+		t.Fatalf("Failed to send checkout: %v", err)
+	}
+	if result.ToState != "review" {
+		t.Errorf("Expected the checkout result to report review, got %s", result.ToState)
+	}
+	if machine.CurrentState() != "approved" {
+		t.Errorf("Expected a small order to be approved, got %s", machine.CurrentState())
+	}
+
+	recent := machine.RecentTransitions()
+	last := recent[len(recent)-1]
+	if last.FromState != "review" || last.ToState != "approved" || last.Event != "" {
+		t.Errorf("Expected an eventless review to approved transition, got %s", last)
+	}
+
+	machine.GetContext().Set("total", 2500.0)
+	machine.SendEvents("reopen", "checkout")
+	if machine.CurrentState() != "manual_review" {
+		t.Errorf("Expected a large order to need manual review, got %s", machine.CurrentState())
+	}
+
+	expected := []State{"cart", "review", "approved", "cart", "review", "manual_review"}
+	if len(entered) != len(expected) {
+		t.Fatalf("Expected entries %v, got %v", expected, entered)
+	}
+	for i, state := range expected {
+		if entered[i] != state {
+			t.Errorf("Expected entry %d to be %s, got %s", i, state, entered[i])
+		}
+	}
+}
+
+// TestAutoTransitionLoop tests that a cycle of always-true auto transitions stops with an error
+func TestAutoTransitionLoop(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("idle", "start", "ping").
+		AddAutoTransition("ping", "pong", nil).
+		AddAutoTransition("pong", "ping", nil).
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	_, err = machine.SendEvent("start")
+	var depthErr *ChainDepthError
+	if !errors.As(err, &depthErr) {
+		t.Fatalf("Expected a ChainDepthError, got %v", err)
+	}
+	if depthErr.Depth != DefaultMaxChainDepth || len(depthErr.Cycle) != 3 {
+		t.Errorf("Expected the default depth and a two-state cycle, got %d and %v", depthErr.Depth, depthErr.Cycle)
+	}
+	if !strings.Contains(err.Error(), "ping -> pong -> ping") {
+		t.Errorf("Expected the error to name the cycle, got %v", err)
+	}
+
+	// An auto transition out of the initial state is taken by Start
+	machine, err = NewBuilder().
+		AddAutoTransition("boot", "ready", nil).
+		AddTransition("ready", "stop", "boot").
+		SetInitialState("boot").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	if machine.CurrentState() != "ready" {
+		t.Errorf("Expected Start to leave boot for ready, got %s", machine.CurrentState())
+	}
+}
+
+// TestMaxChainDepth tests that SetMaxChainDepth bounds a cascade of auto transitions
+func TestMaxChainDepth(t *testing.T) {
+	entries := 0
+	machine, err := NewBuilderWithHooks().
+		AddTransition("idle", "start", "a").
+		AddAutoTransition("a", "b", nil).
+		AddAutoTransition("b", "a", nil).
+		SetInitialState("idle").
+		SetMaxChainDepth(5).
+		AddOnStateEnterHook(func(result TransitionResult, context Context) {
+			entries++
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	entries = 0
+
+	_, err = machine.SendEvent("start")
+	var depthErr *ChainDepthError
+	if !errors.As(err, &depthErr) {
+		t.Fatalf("Expected a ChainDepthError, got %v", err)
+	}
+	if depthErr.Depth != 5 || entries != 6 {
+		t.Errorf("Expected 5 auto transitions after entering a, got depth %d and %d entries", depthErr.Depth, entries)
+	}
+	if machine.CurrentState() != depthErr.State || depthErr.State != "b" {
+		t.Errorf("Expected the machine to stop in b, got %s", machine.CurrentState())
+	}
+	if !reflect.DeepEqual(depthErr.Cycle, []State{"b", "a", "b"}) {
+		t.Errorf("Expected the cycle [b a b], got %v", depthErr.Cycle)
+	}
+
+	// A long chain without a loop has no cycle to report
+	chain := NewBuilder().AddTransition("s0", "go", "s1").SetInitialState("s0").SetMaxChainDepth(2)
+	for i := 1; i <= 4; i++ {
+		chain.AddAutoTransition(State(fmt.Sprintf("s%d", i)), State(fmt.Sprintf("s%d", i+1)), nil)
+	}
+	machine, err = chain.Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	_, err = machine.SendEvent("go")
+	if !errors.As(err, &depthErr) || depthErr.Cycle != nil || depthErr.State != "s3" {
+		t.Errorf("Expected to stop in s3 without a cycle, got %v", err)
+	}
+}
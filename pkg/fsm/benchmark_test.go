@@ -1,32 +1,69 @@
 package fsm
 
 import (
+	"fmt"
 	"testing"
 	"time"
 )
 
-// BenchmarkStateMachine benchmarks basic state machine operations
+// BenchmarkStateMachine benchmarks basic state machine operations with both execution ID generators
 func BenchmarkStateMachine(b *testing.B) {
-	machine, err := NewBuilder().
-		AddStates("idle", "active").
-		AddEvents("activate", "deactivate").
-		AddTransition("idle", "activate", "active").
-		AddTransition("active", "deactivate", "idle").
-		SetInitialState("idle").
-		Build()
+	generators := []struct {
+		name      string
+		generator ExecutionIDGenerator
+	}{
+		{"SequentialIDs", SequentialExecutionIDs},
+		{"RandomIDs", RandomExecutionIDs},
+	}
 
-	if err != nil {
-		b.Fatalf("Failed to build FSM: %v", err)
+	for _, g := range generators {
+		b.Run(g.name, func(b *testing.B) {
+			machine, err := NewBuilder().
+				AddStates("idle", "active").
+				AddEvents("activate", "deactivate").
+				AddTransition("idle", "activate", "active").
+				AddTransition("active", "deactivate", "idle").
+				SetInitialState("idle").
+				Build()
+
+			if err != nil {
+				b.Fatalf("Failed to build FSM: %v", err)
+			}
+			machine.(*StateMachine).SetExecutionIDGenerator(g.generator)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if machine.CurrentState() == "idle" {
+					machine.SendEvent("activate")
+				} else {
+					machine.SendEvent("deactivate")
+				}
+			}
+		})
 	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		if machine.CurrentState() == "idle" {
-			machine.SendEvent("activate")
-		} else {
-			machine.SendEvent("deactivate")
+	// Pooled results are handed back once read, saving the result allocation per event
+	b.Run("PooledResults", func(b *testing.B) {
+		built, err := NewBuilder().
+			AddTransition("idle", "activate", "active").
+			AddTransition("active", "deactivate", "idle").
+			SetInitialState("idle").
+			Build()
+		if err != nil {
+			b.Fatalf("Failed to build FSM: %v", err)
 		}
-	}
+		machine := built.(*StateMachine)
+		machine.SetResultPooling(true)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		events := [2]Event{"activate", "deactivate"}
+		for i := 0; i < b.N; i++ {
+			result, _ := machine.SendEvent(events[i%2])
+			machine.ReleaseResult(result)
+		}
+	})
 }
 
 // BenchmarkConcurrentAccess benchmarks concurrent access to state machine
@@ -160,17 +197,24 @@ func TestMemoryUsage(t *testing.T) {
 	// Create many machines to test memory usage
 	machines := make([]Machine, 1000)
 
-	for i := 0; i < len(machines); i++ {
-		machine, err := NewBuilder().
-			AddStates("idle", "active").
-			AddEvents("activate", "deactivate").
-			AddTransition("idle", "activate", "active").
-			AddTransition("active", "deactivate", "idle").
-			SetInitialState("idle").
-			Build()
+	prototype, err := NewBuilder().
+		AddStates("idle", "active").
+		AddEvents("activate", "deactivate").
+		AddTransition("idle", "activate", "active").
+		AddTransition("active", "deactivate", "idle").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
 
+	for i := 0; i < len(machines); i++ {
+		machine, err := prototype.Clone()
 		if err != nil {
-			t.Fatalf("Failed to build FSM %d: %v", i, err)
+			t.Fatalf("Failed to clone FSM %d: %v", i, err)
+		}
+		if err := machine.Start(machine.InitialState()); err != nil {
+			t.Fatalf("Failed to start FSM %d: %v", i, err)
 		}
 
 		machines[i] = machine
@@ -238,3 +282,38 @@ func TestLargeStateMachine(t *testing.T) {
 		t.Errorf("Large FSM too slow: %v for 1000 transitions", duration)
 	}
 }
+
+// BenchmarkGetValidEventsLargeEventSet benchmarks GetValidEvents on a machine with 100 events where
+// each state has only two outgoing transitions, against probing every event as it used to
+func BenchmarkGetValidEventsLargeEventSet(b *testing.B) {
+	builder := NewBuilder()
+	for i := 0; i < 50; i++ {
+		from := State(fmt.Sprintf("s%d", i))
+		builder.AddTransition(from, Event(fmt.Sprintf("next%d", i)), State(fmt.Sprintf("s%d", (i+1)%50)))
+		builder.AddTransition(from, Event(fmt.Sprintf("back%d", i)), State(fmt.Sprintf("s%d", (i+49)%50)))
+	}
+	machine, err := builder.SetInitialState("s0").Build()
+	if err != nil {
+		b.Fatalf("Failed to build FSM: %v", err)
+	}
+	events := make([]Event, 0, 100)
+	for i := 0; i < 50; i++ {
+		events = append(events, Event(fmt.Sprintf("next%d", i)), Event(fmt.Sprintf("back%d", i)))
+	}
+
+	b.Run("OutgoingIndex", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			machine.GetValidEvents()
+		}
+	})
+	b.Run("AllEvents", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var valid []Event
+			for _, event := range events {
+				if machine.CanTransition(event) {
+					valid = append(valid, event)
+				}
+			}
+		}
+	})
+}
@@ -6,8 +6,12 @@ import "fmt" // Standard library for string formatting and error messages
 // FSMBuilder implements the Builder interface for fluent FSM construction
 // This struct provides a chainable API for constructing finite state machines
 type FSMBuilder struct {
-	machine      *StateMachine // The state machine being constructed
-	initialState State         // The state this FSM will start in when initialized
+	machine      *StateMachine    // The state machine being constructed
+	initialState State            // The state this FSM will start in when initialized
+	embedded     map[string]State // Initial states of embedded sub-machines, keyed by prefix
+	err          error            // First error recorded while building, returned by Build()
+	strict       bool             // Whether Build() rejects transitions using undeclared states or events
+	declared     map[string]bool  // Explicitly declared states and events, keyed "state:name" or "event:name"
 }
 
 // NewBuilder creates a new FSM builder
@@ -22,6 +26,7 @@ func NewBuilder() Builder {
 // This method adds one state to the set of valid states and returns the builder for chaining
 func (b *FSMBuilder) AddState(state State) Builder {
 	b.machine.AddState(state) // Register the state in the underlying state machine
+	b.declare(state, "")      // Remember the declaration for strict mode
 	return b                  // Return builder to enable method chaining
 }
 
@@ -30,6 +35,7 @@ func (b *FSMBuilder) AddState(state State) Builder {
 func (b *FSMBuilder) AddStates(states ...State) Builder {
 	for _, state := range states { // Iterate through all provided states
 		b.machine.AddState(state) // Add each state to the underlying state machine
+		b.declare(state, "")      // Remember the declaration for strict mode
 	}
 	return b // Return builder to enable method chaining
 }
@@ -38,6 +44,7 @@ func (b *FSMBuilder) AddStates(states ...State) Builder {
 // This method adds one event to the set of valid events that can trigger transitions
 func (b *FSMBuilder) AddEvent(event Event) Builder {
 	b.machine.AddEvent(event) // Register the event in the underlying state machine
+	b.declare("", event)      // Remember the declaration for strict mode
 	return b                  // Return builder to enable method chaining
 }
 
@@ -46,6 +53,7 @@ func (b *FSMBuilder) AddEvent(event Event) Builder {
 func (b *FSMBuilder) AddEvents(events ...Event) Builder {
 	for _, event := range events { // Iterate through all provided events
 		b.machine.AddEvent(event) // Add each event to the underlying state machine
+		b.declare("", event)      // Remember the declaration for strict mode
 	}
 	return b // Return builder to enable method chaining
 }
@@ -126,6 +134,25 @@ func (b *FSMBuilder) AddTransitionFull(from State, event Event, to State, condit
 	return b                            // Return builder to enable method chaining
 }
 
+// AddTransitionWithCancelableAction adds a transition with an action that receives a context.Context
+// The context is cancelled once the machine's action timeout expires
+func (b *FSMBuilder) AddTransitionWithCancelableAction(from State, event Event, to State, action CancelableAction) Builder {
+	transition := Transition{ // Create transition structure with cancelable action callback
+		From:             from,   // Source state where transition begins
+		Event:            event,  // Event that triggers this transition
+		To:               to,     // Destination state where transition ends
+		CancelableAction: action, // Function to execute when transition occurs
+	}
+
+	// Automatically add states and events if they don't exist
+	b.machine.AddState(from)  // Ensure source state is registered in the FSM
+	b.machine.AddState(to)    // Ensure destination state is registered in the FSM
+	b.machine.AddEvent(event) // Ensure triggering event is registered in the FSM
+
+	b.machine.AddTransition(transition)
+	return b
+}
+
 // SetInitialState sets the initial state for the FSM
 // Specifies which state the finite state machine should start in when initialized
 func (b *FSMBuilder) SetInitialState(state State) Builder {
@@ -134,14 +161,202 @@ func (b *FSMBuilder) SetInitialState(state State) Builder {
 	return b                  // Return builder to enable method chaining
 }
 
+// SetInitialContext seeds the machine's context with values
+// The context as of Build() is remembered and reinstalled by ResetWithContext
+func (b *FSMBuilder) SetInitialContext(values map[string]interface{}) Builder {
+	context := b.machine.GetContext() // Seed the context the machine will be built with
+	for key, value := range values {  // Copy every provided value into the context
+		context.Set(key, value)
+	}
+	return b // Return builder to enable method chaining
+}
+
+// EnableGuardCache memoizes guard results for CanTransition and GetValidEvents
+// Only use it when every guard is a pure function of the context
+func (b *FSMBuilder) EnableGuardCache() Builder {
+	b.machine.EnableGuardCache() // Configure caching on the machine being built
+	return b                     // Return builder to enable method chaining
+}
+
+// SetLogger routes the machine's diagnostics to logger instead of discarding them
+func (b *FSMBuilder) SetLogger(logger Logger) Builder {
+	b.machine.SetLogger(logger) // Configure the logger on the machine being built
+	return b                    // Return builder to enable method chaining
+}
+
+// AddFinalStates marks states as final (accepting) states
+// Final states are the intended exits of a workflow and are checked for reachability by Lint
+func (b *FSMBuilder) AddFinalStates(states ...State) Builder {
+	for _, state := range states { // Iterate through all provided states
+		b.machine.AddFinalState(state) // Register each state as final in the underlying state machine
+	}
+	return b // Return builder to enable method chaining
+}
+
+// Embed imports another builder's states, final states, events and transitions
+// A non-empty prefix namespaces every imported name as "prefix.name"; without a prefix,
+// events the sub-builder shares with this builder are reported as a collision by Build()
+func (b *FSMBuilder) Embed(prefix string, sub Builder) Builder {
+	source, ok := sub.(*FSMBuilder) // Embedding needs access to the sub-builder's machine
+	if !ok || source == b {
+		b.recordError(fmt.Errorf("cannot embed builder of type %T into itself or from another implementation", sub))
+		return b
+	}
+
+	rename := func(name string) string { // Namespace names when a prefix is given
+		if prefix == "" {
+			return name
+		}
+		return prefix + "." + name
+	}
+
+	source.machine.mu.RLock()
+	defer source.machine.mu.RUnlock()
+
+	// Un-namespaced events must not collide with events this builder already has
+	if prefix == "" {
+		for event := range source.machine.events {
+			if b.machine.events[event] {
+				b.recordError(fmt.Errorf("cannot embed sub-machine: event '%s' already defined", event))
+				return b
+			}
+		}
+	}
+
+	for state := range source.machine.states { // Import states, keeping final states final
+		b.AddState(State(rename(string(state))))
+		if source.machine.finalStates[state] {
+			b.machine.AddFinalState(State(rename(string(state))))
+		}
+	}
+	for event := range source.machine.events { // Import events
+		b.AddEvent(Event(rename(string(event))))
+	}
+	for _, transition := range source.machine.transitionsUnsafe() { // Import transitions with guards and actions
+		transition.From = State(rename(string(transition.From)))
+		transition.To = State(rename(string(transition.To)))
+		transition.Event = Event(rename(string(transition.Event)))
+		b.machine.AddTransition(transition)
+	}
+
+	if b.embedded == nil {
+		b.embedded = make(map[string]State)
+	}
+	if source.initialState != "" { // Remember where the sub-machine starts
+		b.embedded[prefix] = State(rename(string(source.initialState)))
+	}
+	return b // Return builder to enable method chaining
+}
+
+// EnterEmbedded wires a state of this builder to the initial state of an embedded sub-machine
+// The event moves the machine from the given state into the sub-machine embedded under prefix
+func (b *FSMBuilder) EnterEmbedded(from State, event Event, prefix string) Builder {
+	initial, exists := b.embedded[prefix]
+	if !exists {
+		b.recordError(fmt.Errorf("no embedded sub-machine with an initial state under prefix '%s'", prefix))
+		return b
+	}
+	return b.AddTransition(from, event, initial)
+}
+
+// StrictMode makes Build() reject transitions and initial states naming states or events that
+// were never declared with AddState(s) or AddEvent(s), catching typos the default auto-registration hides
+func (b *FSMBuilder) StrictMode() Builder {
+	b.strict = true // Checked once the whole definition is known, in Build()
+	return b        // Return builder to enable method chaining
+}
+
+// declare records an explicitly declared state or event; pass "" for the one not being declared
+func (b *FSMBuilder) declare(state State, event Event) {
+	if b.declared == nil {
+		b.declared = make(map[string]bool)
+	}
+	if state != "" {
+		b.declared["state:"+string(state)] = true
+	}
+	if event != "" {
+		b.declared["event:"+string(event)] = true
+	}
+}
+
+// checkDeclared returns an error naming the first undeclared state or event the machine uses
+func (b *FSMBuilder) checkDeclared() error {
+	undeclaredState := func(state State, where string) error {
+		if b.declared["state:"+string(state)] {
+			return nil
+		}
+		return FSMError{
+			Type:    "UndeclaredState",
+			Message: fmt.Sprintf("State '%s' used by %s is not declared", state, where),
+			State:   state,
+		}
+	}
+
+	if b.initialState != "" {
+		if err := undeclaredState(b.initialState, "the initial state"); err != nil {
+			return err
+		}
+	}
+	for _, transition := range b.machine.GetTransitions() {
+		where := fmt.Sprintf("transition %s", transition)
+		if err := undeclaredState(transition.From, where); err != nil {
+			return err
+		}
+		if err := undeclaredState(transition.To, where); err != nil {
+			return err
+		}
+		if !b.declared["event:"+string(transition.Event)] {
+			return FSMError{
+				Type:    "UndeclaredEvent",
+				Message: fmt.Sprintf("Event '%s' used by %s is not declared", transition.Event, where),
+				Event:   transition.Event,
+			}
+		}
+	}
+	for _, transitions := range b.machine.autoTransitions {
+		for _, transition := range transitions {
+			where := fmt.Sprintf("auto transition %s", transition)
+			if err := undeclaredState(transition.From, where); err != nil {
+				return err
+			}
+			if err := undeclaredState(transition.To, where); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// recordError keeps the first error so Build() can report it
+func (b *FSMBuilder) recordError(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
 // Build creates and validates the FSM, returning it ready for use
 // Final method in the builder chain that constructs the complete finite state machine
 func (b *FSMBuilder) Build() (Machine, error) {
+	// Report errors recorded while chaining builder calls
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	// Reject undeclared states and events in strict mode
+	if b.strict {
+		if err := b.checkDeclared(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Validate the machine configuration
 	if err := b.machine.Validate(); err != nil { // Check if FSM configuration is valid
 		return nil, err // Return error if validation fails
 	}
 
+	// Remember the context values ResetWithContext reinstalls
+	b.machine.captureInitialContext()
+
 	// Set initial state if specified
 	if b.initialState != "" { // Check if initial state was configured
 		if err := b.machine.Start(b.initialState); err != nil { // Start FSM in initial state
@@ -234,12 +449,69 @@ func (b *BuilderWithHooks) AddTransitionFull(from State, event Event, to State,
 	return b
 }
 
+// AddTransitionWithCancelableAction adds a transition with an action that receives a context.Context
+func (b *BuilderWithHooks) AddTransitionWithCancelableAction(from State, event Event, to State, action CancelableAction) *BuilderWithHooks {
+	b.FSMBuilder.AddTransitionWithCancelableAction(from, event, to, action)
+	return b
+}
+
+// AddTransitionDefinition adds a fully specified transition, including its metadata
+func (b *BuilderWithHooks) AddTransitionDefinition(transition Transition) *BuilderWithHooks {
+	b.machine.AddState(transition.From)
+	b.machine.AddState(transition.To)
+	b.machine.AddEvent(transition.Event)
+	b.machine.AddTransition(transition)
+	return b
+}
+
+// AddFinalStates marks states as final (accepting) states
+func (b *BuilderWithHooks) AddFinalStates(states ...State) *BuilderWithHooks {
+	b.FSMBuilder.AddFinalStates(states...)
+	return b
+}
+
+// Embed imports another builder's states, events and transitions, optionally namespaced by prefix
+func (b *BuilderWithHooks) Embed(prefix string, sub Builder) *BuilderWithHooks {
+	b.FSMBuilder.Embed(prefix, sub)
+	return b
+}
+
+// EnterEmbedded wires a state to the initial state of an embedded sub-machine
+func (b *BuilderWithHooks) EnterEmbedded(from State, event Event, prefix string) *BuilderWithHooks {
+	b.FSMBuilder.EnterEmbedded(from, event, prefix)
+	return b
+}
+
+// StrictMode makes Build() reject transitions using undeclared states or events
+func (b *BuilderWithHooks) StrictMode() *BuilderWithHooks {
+	b.FSMBuilder.StrictMode()
+	return b
+}
+
 // SetInitialState sets the initial state for the FSM
 func (b *BuilderWithHooks) SetInitialState(state State) *BuilderWithHooks {
 	b.FSMBuilder.SetInitialState(state)
 	return b
 }
 
+// EnableGuardCache memoizes guard results for CanTransition and GetValidEvents
+func (b *BuilderWithHooks) EnableGuardCache() *BuilderWithHooks {
+	b.FSMBuilder.EnableGuardCache()
+	return b
+}
+
+// SetLogger routes the machine's diagnostics to logger instead of discarding them
+func (b *BuilderWithHooks) SetLogger(logger Logger) *BuilderWithHooks {
+	b.FSMBuilder.SetLogger(logger)
+	return b
+}
+
+// SetInitialContext seeds the machine's context with values
+func (b *BuilderWithHooks) SetInitialContext(values map[string]interface{}) *BuilderWithHooks {
+	b.FSMBuilder.SetInitialContext(values)
+	return b
+}
+
 // Common transition conditions that can be used with the builder
 
 // AlwaysTrue is a condition that always allows transitions
@@ -0,0 +1,174 @@
+package fsm
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// CircuitState describes whether a circuit breaker currently lets its transition fire
+type CircuitState int
+
+// Circuit breaker states
+const (
+	CircuitClosed   CircuitState = iota // Transition fires normally while failures are counted
+	CircuitOpen                         // Transition is refused until the cooldown elapses
+	CircuitHalfOpen                     // A trial transition is allowed to decide whether to close again
+)
+
+// String returns a human-readable name for the circuit state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker trips a transition after too many failures within a time window
+// While open the guarded transition is refused; once the cooldown elapses a single
+// half-open trial decides whether the circuit closes again or re-opens
+type CircuitBreaker struct {
+	mu          sync.Mutex
+	maxFailures int
+	window      time.Duration
+	cooldown    time.Duration
+	failures    []time.Time
+	state       CircuitState
+	openedAt    time.Time
+	clock       func() time.Time
+}
+
+// NewCircuitBreaker creates a circuit breaker that opens after maxFailures failures within window
+// and stays open for cooldown before allowing a trial transition
+func NewCircuitBreaker(maxFailures int, window, cooldown time.Duration) *CircuitBreaker {
+	if maxFailures < 1 {
+		maxFailures = 1
+	}
+	return &CircuitBreaker{
+		maxFailures: maxFailures,
+		window:      window,
+		cooldown:    cooldown,
+		state:       CircuitClosed,
+		clock:       time.Now,
+	}
+}
+
+// State returns the current circuit state
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.refreshUnsafe()
+	return cb.state
+}
+
+// Guard wraps a transition condition so the transition is refused while the circuit is open
+// A nil condition is treated as always true
+func (cb *CircuitBreaker) Guard(condition TransitionCondition) TransitionCondition {
+	return func(context Context) bool {
+		cb.mu.Lock()
+		cb.refreshUnsafe()
+		open := cb.state == CircuitOpen
+		cb.mu.Unlock()
+
+		if open {
+			return false
+		}
+		if condition == nil {
+			return true
+		}
+		return condition(context)
+	}
+}
+
+// Attach registers hooks on a machine that record the outcome of the from/event transition
+// Refused guards are not counted as failures, only errors raised by the transition action
+func (cb *CircuitBreaker) Attach(machine Machine, from State, event Event) {
+	machine.AddHook(OnTransitionError, func(result TransitionResult, context Context) {
+		if result.FromState != from || result.Event != event {
+			return
+		}
+		if errors.Is(result.Error, ErrConditionNotMet) {
+			return
+		}
+		cb.recordFailure()
+	})
+
+	machine.AddHook(AfterTransition, func(result TransitionResult, context Context) {
+		if result.FromState != from || result.Event != event {
+			return
+		}
+		cb.recordSuccess()
+	})
+}
+
+// recordFailure counts a failure and opens the circuit when the threshold is reached
+func (cb *CircuitBreaker) recordFailure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.clock()
+	cb.refreshUnsafe()
+
+	if cb.state == CircuitHalfOpen {
+		cb.state = CircuitOpen
+		cb.openedAt = now
+		return
+	}
+
+	cb.failures = append(cb.failures, now)
+	cb.pruneUnsafe(now)
+
+	if len(cb.failures) >= cb.maxFailures {
+		cb.state = CircuitOpen
+		cb.openedAt = now
+	}
+}
+
+// recordSuccess closes a half-open circuit after a successful trial
+func (cb *CircuitBreaker) recordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.refreshUnsafe()
+	if cb.state == CircuitHalfOpen {
+		cb.state = CircuitClosed
+		cb.failures = nil
+	}
+}
+
+// refreshUnsafe moves an open circuit to half-open once the cooldown has elapsed
+func (cb *CircuitBreaker) refreshUnsafe() {
+	if cb.state == CircuitOpen && cb.clock().Sub(cb.openedAt) >= cb.cooldown {
+		cb.state = CircuitHalfOpen
+	}
+}
+
+// pruneUnsafe drops failures that fall outside the counting window
+func (cb *CircuitBreaker) pruneUnsafe(now time.Time) {
+	kept := cb.failures[:0]
+	for _, failure := range cb.failures {
+		if now.Sub(failure) < cb.window {
+			kept = append(kept, failure)
+		}
+	}
+	cb.failures = kept
+}
+
+// AddCircuitBreaker protects an already added transition with a circuit breaker
+// The transition's existing condition keeps applying while the circuit is closed
+func (b *BuilderWithHooks) AddCircuitBreaker(from State, event Event, breaker *CircuitBreaker) *BuilderWithHooks {
+	b.machine.mu.Lock()
+	for i, transition := range b.machine.transitions[transitionKey(from, event)] {
+		b.machine.transitions[transitionKey(from, event)][i].Condition = breaker.Guard(transition.Condition)
+	}
+	b.machine.mu.Unlock()
+
+	breaker.Attach(b.machine, from, event)
+	return b
+}
@@ -0,0 +1,73 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestCircuitBreaker tests that a failing transition trips, cools down and recovers
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := NewCircuitBreaker(2, time.Minute, 30*time.Second)
+	breaker.clock = func() time.Time { return now }
+
+	paymentFails := true
+	machine, err := NewBuilderWithHooks().
+		AddStates("validated", "paid").
+		AddEvents("pay").
+		AddTransitionWithAction("validated", "pay", "paid", func(from, to State, event Event, context Context) error {
+			if paymentFails {
+				return errors.New("gateway unavailable")
+			}
+			return nil
+		}).
+		AddCircuitBreaker("validated", "pay", breaker).
+		SetInitialState("validated").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	// Two action failures inside the window trip the breaker
+	for i := 0; i < 2; i++ {
+		if _, err := machine.SendEvent("pay"); err == nil {
+			t.Fatalf("Expected payment failure %d", i+1)
+		}
+	}
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("Expected circuit to be open, got %s", breaker.State())
+	}
+
+	// While open the transition is refused even though the action would succeed
+	paymentFails = false
+	if machine.CanTransition("pay") {
+		t.Errorf("Expected transition to be blocked while circuit is open")
+	}
+
+	// After the cooldown a failed trial re-opens the circuit
+	now = now.Add(31 * time.Second)
+	if breaker.State() != CircuitHalfOpen {
+		t.Fatalf("Expected circuit to be half-open, got %s", breaker.State())
+	}
+	paymentFails = true
+	if _, err := machine.SendEvent("pay"); err == nil {
+		t.Fatalf("Expected half-open trial to fail")
+	}
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("Expected circuit to re-open after failed trial, got %s", breaker.State())
+	}
+
+	// A successful trial closes the circuit
+	now = now.Add(31 * time.Second)
+	paymentFails = false
+	if _, err := machine.SendEvent("pay"); err != nil {
+		t.Fatalf("Expected half-open trial to succeed: %v", err)
+	}
+	if breaker.State() != CircuitClosed {
+		t.Errorf("Expected circuit to be closed, got %s", breaker.State())
+	}
+	if machine.CurrentState() != "paid" {
+		t.Errorf("Expected state 'paid', got '%s'", machine.CurrentState())
+	}
+}
@@ -1,56 +1,63 @@
 package fsm
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v2"
 )
 
 // ConfigMachine represents a machine configuration that can be loaded from files
 type ConfigMachine struct {
-	Name         string                  `json:"name" yaml:"name"`
-	Description  string                  `json:"description" yaml:"description"`
-	InitialState string                  `json:"initial_state" yaml:"initial_state"`
-	States       []StateConfig           `json:"states" yaml:"states"`
-	Events       []EventConfig           `json:"events" yaml:"events"`
-	Transitions  []TransitionConfig      `json:"transitions" yaml:"transitions"`
-	Context      map[string]interface{}  `json:"context" yaml:"context"`
-	Hooks        map[string][]HookConfig `json:"hooks" yaml:"hooks"`
+	Name         string                  `json:"name" yaml:"name" toml:"name"`
+	Version      string                  `json:"version,omitempty" yaml:"version,omitempty" toml:"version,omitempty"`
+	Description  string                  `json:"description" yaml:"description" toml:"description"`
+	InitialState string                  `json:"initial_state" yaml:"initial_state" toml:"initial_state"`
+	States       []StateConfig           `json:"states" yaml:"states" toml:"states"`
+	Events       []EventConfig           `json:"events" yaml:"events" toml:"events"`
+	Transitions  []TransitionConfig      `json:"transitions" yaml:"transitions" toml:"transitions"`
+	Context      map[string]interface{}  `json:"context" yaml:"context" toml:"context"`
+	Hooks        map[string][]HookConfig `json:"hooks" yaml:"hooks" toml:"hooks"`
+	Include      []string                `json:"include,omitempty" yaml:"include,omitempty" toml:"include,omitempty"` // Files merged in when loading
 }
 
 // StateConfig represents a state configuration
 type StateConfig struct {
-	Name        string      `json:"name" yaml:"name"`
-	Description string      `json:"description" yaml:"description"`
-	Properties  interface{} `json:"properties" yaml:"properties"`
+	Name        string      `json:"name" yaml:"name" toml:"name"`
+	Description string      `json:"description" yaml:"description" toml:"description"`
+	Properties  interface{} `json:"properties" yaml:"properties" toml:"properties"`
 }
 
 // EventConfig represents an event configuration
 type EventConfig struct {
-	Name        string      `json:"name" yaml:"name"`
-	Description string      `json:"description" yaml:"description"`
-	Properties  interface{} `json:"properties" yaml:"properties"`
+	Name        string      `json:"name" yaml:"name" toml:"name"`
+	Description string      `json:"description" yaml:"description" toml:"description"`
+	Properties  interface{} `json:"properties" yaml:"properties" toml:"properties"`
 }
 
 // TransitionConfig represents a transition configuration
 type TransitionConfig struct {
-	From       string            `json:"from" yaml:"from"`
-	Event      string            `json:"event" yaml:"event"`
-	To         string            `json:"to" yaml:"to"`
-	Condition  string            `json:"condition" yaml:"condition"`
-	Action     string            `json:"action" yaml:"action"`
-	Properties map[string]string `json:"properties" yaml:"properties"`
+	From       string            `json:"from" yaml:"from" toml:"from"`
+	Event      string            `json:"event" yaml:"event" toml:"event"`
+	To         string            `json:"to" yaml:"to" toml:"to"`
+	Condition  string            `json:"condition" yaml:"condition" toml:"condition"`
+	Action     string            `json:"action" yaml:"action" toml:"action"`
+	Label      string            `json:"label,omitempty" yaml:"label,omitempty" toml:"label,omitempty"`
+	Properties map[string]string `json:"properties" yaml:"properties" toml:"properties"`
 }
 
 // HookConfig represents a hook configuration
 type HookConfig struct {
-	Type       string            `json:"type" yaml:"type"`
-	Action     string            `json:"action" yaml:"action"`
-	Properties map[string]string `json:"properties" yaml:"properties"`
+	Type       string            `json:"type" yaml:"type" toml:"type"`
+	Action     string            `json:"action" yaml:"action" toml:"action"`
+	Properties map[string]string `json:"properties" yaml:"properties" toml:"properties"`
 }
 
 // ConditionRegistry holds registered condition functions
@@ -67,6 +74,7 @@ type ConfigLoader struct {
 	conditions ConditionRegistry
 	actions    ActionRegistry
 	hooks      HookRegistry
+	logger     Logger // Set by SetLogger; nil keeps printing log actions and hooks to stdout
 }
 
 // NewConfigLoader creates a new configuration loader with default registries
@@ -108,7 +116,7 @@ func NewConfigLoader() *ConfigLoader {
 	loader.RegisterAction("log", func(props map[string]string) TransitionAction {
 		message := props["message"]
 		return LogTransition(func(msg string) {
-			fmt.Printf("[LOG] %s: %s\n", message, msg)
+			loader.log().Infof("[LOG] %s: %s", message, msg)
 		})
 	})
 
@@ -130,7 +138,7 @@ func NewConfigLoader() *ConfigLoader {
 			prefix = "TRANSITION"
 		}
 		return func(result TransitionResult, context Context) {
-			fmt.Printf("[%s] %s -> %s (Event: %s)\n",
+			loader.log().Infof("[%s] %s -> %s (Event: %s)",
 				prefix, result.FromState, result.ToState, result.Event)
 		}
 	})
@@ -141,13 +149,27 @@ func NewConfigLoader() *ConfigLoader {
 			prefix = "STATE_ENTER"
 		}
 		return func(result TransitionResult, context Context) {
-			fmt.Printf("[%s] Entered state: %s\n", prefix, result.ToState)
+			loader.log().Infof("[%s] Entered state: %s", prefix, result.ToState)
 		}
 	})
 
 	return loader
 }
 
+// SetLogger routes the output of the default log actions and hooks, and the diagnostics of
+// machines built afterwards, to logger
+func (cl *ConfigLoader) SetLogger(logger Logger) {
+	cl.logger = logger
+}
+
+// log returns the configured logger, or one printing to stdout when none is set
+func (cl *ConfigLoader) log() Logger {
+	if cl.logger == nil {
+		return stdoutLogger{}
+	}
+	return cl.logger
+}
+
 // RegisterCondition registers a condition function
 func (cl *ConfigLoader) RegisterCondition(name string, factory func(props map[string]string) TransitionCondition) {
 	cl.conditions[name] = factory
@@ -165,7 +187,7 @@ func (cl *ConfigLoader) RegisterHook(name string, factory func(props map[string]
 
 // LoadFromJSON loads an FSM configuration from a JSON file
 func (cl *ConfigLoader) LoadFromJSON(filename string) (*ConfigMachine, error) {
-	data, err := ioutil.ReadFile(filename)
+	data, err := readConfigFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read JSON file: %w", err)
 	}
@@ -176,12 +198,12 @@ func (cl *ConfigLoader) LoadFromJSON(filename string) (*ConfigMachine, error) {
 		return nil, fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
-	return &config, nil
+	return cl.resolveIncludes(&config, filename, nil)
 }
 
 // LoadFromYAML loads an FSM configuration from a YAML file
 func (cl *ConfigLoader) LoadFromYAML(filename string) (*ConfigMachine, error) {
-	data, err := ioutil.ReadFile(filename)
+	data, err := readConfigFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read YAML file: %w", err)
 	}
@@ -192,12 +214,170 @@ func (cl *ConfigLoader) LoadFromYAML(filename string) (*ConfigMachine, error) {
 		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
 
-	return &config, nil
+	return cl.resolveIncludes(&config, filename, nil)
+}
+
+// LoadFromTOML loads an FSM configuration from a TOML file
+func (cl *ConfigLoader) LoadFromTOML(filename string) (*ConfigMachine, error) {
+	data, err := readConfigFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TOML file: %w", err)
+	}
+
+	var config ConfigMachine
+	err = toml.Unmarshal(data, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse TOML: %w", err)
+	}
+
+	return cl.resolveIncludes(&config, filename, nil)
+}
+
+// ConfigError describes a problem with one field of a machine configuration
+type ConfigError struct {
+	Field   string // Offending field, such as "transitions.from"
+	Index   int    // Position within the field's list, or -1 for top-level fields
+	Message string
+	Err     error // Underlying cause, such as an *ExpressionError, if any
+}
+
+// Error implements the error interface for ConfigError
+func (e ConfigError) Error() string {
+	field := e.Field
+	if e.Index >= 0 {
+		if dot := strings.LastIndex(field, "."); dot >= 0 {
+			field = fmt.Sprintf("%s[%d]%s", field[:dot], e.Index, field[dot:])
+		} else {
+			field = fmt.Sprintf("%s[%d]", field, e.Index)
+		}
+	}
+	return fmt.Sprintf("%s: %s", field, e.Message)
+}
+
+// Unwrap returns the underlying cause of the error
+func (e ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigValidationError combines every problem found by ValidateConfig
+type ConfigValidationError struct {
+	Errors []ConfigError
+}
+
+// Error implements the error interface for ConfigValidationError
+func (e *ConfigValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, configErr := range e.Errors {
+		messages[i] = configErr.Error()
+	}
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(messages, "; "))
+}
+
+// Unwrap returns the individual field errors so errors.As can match their causes
+func (e *ConfigValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, configErr := range e.Errors {
+		errs[i] = configErr
+	}
+	return errs
+}
+
+// ValidateConfig checks a configuration for problems BuildMachine would otherwise mask
+// Transition states and events are only checked against the states and events lists when
+// those lists are declared, since configurations may leave them implicit
+func (cl *ConfigLoader) ValidateConfig(config *ConfigMachine) []ConfigError {
+	var errs []ConfigError
+	report := func(field string, index int, err error, format string, args ...interface{}) {
+		errs = append(errs, ConfigError{Field: field, Index: index, Message: fmt.Sprintf(format, args...), Err: err})
+	}
+
+	states := make(map[string]bool)
+	for i, stateConfig := range config.States {
+		if stateConfig.Name == "" {
+			report("states.name", i, nil, "state name is empty")
+		} else if states[stateConfig.Name] {
+			report("states.name", i, nil, "duplicate state %q", stateConfig.Name)
+		}
+		states[stateConfig.Name] = true
+	}
+
+	events := make(map[string]bool)
+	for i, eventConfig := range config.Events {
+		if eventConfig.Name == "" {
+			report("events.name", i, nil, "event name is empty")
+		} else if events[eventConfig.Name] {
+			report("events.name", i, nil, "duplicate event %q", eventConfig.Name)
+		}
+		events[eventConfig.Name] = true
+	}
+
+	checkState := func(field string, index int, name string) {
+		if name == "" {
+			report(field, index, nil, "state is empty")
+		} else if len(config.States) > 0 && !states[name] {
+			report(field, index, nil, "state %q is not declared in states", name)
+		}
+	}
+
+	if config.InitialState != "" {
+		checkState("initial_state", -1, config.InitialState)
+	}
+
+	for i, transConfig := range config.Transitions {
+		checkState("transitions.from", i, transConfig.From)
+		checkState("transitions.to", i, transConfig.To)
+		if transConfig.Event == "" {
+			report("transitions.event", i, nil, "event is empty")
+		} else if len(config.Events) > 0 && !events[transConfig.Event] {
+			report("transitions.event", i, nil, "event %q is not declared in events", transConfig.Event)
+		}
+
+		if condition := transConfig.Condition; condition != "" && cl.conditions[condition] == nil {
+			if !isExpression(condition) {
+				report("transitions.condition", i, nil, "unknown condition %q", condition)
+			} else if _, err := CompileCondition(condition); err != nil {
+				report("transitions.condition", i, err, "%v", err)
+			}
+		}
+		if action := transConfig.Action; action != "" && cl.actions[action] == nil {
+			if !isExpression(action) {
+				report("transitions.action", i, nil, "unknown action %q", action)
+			} else if _, err := CompileAction(action); err != nil {
+				report("transitions.action", i, err, "%v", err)
+			}
+		}
+	}
+
+	// Sort hook types so errors are reported in a stable order
+	hookTypes := make([]string, 0, len(config.Hooks))
+	for hookTypeStr := range config.Hooks {
+		hookTypes = append(hookTypes, hookTypeStr)
+	}
+	sort.Strings(hookTypes)
+	for _, hookTypeStr := range hookTypes {
+		if _, err := cl.parseHookType(hookTypeStr); err != nil {
+			report("hooks", -1, err, "unknown hook type %q", hookTypeStr)
+			continue
+		}
+		for i, hookConfig := range config.Hooks[hookTypeStr] {
+			if cl.hooks[hookConfig.Action] == nil {
+				report("hooks."+hookTypeStr+".action", i, nil, "unknown hook action %q", hookConfig.Action)
+			}
+		}
+	}
+
+	return errs
 }
 
 // BuildMachine builds an FSM from a configuration
+// The configuration is checked with ValidateConfig first and rejected with a
+// *ConfigValidationError listing every problem found
 func (cl *ConfigLoader) BuildMachine(config *ConfigMachine) (Machine, error) {
-	builder := NewBuilderWithHooks()
+	if errs := cl.ValidateConfig(config); len(errs) > 0 {
+		return nil, &ConfigValidationError{Errors: errs}
+	}
+
+	builder := NewBuilderWithHooks().SetVersion(config.Version)
 
 	// Add states
 	for _, stateConfig := range config.States {
@@ -220,6 +400,12 @@ func (cl *ConfigLoader) BuildMachine(config *ConfigMachine) (Machine, error) {
 		if transConfig.Condition != "" {
 			if conditionFactory, exists := cl.conditions[transConfig.Condition]; exists {
 				condition = conditionFactory(transConfig.Properties)
+			} else if isExpression(transConfig.Condition) {
+				compiled, err := CompileCondition(transConfig.Condition)
+				if err != nil {
+					return nil, fmt.Errorf("transition %s --%s--> %s: %w", from, event, to, err)
+				}
+				condition = compiled
 			} else {
 				return nil, fmt.Errorf("unknown condition: %s", transConfig.Condition)
 			}
@@ -230,21 +416,29 @@ func (cl *ConfigLoader) BuildMachine(config *ConfigMachine) (Machine, error) {
 		if transConfig.Action != "" {
 			if actionFactory, exists := cl.actions[transConfig.Action]; exists {
 				action = actionFactory(transConfig.Properties)
+			} else if isExpression(transConfig.Action) {
+				compiled, err := compileAction(transConfig.Action, cl.log())
+				if err != nil {
+					return nil, fmt.Errorf("transition %s --%s--> %s: %w", from, event, to, err)
+				}
+				action = compiled
 			} else {
 				return nil, fmt.Errorf("unknown action: %s", transConfig.Action)
 			}
 		}
 
-		// Add transition based on what's configured
-		if condition != nil && action != nil {
-			builder.AddTransitionFull(from, event, to, condition, action)
-		} else if condition != nil {
-			builder.AddTransitionWithCondition(from, event, to, condition)
-		} else if action != nil {
-			builder.AddTransitionWithAction(from, event, to, action)
-		} else {
-			builder.AddTransition(from, event, to)
-		}
+		// Keep the configured names so ExtractConfig can reproduce the transition
+		builder.AddTransitionDefinition(Transition{
+			From:          from,
+			Event:         event,
+			To:            to,
+			Condition:     condition,
+			Action:        action,
+			Label:         transConfig.Label,
+			ConditionName: transConfig.Condition,
+			ActionName:    transConfig.Action,
+			Properties:    transConfig.Properties,
+		})
 	}
 
 	// Add hooks
@@ -280,21 +474,107 @@ func (cl *ConfigLoader) BuildMachine(config *ConfigMachine) (Machine, error) {
 		builder.SetInitialState(State(config.InitialState))
 	}
 
+	// Set initial context
+	builder.SetInitialContext(config.Context)
+	if cl.logger != nil {
+		builder.SetLogger(cl.logger)
+	}
+
 	// Build the machine
-	machine, err := builder.Build()
+	return builder.Build()
+}
+
+// CompileAction compiles an inline action script into a TransitionAction
+// Names of registered actions take precedence; any other action containing more than an
+// identifier is compiled as a script when the machine is built.
+//
+// Grammar, with expr as in CompileCondition:
+//
+//	script    = statement { ";" statement } [ ";" ]
+//	statement = "set" identifier "=" expr
+//	          | "increment" identifier
+//	          | "log" expr
+//
+// "increment" treats a missing key as 0. A statement whose expression can't be evaluated,
+// such as arithmetic on a string, fails the action and therefore the transition.
+// "log" prints to stdout; use ConfigLoader.SetLogger to route it elsewhere.
+func CompileAction(script string) (TransitionAction, error) {
+	return compileAction(script, stdoutLogger{})
+}
+
+// compileAction compiles an action script whose "log" statements write to logger
+func compileAction(script string, logger Logger) (TransitionAction, error) {
+	parser, err := newExpressionParser(script)
 	if err != nil {
 		return nil, err
 	}
 
-	// Set initial context
-	if config.Context != nil {
-		context := machine.GetContext()
-		for key, value := range config.Context {
-			context.Set(key, value)
+	var statements []func(context Context) error
+	for !parser.done() {
+		keyword := parser.next()
+		if keyword.kind != tokenIdent {
+			return nil, &ExpressionError{Expression: script, Position: keyword.pos,
+				Message: fmt.Sprintf("expected set, increment or log, found %s", keyword.describe())}
+		}
+
+		switch keyword.text {
+		case "set", "increment":
+			target := parser.next()
+			if target.kind != tokenIdent {
+				return nil, &ExpressionError{Expression: script, Position: target.pos,
+					Message: fmt.Sprintf("expected a context key, found %s", target.describe())}
+			}
+			key := strings.TrimPrefix(target.text, "context.")
+
+			var value exprNode = arithmeticNode{operator: "+", left: contextNode{key: key}, right: literalNode{value: 1}}
+			if keyword.text == "set" {
+				if _, ok := parser.accept("="); !ok {
+					return nil, parser.errorf("expected \"=\", found %s", parser.peek().describe())
+				}
+				if value, err = parser.parseExpression(); err != nil {
+					return nil, err
+				}
+			}
+
+			increment := keyword.text == "increment"
+			statements = append(statements, func(context Context) error {
+				if increment && context.Get(key) == nil {
+					context.Set(key, 0)
+				}
+				result := value.eval(context)
+				if err, ok := result.(evalError); ok {
+					return fmt.Errorf("%s %s: %w", keyword.text, key, err)
+				}
+				context.Set(key, result)
+				return nil
+			})
+		case "log":
+			message, err := parser.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, func(context Context) error {
+				logger.Infof("[LOG] %v", message.eval(context))
+				return nil
+			})
+		default:
+			return nil, &ExpressionError{Expression: script, Position: keyword.pos,
+				Message: fmt.Sprintf("unknown statement %q", keyword.text)}
+		}
+
+		if _, ok := parser.accept(";"); !ok && !parser.done() {
+			return nil, parser.errorf("expected \";\", found %s", parser.peek().describe())
 		}
 	}
 
-	return machine, nil
+	return func(from, to State, event Event, context Context) error {
+		for _, statement := range statements {
+			if err := statement(context); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
 }
 
 // parseHookType converts string to HookType
@@ -345,14 +625,33 @@ func (cl *ConfigLoader) SaveToYAML(config *ConfigMachine, filename string) error
 	return nil
 }
 
+// SaveToTOML saves a machine configuration to TOML
+func (cl *ConfigLoader) SaveToTOML(config *ConfigMachine, filename string) error {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(config); err != nil {
+		return fmt.Errorf("failed to marshal TOML: %w", err)
+	}
+
+	err := ioutil.WriteFile(filename, buf.Bytes(), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write TOML file: %w", err)
+	}
+
+	return nil
+}
+
 // ExtractConfig extracts configuration from an existing machine (reverse engineering)
 func (cl *ConfigLoader) ExtractConfig(machine Machine, name, description string) *ConfigMachine {
 	config := &ConfigMachine{
 		Name:         name,
+		Version:      machine.Version(),
 		Description:  description,
-		InitialState: string(machine.CurrentState()),
+		InitialState: string(machine.InitialState()),
 		Context:      make(map[string]interface{}),
 	}
+	if config.InitialState == "" {
+		config.InitialState = string(machine.CurrentState())
+	}
 
 	// Extract context
 	contextData := machine.GetContext().GetAll()
@@ -360,18 +659,25 @@ func (cl *ConfigLoader) ExtractConfig(machine Machine, name, description string)
 		config.Context[key] = value
 	}
 
-	// Extract transitions (this would need additional machine introspection methods)
-	transitions := machine.GetTransitions()
+	// Extract transitions, keeping candidates for the same state and event in their
+	// evaluation order; guards and actions are only recoverable through their names
+	var transitions []Transition
+	for _, transition := range machine.GetTransitions() {
+		if len(transitions) == 0 || transitions[len(transitions)-1].From != transition.From {
+			transitions = append(transitions, machine.TransitionsFrom(transition.From)...)
+		}
+	}
 	for _, transition := range transitions {
 		transConfig := TransitionConfig{
-			From:  string(transition.From),
-			Event: string(transition.Event),
-			To:    string(transition.To),
+			From:       string(transition.From),
+			Event:      string(transition.Event),
+			To:         string(transition.To),
+			Condition:  transition.ConditionName,
+			Action:     transition.ActionName,
+			Label:      transition.Label,
+			Properties: transition.Properties,
 		}
 
-		// Note: We can't easily extract condition/action details without additional metadata
-		// This would require the machine to store configuration metadata
-
 		config.Transitions = append(config.Transitions, transConfig)
 	}
 
@@ -397,39 +703,73 @@ func (rr *RuntimeReconfigurator) RegisterMachine(name string, machine Machine) {
 	rr.machines[name] = machine
 }
 
+// ReconfigurationResult describes how a reconfiguration changed a registered machine
+type ReconfigurationResult struct {
+	MachineDiff
+	PreviousState  State `json:"previous_state"`
+	CurrentState   State `json:"current_state"`
+	StatePreserved bool  `json:"state_preserved"` // False if the previous state was removed and the machine reset
+}
+
 // ReconfigureFromFile reconfigures a machine from a configuration file
-func (rr *RuntimeReconfigurator) ReconfigureFromFile(machineName, configFile string) error {
-	_, exists := rr.machines[machineName]
-	if !exists {
-		return fmt.Errorf("machine not found: %s", machineName)
+func (rr *RuntimeReconfigurator) ReconfigureFromFile(machineName, configFile string) (*ReconfigurationResult, error) {
+	if _, exists := rr.machines[machineName]; !exists {
+		return nil, fmt.Errorf("machine not found: %s", machineName)
 	}
 
 	// Load new configuration
-	var config *ConfigMachine
-	var err error
-
-	if strings.HasSuffix(configFile, ".json") {
-		config, err = rr.loader.LoadFromJSON(configFile)
-	} else if strings.HasSuffix(configFile, ".yaml") || strings.HasSuffix(configFile, ".yml") {
-		config, err = rr.loader.LoadFromYAML(configFile)
-	} else {
-		return fmt.Errorf("unsupported config file format: %s", configFile)
+	config, err := rr.loader.LoadFromFile(configFile)
+	if err != nil {
+		return nil, err
 	}
 
-	if err != nil {
-		return err
+	return rr.Reconfigure(machineName, config)
+}
+
+// Reconfigure replaces a registered machine with one built from config, carrying over the
+// current state and context when the state still exists in the new definition
+// Context keys only present in the new configuration are added; if the current state was
+// removed the new machine starts fresh in its initial state. A changed version is accepted
+// without snapshot migrations since the state is taken from the running machine
+func (rr *RuntimeReconfigurator) Reconfigure(machineName string, config *ConfigMachine) (*ReconfigurationResult, error) {
+	oldMachine, exists := rr.machines[machineName]
+	if !exists {
+		return nil, fmt.Errorf("machine not found: %s", machineName)
 	}
 
 	// Build new machine
 	newMachine, err := rr.loader.BuildMachine(config)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	result := &ReconfigurationResult{
+		MachineDiff:   DiffMachines(oldMachine, newMachine),
+		PreviousState: oldMachine.CurrentState(),
 	}
 
-	// Replace the machine (in a real implementation, this might involve more sophisticated merging)
+	// Carry the runtime state over unless the new definition no longer has it
+	// The snapshot comes from the live machine being replaced, so it is stamped with the new
+	// version: a reload that bumps version: is the upgrade and needs no registered migration
+	snapshot := oldMachine.Snapshot()
+	snapshot.Version = newMachine.Version()
+	for key, value := range newMachine.GetContext().GetAll() {
+		if _, exists := snapshot.Context[key]; !exists {
+			snapshot.Context[key] = value
+		}
+	}
+	if err := newMachine.Restore(snapshot); err != nil {
+		if !errors.Is(err, ErrStateNotFound) {
+			return nil, fmt.Errorf("failed to preserve state of %s: %w", machineName, err)
+		}
+	} else {
+		result.StatePreserved = true
+	}
+	result.CurrentState = newMachine.CurrentState()
+
 	rr.machines[machineName] = newMachine
 
-	return nil
+	return result, nil
 }
 
 // GetMachine retrieves a registered machine
@@ -0,0 +1,173 @@
+package fsm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// envReference matches ${VAR} and ${VAR:-default} references in configuration files
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// LoadFromFile loads an FSM configuration, choosing the format from the file extension
+func (cl *ConfigLoader) LoadFromFile(filename string) (*ConfigMachine, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return cl.LoadFromJSON(filename)
+	case ".yaml", ".yml":
+		return cl.LoadFromYAML(filename)
+	case ".toml":
+		return cl.LoadFromTOML(filename)
+	default:
+		return nil, fmt.Errorf("unsupported config file format: %s", filename)
+	}
+}
+
+// readConfigFile reads a configuration file and expands environment variable references
+// ${VAR} is replaced by the variable's value and ${VAR:-default} falls back to default
+// when VAR is unset or empty; referencing an unset variable without a default is an error
+func readConfigFile(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	expanded := envReference.ReplaceAllFunc(data, func(reference []byte) []byte {
+		match := envReference.FindSubmatch(reference)
+		name := string(match[1])
+		value, set := os.LookupEnv(name)
+		if value == "" && match[2] != nil {
+			return match[3]
+		}
+		if !set {
+			missing = append(missing, name)
+		}
+		return []byte(value)
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+
+	return expanded, nil
+}
+
+// resolveIncludes merges the files listed in config.Include into config
+// Include paths are relative to the including file. Definitions in the including file take
+// precedence over included ones, and earlier includes over later ones: a state, event or
+// transition (same from, event and to) that is already defined keeps its existing
+// definition, as do context keys. Included transitions and hooks are appended, so under
+// FirstMatch the including file's transitions are tried first. The returned configuration
+// is self-contained and has no Include entries left.
+func (cl *ConfigLoader) resolveIncludes(config *ConfigMachine, filename string, stack []string) (*ConfigMachine, error) {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	for i, included := range stack {
+		if included == path {
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(append(stack[i:], path), " -> "))
+		}
+	}
+	stack = append(stack, path)
+
+	includes := config.Include
+	config.Include = nil
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+
+		data, err := readConfigFile(include)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read included file %s: %w", include, err)
+		}
+		var included ConfigMachine
+		if err := decodeConfig(include, data, &included); err != nil {
+			return nil, fmt.Errorf("failed to parse included file %s: %w", include, err)
+		}
+		if _, err := cl.resolveIncludes(&included, include, stack); err != nil {
+			return nil, err
+		}
+
+		mergeConfig(config, &included)
+	}
+
+	return config, nil
+}
+
+// decodeConfig parses configuration data in the format implied by the file extension
+func decodeConfig(filename string, data []byte, config *ConfigMachine) error {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return json.Unmarshal(data, config)
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, config)
+	case ".toml":
+		return toml.Unmarshal(data, config)
+	default:
+		return fmt.Errorf("unsupported config file format: %s", filename)
+	}
+}
+
+// mergeConfig adds the definitions of included that config doesn't already have
+func mergeConfig(config, included *ConfigMachine) {
+	states := make(map[string]bool, len(config.States))
+	for _, state := range config.States {
+		states[state.Name] = true
+	}
+	for _, state := range included.States {
+		if !states[state.Name] {
+			states[state.Name] = true
+			config.States = append(config.States, state)
+		}
+	}
+
+	events := make(map[string]bool, len(config.Events))
+	for _, event := range config.Events {
+		events[event.Name] = true
+	}
+	for _, event := range included.Events {
+		if !events[event.Name] {
+			events[event.Name] = true
+			config.Events = append(config.Events, event)
+		}
+	}
+
+	transitions := make(map[string]bool, len(config.Transitions))
+	key := func(t TransitionConfig) string {
+		return fmt.Sprintf("%s:%s:%s", t.From, t.Event, t.To)
+	}
+	for _, transition := range config.Transitions {
+		transitions[key(transition)] = true
+	}
+	for _, transition := range included.Transitions {
+		if !transitions[key(transition)] {
+			transitions[key(transition)] = true
+			config.Transitions = append(config.Transitions, transition)
+		}
+	}
+
+	for name, value := range included.Context {
+		if config.Context == nil {
+			config.Context = make(map[string]interface{})
+		}
+		if _, exists := config.Context[name]; !exists {
+			config.Context[name] = value
+		}
+	}
+
+	for hookType, hooks := range included.Hooks {
+		if config.Hooks == nil {
+			config.Hooks = make(map[string][]HookConfig)
+		}
+		config.Hooks[hookType] = append(config.Hooks[hookType], hooks...)
+	}
+}
@@ -0,0 +1,120 @@
+package fsm
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// configHookTypes lists the hook type keys parseHookType accepts
+var configHookTypes = []string{
+	"before_transition",
+	"after_transition",
+	"on_state_enter",
+	"on_state_exit",
+	"on_transition_error",
+}
+
+// ConfigJSONSchema returns a JSON Schema (draft-07) for ConfigMachine documents using the
+// conditions, actions and hooks a default ConfigLoader registers
+func ConfigJSONSchema() []byte {
+	return NewConfigLoader().ConfigJSONSchema()
+}
+
+// ConfigJSONSchema returns a JSON Schema (draft-07) for ConfigMachine documents
+// Conditions and actions may name one of the loader's registered factories or be an
+// expression, which is told apart from a name by containing a non-identifier character
+func (cl *ConfigLoader) ConfigJSONSchema() []byte {
+	reference := func(names []string) map[string]interface{} {
+		return map[string]interface{}{
+			"type": "string",
+			"anyOf": []interface{}{
+				map[string]interface{}{"enum": append([]string{""}, names...)},
+				map[string]interface{}{"pattern": "[^A-Za-z0-9_]"},
+			},
+		}
+	}
+	named := func(description string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":        "object",
+			"description": description,
+			"required":    []string{"name"},
+			"properties": map[string]interface{}{
+				"name":        map[string]interface{}{"type": "string", "minLength": 1},
+				"description": map[string]interface{}{"type": "string"},
+				"properties":  map[string]interface{}{},
+			},
+		}
+	}
+	stringMap := map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": map[string]interface{}{"type": "string"},
+	}
+
+	hook := map[string]interface{}{
+		"type":     "object",
+		"required": []string{"action"},
+		"properties": map[string]interface{}{
+			"type":       map[string]interface{}{"type": "string"},
+			"action":     map[string]interface{}{"type": "string", "enum": registryNames(cl.hooks)},
+			"properties": stringMap,
+		},
+	}
+	hookProperties := make(map[string]interface{}, len(configHookTypes))
+	for _, hookType := range configHookTypes {
+		hookProperties[hookType] = map[string]interface{}{"type": "array", "items": hook}
+	}
+
+	schema := map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "ConfigMachine",
+		"description": "Finite state machine configuration",
+		"type":        "object",
+		"properties": map[string]interface{}{
+			"name":          map[string]interface{}{"type": "string"},
+			"version":       map[string]interface{}{"type": "string"},
+			"description":   map[string]interface{}{"type": "string"},
+			"initial_state": map[string]interface{}{"type": "string"},
+			"states":        map[string]interface{}{"type": "array", "items": named("A state of the machine")},
+			"events":        map[string]interface{}{"type": "array", "items": named("An event that can trigger transitions")},
+			"transitions": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"from", "event", "to"},
+					"properties": map[string]interface{}{
+						"from":       map[string]interface{}{"type": "string", "minLength": 1},
+						"event":      map[string]interface{}{"type": "string", "minLength": 1},
+						"to":         map[string]interface{}{"type": "string", "minLength": 1},
+						"condition":  reference(registryNames(cl.conditions)),
+						"action":     reference(registryNames(cl.actions)),
+						"label":      map[string]interface{}{"type": "string"},
+						"properties": stringMap,
+					},
+				},
+			},
+			"context": map[string]interface{}{"type": "object"},
+			"hooks": map[string]interface{}{
+				"type":                 "object",
+				"properties":           hookProperties,
+				"additionalProperties": false,
+			},
+			"include": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+		},
+	}
+
+	data, _ := json.MarshalIndent(schema, "", "  ") // Only maps, slices and strings, so this cannot fail
+	return data
+}
+
+// registryNames returns the sorted names registered in a condition, action or hook registry
+func registryNames[F any](registry map[string]F) []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,459 @@
+package fsm
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestValidateConfig tests that every field-level problem is reported with its location
+func TestValidateConfig(t *testing.T) {
+	loader := NewConfigLoader()
+	config := &ConfigMachine{
+		InitialState: "idel",
+		States:       []StateConfig{{Name: "idle"}, {Name: "running"}, {Name: "idle"}},
+		Events:       []EventConfig{{Name: "start"}},
+		Transitions: []TransitionConfig{
+			{From: "idle", Event: "start", To: "running"},
+			{From: "runing", Event: "stop", To: "idle", Condition: "is_ready", Action: "set x ="},
+		},
+		Hooks: map[string][]HookConfig{
+			"after_transition": {{Action: "log_transition"}, {Action: "notify"}},
+			"on_explode":       {{Action: "log_transition"}},
+		},
+	}
+
+	expected := []string{
+		`states[2].name: duplicate state "idle"`,
+		`initial_state: state "idel" is not declared in states`,
+		`transitions[1].from: state "runing" is not declared in states`,
+		`transitions[1].event: event "stop" is not declared in events`,
+		`transitions[1].condition: unknown condition "is_ready"`,
+		`transitions[1].action: `,
+		`hooks.after_transition[1].action: unknown hook action "notify"`,
+		`hooks: unknown hook type "on_explode"`,
+	}
+
+	errs := loader.ValidateConfig(config)
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, prefix := range expected {
+		if got := errs[i].Error(); len(got) < len(prefix) || got[:len(prefix)] != prefix {
+			t.Errorf("Expected error %d to start with %q, got %q", i, prefix, got)
+		}
+	}
+
+	var exprErr *ExpressionError
+	if !errors.As(errs[5], &exprErr) {
+		t.Errorf("Expected the action error to wrap an ExpressionError, got %v", errs[5])
+	}
+
+	_, err := loader.BuildMachine(config)
+	var validationErr *ConfigValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected ConfigValidationError from BuildMachine, got %v", err)
+	}
+	if len(validationErr.Errors) != len(expected) {
+		t.Errorf("Expected %d combined errors, got %d", len(expected), len(validationErr.Errors))
+	}
+}
+
+// TestValidateConfigImplicitLists tests that undeclared state and event lists aren't enforced
+func TestValidateConfigImplicitLists(t *testing.T) {
+	loader := NewConfigLoader()
+	config := &ConfigMachine{
+		InitialState: "idle",
+		Transitions:  []TransitionConfig{{From: "idle", Event: "start", To: "running"}},
+	}
+
+	if errs := loader.ValidateConfig(config); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+	if _, err := loader.BuildMachine(config); err != nil {
+		t.Errorf("Failed to build FSM: %v", err)
+	}
+}
+
+// writeOrderConfig writes a YAML order machine with the given transitions to a temporary file
+func writeOrderConfig(t *testing.T, transitions string) string {
+	path := filepath.Join(t.TempDir(), "order.yaml")
+	content := "initial_state: pending\ncontext:\n  carrier: ups\ntransitions:\n" + transitions
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+// TestReconfigurePreservesState tests hot reloading a machine that is partway through its flow
+func TestReconfigurePreservesState(t *testing.T) {
+	machine, err := NewBuilderWithHooks().
+		AddTransition("pending", "pay", "shipping").
+		AddTransition("shipping", "deliver", "delivered").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	machine.GetContext().Set("order_id", "A-1")
+	if _, err := machine.SendEvent("pay"); err != nil {
+		t.Fatalf("Failed to send pay: %v", err)
+	}
+
+	rr := NewRuntimeReconfigurator()
+	rr.RegisterMachine("order", machine)
+
+	result, err := rr.ReconfigureFromFile("order", writeOrderConfig(t,
+		"  - {from: pending, event: pay, to: shipping}\n"+
+			"  - {from: shipping, event: deliver, to: delivered}\n"+
+			"  - {from: shipping, event: lose, to: lost}\n"))
+	if err != nil {
+		t.Fatalf("Failed to reconfigure: %v", err)
+	}
+	if !result.StatePreserved || result.CurrentState != "shipping" {
+		t.Errorf("Expected state 'shipping' to be preserved, got %+v", result)
+	}
+	if len(result.AddedStates) != 1 || result.AddedStates[0] != "lost" {
+		t.Errorf("Expected added state 'lost', got %v", result.AddedStates)
+	}
+	if len(result.AddedTransitions) != 1 || result.AddedTransitions[0].Event != "lose" {
+		t.Errorf("Expected added transition on 'lose', got %v", result.AddedTransitions)
+	}
+
+	reloaded, _ := rr.GetMachine("order")
+	if reloaded.CurrentState() != "shipping" {
+		t.Errorf("Expected state 'shipping', got '%s'", reloaded.CurrentState())
+	}
+	if id := reloaded.GetContext().Get("order_id"); id != "A-1" {
+		t.Errorf("Expected order_id 'A-1' to be preserved, got %v", id)
+	}
+	if carrier := reloaded.GetContext().Get("carrier"); carrier != "ups" {
+		t.Errorf("Expected new context key carrier 'ups', got %v", carrier)
+	}
+
+	result, err = rr.ReconfigureFromFile("order", writeOrderConfig(t,
+		"  - {from: pending, event: pay, to: delivered}\n"))
+	if err != nil {
+		t.Fatalf("Failed to reconfigure: %v", err)
+	}
+	if result.StatePreserved || result.CurrentState != "pending" {
+		t.Errorf("Expected reset to 'pending' after 'shipping' was removed, got %+v", result)
+	}
+	if len(result.RemovedStates) != 2 || result.RemovedStates[0] != "lost" || result.RemovedStates[1] != "shipping" {
+		t.Errorf("Expected removed states [lost shipping], got %v", result.RemovedStates)
+	}
+	if len(result.RemovedTransitions) != 2 {
+		t.Errorf("Expected 2 removed transitions, got %v", result.RemovedTransitions)
+	}
+	if len(result.ChangedTransitions) != 1 || result.ChangedTransitions[0].After.To != "delivered" {
+		t.Errorf("Expected pay to be retargeted to 'delivered', got %v", result.ChangedTransitions)
+	}
+}
+
+// TestReconfigureVersionBump tests hot reloading a configuration that changes its version
+func TestReconfigureVersionBump(t *testing.T) {
+	loader := NewConfigLoader()
+	config := &ConfigMachine{
+		Version:      "1",
+		InitialState: "pending",
+		Transitions:  []TransitionConfig{{From: "pending", Event: "pay", To: "shipping"}},
+	}
+	machine, err := loader.BuildMachine(config)
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	if _, err := machine.SendEvent("pay"); err != nil {
+		t.Fatalf("Failed to send pay: %v", err)
+	}
+
+	rr := NewRuntimeReconfigurator()
+	rr.RegisterMachine("order", machine)
+
+	bumped := *config
+	bumped.Version = "2"
+	result, err := rr.Reconfigure("order", &bumped)
+	if err != nil {
+		t.Fatalf("Failed to reconfigure to version 2: %v", err)
+	}
+	if !result.StatePreserved || result.CurrentState != "shipping" {
+		t.Errorf("Expected state 'shipping' to be preserved, got %+v", result)
+	}
+	reloaded, _ := rr.GetMachine("order")
+	if reloaded.Version() != "2" {
+		t.Errorf("Expected version '2', got '%s'", reloaded.Version())
+	}
+}
+
+// TestTOMLConfig tests loading, building and round-tripping a TOML configuration
+func TestTOMLConfig(t *testing.T) {
+	loader := NewConfigLoader()
+	config, err := loader.LoadFromTOML(filepath.Join("testdata", "order.toml"))
+	if err != nil {
+		t.Fatalf("Failed to load TOML: %v", err)
+	}
+	if len(config.Transitions) != 2 || config.Transitions[1].Properties["message"] != "shipping order" {
+		t.Fatalf("Expected 2 transitions with properties, got %+v", config.Transitions)
+	}
+	if hooks := config.Hooks["after_transition"]; len(hooks) != 1 || hooks[0].Properties["prefix"] != "ORDER" {
+		t.Errorf("Expected after_transition hook with prefix 'ORDER', got %+v", hooks)
+	}
+
+	machine, err := loader.BuildMachine(config)
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	if _, err := machine.SendEvent("pay"); err != nil {
+		t.Fatalf("Failed to send pay: %v", err)
+	}
+	if payments := machine.GetContext().Get("payments"); payments != 1 {
+		t.Errorf("Expected payments 1, got %v", payments)
+	}
+	if machine.Version() != "1" {
+		t.Errorf("Expected version '1', got '%s'", machine.Version())
+	}
+
+	path := filepath.Join(t.TempDir(), "order.toml")
+	if err := loader.SaveToTOML(config, path); err != nil {
+		t.Fatalf("Failed to save TOML: %v", err)
+	}
+	reloaded, err := loader.LoadFromTOML(path)
+	if err != nil {
+		t.Fatalf("Failed to reload TOML: %v", err)
+	}
+	if !reflect.DeepEqual(config, reloaded) {
+		t.Errorf("Expected round-tripped config to match\noriginal: %+v\nreloaded: %+v", config, reloaded)
+	}
+}
+
+// writeConfigFiles writes the named files to a temporary directory and returns its path
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// TestConfigEnvironmentExpansion tests ${VAR} and ${VAR:-default} references
+func TestConfigEnvironmentExpansion(t *testing.T) {
+	t.Setenv("INITIAL_STATE", "ready")
+	t.Setenv("EMPTY_NAME", "")
+	dir := writeConfigFiles(t, map[string]string{
+		"machine.yaml": "name: \"${EMPTY_NAME:-orders}\"\ninitial_state: \"${INITIAL_STATE}\"\n" +
+			"transitions:\n  - {from: \"${INITIAL_STATE}\", event: go, to: \"${TARGET_STATE:-done}\"}\n",
+		"missing.yaml": "initial_state: \"${FSM_UNSET_STATE}\"\n",
+	})
+
+	loader := NewConfigLoader()
+	config, err := loader.LoadFromFile(filepath.Join(dir, "machine.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Name != "orders" || config.InitialState != "ready" || config.Transitions[0].To != "done" {
+		t.Errorf("Expected expanded name, initial state and target, got %+v", config)
+	}
+
+	if _, err := loader.LoadFromFile(filepath.Join(dir, "missing.yaml")); err == nil || !strings.Contains(err.Error(), "FSM_UNSET_STATE") {
+		t.Errorf("Expected an error naming FSM_UNSET_STATE, got %v", err)
+	}
+}
+
+// TestConfigIncludes tests merging included files and the precedence of colliding definitions
+func TestConfigIncludes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"machine.yaml": "initial_state: idle\ninclude: [shared/events.yaml]\n" +
+			"events:\n  - {name: start, description: parent}\n" +
+			"transitions:\n  - {from: idle, event: start, to: running}\n" +
+			"context:\n  retries: 1\n",
+		"shared/events.yaml": "include: [common.toml]\n" +
+			"events:\n  - {name: start, description: shared}\n  - {name: stop}\n" +
+			"transitions:\n  - {from: running, event: stop, to: idle}\n  - {from: idle, event: start, to: running}\n" +
+			"context:\n  retries: 5\n  timeout: 30\n",
+		"shared/common.toml": "[[events]]\nname = \"fail\"\n\n" +
+			"[[transitions]]\nfrom = \"running\"\nevent = \"fail\"\nto = \"idle\"\n",
+	})
+
+	loader := NewConfigLoader()
+	config, err := loader.LoadFromFile(filepath.Join(dir, "machine.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(config.Include) != 0 {
+		t.Errorf("Expected includes to be resolved, got %v", config.Include)
+	}
+	if len(config.Events) != 3 || config.Events[0].Description != "parent" {
+		t.Errorf("Expected 3 events with the parent's 'start', got %+v", config.Events)
+	}
+	if len(config.Transitions) != 3 {
+		t.Errorf("Expected 3 deduplicated transitions, got %+v", config.Transitions)
+	}
+	if config.Context["retries"] != 1 || config.Context["timeout"] != 30 {
+		t.Errorf("Expected retries 1 and timeout 30, got %v", config.Context)
+	}
+
+	machine, err := loader.BuildMachine(config)
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	if _, _, err := machine.Run([]Event{"start", "fail", "start", "stop"}); err != nil {
+		t.Errorf("Expected merged transitions to run, got %v", err)
+	}
+}
+
+// TestConfigIncludeCycle tests that files including each other are rejected
+func TestConfigIncludeCycle(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.yaml": "include: [b.yaml]\n",
+		"b.yaml": "include: [a.yaml]\n",
+	})
+
+	_, err := NewConfigLoader().LoadFromYAML(filepath.Join(dir, "a.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("Expected an include cycle error, got %v", err)
+	}
+}
+
+// TestExtractConfigRoundTrip tests that extracting a configured machine reproduces its configuration
+func TestExtractConfigRoundTrip(t *testing.T) {
+	original := &ConfigMachine{
+		Name:         "vending",
+		Version:      "3",
+		Description:  "Vending machine",
+		InitialState: "idle",
+		Transitions: []TransitionConfig{
+			{From: "idle", Event: "coin", To: "ready", Label: "accept coin", Action: "increment_counter",
+				Properties: map[string]string{"key": "coins"}},
+			{From: "ready", Event: "select", To: "vending", Condition: "balance >= price",
+				Action: "set balance = balance - price"},
+			{From: "ready", Event: "select", To: "ready", Condition: "context_equals",
+				Properties: map[string]string{"key": "stock", "value": "empty"}},
+			{From: "vending", Event: "done", To: "idle"},
+		},
+		Context: map[string]interface{}{"balance": 0, "price": 2},
+	}
+
+	loader := NewConfigLoader()
+	machine, err := loader.BuildMachine(original)
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	extracted := loader.ExtractConfig(machine, original.Name, original.Description)
+	if !reflect.DeepEqual(original, extracted) {
+		t.Fatalf("Expected extracted config to match\noriginal:  %+v\nextracted: %+v", original, extracted)
+	}
+
+	if diagram := ExportPlantUML(machine); !strings.Contains(diagram, "ready --> vending : select [balance >= price] / set balance = balance - price\n") {
+		t.Errorf("Expected PlantUML to label the guard and action, got:\n%s", diagram)
+	}
+
+	path := filepath.Join(t.TempDir(), "vending.json")
+	if err := loader.SaveToJSON(extracted, path); err != nil {
+		t.Fatalf("Failed to save JSON: %v", err)
+	}
+	loaded, err := loader.LoadFromJSON(path)
+	if err != nil {
+		t.Fatalf("Failed to load JSON: %v", err)
+	}
+	reloaded, err := loader.BuildMachine(loaded)
+	if err != nil {
+		t.Fatalf("Failed to rebuild FSM: %v", err)
+	}
+	if _, err := reloaded.SendEvent("coin"); err != nil {
+		t.Fatalf("Failed to send coin: %v", err)
+	}
+	if coins := reloaded.GetContext().Get("coins"); coins != 1 {
+		t.Errorf("Expected the reloaded action to count coins, got %v", coins)
+	}
+	if resaved := loader.ExtractConfig(reloaded, loaded.Name, loaded.Description); !reflect.DeepEqual(loaded.Transitions, resaved.Transitions) {
+		t.Errorf("Expected transitions to be stable across save and load\nloaded:  %+v\nresaved: %+v", loaded.Transitions, resaved.Transitions)
+	}
+}
+
+// TestConfigInitialContextReplay tests that a config's context can be restored after a run
+func TestConfigInitialContextReplay(t *testing.T) {
+	loader := NewConfigLoader()
+	config, err := loader.LoadFromYAML(writeOrderConfig(t, `  - {from: pending, event: pay, to: paid, action: "set carrier = 'fedex'"}`+"\n"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	machine, err := loader.BuildMachine(config)
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	if _, err := machine.SendEvent("pay"); err != nil {
+		t.Fatalf("Failed to send pay: %v", err)
+	}
+	machine.GetContext().Set("attempts", 2)
+	if value := machine.GetContext().Get("carrier"); value != "fedex" {
+		t.Fatalf("Expected the action to change the carrier, got %v", value)
+	}
+
+	initial := machine.InitialContext()
+	if !reflect.DeepEqual(initial, map[string]interface{}{"carrier": "ups"}) {
+		t.Errorf("Expected the config's context, got %v", initial)
+	}
+	initial["carrier"] = "dhl"
+
+	if err := machine.ResetWithContext(); err != nil {
+		t.Fatalf("Failed to reset with context: %v", err)
+	}
+	if state := machine.CurrentState(); state != "pending" {
+		t.Errorf("Expected 'pending', got %s", state)
+	}
+	if context := machine.GetContext().GetAll(); !reflect.DeepEqual(context, map[string]interface{}{"carrier": "ups"}) {
+		t.Errorf("Expected the config's context to be restored, got %v", context)
+	}
+}
+
+// TestConfigJSONSchema tests that the schema lists hook types and the loader's registered names
+func TestConfigJSONSchema(t *testing.T) {
+	loader := NewConfigLoader()
+	loader.RegisterCondition("has_stock", func(props map[string]string) TransitionCondition {
+		return AlwaysTrue()
+	})
+
+	var schema struct {
+		Schema     string `json:"$schema"`
+		Properties struct {
+			Transitions struct {
+				Items struct {
+					Properties struct {
+						Condition struct {
+							AnyOf []struct {
+								Enum []string `json:"enum"`
+							} `json:"anyOf"`
+						} `json:"condition"`
+					} `json:"properties"`
+				} `json:"items"`
+			} `json:"transitions"`
+			Hooks struct {
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"hooks"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(loader.ConfigJSONSchema(), &schema); err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	if schema.Schema != "http://json-schema.org/draft-07/schema#" {
+		t.Errorf("Expected a draft-07 schema, got %q", schema.Schema)
+	}
+	if len(schema.Properties.Hooks.Properties) != 5 || schema.Properties.Hooks.Properties["on_state_enter"] == nil {
+		t.Errorf("Expected the five hook types, got %v", schema.Properties.Hooks.Properties)
+	}
+	conditions := schema.Properties.Transitions.Items.Properties.Condition.AnyOf
+	if len(conditions) == 0 || !strings.Contains(strings.Join(conditions[0].Enum, ","), "has_stock") {
+		t.Errorf("Expected the registered condition in the schema, got %v", conditions)
+	}
+}
@@ -0,0 +1,83 @@
+package fsm
+
+import "reflect"
+
+// ContextChange describes how one context key changed across a transition
+type ContextChange struct {
+	Old     interface{} `json:"old,omitempty"`     // Value before the transition, nil if it was added
+	New     interface{} `json:"new,omitempty"`     // Value after the transition, nil if it was removed
+	Added   bool        `json:"added,omitempty"`   // The key wasn't set before the transition
+	Removed bool        `json:"removed,omitempty"` // The key isn't set after the transition
+}
+
+// EnableContextDelta records which context keys each transition changes in its ContextDelta
+// The context is copied before every transition, so this is off by default. The delta covers
+// writes made by the before and exit hooks and by the action, and is seen by the later hooks
+func (sm *StateMachine) EnableContextDelta() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.contextDelta = true
+}
+
+// contextDeltaUnsafe diffs the machine's context against values captured before the transition
+// Values are compared in plaintext, but sensitive keys of a SecureContext are reported as
+// RedactedValue so the delta never carries secrets into traces and hooks
+func (sm *StateMachine) contextDeltaUnsafe(before map[string]interface{}) map[string]ContextChange {
+	delta := diffContext(before, plainValues(sm.context))
+	secure, ok := storedContext(sm.context).(*SecureContext)
+	if !ok {
+		return delta
+	}
+	for key, change := range delta {
+		if !secure.encryptor.IsSensitive(key) {
+			continue
+		}
+		if change.Old != nil {
+			change.Old = RedactedValue
+		}
+		if change.New != nil {
+			change.New = RedactedValue
+		}
+		delta[key] = change
+	}
+	return delta
+}
+
+// diffContext returns the keys whose values differ between two context snapshots, nil if none do
+// Values are compared with reflect.DeepEqual, so maps and slices are compared by content
+func diffContext(before, after map[string]interface{}) map[string]ContextChange {
+	var delta map[string]ContextChange
+	record := func(key string, change ContextChange) {
+		if delta == nil {
+			delta = make(map[string]ContextChange)
+		}
+		delta[key] = change
+	}
+
+	for key, old := range before {
+		value, exists := after[key]
+		if !exists {
+			record(key, ContextChange{Old: old, Removed: true})
+		} else if !reflect.DeepEqual(old, value) {
+			record(key, ContextChange{Old: old, New: value})
+		}
+	}
+	for key, value := range after {
+		if _, existed := before[key]; !existed {
+			record(key, ContextChange{New: value, Added: true})
+		}
+	}
+	return delta
+}
+
+// EnableContextDelta records which context keys each transition changes in its ContextDelta
+func (b *FSMBuilder) EnableContextDelta() Builder {
+	b.machine.EnableContextDelta() // Configure recording on the machine being built
+	return b                       // Return builder to enable method chaining
+}
+
+// EnableContextDelta records which context keys each transition changes in its ContextDelta
+func (b *BuilderWithHooks) EnableContextDelta() *BuilderWithHooks {
+	b.FSMBuilder.EnableContextDelta()
+	return b
+}
@@ -0,0 +1,137 @@
+package fsm
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestContextDelta tests that a transition's result lists the context keys its action changed
+func TestContextDelta(t *testing.T) {
+	var hookDelta map[string]ContextChange
+	machine, err := NewBuilderWithHooks().
+		AddTransitionWithAction("pending", "pay", "paid", func(from, to State, event Event, context Context) error {
+			context.Set("status", "paid")
+			context.Set("receipt", "R-1")
+			context.Set("total", 42.0) // Unchanged, so not part of the delta
+			return nil
+		}).
+		AddTransition("paid", "refund", "pending").
+		SetInitialState("pending").
+		SetInitialContext(map[string]interface{}{"status": "pending", "total": 42.0}).
+		EnableContextDelta().
+		AddAfterTransitionHook(func(result TransitionResult, context Context) {
+			hookDelta = result.ContextDelta
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	machine.EnableTrace()
+
+	result, err := machine.SendEvent("pay")
+	if err != nil {
+		t.Fatalf("Failed to send pay: %v", err)
+	}
+	expected := map[string]ContextChange{
+		"status":  {Old: "pending", New: "paid"},
+		"receipt": {New: "R-1", Added: true},
+	}
+	if !reflect.DeepEqual(result.ContextDelta, expected) {
+		t.Errorf("Expected delta %v, got %v", expected, result.ContextDelta)
+	}
+	if !reflect.DeepEqual(hookDelta, expected) {
+		t.Errorf("Expected hooks to see delta %v, got %v", expected, hookDelta)
+	}
+
+	// A transition that writes nothing has no delta
+	result, _ = machine.SendEvent("refund")
+	if result.ContextDelta != nil {
+		t.Errorf("Expected no delta for refund, got %v", result.ContextDelta)
+	}
+
+	data, err := machine.ExportTraceJSON()
+	if err != nil {
+		t.Fatalf("Failed to export trace: %v", err)
+	}
+	var entries []TraceEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("Failed to parse trace: %v", err)
+	}
+	if len(entries) != 2 || entries[0].ContextDelta["receipt"].New != "R-1" || entries[1].ContextDelta != nil {
+		t.Errorf("Expected the trace to carry the deltas, got %s", data)
+	}
+}
+
+// TestContextDeltaDisabled tests that no delta is recorded by default
+func TestContextDeltaDisabled(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransitionWithAction("a", "go", "b", func(from, to State, event Event, context Context) error {
+			context.Set("touched", true)
+			return nil
+		}).
+		SetInitialState("a").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	result, err := machine.SendEvent("go")
+	if err != nil {
+		t.Fatalf("Failed to send go: %v", err)
+	}
+	if result.ContextDelta != nil {
+		t.Errorf("Expected no delta without EnableContextDelta, got %v", result.ContextDelta)
+	}
+}
+
+// TestContextDeltaSecureContext tests that sensitive keys are diffed in plaintext but redacted
+func TestContextDeltaSecureContext(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransitionWithAction("pending", "pay", "paid", func(from, to State, event Event, context Context) error {
+			context.Set("status", "paid")
+			return nil
+		}).
+		AddTransitionWithAction("paid", "rotate", "paid", func(from, to State, event Event, context Context) error {
+			context.Set("token", "tok_456")
+			return nil
+		}).
+		SetInitialState("pending").
+		EnableContextDelta().
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	secure := NewSecureContext(NewContextEncryptor(StaticKey([]byte("0123456789abcdef")), "token"))
+	secure.Set("token", "tok_123")
+	machine.SetContext(secure)
+
+	// The token is re-encrypted with a fresh nonce on every GetAll, but it didn't change
+	result, err := machine.SendEvent("pay")
+	if err != nil {
+		t.Fatalf("Failed to send pay: %v", err)
+	}
+	expected := map[string]ContextChange{"status": {New: "paid", Added: true}}
+	if !reflect.DeepEqual(result.ContextDelta, expected) {
+		t.Errorf("Expected delta %v, got %v", expected, result.ContextDelta)
+	}
+
+	result, err = machine.SendEvent("rotate")
+	if err != nil {
+		t.Fatalf("Failed to send rotate: %v", err)
+	}
+	expected = map[string]ContextChange{"token": {Old: RedactedValue, New: RedactedValue}}
+	if !reflect.DeepEqual(result.ContextDelta, expected) {
+		t.Errorf("Expected the token change to be redacted, got %v", result.ContextDelta)
+	}
+
+	// Clones keep recording deltas
+	clone, err := machine.Clone()
+	if err != nil {
+		t.Fatalf("Failed to clone FSM: %v", err)
+	}
+	clone.Start("pending")
+	if result, _ := clone.SendEvent("pay"); result == nil || result.ContextDelta == nil {
+		t.Errorf("Expected the clone to record a delta, got %v", result)
+	}
+}
@@ -0,0 +1,159 @@
+package fsm
+
+import "sort"
+
+// TransitionChange pairs a transition with its replacement in a newer definition
+type TransitionChange struct {
+	Before Transition `json:"before"`
+	After  Transition `json:"after"`
+}
+
+// MachineDiff describes how one machine definition differs from another
+// States and events are those referenced by transitions, plus each machine's initial state
+type MachineDiff struct {
+	AddedStates        []State            `json:"added_states"`
+	RemovedStates      []State            `json:"removed_states"`
+	AddedEvents        []Event            `json:"added_events"`
+	RemovedEvents      []Event            `json:"removed_events"`
+	AddedTransitions   []Transition       `json:"added_transitions"`
+	RemovedTransitions []Transition       `json:"removed_transitions"`
+	ChangedTransitions []TransitionChange `json:"changed_transitions"` // Same source and event, but a new target or guard/action presence
+}
+
+// IsEmpty returns true if the two definitions are equivalent
+func (d MachineDiff) IsEmpty() bool {
+	return len(d.AddedStates) == 0 && len(d.RemovedStates) == 0 &&
+		len(d.AddedEvents) == 0 && len(d.RemovedEvents) == 0 &&
+		len(d.AddedTransitions) == 0 && len(d.RemovedTransitions) == 0 &&
+		len(d.ChangedTransitions) == 0
+}
+
+// DiffMachines compares the definitions of a and b, reporting what b adds, removes and changes
+// Transitions are keyed by source state and event; when a key has several transitions they are
+// matched by target first, and a single unmatched transition on each side counts as a change
+func DiffMachines(a, b Machine) MachineDiff {
+	previous := sortedTransitions(a)
+	next := sortedTransitions(b)
+
+	var diff MachineDiff
+	diff.AddedStates, diff.RemovedStates = diffStates(sortedStates(a, previous), sortedStates(b, next))
+	diff.AddedEvents, diff.RemovedEvents = diffEvents(sortedEvents(previous), sortedEvents(next))
+	diff.AddedTransitions, diff.RemovedTransitions, diff.ChangedTransitions = diffTransitions(previous, next)
+	return diff
+}
+
+// sortedEvents returns every event the transitions are triggered by
+func sortedEvents(transitions []Transition) []Event {
+	seen := make(map[Event]bool)
+	for _, transition := range transitions {
+		seen[transition.Event] = true
+	}
+	return sortedEventSet(seen)
+}
+
+// sortedEventSet returns the events of a set in sorted order
+func sortedEventSet(set map[Event]bool) []Event {
+	events := make([]Event, 0, len(set))
+	for event := range set {
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i] < events[j] })
+	return events
+}
+
+// diffStates returns the states only present in next and only present in previous
+func diffStates(previous, next []State) (added, removed []State) {
+	seen := make(map[State]bool, len(previous))
+	for _, state := range previous {
+		seen[state] = true
+	}
+	for _, state := range next {
+		if !seen[state] {
+			added = append(added, state)
+		}
+		delete(seen, state)
+	}
+	return added, sortedStateSet(seen)
+}
+
+// diffEvents returns the events only present in next and only present in previous
+func diffEvents(previous, next []Event) (added, removed []Event) {
+	seen := make(map[Event]bool, len(previous))
+	for _, event := range previous {
+		seen[event] = true
+	}
+	for _, event := range next {
+		if !seen[event] {
+			added = append(added, event)
+		}
+		delete(seen, event)
+	}
+	return added, sortedEventSet(seen)
+}
+
+// diffTransitions compares two sorted transition lists key by key
+func diffTransitions(previous, next []Transition) (added, removed []Transition, changed []TransitionChange) {
+	previousByKey := groupTransitions(previous)
+	nextByKey := groupTransitions(next)
+
+	for _, transition := range previous {
+		key := transitionKey(transition.From, transition.Event)
+		before, seen := previousByKey[key]
+		if !seen {
+			continue // Key already compared
+		}
+		delete(previousByKey, key)
+		after := nextByKey[key]
+		delete(nextByKey, key)
+
+		// Match by target, collecting the transitions left over on either side
+		var unmatchedBefore []Transition
+		matched := make([]bool, len(after))
+		for _, old := range before {
+			found := false
+			for i, candidate := range after {
+				if !matched[i] && candidate.To == old.To {
+					matched[i], found = true, true
+					if candidate.HasGuard() != old.HasGuard() || candidate.HasAction() != old.HasAction() {
+						changed = append(changed, TransitionChange{Before: old, After: candidate})
+					}
+					break
+				}
+			}
+			if !found {
+				unmatchedBefore = append(unmatchedBefore, old)
+			}
+		}
+		var unmatchedAfter []Transition
+		for i, candidate := range after {
+			if !matched[i] {
+				unmatchedAfter = append(unmatchedAfter, candidate)
+			}
+		}
+
+		if len(unmatchedBefore) == 1 && len(unmatchedAfter) == 1 {
+			changed = append(changed, TransitionChange{Before: unmatchedBefore[0], After: unmatchedAfter[0]})
+			continue
+		}
+		removed = append(removed, unmatchedBefore...)
+		added = append(added, unmatchedAfter...)
+	}
+
+	// Keys only present in next are additions, in their sorted order
+	for _, transition := range next {
+		if _, remaining := nextByKey[transitionKey(transition.From, transition.Event)]; remaining {
+			added = append(added, transition)
+		}
+	}
+	return added, removed, changed
+}
+
+// groupTransitions indexes transitions by source state and event
+func groupTransitions(transitions []Transition) map[string][]Transition {
+	groups := make(map[string][]Transition, len(transitions))
+	for _, transition := range transitions {
+		key := transitionKey(transition.From, transition.Event)
+		groups[key] = append(groups[key], transition)
+	}
+	return groups
+}
@@ -0,0 +1,56 @@
+package fsm
+
+import "testing"
+
+// TestDiffMachines tests reporting added, removed and changed states, events and transitions
+func TestDiffMachines(t *testing.T) {
+	before, err := NewBuilder().
+		AddTransition("pending", "pay", "paid").
+		AddTransition("paid", "ship", "shipped").
+		AddTransition("paid", "refund", "refunded").
+		AddTransition("shipped", "deliver", "delivered").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	after, err := NewBuilder().
+		AddTransitionWithAction("pending", "pay", "paid", func(from, to State, event Event, context Context) error {
+			return nil
+		}).
+		AddTransition("paid", "ship", "in_transit").
+		AddTransition("in_transit", "deliver", "delivered").
+		AddTransition("paid", "cancel", "cancelled").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	diff := DiffMachines(before, after)
+	if len(diff.AddedStates) != 2 || diff.AddedStates[0] != "cancelled" || diff.AddedStates[1] != "in_transit" {
+		t.Errorf("Expected added states [cancelled in_transit], got %v", diff.AddedStates)
+	}
+	if len(diff.RemovedStates) != 2 || diff.RemovedStates[0] != "refunded" || diff.RemovedStates[1] != "shipped" {
+		t.Errorf("Expected removed states [refunded shipped], got %v", diff.RemovedStates)
+	}
+	if len(diff.AddedEvents) != 1 || diff.AddedEvents[0] != "cancel" || len(diff.RemovedEvents) != 1 || diff.RemovedEvents[0] != "refund" {
+		t.Errorf("Expected event cancel added and refund removed, got %v and %v", diff.AddedEvents, diff.RemovedEvents)
+	}
+	if len(diff.AddedTransitions) != 2 || len(diff.RemovedTransitions) != 2 {
+		t.Errorf("Expected 2 added and 2 removed transitions, got %v and %v", diff.AddedTransitions, diff.RemovedTransitions)
+	}
+	if len(diff.ChangedTransitions) != 2 {
+		t.Fatalf("Expected 2 changed transitions, got %v", diff.ChangedTransitions)
+	}
+	if change := diff.ChangedTransitions[0]; change.Before.Event != "ship" || change.After.To != "in_transit" {
+		t.Errorf("Expected ship to be retargeted, got %v", change)
+	}
+	if change := diff.ChangedTransitions[1]; change.Before.Event != "pay" || !change.After.HasAction() {
+		t.Errorf("Expected pay to gain an action, got %v", change)
+	}
+
+	if !DiffMachines(before, before).IsEmpty() {
+		t.Error("Expected no differences between a machine and itself")
+	}
+}
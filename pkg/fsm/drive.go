@@ -0,0 +1,102 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DriveTo sends events along a shortest path from the current state to target
+// Guards are only evaluated as each step is sent; when one refuses, or a step lands somewhere
+// unplanned, the rest of the path is re-planned without the refused transition. The attempts
+// made are returned, and an error of type "NoPathToState" once no unrefused path remains
+func (sm *StateMachine) DriveTo(target State) ([]TransitionResult, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if !sm.states[target] {
+		return nil, FSMError{
+			Type:    "StateNotFound",
+			Message: fmt.Sprintf("State '%s' is not defined in this FSM", target),
+			State:   target,
+		}
+	}
+
+	var results []TransitionResult
+	refused := make(map[string]bool)
+	for sm.currentState != target {
+		path := sm.shortestPathUnsafe(sm.currentState, target, refused)
+		if path == nil {
+			return results, FSMError{
+				Type:    "NoPathToState",
+				Message: fmt.Sprintf("No transition path from '%s' to '%s' with satisfiable guards", sm.currentState, target),
+				State:   sm.currentState,
+			}
+		}
+
+		step := path[0]
+		result, err := sm.sendEventUnsafe(context.Background(), step.Event)
+		if result != nil {
+			results = append(results, *result)
+			if sm.pooling {
+				sm.resultPool.Put(result)
+			}
+		}
+
+		if errors.Is(err, ErrConditionNotMet) {
+			refused[edgeKey(step)] = true // Re-plan around the guard
+			continue
+		}
+		if err != nil {
+			return results, err
+		}
+		if sm.currentState != step.To {
+			refused[edgeKey(step)] = true // Another candidate fired, so don't count on this one again
+		}
+	}
+
+	return results, nil
+}
+
+// shortestPathUnsafe returns the fewest transitions leading from one state to another, skipping
+// excluded transitions, or nil if there is no such path; an empty path means from is to
+func (sm *StateMachine) shortestPathUnsafe(from, to State, excluded map[string]bool) []Transition {
+	if from == to {
+		return []Transition{}
+	}
+
+	previous := map[State]Transition{}
+	visited := map[State]bool{from: true}
+	queue := []State{from}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		for _, event := range sm.outgoing[state] {
+			for _, transition := range sm.transitions[transitionKey(state, event)] {
+				if excluded[edgeKey(transition)] || visited[transition.To] {
+					continue
+				}
+				visited[transition.To] = true
+				previous[transition.To] = transition
+				if transition.To != to {
+					queue = append(queue, transition.To)
+					continue
+				}
+
+				// Walk back from the target to recover the path
+				var path []Transition
+				for step := to; step != from; step = previous[step].From {
+					path = append([]Transition{previous[step]}, path...)
+				}
+				return path
+			}
+		}
+	}
+	return nil
+}
+
+// edgeKey identifies a transition by its source, event and target
+func edgeKey(transition Transition) string {
+	return transitionKey(transition.From, transition.Event) + "->" + string(transition.To)
+}
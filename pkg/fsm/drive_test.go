@@ -0,0 +1,53 @@
+package fsm
+
+import "testing"
+
+// TestDriveTo tests driving a machine along the shortest path and re-planning around a refused guard
+func TestDriveTo(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("cart", "checkout", "payment").
+		AddTransitionWithCondition("payment", "express", "shipped", func(context Context) bool {
+			return context.Get("express") == true
+		}).
+		AddTransition("payment", "pay", "paid").
+		AddTransition("paid", "ship", "shipped").
+		AddTransition("shipped", "deliver", "delivered").
+		AddTransition("archived", "restore", "cart").
+		SetInitialState("cart").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	// The express shortcut is refused, so the drive detours through paid
+	results, err := machine.DriveTo("delivered")
+	if err != nil {
+		t.Fatalf("Failed to drive to delivered: %v", err)
+	}
+	if machine.CurrentState() != "delivered" {
+		t.Errorf("Expected state delivered, got %s", machine.CurrentState())
+	}
+	expected := []Event{"checkout", "express", "pay", "ship", "deliver"}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected attempts %v, got %v", expected, results)
+	}
+	for i, event := range expected {
+		if results[i].Event != event {
+			t.Errorf("Expected attempt %d to send %s, got %s", i, event, results[i].Event)
+		}
+	}
+	if results[1].Success {
+		t.Error("Expected the express attempt to be refused")
+	}
+
+	// Nothing leads back to archived
+	if _, err := machine.DriveTo("archived"); err == nil {
+		t.Error("Expected an error for an unreachable target")
+	}
+	if machine.CurrentState() != "delivered" {
+		t.Errorf("Expected state delivered to be kept, got %s", machine.CurrentState())
+	}
+	if _, err := machine.DriveTo("missing"); err == nil {
+		t.Error("Expected an error for an undefined target")
+	}
+}
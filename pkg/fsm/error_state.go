@@ -0,0 +1,42 @@
+package fsm
+
+// SetErrorState routes the machine to errorState whenever a transition action fails
+// With source states the route only applies to transitions leaving them, overriding the
+// machine-wide route; without any it becomes the machine-wide route
+func (sm *StateMachine) SetErrorState(errorState State, from ...State) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.states[errorState] = true
+	if len(from) == 0 {
+		sm.errorState = errorState
+		return
+	}
+	if sm.stateErrorStates == nil {
+		sm.stateErrorStates = make(map[State]State)
+	}
+	for _, state := range from {
+		sm.stateErrorStates[state] = errorState
+	}
+}
+
+// errorStateUnsafe returns the state a failed action leaving from routes to, if any
+func (sm *StateMachine) errorStateUnsafe(from State) (State, bool) {
+	if state, ok := sm.stateErrorStates[from]; ok {
+		return state, true
+	}
+	return sm.errorState, sm.errorState != ""
+}
+
+// OnErrorGoTo sends the machine to errorState when a transition action returns an error,
+// firing the usual enter hooks; pass source states to scope the route to them
+func (b *FSMBuilder) OnErrorGoTo(errorState State, from ...State) Builder {
+	b.machine.SetErrorState(errorState, from...) // Configure routing on the machine being built
+	return b                                     // Return builder to enable method chaining
+}
+
+// OnErrorGoTo sends the machine to errorState when a transition action returns an error
+func (b *BuilderWithHooks) OnErrorGoTo(errorState State, from ...State) *BuilderWithHooks {
+	b.FSMBuilder.OnErrorGoTo(errorState, from...)
+	return b
+}
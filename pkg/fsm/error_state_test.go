@@ -0,0 +1,79 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestOnErrorGoTo tests that failed actions route to the machine-wide and per-state error states
+func TestOnErrorGoTo(t *testing.T) {
+	fail := func(from, to State, event Event, context Context) error {
+		return errors.New("declined")
+	}
+
+	var entered []State
+	machine, err := NewBuilderWithHooks().
+		AddTransitionWithAction("idle", "charge", "charged", fail).
+		AddTransitionWithAction("charged", "ship", "shipped", fail).
+		AddTransition("failed", "retry", "idle").
+		AddTransition("idle", "skip", "charged").
+		AddStates("shipping_failed").
+		OnErrorGoTo("failed").
+		OnErrorGoTo("shipping_failed", "charged").
+		AddOnStateEnterHook(func(result TransitionResult, context Context) {
+			entered = append(entered, result.ToState)
+		}).
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	// The machine-wide route applies to states without an override
+	result, err := machine.SendEvent("charge")
+	if err == nil {
+		t.Fatal("Expected the action error to be returned")
+	}
+	if !result.Recovered || result.ToState != "failed" || machine.CurrentState() != "failed" {
+		t.Errorf("Expected recovery to failed, got %s (recovered=%v)", machine.CurrentState(), result.Recovered)
+	}
+
+	// A per-state route overrides the machine-wide one
+	machine.SendEvent("retry")
+	machine.SendEvent("skip")
+	result, _ = machine.SendEvent("ship")
+	if !result.Recovered || machine.CurrentState() != "shipping_failed" {
+		t.Errorf("Expected recovery to shipping_failed, got %s (recovered=%v)", machine.CurrentState(), result.Recovered)
+	}
+
+	want := []State{"idle", "failed", "idle", "charged", "shipping_failed"}
+	if len(entered) != len(want) {
+		t.Fatalf("Expected enter hooks for %v, got %v", want, entered)
+	}
+	for i := range want {
+		if entered[i] != want[i] {
+			t.Errorf("Expected enter hooks for %v, got %v", want, entered)
+			break
+		}
+	}
+}
+
+// TestOnErrorGoToUnrouted tests that a failed action without a route leaves the state unchanged
+func TestOnErrorGoToUnrouted(t *testing.T) {
+	machine, err := NewBuilderWithHooks().
+		AddTransitionWithAction("idle", "charge", "charged", func(from, to State, event Event, context Context) error {
+			return errors.New("declined")
+		}).
+		AddStates("failed").
+		OnErrorGoTo("failed", "charged").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	result, _ := machine.SendEvent("charge")
+	if result.Recovered || machine.CurrentState() != "idle" {
+		t.Errorf("Expected to stay in idle, got %s (recovered=%v)", machine.CurrentState(), result.Recovered)
+	}
+}
@@ -0,0 +1,64 @@
+package fsm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+)
+
+// ExecutionIDGenerator produces the ExecutionID of each transition attempt
+// It may be called concurrently by different machines, so it must be safe for concurrent use
+type ExecutionIDGenerator func() string
+
+// executionNonce distinguishes the execution IDs of this process from those of earlier runs
+var executionNonce = func() string {
+	bytes := make([]byte, 4)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}()
+
+// executionCounter numbers execution IDs within this process
+var executionCounter atomic.Uint64
+
+// SequentialExecutionIDs returns a random per-process nonce followed by a process-wide counter, in hex
+// It is the default: unique within and, with high probability, across processes, at the cost of a
+// single allocation. IDs reveal how many attempts preceded them; use RandomExecutionIDs if that matters.
+func SequentialExecutionIDs() string {
+	const digits = "0123456789abcdef"
+
+	var buf [8 + 16]byte
+	n := copy(buf[:], executionNonce)
+
+	counter := executionCounter.Add(1)
+	var hexCounter [16]byte
+	i := len(hexCounter)
+	for counter > 0 || i > len(hexCounter)-8 { // At least 8 digits, so IDs keep their usual length
+		i--
+		hexCounter[i] = digits[counter&0xf]
+		counter >>= 4
+	}
+	n += copy(buf[n:], hexCounter[i:])
+	return string(buf[:n])
+}
+
+// RandomExecutionIDs returns 8 bytes from crypto/rand in hex, so IDs can't be guessed or ordered
+func RandomExecutionIDs() string {
+	var bytes [8]byte
+	rand.Read(bytes[:])
+	return hex.EncodeToString(bytes[:])
+}
+
+// SetExecutionIDGenerator changes how ExecutionIDs are produced; nil restores SequentialExecutionIDs
+func (sm *StateMachine) SetExecutionIDGenerator(generator ExecutionIDGenerator) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.executionIDs = generator
+}
+
+// newExecutionID produces the ExecutionID of a transition attempt without acquiring locks
+func (sm *StateMachine) newExecutionID() string {
+	if sm.executionIDs == nil {
+		return SequentialExecutionIDs()
+	}
+	return sm.executionIDs()
+}
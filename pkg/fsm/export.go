@@ -0,0 +1,298 @@
+package fsm
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sortedTransitions returns the machine's transitions ordered by source state, event and target
+// GetTransitions already guarantees this order, which the exporters and analyses rely on
+func sortedTransitions(m Machine) []Transition {
+	return m.GetTransitions()
+}
+
+// sortedStates returns every state of the machine plus those referenced by the transitions
+func sortedStates(m Machine, transitions []Transition) []State {
+	seen := make(map[State]bool)
+	for _, state := range m.States() {
+		seen[state] = true
+	}
+	if initial := m.InitialState(); initial != "" {
+		seen[initial] = true
+	}
+	for _, transition := range transitions {
+		seen[transition.From] = true
+		seen[transition.To] = true
+	}
+	return sortedStateSet(seen)
+}
+
+// ExportDOT renders a machine as a Graphviz DOT digraph
+// The initial state is drawn as a double circle and conditional transitions as dashed edges
+func ExportDOT(m Machine) (string, error) {
+	if m == nil {
+		return "", fmt.Errorf("cannot export a nil machine")
+	}
+
+	transitions := sortedTransitions(m)
+	initial := m.InitialState()
+
+	var sb strings.Builder
+	sb.WriteString("digraph fsm {\n")
+	if version := m.Version(); version != "" {
+		fmt.Fprintf(&sb, "    // version: %s\n", version)
+	}
+	sb.WriteString("    rankdir=LR;\n")
+
+	for _, state := range sortedStates(m, transitions) {
+		shape := "circle"
+		if state == initial {
+			shape = "doublecircle"
+		}
+		fmt.Fprintf(&sb, "    %s [shape=%s];\n", strconv.Quote(string(state)), shape)
+	}
+
+	for _, transition := range transitions {
+		style := ""
+		if transition.HasGuard() {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&sb, "    %s -> %s [label=%s%s];\n",
+			strconv.Quote(string(transition.From)), strconv.Quote(string(transition.To)),
+			strconv.Quote(string(transition.Event)), style)
+	}
+
+	sb.WriteString("}\n")
+	return sb.String(), nil
+}
+
+// ExportMermaid renders a machine as a Mermaid stateDiagram-v2 definition
+func ExportMermaid(m Machine) (string, error) {
+	if m == nil {
+		return "", fmt.Errorf("cannot export a nil machine")
+	}
+
+	transitions := sortedTransitions(m)
+
+	var sb strings.Builder
+	sb.WriteString("stateDiagram-v2\n")
+	if version := m.Version(); version != "" {
+		fmt.Fprintf(&sb, "    %%%% version: %s\n", version)
+	}
+
+	if initial := m.InitialState(); initial != "" {
+		fmt.Fprintf(&sb, "    [*] --> %s\n", initial)
+	}
+
+	for _, transition := range transitions {
+		fmt.Fprintf(&sb, "    %s --> %s : %s\n", transition.From, transition.To, transition.Event)
+	}
+
+	return sb.String(), nil
+}
+
+// ExportPlantUML renders a machine as a PlantUML state diagram
+// Transitions are labeled "event [guard] / action" using the names recorded on them; final states are connected to the [*] end marker
+func ExportPlantUML(m Machine) string {
+	if m == nil {
+		return "@startuml\n@enduml\n"
+	}
+
+	transitions := sortedTransitions(m)
+
+	var sb strings.Builder
+	sb.WriteString("@startuml\n")
+	if version := m.Version(); version != "" {
+		fmt.Fprintf(&sb, "' version: %s\n", version)
+	}
+
+	if initial := m.InitialState(); initial != "" {
+		fmt.Fprintf(&sb, "[*] --> %s\n", initial)
+	}
+
+	for _, transition := range transitions {
+		label := string(transition.Event)
+		if transition.ConditionName != "" {
+			label += " [" + transition.ConditionName + "]"
+		}
+		if transition.ActionName != "" {
+			label += " / " + transition.ActionName
+		}
+		fmt.Fprintf(&sb, "%s --> %s : %s\n", transition.From, transition.To, label)
+	}
+
+	for _, state := range m.FinalStates() {
+		fmt.Fprintf(&sb, "%s --> [*]\n", state)
+	}
+
+	sb.WriteString("@enduml\n")
+	return sb.String()
+}
+
+// svgStateRadius is the radius of a state's circle in ExportSVG, in diagram units
+const svgStateRadius = 35.0
+
+// ExportSVG renders a machine as a standalone SVG image positioned by LayeredLayout
+// Like ExportDOT, the initial state is drawn as a double circle and conditional transitions as
+// dashed edges; edges bend to one side so transitions in both directions stay apart
+func ExportSVG(m Machine) (string, error) {
+	if m == nil {
+		return "", fmt.Errorf("cannot export a nil machine")
+	}
+
+	transitions := sortedTransitions(m)
+	states := sortedStates(m, transitions)
+	layout := LayeredLayout(m)
+	initial := m.InitialState()
+
+	width, height := 2*layoutMargin, 2*layoutMargin
+	for _, point := range layout {
+		width = math.Max(width, point.X+layoutMargin)
+		height = math.Max(height, point.Y+layoutMargin)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%g\" height=\"%g\" viewBox=\"0 0 %g %g\">\n",
+		width, height, width, height)
+	if version := m.Version(); version != "" {
+		fmt.Fprintf(&sb, "  <title>version %s</title>\n", html.EscapeString(version))
+	}
+	sb.WriteString("  <defs><marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"8\" markerHeight=\"8\" orient=\"auto\">" +
+		"<path d=\"M0,0 L10,5 L0,10 z\"/></marker></defs>\n")
+
+	for _, transition := range transitions {
+		from, to := layout[transition.From], layout[transition.To]
+		style := ""
+		if transition.HasGuard() {
+			style = " stroke-dasharray=\"6,4\""
+		}
+
+		var path string
+		var labelX, labelY float64
+		if transition.From == transition.To {
+			// A loop over the top of the state
+			path = fmt.Sprintf("M%g,%g C%g,%g %g,%g %g,%g",
+				from.X-svgStateRadius/2, from.Y-svgStateRadius*0.85,
+				from.X-svgStateRadius, from.Y-2.5*svgStateRadius,
+				from.X+svgStateRadius, from.Y-2.5*svgStateRadius,
+				from.X+svgStateRadius/2, from.Y-svgStateRadius*0.85)
+			labelX, labelY = from.X, from.Y-2.1*svgStateRadius
+		} else {
+			// Trim the edge to the circles and bend it to its left around the midpoint
+			dx, dy := to.X-from.X, to.Y-from.Y
+			length := math.Hypot(dx, dy)
+			ux, uy := dx/length, dy/length
+			controlX, controlY := (from.X+to.X)/2+uy*30, (from.Y+to.Y)/2-ux*30
+			path = fmt.Sprintf("M%g,%g Q%g,%g %g,%g",
+				from.X+ux*svgStateRadius, from.Y+uy*svgStateRadius,
+				controlX, controlY,
+				to.X-ux*svgStateRadius, to.Y-uy*svgStateRadius)
+			labelX, labelY = controlX, controlY
+		}
+		fmt.Fprintf(&sb, "  <path d=\"%s\" fill=\"none\" stroke=\"black\"%s marker-end=\"url(#arrow)\"/>\n", path, style)
+		fmt.Fprintf(&sb, "  <text x=\"%g\" y=\"%g\" text-anchor=\"middle\" font-size=\"12\">%s</text>\n",
+			labelX, labelY, html.EscapeString(string(transition.Event)))
+	}
+
+	for _, state := range states {
+		point := layout[state]
+		fmt.Fprintf(&sb, "  <circle cx=\"%g\" cy=\"%g\" r=\"%g\" fill=\"white\" stroke=\"black\"/>\n",
+			point.X, point.Y, svgStateRadius)
+		if state == initial {
+			fmt.Fprintf(&sb, "  <circle cx=\"%g\" cy=\"%g\" r=\"%g\" fill=\"none\" stroke=\"black\"/>\n",
+				point.X, point.Y, svgStateRadius-4)
+		}
+		fmt.Fprintf(&sb, "  <text x=\"%g\" y=\"%g\" text-anchor=\"middle\" dominant-baseline=\"middle\" font-size=\"14\">%s</text>\n",
+			point.X, point.Y, html.EscapeString(string(state)))
+	}
+
+	sb.WriteString("</svg>\n")
+	return sb.String(), nil
+}
+
+// traceEvent is a single entry of the Chrome trace event format
+type traceEvent struct {
+	Name      string                 `json:"name"`
+	Category  string                 `json:"cat"`
+	Phase     string                 `json:"ph"`
+	Timestamp int64                  `json:"ts"`
+	Duration  int64                  `json:"dur"`
+	ProcessID int                    `json:"pid"`
+	ThreadID  int                    `json:"tid"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+}
+
+// Thread IDs used to lay out the Chrome trace timeline
+const (
+	traceTransitionThread = 1 // Transition attempts with their guard and action duration
+	traceStateThread      = 2 // Time spent in each state between successful transitions
+)
+
+// ExportTrace renders a machine's recent transitions in the Chrome trace event format
+// The output can be loaded in chrome://tracing or Perfetto; transitions appear on one track
+// with their measured duration and state residency appears on a second track
+func ExportTrace(m Machine) ([]byte, error) {
+	if m == nil {
+		return nil, fmt.Errorf("cannot export a nil machine")
+	}
+
+	recent := m.RecentTransitions()
+	events := make([]traceEvent, 0, len(recent)*2)
+
+	for i, result := range recent {
+		args := map[string]interface{}{
+			"from":         string(result.FromState),
+			"to":           string(result.ToState),
+			"event":        string(result.Event),
+			"success":      result.Success,
+			"execution_id": result.ExecutionID,
+		}
+		if result.Error != nil {
+			args["error"] = result.Error.Error()
+		}
+
+		events = append(events, traceEvent{
+			Name:      result.String(),
+			Category:  "transition",
+			Phase:     "X",
+			Timestamp: result.Timestamp.UnixMicro(),
+			Duration:  result.Duration.Microseconds(),
+			ProcessID: 1,
+			ThreadID:  traceTransitionThread,
+			Args:      args,
+		})
+
+		if !result.Success {
+			continue
+		}
+
+		// The state is occupied until the next successful transition leaves it
+		leftAt := time.Now()
+		for _, next := range recent[i+1:] {
+			if next.Success {
+				leftAt = next.Timestamp.Add(next.Duration)
+				break
+			}
+		}
+		enteredAt := result.Timestamp.Add(result.Duration)
+		events = append(events, traceEvent{
+			Name:      string(result.ToState),
+			Category:  "state",
+			Phase:     "X",
+			Timestamp: enteredAt.UnixMicro(),
+			Duration:  leftAt.Sub(enteredAt).Microseconds(),
+			ProcessID: 1,
+			ThreadID:  traceStateThread,
+		})
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	})
+}
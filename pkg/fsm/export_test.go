@@ -0,0 +1,208 @@
+package fsm
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExportMermaid tests Mermaid diagram generation
+func TestExportMermaid(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("red", "timer", "green").
+		AddTransition("green", "timer", "yellow").
+		AddTransition("yellow", "timer", "red").
+		SetInitialState("red").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	diagram, err := ExportMermaid(machine)
+	if err != nil {
+		t.Fatalf("Failed to export Mermaid diagram: %v", err)
+	}
+
+	expected := "stateDiagram-v2\n" +
+		"    [*] --> red\n" +
+		"    green --> yellow : timer\n" +
+		"    red --> green : timer\n" +
+		"    yellow --> red : timer\n"
+	if diagram != expected {
+		t.Errorf("Unexpected Mermaid diagram:\n%s", diagram)
+	}
+}
+
+// TestExportDOT tests Graphviz DOT generation
+func TestExportDOT(t *testing.T) {
+	machine, err := NewBuilder().
+		AddStates("idle", "running", "stopped").
+		AddTransition("idle", "start", "running").
+		AddTransitionWithCondition("running", "stop", "stopped", AlwaysTrue()).
+		AddTransition("stopped", "reset", "idle").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	dot, err := ExportDOT(machine)
+	if err != nil {
+		t.Fatalf("Failed to export DOT: %v", err)
+	}
+
+	nodes := regexp.MustCompile(`(?m)^\s+"[^"]+" \[shape=(circle|doublecircle)\];$`).FindAllStringSubmatch(dot, -1)
+	if len(nodes) != 3 {
+		t.Errorf("Expected 3 nodes, got %d:\n%s", len(nodes), dot)
+	}
+	edges := regexp.MustCompile(`(?m)^\s+"[^"]+" -> "[^"]+" \[label="[^"]+"(, style=dashed)?\];$`).FindAllStringSubmatch(dot, -1)
+	if len(edges) != 3 {
+		t.Errorf("Expected 3 edges, got %d:\n%s", len(edges), dot)
+	}
+	if !strings.Contains(dot, `"idle" [shape=doublecircle];`) {
+		t.Errorf("Expected initial state to be drawn as a double circle:\n%s", dot)
+	}
+	if !strings.Contains(dot, `"running" -> "stopped" [label="stop", style=dashed];`) {
+		t.Errorf("Expected conditional transition to be dashed:\n%s", dot)
+	}
+
+	again, _ := ExportDOT(machine)
+	if again != dot {
+		t.Errorf("Expected deterministic DOT output")
+	}
+}
+
+// TestExportSVG tests that the SVG export is a well-formed image of every state and transition
+func TestExportSVG(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("idle", "start", "running").
+		AddTransitionWithCondition("running", "stop", "idle", AlwaysTrue()).
+		AddTransition("running", "tick", "running").
+		AddTransition("running", "<fail>", "failed & stopped").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	image, err := ExportSVG(machine)
+	if err != nil {
+		t.Fatalf("Failed to export SVG: %v", err)
+	}
+
+	var document struct {
+		XMLName xml.Name   `xml:"svg"`
+		Circles []struct{} `xml:"circle"`
+		Paths   []struct {
+			Dash string `xml:"stroke-dasharray,attr"`
+		} `xml:"path"`
+		Texts []string `xml:"text"`
+	}
+	if err := xml.Unmarshal([]byte(image), &document); err != nil {
+		t.Fatalf("Expected well-formed SVG, got %v:\n%s", err, image)
+	}
+	if len(document.Circles) != 4 { // Three states, plus the inner ring of the initial state
+		t.Errorf("Expected 4 circles, got %d:\n%s", len(document.Circles), image)
+	}
+	if len(document.Paths) != 4 {
+		t.Errorf("Expected 4 edges, got %d:\n%s", len(document.Paths), image)
+	}
+	dashed := 0
+	for _, path := range document.Paths {
+		if path.Dash != "" {
+			dashed++
+		}
+	}
+	if dashed != 1 {
+		t.Errorf("Expected only the conditional transition to be dashed, got %d", dashed)
+	}
+	if !strings.Contains(strings.Join(document.Texts, "|"), "failed & stopped") {
+		t.Errorf("Expected escaped names to round-trip, got %v", document.Texts)
+	}
+
+	again, _ := ExportSVG(machine)
+	if again != image {
+		t.Errorf("Expected deterministic SVG output")
+	}
+}
+
+// TestExportPlantUML tests PlantUML diagram generation
+func TestExportPlantUML(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("pending", "pay", "paid").
+		AddTransition("paid", "ship", "shipped").
+		AddFinalStates("shipped").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	expected := "@startuml\n" +
+		"[*] --> pending\n" +
+		"paid --> shipped : ship\n" +
+		"pending --> paid : pay\n" +
+		"shipped --> [*]\n" +
+		"@enduml\n"
+	if diagram := ExportPlantUML(machine); diagram != expected {
+		t.Errorf("Unexpected PlantUML diagram:\n%s", diagram)
+	}
+}
+
+// TestExportTrace tests Chrome trace event generation from recent transitions
+func TestExportTrace(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransitionWithAction("idle", "start", "running", func(from, to State, event Event, context Context) error {
+			time.Sleep(2 * time.Millisecond)
+			return nil
+		}).
+		AddTransition("running", "stop", "idle").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	machine.SendEvent("start")
+	machine.SendEvent("start") // Invalid from running
+	machine.SendEvent("stop")
+
+	data, err := ExportTrace(machine)
+	if err != nil {
+		t.Fatalf("Failed to export trace: %v", err)
+	}
+
+	var trace struct {
+		TraceEvents []struct {
+			Name     string `json:"name"`
+			Category string `json:"cat"`
+			Phase    string `json:"ph"`
+			Duration int64  `json:"dur"`
+		} `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(data, &trace); err != nil {
+		t.Fatalf("Failed to parse trace JSON: %v", err)
+	}
+
+	var transitions, states int
+	for _, event := range trace.TraceEvents {
+		if event.Phase != "X" {
+			t.Errorf("Expected complete events, got phase %q", event.Phase)
+		}
+		switch event.Category {
+		case "transition":
+			transitions++
+		case "state":
+			states++
+		}
+	}
+	if transitions != 3 || states != 2 {
+		t.Errorf("Expected 3 transition and 2 state events, got %d and %d", transitions, states)
+	}
+	if trace.TraceEvents[0].Name != "idle --start--> running" || trace.TraceEvents[0].Duration < 2000 {
+		t.Errorf("Expected first transition to include the action duration, got %+v", trace.TraceEvents[0])
+	}
+}
@@ -0,0 +1,633 @@
+package fsm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ExpressionError reports a malformed expression and where in it the problem was found
+type ExpressionError struct {
+	Expression string // The expression being compiled
+	Position   int    // Byte offset of the offending token, starting at 0
+	Message    string // Description of the problem
+}
+
+// Error implements the error interface for ExpressionError
+func (e *ExpressionError) Error() string {
+	return fmt.Sprintf("invalid expression %q at position %d: %s", e.Expression, e.Position, e.Message)
+}
+
+// CompileCondition compiles a boolean expression over context values into a TransitionCondition
+//
+// Grammar:
+//
+//	expr       = or
+//	or         = and { ("or" | "||") and }
+//	and        = not { ("and" | "&&") not }
+//	not        = ("not" | "!") not | comparison
+//	comparison = sum [ ("==" | "!=" | "<" | "<=" | ">" | ">=") sum ]
+//	sum        = product { ("+" | "-") product }
+//	product    = unary { ("*" | "/") unary }
+//	unary      = "-" unary | operand
+//	operand    = number | string | "true" | "false" | "nil" | identifier | "(" expr ")"
+//
+// Identifiers read context keys, with or without a "context." prefix. Numbers of any Go
+// numeric type compare numerically; comparing values of different kinds is false.
+// Arithmetic on integers stays integral, "+" also concatenates strings, and arithmetic
+// on anything else makes the whole condition false.
+func CompileCondition(expression string) (TransitionCondition, error) {
+	parser, err := newExpressionParser(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := parser.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if !parser.done() {
+		return nil, parser.errorf("unexpected %s", parser.peek().describe())
+	}
+
+	return func(context Context) bool {
+		return truthy(node.eval(context))
+	}, nil
+}
+
+// isExpression reports whether a configured condition is an expression rather than a registry name
+func isExpression(value string) bool {
+	for _, r := range value {
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenKind classifies lexical tokens of an expression
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+	tokenLParen
+	tokenRParen
+)
+
+// token is a lexical token and its position in the expression
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+// describe returns a human-readable description of the token for error messages
+func (t token) describe() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// tokenize splits an expression into tokens
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	fail := func(pos int, format string, args ...interface{}) error {
+		return &ExpressionError{Expression: expression, Position: pos, Message: fmt.Sprintf(format, args...)}
+	}
+
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			var sb strings.Builder
+			for end < len(expression) && expression[end] != c {
+				if expression[end] == '\\' && end+1 < len(expression) {
+					end++
+				}
+				sb.WriteByte(expression[end])
+				end++
+			}
+			if end >= len(expression) {
+				return nil, fail(i, "unterminated string")
+			}
+			tokens = append(tokens, token{kind: tokenString, text: expression[i : end+1], value: sb.String(), pos: i})
+			i = end + 1
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(expression) && expression[i+1] >= '0' && expression[i+1] <= '9':
+			end := i
+			for end < len(expression) && (expression[end] >= '0' && expression[end] <= '9' || expression[end] == '.') {
+				end++
+			}
+			var number interface{}
+			if integer, err := strconv.Atoi(expression[i:end]); err == nil {
+				number = integer
+			} else if float, err := strconv.ParseFloat(expression[i:end], 64); err == nil {
+				number = float
+			} else {
+				return nil, fail(i, "invalid number %q", expression[i:end])
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: expression[i:end], value: number, pos: i})
+			i = end
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := i
+			for end < len(expression) && (expression[end] == '_' || expression[end] == '.' ||
+				unicode.IsLetter(rune(expression[end])) || unicode.IsDigit(rune(expression[end]))) {
+				end++
+			}
+			word := expression[i:end]
+			switch word {
+			case "and", "or", "not":
+				tokens = append(tokens, token{kind: tokenOperator, text: word, pos: i})
+			default:
+				tokens = append(tokens, token{kind: tokenIdent, text: word, pos: i})
+			}
+			i = end
+		default:
+			operator := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "+", "-", "*", "/", "=", ";"} {
+				if strings.HasPrefix(expression[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fail(i, "unexpected character %q", c)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: operator, pos: i})
+			i += len(operator)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(expression)}), nil
+}
+
+// expressionParser is a recursive descent parser over the tokens of one expression
+type expressionParser struct {
+	expression string
+	tokens     []token
+	pos        int
+}
+
+// newExpressionParser tokenizes an expression and prepares it for parsing
+func newExpressionParser(expression string) (*expressionParser, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	return &expressionParser{expression: expression, tokens: tokens}, nil
+}
+
+// peek returns the current token without consuming it
+func (p *expressionParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the current token
+func (p *expressionParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// done reports whether every token has been consumed
+func (p *expressionParser) done() bool {
+	return p.peek().kind == tokenEOF
+}
+
+// accept consumes the current token if it is one of the given operators
+func (p *expressionParser) accept(operators ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return "", false
+	}
+	for _, operator := range operators {
+		if t.text == operator {
+			p.next()
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+// errorf returns an ExpressionError at the current token
+func (p *expressionParser) errorf(format string, args ...interface{}) error {
+	return &ExpressionError{Expression: p.expression, Position: p.peek().pos, Message: fmt.Sprintf(format, args...)}
+}
+
+// parseExpression parses a full expression
+func (p *expressionParser) parseExpression() (exprNode, error) {
+	return p.parseOr()
+}
+
+// parseOr parses a disjunction
+func (p *expressionParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("or", "||"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{or: true, left: left, right: right}
+	}
+}
+
+// parseAnd parses a conjunction
+func (p *expressionParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("and", "&&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{left: left, right: right}
+	}
+}
+
+// parseNot parses a negation
+func (p *expressionParser) parseNot() (exprNode, error) {
+	if _, ok := p.accept("not", "!"); ok {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses an optional binary comparison
+func (p *expressionParser) parseComparison() (exprNode, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	operator, ok := p.accept("==", "!=", "<", "<=", ">", ">=")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	return comparisonNode{operator: operator, left: left, right: right}, nil
+}
+
+// parseSum parses addition and subtraction
+func (p *expressionParser) parseSum() (exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmeticNode{operator: operator, left: left, right: right}
+	}
+}
+
+// parseProduct parses multiplication and division
+func (p *expressionParser) parseProduct() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.accept("*", "/")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmeticNode{operator: operator, left: left, right: right}
+	}
+}
+
+// parseUnary parses numeric negation
+func (p *expressionParser) parseUnary() (exprNode, error) {
+	if _, ok := p.accept("-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return arithmeticNode{operator: "-", left: literalNode{value: 0}, right: operand}, nil
+	}
+	return p.parseOperand()
+}
+
+// parseOperand parses a literal, identifier or parenthesized expression
+func (p *expressionParser) parseOperand() (exprNode, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenNumber, tokenString:
+		p.next()
+		return literalNode{value: t.value}, nil
+	case tokenIdent:
+		p.next()
+		switch t.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "nil":
+			return literalNode{value: nil}, nil
+		}
+		return contextNode{key: strings.TrimPrefix(t.text, "context.")}, nil
+	case tokenLParen:
+		p.next()
+		node, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenRParen {
+			return nil, p.errorf("expected \")\", found %s", p.peek().describe())
+		}
+		p.next()
+		return node, nil
+	default:
+		return nil, p.errorf("expected a value, found %s", t.describe())
+	}
+}
+
+// exprNode is a node of a compiled expression
+type exprNode interface {
+	eval(context Context) interface{}
+}
+
+// literalNode is a constant value
+type literalNode struct {
+	value interface{}
+}
+
+func (n literalNode) eval(context Context) interface{} {
+	return n.value
+}
+
+// contextNode reads a value from the context
+type contextNode struct {
+	key string
+}
+
+func (n contextNode) eval(context Context) interface{} {
+	if context == nil {
+		return nil
+	}
+	return context.Get(n.key)
+}
+
+// logicalNode is a short-circuiting "and" or "or"
+type logicalNode struct {
+	or          bool
+	left, right exprNode
+}
+
+func (n logicalNode) eval(context Context) interface{} {
+	left := truthy(n.left.eval(context))
+	if left == n.or {
+		return left
+	}
+	return truthy(n.right.eval(context))
+}
+
+// notNode negates its operand
+type notNode struct {
+	operand exprNode
+}
+
+func (n notNode) eval(context Context) interface{} {
+	return !truthy(n.operand.eval(context))
+}
+
+// comparisonNode compares two values
+type comparisonNode struct {
+	operator    string
+	left, right exprNode
+}
+
+func (n comparisonNode) eval(context Context) interface{} {
+	return compareValues(n.operator, n.left.eval(context), n.right.eval(context))
+}
+
+// arithmeticNode applies an arithmetic operator
+type arithmeticNode struct {
+	operator    string
+	left, right exprNode
+}
+
+func (n arithmeticNode) eval(context Context) interface{} {
+	return arithmetic(n.operator, n.left.eval(context), n.right.eval(context))
+}
+
+// evalError is the value of an expression that can't be evaluated, such as 1 / 0
+// It propagates through arithmetic and makes comparisons and conditions false
+type evalError struct {
+	message string
+}
+
+func (e evalError) Error() string {
+	return e.message
+}
+
+// arithmetic applies an arithmetic operator, keeping integer results integral
+func arithmetic(operator string, left, right interface{}) interface{} {
+	if err, ok := left.(evalError); ok {
+		return err
+	}
+	if err, ok := right.(evalError); ok {
+		return err
+	}
+
+	if l, ok := left.(string); ok && operator == "+" {
+		if r, ok := right.(string); ok {
+			return l + r
+		}
+	}
+
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		return evalError{fmt.Sprintf("cannot apply %s to %v and %v", operator, left, right)}
+	}
+
+	_, lint := left.(int)
+	_, rint := right.(int)
+	switch operator {
+	case "+":
+		if lint && rint {
+			return left.(int) + right.(int)
+		}
+		return l + r
+	case "-":
+		if lint && rint {
+			return left.(int) - right.(int)
+		}
+		return l - r
+	case "*":
+		if lint && rint {
+			return left.(int) * right.(int)
+		}
+		return l * r
+	default:
+		if r == 0 {
+			return evalError{"division by zero"}
+		}
+		if lint && rint && left.(int)%right.(int) == 0 {
+			return left.(int) / right.(int)
+		}
+		return l / r
+	}
+}
+
+// compareValues applies a comparison operator; values of different kinds are never equal
+func compareValues(operator string, left, right interface{}) bool {
+	if _, ok := left.(evalError); ok {
+		return false
+	}
+	if _, ok := right.(evalError); ok {
+		return false
+	}
+
+	if l, ok := toNumber(left); ok {
+		if r, ok := toNumber(right); ok {
+			switch operator {
+			case "==":
+				return l == r
+			case "!=":
+				return l != r
+			case "<":
+				return l < r
+			case "<=":
+				return l <= r
+			case ">":
+				return l > r
+			case ">=":
+				return l >= r
+			}
+		}
+	}
+
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			switch operator {
+			case "==":
+				return l == r
+			case "!=":
+				return l != r
+			case "<":
+				return l < r
+			case "<=":
+				return l <= r
+			case ">":
+				return l > r
+			case ">=":
+				return l >= r
+			}
+		}
+	}
+
+	switch operator {
+	case "==":
+		return scalarEqual(left, right)
+	case "!=":
+		return !scalarEqual(left, right)
+	}
+	return false
+}
+
+// scalarEqual compares booleans and nil, and treats every other mix of kinds as unequal
+func scalarEqual(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	l, lok := left.(bool)
+	r, rok := right.(bool)
+	return lok && rok && l == r
+}
+
+// toNumber converts any Go numeric value to float64
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// truthy interprets a value as a boolean: false, nil, zero and "" are false
+func truthy(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	if _, ok := value.(evalError); ok {
+		return false
+	}
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	if n, ok := toNumber(value); ok {
+		return n != 0
+	}
+	if s, ok := value.(string); ok {
+		return s != ""
+	}
+	return true
+}
@@ -0,0 +1,156 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestCompileCondition tests evaluating condition expressions against a context
+func TestCompileCondition(t *testing.T) {
+	context := NewContext()
+	context.Set("balance", 150)
+	context.Set("product", "A1")
+	context.Set("in_stock", true)
+	context.Set("discount", 0.5)
+
+	tests := map[string]bool{
+		`context.balance > 100 and context.in_stock == true`:   true,
+		`balance > 100 && product == "A1"`:                     true,
+		`balance >= 200 or product == 'A1'`:                    true,
+		`not in_stock`:                                         false,
+		`!(balance < 100) && discount <= .5`:                   true,
+		`(balance > 100 or missing) and not (product != "A1")`: true,
+		`missing == nil`:                                       true,
+		`balance == "150"`:                                     false,
+		`in_stock`:                                             true,
+	}
+
+	for expression, expected := range tests {
+		condition, err := CompileCondition(expression)
+		if err != nil {
+			t.Fatalf("Failed to compile %q: %v", expression, err)
+		}
+		if condition(context) != expected {
+			t.Errorf("Expected %q to be %v", expression, expected)
+		}
+	}
+}
+
+// TestCompileConditionErrors tests parse errors with positions
+func TestCompileConditionErrors(t *testing.T) {
+	tests := map[string]int{
+		`balance > `:          10,
+		`(balance > 1`:        12,
+		`balance > 1 product`: 12,
+		`name == "open`:       8,
+		`balance # 1`:         8,
+	}
+
+	for expression, position := range tests {
+		_, err := CompileCondition(expression)
+		var exprErr *ExpressionError
+		if !errors.As(err, &exprErr) {
+			t.Fatalf("Expected ExpressionError for %q, got %v", expression, err)
+		}
+		if exprErr.Position != position {
+			t.Errorf("Expected error at %d for %q, got %d (%s)", position, expression, exprErr.Position, exprErr.Message)
+		}
+	}
+}
+
+// TestConfigConditionExpression tests compiling a configured expression at BuildMachine time
+func TestConfigConditionExpression(t *testing.T) {
+	loader := NewConfigLoader()
+	machine, err := loader.BuildMachine(&ConfigMachine{
+		InitialState: "idle",
+		Transitions: []TransitionConfig{
+			{From: "idle", Event: "buy", To: "sold", Condition: `context.balance > 100 and context.in_stock == true`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	machine.GetContext().Set("balance", 50)
+	machine.GetContext().Set("in_stock", true)
+	if machine.CanTransition("buy") {
+		t.Errorf("Expected guard to reject a balance of 50")
+	}
+	machine.GetContext().Set("balance", 120)
+	if !machine.CanTransition("buy") {
+		t.Errorf("Expected guard to accept a balance of 120")
+	}
+
+	_, err = loader.BuildMachine(&ConfigMachine{
+		Transitions: []TransitionConfig{{From: "idle", Event: "buy", To: "sold", Condition: "balance >"}},
+	})
+	var exprErr *ExpressionError
+	if !errors.As(err, &exprErr) {
+		t.Errorf("Expected ExpressionError for a malformed condition, got %v", err)
+	}
+}
+
+// TestConfigActionScript tests running an inline action script from a built machine
+func TestConfigActionScript(t *testing.T) {
+	loader := NewConfigLoader()
+	machine, err := loader.BuildMachine(&ConfigMachine{
+		InitialState: "ready",
+		Transitions: []TransitionConfig{
+			{From: "ready", Event: "buy", To: "sold", Action: `set balance = balance - product_price; increment transaction_count`},
+			{From: "sold", Event: "label", To: "ready", Action: `set label = "sold by " + cashier; log label`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	ctx := machine.GetContext()
+	ctx.Set("balance", 200)
+	ctx.Set("product_price", 150)
+	ctx.Set("cashier", "ann")
+	if _, err := machine.SendEvent("buy"); err != nil {
+		t.Fatalf("Failed to send buy: %v", err)
+	}
+	if balance, _ := ctx.Get("balance").(int); balance != 50 {
+		t.Errorf("Expected balance 50, got %v", ctx.Get("balance"))
+	}
+	if count, _ := ctx.Get("transaction_count").(int); count != 1 {
+		t.Errorf("Expected transaction_count 1, got %v", ctx.Get("transaction_count"))
+	}
+
+	if _, err := machine.SendEvent("label"); err != nil {
+		t.Fatalf("Failed to send label: %v", err)
+	}
+	if label := ctx.Get("label"); label != "sold by ann" {
+		t.Errorf("Expected label 'sold by ann', got %v", label)
+	}
+
+	ctx.Set("product_price", "free")
+	if _, err := machine.SendEvent("buy"); err == nil {
+		t.Errorf("Expected arithmetic on a string to fail the transition")
+	}
+	if machine.CurrentState() != "ready" {
+		t.Errorf("Expected state 'ready' after a failed action, got '%s'", machine.CurrentState())
+	}
+}
+
+// TestCompileActionErrors tests that malformed scripts report the offending position
+func TestCompileActionErrors(t *testing.T) {
+	cases := map[string]int{
+		`set = 1`:              4,
+		`set x 1`:              6,
+		`increment x log "a"`:  12,
+		`delete x`:             0,
+		`set x = 1; set y = *`: 19,
+	}
+	for script, position := range cases {
+		_, err := CompileAction(script)
+		var exprErr *ExpressionError
+		if !errors.As(err, &exprErr) {
+			t.Fatalf("Expected ExpressionError for %q, got %v", script, err)
+		}
+		if exprErr.Position != position {
+			t.Errorf("Expected error at %d for %q, got %d (%s)", position, script, exprErr.Position, exprErr.Message)
+		}
+	}
+}
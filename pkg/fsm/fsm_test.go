@@ -2,6 +2,8 @@ package fsm
 
 import (
 	"errors"
+	"fmt"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -409,6 +411,32 @@ func TestBuilderValidation(t *testing.T) {
 	}
 }
 
+// TestBuilderStrictMode tests that strict mode reports transitions using undeclared names
+func TestBuilderStrictMode(t *testing.T) {
+	build := func(event Event, to State) error {
+		_, err := NewBuilder().
+			StrictMode().
+			AddStates("idle", "running").
+			AddEvents("start").
+			AddTransition("idle", event, to).
+			SetInitialState("idle").
+			Build()
+		return err
+	}
+
+	if err := build("start", "running"); err != nil {
+		t.Fatalf("Expected a fully declared machine to build, got %v", err)
+	}
+
+	var fsmErr FSMError
+	if err := build("strt", "running"); !errors.As(err, &fsmErr) || fsmErr.Type != "UndeclaredEvent" || fsmErr.Event != "strt" {
+		t.Errorf("Expected an UndeclaredEvent error naming 'strt', got %v", err)
+	}
+	if err := build("start", "runing"); !errors.As(err, &fsmErr) || fsmErr.Type != "UndeclaredState" || fsmErr.State != "runing" {
+		t.Errorf("Expected an UndeclaredState error naming 'runing', got %v", err)
+	}
+}
+
 // TestGetValidEvents tests the GetValidEvents method
 func TestGetValidEvents(t *testing.T) {
 	machine, err := NewBuilder().
@@ -444,6 +472,36 @@ func TestGetValidEvents(t *testing.T) {
 	}
 }
 
+// TestDeterministicOrder tests that transitions and valid events come back sorted
+func TestDeterministicOrder(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("b", "go", "c").
+		AddTransition("a", "zap", "c").
+		AddTransition("a", "go", "c").
+		AddTransitionWithCondition("a", "go", "b", AlwaysTrue()).
+		AddTransition("a", "hop", "b").
+		SetInitialState("a").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		transitions := machine.GetTransitions()
+		expected := []string{"a:go:b", "a:go:c", "a:hop:b", "a:zap:c", "b:go:c"}
+		for j, transition := range transitions {
+			if key := string(transition.From) + ":" + string(transition.Event) + ":" + string(transition.To); key != expected[j] {
+				t.Fatalf("Expected transitions %v, got %v", expected, transitions)
+			}
+		}
+
+		events := machine.GetValidEvents()
+		if len(events) != 3 || events[0] != "go" || events[1] != "hop" || events[2] != "zap" {
+			t.Fatalf("Expected valid events [go hop zap], got %v", events)
+		}
+	}
+}
+
 // TestCanTransition tests the CanTransition method
 func TestCanTransition(t *testing.T) {
 	machine, err := NewBuilder().
@@ -472,3 +530,776 @@ func TestCanTransition(t *testing.T) {
 		t.Errorf("Expected CanTransition to return false for nonexistent event")
 	}
 }
+
+// TestLintUnreachableFinalStates tests that final states outside the reachable graph are reported
+func TestLintUnreachableFinalStates(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("pending", "pay", "paid").
+		AddTransition("paid", "ship", "shipped").
+		AddTransition("returned", "refund", "refunded").
+		AddFinalStates("shipped", "refunded").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	issues := machine.Lint()
+	if len(issues) != 1 {
+		t.Fatalf("Expected 1 lint issue, got %d: %v", len(issues), issues)
+	}
+	if issues[0].Type != "UnreachableFinalState" || issues[0].State != "refunded" {
+		t.Errorf("Expected unreachable final state 'refunded', got %v", issues[0])
+	}
+}
+
+// TestValidateStrict tests reachability and dead-end analysis
+func TestValidateStrict(t *testing.T) {
+	machine, err := NewBuilder().
+		AddStates("maintenance").
+		AddTransition("idle", "start", "running").
+		AddTransition("running", "finish", "done").
+		AddTransition("running", "crash", "crashed").
+		AddFinalStates("done").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	err = machine.ValidateStrict()
+	var structureErr *StructureError
+	if !errors.As(err, &structureErr) {
+		t.Fatalf("Expected StructureError, got %v", err)
+	}
+	if len(structureErr.UnreachableStates) != 1 || structureErr.UnreachableStates[0] != "maintenance" {
+		t.Errorf("Expected unreachable state 'maintenance', got %v", structureErr.UnreachableStates)
+	}
+	if len(structureErr.DeadEndStates) != 2 || structureErr.DeadEndStates[0] != "crashed" || structureErr.DeadEndStates[1] != "maintenance" {
+		t.Errorf("Expected dead-end states [crashed maintenance], got %v", structureErr.DeadEndStates)
+	}
+
+	// Plain Validate keeps accepting the machine
+	if err := machine.Validate(); err != nil {
+		t.Errorf("Expected Validate to pass, got %v", err)
+	}
+}
+
+// TestConformsTo tests reporting transitions missing from a reference specification
+func TestConformsTo(t *testing.T) {
+	spec := []Transition{
+		{From: "pending", Event: "pay", To: "paid"},
+		{From: "paid", Event: "ship", To: "shipped"},
+		{From: "paid", Event: "refund", To: "refunded"},
+	}
+	machine, err := NewBuilder().
+		AddTransition("pending", "pay", "paid").
+		AddTransition("paid", "ship", "shipped").
+		AddTransition("pending", "ship", "shipped").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	ok, unapproved := machine.ConformsTo(spec)
+	if ok || len(unapproved) != 1 || unapproved[0].From != "pending" || unapproved[0].Event != "ship" {
+		t.Errorf("Expected the pending --ship--> shipped shortcut to be unapproved, got %v", unapproved)
+	}
+	if missing := MissingTransitions(machine, spec); len(missing) != 1 || missing[0].Event != "refund" {
+		t.Errorf("Expected the refund transition to be missing, got %v", missing)
+	}
+
+	if ok, _ := machine.ConformsTo(append(spec, Transition{From: "pending", Event: "ship", To: "shipped"})); !ok {
+		t.Error("Expected the machine to conform once the shortcut is approved")
+	}
+}
+
+// TestRecentTransitions tests the bounded buffer of recent transition attempts
+func TestRecentTransitions(t *testing.T) {
+	machine := NewStateMachine()
+	machine.AddState("a")
+	machine.AddState("b")
+	machine.AddEvent("toggle")
+	machine.AddTransition(Transition{From: "a", Event: "toggle", To: "b"})
+	machine.AddTransition(Transition{From: "b", Event: "toggle", To: "a"})
+	machine.SetHistorySize(3)
+	machine.Start("a")
+
+	for i := 0; i < 5; i++ {
+		machine.SendEvent("toggle")
+	}
+
+	recent := machine.RecentTransitions()
+	if len(recent) != 3 {
+		t.Fatalf("Expected 3 recent transitions, got %d", len(recent))
+	}
+	// Attempts 3, 4 and 5 remain: a->b, b->a, a->b
+	if recent[0].FromState != "a" || recent[1].FromState != "b" || recent[2].FromState != "a" {
+		t.Errorf("Expected oldest-first order, got %v", recent)
+	}
+}
+
+// TestGuardedAlternatives tests choosing between transitions sharing a state and event
+func TestGuardedAlternatives(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransitionWithCondition("review", "decide", "approved", func(ctx Context) bool {
+			score, ok := ctx.Get("score").(int)
+			return ok && score >= 50
+		}).
+		AddTransition("review", "decide", "rejected").
+		SetInitialState("review").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	machine.GetContext().Set("score", 80)
+	if _, err := machine.SendEvent("decide"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if machine.CurrentState() != "approved" {
+		t.Errorf("Expected state 'approved', got '%s'", machine.CurrentState())
+	}
+
+	machine.Reset()
+	machine.GetContext().Set("score", 10)
+	machine.SendEvent("decide")
+	if machine.CurrentState() != "rejected" {
+		t.Errorf("Expected state 'rejected', got '%s'", machine.CurrentState())
+	}
+}
+
+// TestNondeterminismDetection tests that Build rejects competing unguarded transitions
+func TestNondeterminismDetection(t *testing.T) {
+	_, err := NewBuilder().
+		AddTransition("idle", "go", "left").
+		AddTransition("idle", "go", "right").
+		SetInitialState("idle").
+		Build()
+
+	var nondeterminism *NondeterminismError
+	if !errors.As(err, &nondeterminism) {
+		t.Fatalf("Expected NondeterminismError, got %v", err)
+	}
+	if nondeterminism.From != "idle" || nondeterminism.Event != "go" || len(nondeterminism.Targets) != 2 {
+		t.Errorf("Unexpected conflict details: %+v", nondeterminism)
+	}
+}
+
+// TestRedefineTransitionTarget tests that a new target is added alongside the old one, not over it
+func TestRedefineTransitionTarget(t *testing.T) {
+	machine := NewStateMachine()
+	for _, state := range []State{"idle", "left", "right"} {
+		machine.AddState(state)
+	}
+	machine.AddEvent("go")
+	machine.AddTransition(Transition{From: "idle", Event: "go", To: "left"})
+
+	// Same target: the transition is replaced
+	machine.AddTransition(Transition{From: "idle", Event: "go", To: "left", Label: "updated"})
+	if transitions := machine.GetTransitions(); len(transitions) != 1 || transitions[0].Label != "updated" {
+		t.Fatalf("Expected the transition to be replaced, got %+v", transitions)
+	}
+
+	// Different target: both are kept, which Validate rejects while neither has a guard
+	machine.AddTransition(Transition{From: "idle", Event: "go", To: "right"})
+	if transitions := machine.GetTransitions(); len(transitions) != 2 {
+		t.Fatalf("Expected both targets to be kept, got %+v", transitions)
+	}
+	var nondeterminism *NondeterminismError
+	if err := machine.Validate(); !errors.As(err, &nondeterminism) {
+		t.Errorf("Expected NondeterminismError, got %v", err)
+	}
+
+	// Removing first redefines the target
+	machine.RemoveTransition("idle", "go")
+	machine.AddTransition(Transition{From: "idle", Event: "go", To: "right"})
+	if transitions := machine.GetTransitions(); len(transitions) != 1 || transitions[0].To != "right" {
+		t.Errorf("Expected only the new target, got %+v", transitions)
+	}
+	if err := machine.Validate(); err != nil {
+		t.Errorf("Expected a valid machine, got %v", err)
+	}
+}
+
+// TestFinalStates tests accepting an input sequence by ending in a final state
+func TestFinalStates(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("q0", "a", "q1").
+		AddTransition("q1", "b", "q2").
+		AddTransition("q2", "a", "q1").
+		AddFinalStates("q2").
+		SetInitialState("q0").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	if !machine.IsFinal("q2") || machine.IsFinal("q1") {
+		t.Errorf("Expected only 'q2' to be final")
+	}
+	if finals := machine.FinalStates(); len(finals) != 1 || finals[0] != "q2" {
+		t.Errorf("Expected final states [q2], got %v", finals)
+	}
+
+	// "ab" is accepted, "aba" is not
+	for _, event := range []Event{"a", "b"} {
+		machine.SendEvent(event)
+	}
+	if !machine.IsInFinalState() {
+		t.Errorf("Expected 'ab' to be accepted")
+	}
+	machine.SendEvent("a")
+	if machine.IsInFinalState() {
+		t.Errorf("Expected 'aba' to be rejected")
+	}
+}
+
+// TestRun tests recognizing event sequences with a DFA
+func TestRun(t *testing.T) {
+	// Accepts words over {a, b} that end in "ab"
+	machine, err := NewBuilder().
+		AddTransition("q0", "a", "q1").
+		AddTransition("q0", "b", "q0").
+		AddTransition("q1", "a", "q1").
+		AddTransition("q1", "b", "q2").
+		AddTransition("q2", "a", "q1").
+		AddTransition("q2", "b", "q0").
+		AddFinalStates("q2").
+		SetInitialState("q0").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	accepted, trace, err := machine.Run([]Event{"b", "a", "a", "b"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !accepted || len(trace) != 4 {
+		t.Errorf("Expected 'baab' accepted with 4 steps, got %v with %d", accepted, len(trace))
+	}
+
+	machine.Reset()
+	accepted, _, _ = machine.Run([]Event{"a", "b", "b"})
+	if accepted {
+		t.Errorf("Expected 'abb' to be rejected")
+	}
+
+	// An unknown symbol stops the run with a partial trace
+	machine.Reset()
+	accepted, trace, err = machine.Run([]Event{"a", "c", "b"})
+	if err == nil || accepted {
+		t.Fatalf("Expected error for unknown event")
+	}
+	if len(trace) != 1 || trace[0].ToState != "q1" {
+		t.Errorf("Expected partial trace of 1 step, got %v", trace)
+	}
+}
+
+// TestEventAvailability tests capping guard evaluation when listing valid events
+func TestEventAvailability(t *testing.T) {
+	evaluations := 0
+	guard := func(result bool) TransitionCondition {
+		return func(ctx Context) bool {
+			evaluations++
+			return result
+		}
+	}
+
+	machine, err := NewBuilder().
+		AddTransition("idle", "cancel", "cancelled").
+		AddTransitionWithCondition("idle", "approve", "approved", guard(true)).
+		AddTransitionWithCondition("idle", "escalate", "escalated", guard(false)).
+		AddTransitionWithCondition("idle", "review", "reviewing", guard(true)).
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	// Without a budget only unguarded events are confirmed
+	availability := machine.GetEventAvailability(0)
+	if evaluations != 0 {
+		t.Errorf("Expected no guard evaluations, got %d", evaluations)
+	}
+	if len(availability.Available) != 1 || availability.Available[0] != "cancel" {
+		t.Errorf("Expected only 'cancel' available, got %v", availability.Available)
+	}
+	if len(availability.Conditional) != 3 {
+		t.Errorf("Expected 3 conditional events, got %v", availability.Conditional)
+	}
+
+	// A budget of two settles approve and escalate, leaving review conditional
+	availability = machine.GetEventAvailability(2)
+	if evaluations != 2 {
+		t.Errorf("Expected 2 guard evaluations, got %d", evaluations)
+	}
+	if len(availability.Available) != 2 || len(availability.Conditional) != 1 || availability.Conditional[0] != "review" {
+		t.Errorf("Unexpected availability: %+v", availability)
+	}
+
+	// An unlimited budget matches GetValidEvents
+	availability = machine.GetEventAvailability(-1)
+	if len(availability.Available) != len(machine.GetValidEvents()) || len(availability.Conditional) != 0 {
+		t.Errorf("Expected unlimited budget to match GetValidEvents, got %+v", availability)
+	}
+}
+
+// TestClone tests that a clone shares the definition but not the runtime state
+func TestClone(t *testing.T) {
+	entered := 0
+	original, err := NewBuilderWithHooks().
+		AddTransition("idle", "start", "running").
+		AddOnStateEnterHook(func(result TransitionResult, context Context) { entered++ }).
+		SetInitialState("idle").
+		EnableGuardCache().
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	original.GetContext().Set("owner", "original")
+	original.SendEvent("start")
+
+	clone, err := original.Clone()
+	if err != nil {
+		t.Fatalf("Failed to clone FSM: %v", err)
+	}
+	if clone.IsRunning() || clone.GetContext().Get("owner") != nil {
+		t.Errorf("Expected a stopped clone with an empty context")
+	}
+	if clone.(*StateMachine).guardCache == nil {
+		t.Error("Expected the clone to keep the guard cache")
+	}
+
+	// Changing the clone's transitions leaves the original alone
+	clone.AddTransition(Transition{From: "running", Event: "start", To: "idle"})
+	if len(original.GetTransitions()) != 1 {
+		t.Errorf("Expected original to keep 1 transition, got %d", len(original.GetTransitions()))
+	}
+
+	enteredBefore := entered
+	clone.Start(clone.InitialState())
+	clone.SendEvent("start")
+	if clone.CurrentState() != "running" || original.CurrentState() != "running" {
+		t.Errorf("Expected both machines in 'running'")
+	}
+	if entered != enteredBefore+2 {
+		t.Errorf("Expected the clone to run the copied hooks, got %d calls", entered-enteredBefore)
+	}
+}
+
+// TestCloneLeavesBoundHooks tests that a clone doesn't drive observers attached to the original
+func TestCloneLeavesBoundHooks(t *testing.T) {
+	sla := NewSLA("processing", 20*time.Millisecond, "escalate")
+	original, err := NewBuilderWithHooks().
+		AddTransition("pending", "process", "processing").
+		AddTransition("processing", "complete", "completed").
+		AddTransition("processing", "escalate", "escalated").
+		AddSLA(sla).
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	index := NewStateIndex()
+	index.Track("order", original)
+	original.SendEvent("process")
+
+	clone, err := original.Clone()
+	if err != nil {
+		t.Fatalf("Failed to clone: %v", err)
+	}
+	clone.Start("processing")
+	clone.SendEvent("complete")
+
+	if names := index.MachinesInState("processing"); len(names) != 1 || names[0] != "order" {
+		t.Errorf("Expected the clone to leave the index alone, got %v in 'processing'", names)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for original.CurrentState() != "escalated" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if original.CurrentState() != "escalated" {
+		t.Errorf("Expected the original to escalate, got '%s'", original.CurrentState())
+	}
+	if sla.Breaches() != 1 {
+		t.Errorf("Expected 1 breach, got %d", sla.Breaches())
+	}
+}
+
+// TestEmbedSubMachine tests assembling a machine from reusable fragments
+func TestEmbedSubMachine(t *testing.T) {
+	retry := func() Builder {
+		return NewBuilder().
+			AddTransition("waiting", "retry", "trying").
+			AddTransition("trying", "fail", "waiting").
+			AddTransition("trying", "succeed", "done").
+			AddFinalStates("done").
+			SetInitialState("trying")
+	}
+
+	machine, err := NewBuilder().
+		AddTransition("idle", "submit", "submitted").
+		Embed("upload", retry()).
+		Embed("notify", retry()).
+		EnterEmbedded("submitted", "upload", "upload").
+		AddTransition("upload.done", "notify", "notify.trying").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	for _, event := range []Event{"submit", "upload", "upload.fail", "upload.retry", "upload.succeed", "notify", "notify.succeed"} {
+		if _, err := machine.SendEvent(event); err != nil {
+			t.Fatalf("Unexpected error on %s: %v", event, err)
+		}
+	}
+	if machine.CurrentState() != "notify.done" || !machine.IsInFinalState() {
+		t.Errorf("Expected final state 'notify.done', got '%s'", machine.CurrentState())
+	}
+
+	// Embedding without a prefix rejects shared events
+	_, err = NewBuilder().
+		AddTransition("idle", "retry", "idle").
+		Embed("", retry()).
+		Build()
+	if err == nil {
+		t.Errorf("Expected an event collision error")
+	}
+}
+
+// TestTransitionsFrom tests the per-state transition and event queries
+func TestTransitionsFrom(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("pending", "pay", "paid").
+		AddTransition("pending", "cancel", "cancelled").
+		AddTransitionWithCondition("pending", "pay", "review", func(context Context) bool { return false }).
+		AddTransition("paid", "ship", "shipped").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	events := machine.OutgoingEvents("pending")
+	if !reflect.DeepEqual(events, []Event{"cancel", "pay"}) {
+		t.Errorf("Expected [cancel pay], got %v", events)
+	}
+
+	transitions := machine.TransitionsFrom("pending")
+	if len(transitions) != 3 || transitions[0].To != "cancelled" || transitions[1].To != "paid" || transitions[2].To != "review" {
+		t.Errorf("Expected cancel then both pay candidates in insertion order, got %v", transitions)
+	}
+
+	if err := machine.RemoveTransition("pending", "cancel"); err != nil {
+		t.Fatalf("Failed to remove transition: %v", err)
+	}
+	if events := machine.OutgoingEvents("pending"); !reflect.DeepEqual(events, []Event{"pay"}) {
+		t.Errorf("Expected [pay] after removal, got %v", events)
+	}
+	if events := machine.OutgoingEvents("shipped"); len(events) != 0 {
+		t.Errorf("Expected no outgoing events from a dead end, got %v", events)
+	}
+
+	clone, err := machine.Clone()
+	if err != nil {
+		t.Fatalf("Failed to clone: %v", err)
+	}
+	if events := clone.OutgoingEvents("paid"); !reflect.DeepEqual(events, []Event{"ship"}) {
+		t.Errorf("Expected the clone to keep the index, got %v", events)
+	}
+}
+
+// TestTransitionIntrospection tests that tooling can tell guarded and acting transitions apart
+func TestTransitionIntrospection(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("idle", "start", "running").
+		AddTransitionFull("running", "stop", "idle",
+			func(context Context) bool { return true },
+			func(from, to State, event Event, context Context) error { return nil }).
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	plain := machine.TransitionsFrom("idle")[0]
+	if plain.HasGuard() || plain.HasAction() {
+		t.Errorf("Expected no guard or action on %s", plain)
+	}
+	full := machine.TransitionsFrom("running")[0]
+	if !full.HasGuard() || !full.HasAction() {
+		t.Errorf("Expected a guard and an action on %s", full)
+	}
+}
+
+// TestResetWithContext tests that ResetWithContext discards values accumulated since Build
+func TestResetWithContext(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransitionWithAction("open", "sell", "open", func(from, to State, event Event, context Context) error {
+			context.Set("total_sales", context.Get("total_sales").(int)+1)
+			return nil
+		}).
+		SetInitialState("open").
+		SetInitialContext(map[string]interface{}{"total_sales": 0, "store": "north"}).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	machine.SendEvent("sell")
+	machine.GetContext().Set("optimization_level", 3)
+
+	if err := machine.Reset(); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	if value := machine.GetContext().Get("total_sales"); value != 1 {
+		t.Errorf("Expected Reset to keep the context, got total_sales %v", value)
+	}
+
+	if err := machine.ResetWithContext(); err != nil {
+		t.Fatalf("Failed to reset with context: %v", err)
+	}
+	context := machine.GetContext().GetAll()
+	if !reflect.DeepEqual(context, map[string]interface{}{"total_sales": 0, "store": "north"}) {
+		t.Errorf("Expected the context captured at Build, got %v", context)
+	}
+
+	// The captured values must not be shared with the reinstalled context
+	machine.SendEvent("sell")
+	machine.ResetWithContext()
+	if value := machine.GetContext().Get("total_sales"); value != 0 {
+		t.Errorf("Expected a fresh copy on every reset, got total_sales %v", value)
+	}
+}
+
+// TestResetWithSecureContext tests that ResetWithContext reinstalls a SecureContext in plaintext
+func TestResetWithSecureContext(t *testing.T) {
+	machine := NewStateMachine()
+	machine.AddState("open")
+	encryptor := NewContextEncryptor(StaticKey([]byte("0123456789abcdef")), "token")
+	secure := NewSecureContext(encryptor)
+	secure.Set("token", "tok_123")
+	secure.Set("attempts", 0)
+	machine.SetContext(secure)
+	machine.captureInitialContext()
+	if err := machine.Start("open"); err != nil {
+		t.Fatalf("Failed to start FSM: %v", err)
+	}
+
+	if !isEncryptedValue(machine.InitialContext()["token"]) {
+		t.Error("Expected InitialContext to keep the token encrypted")
+	}
+
+	machine.GetContext().Set("token", "tok_456")
+	machine.GetContext().Set("attempts", 3)
+	if err := machine.ResetWithContext(); err != nil {
+		t.Fatalf("Failed to reset with context: %v", err)
+	}
+	restored, ok := machine.GetContext().(*SecureContext)
+	if !ok {
+		t.Fatalf("Expected a SecureContext after reset, got %T", machine.GetContext())
+	}
+	if restored.Get("token") != "tok_123" || restored.Get("attempts") != 0 {
+		t.Errorf("Expected the plaintext values captured at Build, got %v", restored.plaintext())
+	}
+}
+
+// TestFSMErrorSentinels tests matching FSMErrors with errors.Is and errors.As
+func TestFSMErrorSentinels(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransitionWithCondition("locked", "push", "open", func(context Context) bool { return false }).
+		AddTransition("locked", "coin", "unlocked").
+		AddStates("open").
+		SetInitialState("locked").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	_, err = machine.SendEvent("push")
+	if !errors.Is(err, ErrConditionNotMet) || errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected only ErrConditionNotMet to match, got %v", err)
+	}
+	var fsmErr FSMError
+	if !errors.As(err, &fsmErr) || fsmErr.State != "locked" || fsmErr.Event != "push" {
+		t.Errorf("Expected the rich fields to be kept, got %+v", fsmErr)
+	}
+
+	machine.SendEvent("coin")
+	if _, err := machine.SendEvent("coin"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition, got %v", err)
+	}
+	if _, err := machine.SendEvent("kick"); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Expected ErrEventNotFound, got %v", err)
+	}
+	if err := machine.SetState("missing"); !errors.Is(err, ErrStateNotFound) {
+		t.Errorf("Expected ErrStateNotFound, got %v", err)
+	}
+	machine.Stop()
+	_, err = machine.SendEvent("coin")
+	if !errors.Is(err, ErrMachineNotRunning) {
+		t.Errorf("Expected ErrMachineNotRunning, got %v", err)
+	}
+
+	// Wrapped errors still match, and so do FSMErrors of the same type
+	wrapped := fmt.Errorf("sending coin: %w", err)
+	if !errors.Is(wrapped, ErrMachineNotRunning) || !errors.Is(wrapped, FSMError{Type: "MachineNotRunning"}) {
+		t.Errorf("Expected the wrapped error to match, got %v", wrapped)
+	}
+	if errors.Is(FSMError{Type: "ActionTimeout"}, ErrConditionNotMet) {
+		t.Error("Expected an error type without a sentinel to match none")
+	}
+}
+
+// TestStatesAndEvents tests listing every defined state and event, including unused ones
+func TestStatesAndEvents(t *testing.T) {
+	machine, err := NewBuilder().
+		AddStates("maintenance").
+		AddEvents("audit").
+		AddTransition("idle", "start", "running").
+		AddTransition("running", "stop", "idle").
+		AddEventAlias("halt", "stop").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	if states := machine.States(); !reflect.DeepEqual(states, []State{"idle", "maintenance", "running"}) {
+		t.Errorf("Expected states [idle maintenance running], got %v", states)
+	}
+	events := machine.Events()
+	if !reflect.DeepEqual(events, []Event{"audit", "start", "stop"}) {
+		t.Errorf("Expected events [audit start stop], got %v", events)
+	}
+
+	// The result is a copy
+	events[0] = "changed"
+	if machine.Events()[0] != "audit" {
+		t.Error("Expected Events to return a copy")
+	}
+}
+
+// TestSameStateEntryHooks tests that Start, Reset and SetState don't re-enter the current state
+func TestSameStateEntryHooks(t *testing.T) {
+	visits := make(map[State]int)
+	exits := 0
+	machine, err := NewBuilderWithHooks().
+		AddTransition("idle", "start", "running").
+		AddTransition("running", "stop", "idle").
+		SetInitialState("idle").
+		AddOnStateEnterHook(func(result TransitionResult, context Context) {
+			visits[result.ToState]++
+		}).
+		AddOnStateExitHook(func(result TransitionResult, context Context) {
+			exits++
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := machine.Reset(); err != nil {
+			t.Fatalf("Failed to reset: %v", err)
+		}
+	}
+	machine.Start("idle")
+	machine.SetState("idle")
+	if visits["idle"] != 1 || exits != 0 {
+		t.Errorf("Expected a single visit to idle and no exits, got %d visits and %d exits", visits["idle"], exits)
+	}
+
+	// Resetting from another state still leaves it and re-enters the initial state
+	machine.SendEvent("start")
+	machine.Reset()
+	machine.Reset()
+	if visits["idle"] != 2 || visits["running"] != 1 || exits != 2 {
+		t.Errorf("Expected 2 visits to idle, 1 to running and 2 exits, got %v and %d exits", visits, exits)
+	}
+
+	// ResetWithContext still reinstalls the context without re-entering
+	machine.GetContext().Set("scratch", true)
+	machine.ResetWithContext()
+	if machine.GetContext().Get("scratch") != nil || visits["idle"] != 2 {
+		t.Errorf("Expected a fresh context and no extra visit, got %v and %d visits", machine.GetContext().GetAll(), visits["idle"])
+	}
+}
+
+// TestExecutionIDs tests that execution IDs are unique and that the generator can be replaced
+func TestExecutionIDs(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := SequentialExecutionIDs()
+		if len(id) != 16 || seen[id] {
+			t.Fatalf("Expected a fresh 16-character ID, got %q", id)
+		}
+		seen[id] = true
+	}
+	if id := RandomExecutionIDs(); len(id) != 16 {
+		t.Errorf("Expected a 16-character random ID, got %q", id)
+	}
+
+	machine, err := NewBuilder().
+		AddTransition("idle", "start", "running").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	machine.(*StateMachine).SetExecutionIDGenerator(func() string { return "fixed" })
+	if result, _ := machine.SendEvent("start"); result.ExecutionID != "fixed" {
+		t.Errorf("Expected the custom generator to be used, got %q", result.ExecutionID)
+	}
+}
+
+// TestSendEventIfInState tests that a conditional send only fires from the expected state
+func TestSendEventIfInState(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("pending", "approve", "approved").
+		AddTransition("pending", "reject", "rejected").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	_, err = machine.SendEventIfInState("approved", "reject")
+	if !errors.Is(err, ErrStateMismatch) || machine.CurrentState() != "pending" {
+		t.Fatalf("Expected ErrStateMismatch and state pending, got %v and %s", err, machine.CurrentState())
+	}
+
+	// Two callers racing on the same read of the state: exactly one of them wins
+	outcomes := make(chan error, 2)
+	for _, event := range []Event{"approve", "reject"} {
+		go func(event Event) {
+			_, err := machine.SendEventIfInState("pending", event)
+			outcomes <- err
+		}(event)
+	}
+	var won, mismatched int
+	for i := 0; i < 2; i++ {
+		switch err := <-outcomes; {
+		case err == nil:
+			won++
+		case errors.Is(err, ErrStateMismatch):
+			mismatched++
+		default:
+			t.Errorf("Expected success or ErrStateMismatch, got %v", err)
+		}
+	}
+	if won != 1 || mismatched != 1 {
+		t.Errorf("Expected one winner and one mismatch, got %d and %d", won, mismatched)
+	}
+
+	// A stopped machine reports that it isn't running rather than a mismatch
+	machine.Stop()
+	if _, err := machine.SendEventIfInState("pending", "approve"); !errors.Is(err, ErrMachineNotRunning) {
+		t.Errorf("Expected ErrMachineNotRunning, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package fsm
+
+import "sync"
+
+// VersionedContext is a Context that counts its writes
+// ContextImpl, SecureContext and StrictContext implement it; guard caching needs it to notice changes
+type VersionedContext interface {
+	Context
+	Version() uint64 // Returns a counter that changes whenever a value is set
+}
+
+// guardCache memoizes whether an event can fire from a state for one version of one context
+// It has its own lock because CanTransition and GetValidEvents only hold the machine's read lock
+type guardCache struct {
+	mu      sync.Mutex
+	context Context
+	version uint64
+	allowed map[guardCacheKey]bool
+}
+
+// guardCacheKey identifies a cached result; a struct avoids formatting a transitionKey on every lookup
+type guardCacheKey struct {
+	state State
+	event Event
+}
+
+// lookup returns the cached result for key, resetting the cache if the context changed since it was filled
+// version is the context version the caller should store a freshly computed result under
+func (gc *guardCache) lookup(context VersionedContext, key guardCacheKey) (allowed bool, hit bool, version uint64) {
+	version = context.Version()
+
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	if gc.context != Context(context) || gc.version != version || gc.allowed == nil {
+		gc.context = context
+		gc.version = version
+		gc.allowed = make(map[guardCacheKey]bool)
+	}
+	allowed, hit = gc.allowed[key]
+	return allowed, hit, version
+}
+
+// store caches a result computed for the given context version, unless the cache moved on meanwhile
+func (gc *guardCache) store(context VersionedContext, version uint64, key guardCacheKey, allowed bool) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	if gc.context == Context(context) && gc.version == version {
+		gc.allowed[key] = allowed
+	}
+}
+
+// EnableGuardCache memoizes CanTransition and GetValidEvents until the context is next written
+// Only safe when every guard is a pure function of the context: guards reading the clock, other
+// machines or values mutated in place (such as a map fetched with Get) may return stale results.
+// Contexts that don't implement VersionedContext are never cached; SendEvent always evaluates guards.
+func (sm *StateMachine) EnableGuardCache() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.guardCache == nil {
+		sm.guardCache = &guardCache{}
+	}
+}
+
+// resetGuardCacheUnsafe forgets every cached guard result after the transitions changed
+func (sm *StateMachine) resetGuardCacheUnsafe() {
+	if sm.guardCache == nil {
+		return
+	}
+	sm.guardCache.mu.Lock()
+	defer sm.guardCache.mu.Unlock()
+	sm.guardCache.allowed = nil
+}
+
+// cachedCanTransitionUnsafe evaluates whether an event can fire, consulting the guard cache if enabled
+func (sm *StateMachine) cachedCanTransitionUnsafe(event Event) bool {
+	context, versioned := sm.context.(VersionedContext)
+	if sm.guardCache == nil || !versioned {
+		_, _, allowed, err := sm.selectTransitionUnsafe(event)
+		sm.propagatePanicUnsafe(err)
+		return allowed
+	}
+
+	key := guardCacheKey{state: sm.currentState, event: event}
+	allowed, hit, version := sm.guardCache.lookup(context, key)
+	if hit {
+		return allowed
+	}
+	_, _, allowed, err := sm.selectTransitionUnsafe(event)
+	sm.propagatePanicUnsafe(err)
+	sm.guardCache.store(context, version, key, allowed)
+	return allowed
+}
@@ -0,0 +1,73 @@
+package fsm
+
+import "testing"
+
+// TestGuardCache tests that guard results are reused until the context or transitions change
+func TestGuardCache(t *testing.T) {
+	evaluations := 0
+	hasStock := func(context Context) bool {
+		evaluations++
+		return context.Get("stock") != 0
+	}
+
+	machine, err := NewBuilder().
+		AddTransitionWithCondition("idle", "sell", "idle", hasStock).
+		SetInitialState("idle").
+		SetInitialContext(map[string]interface{}{"stock": 1}).
+		EnableGuardCache().
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if !machine.CanTransition("sell") || len(machine.GetValidEvents()) != 1 {
+			t.Fatal("Expected 'sell' to be valid")
+		}
+	}
+	if evaluations != 1 {
+		t.Errorf("Expected 1 guard evaluation within one context version, got %d", evaluations)
+	}
+
+	machine.GetContext().Set("stock", 0)
+	if machine.CanTransition("sell") {
+		t.Error("Expected a context write to invalidate the cached result")
+	}
+	if evaluations != 2 {
+		t.Errorf("Expected 2 guard evaluations, got %d", evaluations)
+	}
+
+	machine.AddTransition(Transition{From: "idle", Event: "sell", To: "idle", Condition: AlwaysTrue()})
+	if !machine.CanTransition("sell") {
+		t.Error("Expected replacing the transition to invalidate the cached result")
+	}
+}
+
+// BenchmarkGetValidEventsGuardCache compares GetValidEvents with and without the guard cache
+func BenchmarkGetValidEventsGuardCache(b *testing.B) {
+	for _, cached := range []bool{false, true} {
+		name := "Uncached"
+		if cached {
+			name = "Cached"
+		}
+		b.Run(name, func(b *testing.B) {
+			builder := NewBuilder()
+			for _, event := range []Event{"approve", "reject", "escalate", "hold", "resume", "cancel"} {
+				builder.AddTransitionWithCondition("review", event, "done", ContextGreaterThan("score", 0.5))
+			}
+			if cached {
+				builder.EnableGuardCache()
+			}
+			machine, err := builder.SetInitialState("review").Build()
+			if err != nil {
+				b.Fatalf("Failed to build FSM: %v", err)
+			}
+			machine.GetContext().Set("score", 0.9)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				machine.GetValidEvents()
+			}
+		})
+	}
+}
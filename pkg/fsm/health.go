@@ -0,0 +1,67 @@
+package fsm
+
+import "time"
+
+// StateHealth summarizes how a state behaved over the machine's recent transitions
+type StateHealth struct {
+	State            State         `json:"state"`
+	Attempts         int           `json:"attempts"`          // Transition attempts made while in the state
+	Failures         int           `json:"failures"`          // Attempts that failed
+	ErrorRate        float64       `json:"error_rate"`        // Failures divided by attempts, 0 without attempts
+	Visits           int           `json:"visits"`            // Completed stays in the state
+	AverageResidence time.Duration `json:"average_residence"` // Mean duration of completed stays
+}
+
+// ComputeStateHealth derives per-state error rates and residence times from RecentTransitions
+// Every state referenced by the machine is listed, in sorted order, even without recent activity
+func ComputeStateHealth(m Machine) []StateHealth {
+	health := make(map[State]*StateHealth)
+	for _, state := range sortedStates(m, sortedTransitions(m)) {
+		health[state] = &StateHealth{State: state}
+	}
+	entry := func(state State) *StateHealth {
+		if health[state] == nil {
+			health[state] = &StateHealth{State: state}
+		}
+		return health[state]
+	}
+
+	residence := make(map[State]time.Duration)
+	var entered time.Time
+	var enteredState State
+	for _, result := range m.RecentTransitions() {
+		from := entry(result.FromState)
+		from.Attempts++
+		if !result.Success {
+			from.Failures++
+			continue
+		}
+
+		// A stay is only complete once both its entry and exit are in the buffer;
+		// resets and direct state changes aren't recorded, so unmatched stays are skipped
+		if !entered.IsZero() && enteredState == result.FromState {
+			from.Visits++
+			residence[result.FromState] += result.Timestamp.Sub(entered)
+		}
+		entered = result.Timestamp.Add(result.Duration)
+		enteredState = result.ToState
+	}
+
+	states := make(map[State]bool, len(health))
+	for state := range health {
+		states[state] = true
+	}
+
+	report := make([]StateHealth, 0, len(health))
+	for _, state := range sortedStateSet(states) {
+		h := health[state]
+		if h.Attempts > 0 {
+			h.ErrorRate = float64(h.Failures) / float64(h.Attempts)
+		}
+		if h.Visits > 0 {
+			h.AverageResidence = residence[state] / time.Duration(h.Visits)
+		}
+		report = append(report, *h)
+	}
+	return report
+}
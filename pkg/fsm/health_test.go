@@ -0,0 +1,43 @@
+package fsm
+
+import (
+	"testing"
+	"time"
+)
+
+// TestComputeStateHealth tests per-state error rates and residence times
+func TestComputeStateHealth(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("idle", "start", "running").
+		AddTransition("running", "stop", "idle").
+		AddTransitionWithCondition("running", "pause", "paused", AlwaysFalse()).
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	machine.SendEvent("start")
+	time.Sleep(10 * time.Millisecond)
+	machine.SendEvent("pause") // Guard fails
+	machine.SendEvent("stop")
+
+	health := make(map[State]StateHealth)
+	for _, h := range ComputeStateHealth(machine) {
+		health[h.State] = h
+	}
+
+	if len(health) != 3 {
+		t.Fatalf("Expected health for 3 states, got %d", len(health))
+	}
+	running := health["running"]
+	if running.Attempts != 2 || running.Failures != 1 || running.ErrorRate != 0.5 {
+		t.Errorf("Expected running error rate 0.5 over 2 attempts, got %+v", running)
+	}
+	if running.Visits != 1 || running.AverageResidence < 10*time.Millisecond {
+		t.Errorf("Expected one stay of at least 10ms in running, got %+v", running)
+	}
+	if paused := health["paused"]; paused.Attempts != 0 || paused.ErrorRate != 0 {
+		t.Errorf("Expected no activity in paused, got %+v", paused)
+	}
+}
@@ -0,0 +1,97 @@
+package fsm
+
+import "sort"
+
+// Layout spacing in diagram units, matching the designer's canvas
+const (
+	layoutMargin       = 100.0 // Offset of the first column and row from the origin
+	layoutLayerSpacing = 200.0 // Horizontal distance between layers
+	layoutRowSpacing   = 120.0 // Vertical distance between states in a layer
+)
+
+// Point is a state's position in a diagram
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// LayeredLayout positions a machine's states in layers by BFS depth from the initial state
+// States unreachable from it start their own BFS in the first layer. Within a layer states are
+// ordered by the mean row of their predecessors in the previous layer, which cuts edge crossings
+func LayeredLayout(m Machine) map[State]Point {
+	transitions := sortedTransitions(m)
+	states := sortedStates(m, transitions)
+
+	successors := make(map[State][]State)
+	predecessors := make(map[State][]State)
+	for _, transition := range transitions {
+		successors[transition.From] = append(successors[transition.From], transition.To)
+		predecessors[transition.To] = append(predecessors[transition.To], transition.From)
+	}
+
+	// Assign depths breadth first, starting with the initial state
+	roots := states
+	if initial := m.InitialState(); initial != "" {
+		roots = append([]State{initial}, states...)
+	}
+	depth := make(map[State]int, len(states))
+	var layers [][]State
+	for _, root := range roots {
+		if _, seen := depth[root]; seen {
+			continue
+		}
+		depth[root] = 0
+		queue := []State{root}
+		for len(queue) > 0 {
+			state := queue[0]
+			queue = queue[1:]
+			if depth[state] == len(layers) {
+				layers = append(layers, nil)
+			}
+			layers[depth[state]] = append(layers[depth[state]], state)
+			for _, next := range successors[state] {
+				if _, seen := depth[next]; !seen {
+					depth[next] = depth[state] + 1
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+
+	// Order each layer by the barycenter of its predecessors in the layer before it
+	row := make(map[State]int, len(states))
+	for i, layer := range layers {
+		if i > 0 {
+			barycenter := make(map[State]float64, len(layer))
+			for position, state := range layer {
+				sum, count := 0, 0
+				for _, previous := range predecessors[state] {
+					if depth[previous] == i-1 {
+						sum += row[previous]
+						count++
+					}
+				}
+				if count == 0 {
+					barycenter[state] = float64(position) // Keep discovery order for states without a parent here
+					continue
+				}
+				barycenter[state] = float64(sum) / float64(count)
+			}
+			sort.SliceStable(layer, func(a, b int) bool {
+				return barycenter[layer[a]] < barycenter[layer[b]]
+			})
+		}
+		for position, state := range layer {
+			row[state] = position
+		}
+	}
+
+	layout := make(map[State]Point, len(states))
+	for state, layer := range depth {
+		layout[state] = Point{
+			X: layoutMargin + float64(layer)*layoutLayerSpacing,
+			Y: layoutMargin + float64(row[state])*layoutRowSpacing,
+		}
+	}
+	return layout
+}
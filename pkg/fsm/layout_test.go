@@ -0,0 +1,33 @@
+package fsm
+
+import "testing"
+
+// TestLayeredLayout tests that states are placed in columns by BFS depth without overlapping
+func TestLayeredLayout(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("idle", "start", "working").
+		AddTransition("idle", "skip", "done").
+		AddTransition("working", "finish", "done").
+		AddTransition("working", "fail", "failed").
+		AddTransition("orphan", "adopt", "idle").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	layout := LayeredLayout(machine)
+	if len(layout) != 5 {
+		t.Fatalf("Expected 5 positioned states, got %v", layout)
+	}
+
+	columns := map[State]float64{"idle": 100, "working": 300, "done": 300, "failed": 500, "orphan": 100}
+	for state, x := range columns {
+		if layout[state].X != x {
+			t.Errorf("Expected %s at x=%v, got %v", state, x, layout[state].X)
+		}
+	}
+	if layout["idle"].Y == layout["orphan"].Y || layout["working"].Y == layout["done"].Y {
+		t.Errorf("Expected states in the same layer not to overlap, got %v", layout)
+	}
+}
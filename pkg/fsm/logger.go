@@ -0,0 +1,50 @@
+package fsm
+
+import "fmt"
+
+// Logger receives diagnostics from machines and configuration loaders
+// Adapters for log, slog or zap only need to forward the three levels
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// NopLogger discards everything; machines use it unless SetLogger is called
+type NopLogger struct{}
+
+// Debugf discards the message
+func (NopLogger) Debugf(format string, args ...interface{}) {}
+
+// Infof discards the message
+func (NopLogger) Infof(format string, args ...interface{}) {}
+
+// Errorf discards the message
+func (NopLogger) Errorf(format string, args ...interface{}) {}
+
+// stdoutLogger prints info and error messages to standard output, one per line
+// It keeps the output of the log actions and hooks registered by NewConfigLoader unchanged
+type stdoutLogger struct{}
+
+func (stdoutLogger) Debugf(format string, args ...interface{}) {}
+
+func (stdoutLogger) Infof(format string, args ...interface{}) {
+	fmt.Printf(format+"\n", args...)
+}
+
+func (stdoutLogger) Errorf(format string, args ...interface{}) {
+	fmt.Printf(format+"\n", args...)
+}
+
+// SetLogger routes the machine's diagnostics to logger; nil restores the silent default
+// Transition attempts are logged at debug level, or through LogTransition if logger is a
+// TransitionLogger, and failing actions at error level
+func (sm *StateMachine) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = NopLogger{}
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.logger = logger
+}
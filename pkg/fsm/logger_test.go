@@ -0,0 +1,104 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recordingLogger keeps every message with its level for assertions
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.lines = append(l.lines, "DEBUG "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.lines = append(l.lines, "INFO "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.lines = append(l.lines, "ERROR "+fmt.Sprintf(format, args...))
+}
+
+// contains reports whether any recorded line starts with level and contains text
+func (l *recordingLogger) contains(level, text string) bool {
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, level+" ") && strings.Contains(line, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestConfigLoaderLogger tests that default log actions, hooks and built machines use the configured logger
+func TestConfigLoaderLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	loader := NewConfigLoader()
+	loader.SetLogger(logger)
+
+	machine, err := loader.BuildMachine(&ConfigMachine{
+		InitialState: "idle",
+		Transitions: []TransitionConfig{
+			{From: "idle", Event: "start", To: "running", Action: "log", Properties: map[string]string{"message": "starting"}},
+			{From: "running", Event: "stop", To: "idle", Action: `log "stopping"`},
+		},
+		Hooks: map[string][]HookConfig{"on_state_enter": {{Type: "on_state_enter", Action: "log_state_enter"}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	machine.SendEvent("start")
+	machine.SendEvent("stop")
+
+	expected := []struct{ level, text string }{
+		{"INFO", "[LOG] starting: Transition: idle --start--> running"},
+		{"INFO", "[LOG] stopping"},
+		{"INFO", "[STATE_ENTER] Entered state: running"},
+		{"DEBUG", "fsm: idle --start--> running"},
+	}
+	for _, e := range expected {
+		if !logger.contains(e.level, e.text) {
+			t.Errorf("Expected %s %q, got %q", e.level, e.text, logger.lines)
+		}
+	}
+}
+
+// TestMachineLogger tests that machines are silent by default and log failures once configured
+func TestMachineLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	machine, err := NewBuilder().
+		AddTransitionWithAction("idle", "start", "running", func(from, to State, event Event, context Context) error {
+			return errors.New("no capacity")
+		}).
+		SetInitialState("idle").
+		SetLogger(logger).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	machine.(*StateMachine).UseReadOnlyHookContext(true)
+	machine.AddHook(OnTransitionError, func(result TransitionResult, context Context) {
+		context.Set("last_error", result.Error)
+	})
+
+	machine.SendEvent("start")
+
+	if !logger.contains("ERROR", "no capacity") {
+		t.Errorf("Expected the failing action to be logged, got %q", logger.lines)
+	}
+	if !logger.contains("ERROR", `ignored write of "last_error"`) {
+		t.Errorf("Expected the rejected hook write to be logged, got %q", logger.lines)
+	}
+
+	logger.lines = nil
+	machine.(*StateMachine).SetLogger(nil)
+	machine.SendEvent("start")
+	if len(logger.lines) != 0 {
+		t.Errorf("Expected nothing after removing the logger, got %q", logger.lines)
+	}
+}
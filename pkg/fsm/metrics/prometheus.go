@@ -0,0 +1,267 @@
+// Package metrics exposes FSM activity in the Prometheus and OpenMetrics text exposition formats
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fla/self-programming-ai/pkg/fsm"
+)
+
+// transitionLabels identifies one transitions_total time series
+type transitionLabels struct {
+	machine string
+	from    string
+	to      string
+	event   string
+	success bool
+}
+
+// ExemplarFunc returns the exemplar labels, such as trace_id and span_id, for a transition
+// Returning no labels leaves the previous exemplar of the series in place
+type ExemplarFunc func(result fsm.TransitionResult, context fsm.Context) map[string]string
+
+// exemplar is the most recent sample transition of a transitions_total series
+type exemplar struct {
+	labels    map[string]string
+	timestamp time.Time
+}
+
+// Collector gathers transition counters and state gauges for registered machines
+// It implements http.Handler so it can be mounted directly at /metrics
+type Collector struct {
+	mu           sync.RWMutex
+	machines     map[string]fsm.Machine
+	transitions  map[transitionLabels]uint64
+	exemplars    map[transitionLabels]exemplar
+	exemplarFunc ExemplarFunc
+	slas         map[string][]*fsm.SLA
+}
+
+// NewCollector creates an empty metrics collector
+func NewCollector() *Collector {
+	return &Collector{
+		machines:    make(map[string]fsm.Machine),
+		transitions: make(map[transitionLabels]uint64),
+		exemplars:   make(map[transitionLabels]exemplar),
+		slas:        make(map[string][]*fsm.SLA),
+	}
+}
+
+// SetExemplarFunc attaches exemplars to transition counters in the OpenMetrics output
+// Exemplars are only recorded for transitions that happen after the function is set
+func (c *Collector) SetExemplarFunc(fn ExemplarFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exemplarFunc = fn
+}
+
+// ContextExemplar builds exemplars from trace identifiers stored in the machine context
+// Keys that are missing or empty in the context are left out of the exemplar
+func ContextExemplar(keys ...string) ExemplarFunc {
+	return func(result fsm.TransitionResult, context fsm.Context) map[string]string {
+		if context == nil {
+			return nil
+		}
+		labels := make(map[string]string)
+		for _, key := range keys {
+			if value := context.Get(key); value != nil && fmt.Sprint(value) != "" {
+				labels[key] = fmt.Sprint(value)
+			}
+		}
+		return labels
+	}
+}
+
+// RegisterSLA exposes the breach count of an SLA attached to the named machine
+func (c *Collector) RegisterSLA(name string, sla *fsm.SLA) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slas[name] = append(c.slas[name], sla)
+}
+
+// Register starts collecting metrics for a machine under the given name
+// Transition counters are fed from AfterTransition and OnTransitionError hooks
+func (c *Collector) Register(name string, machine fsm.Machine) {
+	c.mu.Lock()
+	c.machines[name] = machine
+	c.mu.Unlock()
+
+	record := func(result fsm.TransitionResult, context fsm.Context) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		labels := transitionLabels{
+			machine: name,
+			from:    string(result.FromState),
+			to:      string(result.ToState),
+			event:   string(result.Event),
+			success: result.Success,
+		}
+		c.transitions[labels]++
+
+		if c.exemplarFunc != nil {
+			if sample := c.exemplarFunc(result, context); len(sample) > 0 {
+				c.exemplars[labels] = exemplar{labels: sample, timestamp: result.Timestamp}
+			}
+		}
+	}
+
+	fsm.AddBoundHook(machine, fsm.AfterTransition, record)
+	fsm.AddBoundHook(machine, fsm.OnTransitionError, record)
+}
+
+// ServeHTTP writes the current metrics, in OpenMetrics format when the scraper asks for it
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	write := c.Write
+	if strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text") {
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		write = c.WriteOpenMetrics
+	} else {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	}
+	if err := write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Write renders all collected metrics to w in the Prometheus text format
+func (c *Collector) Write(w io.Writer) error {
+	return c.write(w, false)
+}
+
+// WriteOpenMetrics renders all collected metrics to w in the OpenMetrics text format
+// Transition counters carry the exemplar of their most recent sampled transition
+func (c *Collector) WriteOpenMetrics(w io.Writer) error {
+	return c.write(w, true)
+}
+
+// write renders the metrics, switching counter family names and exemplars for OpenMetrics
+// The collector's lock is only held while copying its own state: the record hook takes it
+// while a machine is locked, so querying machines under it could deadlock with a transition
+func (c *Collector) write(w io.Writer, openMetrics bool) error {
+	c.mu.RLock()
+	transitions := make(map[transitionLabels]uint64, len(c.transitions))
+	for labels, count := range c.transitions {
+		transitions[labels] = count
+	}
+	exemplars := make(map[transitionLabels]exemplar, len(c.exemplars))
+	for labels, sample := range c.exemplars {
+		exemplars[labels] = sample
+	}
+	machines := make(map[string]fsm.Machine, len(c.machines))
+	for name, machine := range c.machines {
+		machines[name] = machine
+	}
+	slas := make(map[string][]*fsm.SLA, len(c.slas))
+	for name, list := range c.slas {
+		slas[name] = append([]*fsm.SLA(nil), list...)
+	}
+	c.mu.RUnlock()
+
+	var sb strings.Builder
+
+	writeCounterHeader(&sb, "fsm_transitions", "Total number of transition attempts.", openMetrics)
+	series := make([]transitionLabels, 0, len(transitions))
+	for labels := range transitions {
+		series = append(series, labels)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].String() < series[j].String() })
+	for _, labels := range series {
+		fmt.Fprintf(&sb, "fsm_transitions_total{%s} %d", labels, transitions[labels])
+		if sample, exists := exemplars[labels]; exists && openMetrics {
+			fmt.Fprintf(&sb, " # {%s} 1 %.3f", sample.String(), float64(sample.timestamp.UnixNano())/1e9)
+		}
+		sb.WriteString("\n")
+	}
+
+	names := make([]string, 0, len(machines))
+	for name := range machines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sb.WriteString("# HELP fsm_current_state Whether the machine is currently in the state (1) or not (0).\n")
+	sb.WriteString("# TYPE fsm_current_state gauge\n")
+	for _, name := range names {
+		machine := machines[name]
+		current := machine.CurrentState()
+		for _, state := range machine.States() {
+			value := 0
+			if state == current {
+				value = 1
+			}
+			fmt.Fprintf(&sb, "fsm_current_state{machine=\"%s\",state=\"%s\"} %d\n",
+				escapeLabel(name), escapeLabel(string(state)), value)
+		}
+	}
+
+	sb.WriteString("# HELP fsm_valid_events Number of events that can currently fire.\n")
+	sb.WriteString("# TYPE fsm_valid_events gauge\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "fsm_valid_events{machine=\"%s\"} %d\n",
+			escapeLabel(name), len(machines[name].GetValidEvents()))
+	}
+
+	writeCounterHeader(&sb, "fsm_sla_breaches", "Number of times a state was occupied past its SLA deadline.", openMetrics)
+	slaNames := make([]string, 0, len(slas))
+	for name := range slas {
+		slaNames = append(slaNames, name)
+	}
+	sort.Strings(slaNames)
+	for _, name := range slaNames {
+		for _, sla := range slas[name] {
+			fmt.Fprintf(&sb, "fsm_sla_breaches_total{machine=\"%s\",state=\"%s\"} %d\n",
+				escapeLabel(name), escapeLabel(string(sla.State())), sla.Breaches())
+		}
+	}
+
+	if openMetrics {
+		sb.WriteString("# EOF\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeCounterHeader writes HELP and TYPE lines for a counter family
+// OpenMetrics names the family without the _total suffix its samples carry
+func writeCounterHeader(sb *strings.Builder, family, help string, openMetrics bool) {
+	if !openMetrics {
+		family += "_total"
+	}
+	fmt.Fprintf(sb, "# HELP %s %s\n", family, help)
+	fmt.Fprintf(sb, "# TYPE %s counter\n", family)
+}
+
+// String formats the exemplar labels in sorted label syntax
+func (e exemplar) String() string {
+	keys := make([]string, 0, len(e.labels))
+	for key := range e.labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", key, escapeLabel(e.labels[key])))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// String formats the labels in Prometheus label syntax
+func (l transitionLabels) String() string {
+	return fmt.Sprintf("machine=\"%s\",from=\"%s\",to=\"%s\",event=\"%s\",success=\"%t\"",
+		escapeLabel(l.machine), escapeLabel(l.from), escapeLabel(l.to), escapeLabel(l.event), l.success)
+}
+
+// escapeLabel escapes a label value according to the text exposition format
+func escapeLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fla/self-programming-ai/pkg/fsm"
+)
+
+// TestCollectorOutput tests the Prometheus text rendering of collected metrics
+func TestCollectorOutput(t *testing.T) {
+	machine, err := fsm.NewBuilder().
+		AddTransition("idle", "start", "running").
+		AddTransition("running", "stop", "idle").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	collector := NewCollector()
+	collector.Register("worker", machine)
+
+	machine.SendEvent("start")
+	machine.SendEvent("start") // Invalid from running
+
+	var sb strings.Builder
+	if err := collector.Write(&sb); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+	output := sb.String()
+
+	expected := []string{
+		`fsm_transitions_total{machine="worker",from="idle",to="running",event="start",success="true"} 1`,
+		`fsm_transitions_total{machine="worker",from="running",to="running",event="start",success="false"} 1`,
+		`fsm_current_state{machine="worker",state="idle"} 0`,
+		`fsm_current_state{machine="worker",state="running"} 1`,
+		`fsm_valid_events{machine="worker"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected metrics output to contain %q, got:\n%s", line, output)
+		}
+	}
+}
+
+// TestOpenMetricsExemplars tests that transition counters carry trace exemplars
+func TestOpenMetricsExemplars(t *testing.T) {
+	machine, err := fsm.NewBuilder().
+		AddTransition("idle", "start", "running").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	collector := NewCollector()
+	collector.SetExemplarFunc(ContextExemplar("trace_id", "span_id"))
+	collector.Register("worker", machine)
+
+	machine.GetContext().Set("trace_id", "4bf92f3577b34da6")
+	machine.SendEvent("start")
+
+	var sb strings.Builder
+	if err := collector.WriteOpenMetrics(&sb); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+	output := sb.String()
+
+	prefix := `fsm_transitions_total{machine="worker",from="idle",to="running",event="start",success="true"} 1 # {trace_id="4bf92f3577b34da6"} 1 `
+	if !strings.Contains(output, prefix) {
+		t.Errorf("Expected exemplar line starting with %q, got:\n%s", prefix, output)
+	}
+	if !strings.Contains(output, "# TYPE fsm_transitions counter\n") || !strings.HasSuffix(output, "# EOF\n") {
+		t.Errorf("Expected OpenMetrics family names and EOF marker, got:\n%s", output)
+	}
+
+	// The classic text format stays free of exemplars
+	sb.Reset()
+	collector.Write(&sb)
+	if strings.Contains(sb.String(), "trace_id") {
+		t.Errorf("Expected no exemplars in Prometheus text output")
+	}
+}
+
+// TestCollectorScrapeDuringTransitions tests that scraping while machines transition can't deadlock
+func TestCollectorScrapeDuringTransitions(t *testing.T) {
+	collector := NewCollector()
+	machines := make([]fsm.Machine, 4)
+	for i := range machines {
+		machine, err := fsm.NewBuilder().
+			AddTransition("idle", "toggle", "running").
+			AddTransition("running", "toggle", "idle").
+			SetInitialState("idle").
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build FSM: %v", err)
+		}
+		machines[i] = machine
+		collector.Register(fmt.Sprintf("worker-%d", i), machine)
+	}
+
+	// Senders and scrapers run side by side until stop closes; a deadlock keeps done open
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for _, machine := range machines {
+			wg.Add(1)
+			go func(machine fsm.Machine) {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						machine.SendEvent("toggle")
+					}
+				}
+			}(machine)
+		}
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						collector.Write(io.Discard)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Scraping and transitioning deadlocked")
+	}
+}
@@ -0,0 +1,207 @@
+package fsm
+
+import "fmt"
+
+// Minimize returns the smallest machine accepting the same event sequences as m
+// It runs Hopcroft's partition refinement over the states reachable from the initial state.
+// Merged states take the initial state's name if they include it and otherwise the smallest
+// name among them. As with NFA.ToDFA, states that cannot reach a final state are dropped, so
+// events leading to them become invalid transitions. The machine must have final states and
+// only unguarded, deterministic transitions without actions
+func Minimize(m Machine) (Machine, error) {
+	if m == nil {
+		return nil, fmt.Errorf("cannot minimize a nil machine")
+	}
+	initial := m.InitialState()
+	if initial == "" {
+		return nil, FSMError{
+			Type:    "NoInitialState",
+			Message: "Cannot minimize machine: no initial state defined",
+		}
+	}
+	if len(m.FinalStates()) == 0 {
+		return nil, FSMError{
+			Type:    "NoFinalStates",
+			Message: "Cannot minimize machine: no final states defined",
+		}
+	}
+
+	transitions := sortedTransitions(m)
+	events := sortedEvents(transitions)
+
+	next := make(map[State]map[Event]State)
+	for _, transition := range transitions {
+		if transition.HasGuard() || transition.HasAction() {
+			return nil, FSMError{
+				Type:    "NotMinimizable",
+				Message: fmt.Sprintf("Cannot minimize machine: %s has a guard or action", transition),
+				State:   transition.From,
+				Event:   transition.Event,
+			}
+		}
+		if previous, exists := next[transition.From][transition.Event]; exists {
+			return nil, &NondeterminismError{
+				From:    transition.From,
+				Event:   transition.Event,
+				Targets: []State{previous, transition.To},
+			}
+		}
+		if next[transition.From] == nil {
+			next[transition.From] = make(map[Event]State)
+		}
+		next[transition.From][transition.Event] = transition.To
+	}
+
+	// Number the reachable states breadth first; index len(states) is an implicit dead state
+	// completing the transition function
+	index := map[State]int{initial: 0}
+	states := []State{initial}
+	for i := 0; i < len(states); i++ {
+		for _, event := range events {
+			if target, ok := next[states[i]][event]; ok {
+				if _, seen := index[target]; !seen {
+					index[target] = len(states)
+					states = append(states, target)
+				}
+			}
+		}
+	}
+	dead := len(states)
+	delta := make([][]int, dead+1)
+	inverse := make([][][]int, len(events))
+	for e := range events {
+		inverse[e] = make([][]int, dead+1)
+	}
+	for q := 0; q <= dead; q++ {
+		delta[q] = make([]int, len(events))
+		for e, event := range events {
+			target := dead
+			if q < dead {
+				if to, ok := next[states[q]][event]; ok {
+					target = index[to]
+				}
+			}
+			delta[q][e] = target
+			inverse[e][target] = append(inverse[e][target], q)
+		}
+	}
+
+	// Start from the accepting/non-accepting split and refine until no block can be split
+	block := make([]int, dead+1)
+	var blocks [][]int
+	var accepting, rejecting []int
+	for q := 0; q <= dead; q++ {
+		if q < dead && m.IsFinal(states[q]) {
+			accepting = append(accepting, q)
+		} else {
+			rejecting = append(rejecting, q)
+		}
+	}
+	for _, members := range [][]int{accepting, rejecting} {
+		if len(members) == 0 {
+			continue
+		}
+		for _, q := range members {
+			block[q] = len(blocks)
+		}
+		blocks = append(blocks, members)
+	}
+
+	pending := make([]bool, len(blocks))
+	worklist := make([]int, 0, len(blocks))
+	for b := range blocks {
+		pending[b] = true
+		worklist = append(worklist, b)
+	}
+	for len(worklist) > 0 {
+		splitter := append([]int(nil), blocks[worklist[0]]...)
+		pending[worklist[0]] = false
+		worklist = worklist[1:]
+
+		for e := range events {
+			// Group the predecessors of the splitter on e by their current block
+			predecessors := make(map[int][]int)
+			var touched []int
+			for _, target := range splitter {
+				for _, q := range inverse[e][target] {
+					if _, ok := predecessors[block[q]]; !ok {
+						touched = append(touched, block[q])
+					}
+					predecessors[block[q]] = append(predecessors[block[q]], q)
+				}
+			}
+
+			for _, b := range touched {
+				inside := predecessors[b]
+				if len(inside) == len(blocks[b]) {
+					continue // The whole block moves into the splitter, so it stays together
+				}
+				isInside := make(map[int]bool, len(inside))
+				for _, q := range inside {
+					isInside[q] = true
+				}
+				var outside []int
+				for _, q := range blocks[b] {
+					if !isInside[q] {
+						outside = append(outside, q)
+					}
+				}
+
+				split := len(blocks)
+				blocks[b] = outside
+				blocks = append(blocks, inside)
+				pending = append(pending, false)
+				for _, q := range inside {
+					block[q] = split
+				}
+
+				// Refine with both halves if b was waiting, otherwise the smaller one suffices
+				if pending[b] || len(inside) <= len(outside) {
+					pending[split] = true
+					worklist = append(worklist, split)
+				} else {
+					pending[b] = true
+					worklist = append(worklist, b)
+				}
+			}
+		}
+	}
+
+	// Name each block and rebuild the machine without the dead state's block
+	names := make([]State, len(blocks))
+	for b, members := range blocks {
+		for _, q := range members {
+			if q == dead {
+				continue
+			}
+			if names[b] == "" || q == 0 || (names[b] != initial && states[q] < names[b]) {
+				names[b] = states[q]
+			}
+		}
+	}
+	deadBlock := block[dead]
+
+	builder := NewBuilder()
+	builder.AddEvents(events...)
+	builder.SetInitialState(initial)
+	for b, members := range blocks {
+		if b == deadBlock {
+			continue
+		}
+		builder.AddState(names[b])
+		representative := members[0]
+		if m.IsFinal(states[representative]) {
+			builder.AddFinalStates(names[b])
+		}
+		for e, event := range events {
+			if target := block[delta[representative][e]]; target != deadBlock {
+				builder.AddTransition(names[b], event, names[target])
+			}
+		}
+	}
+	if block[0] == deadBlock {
+		builder.AddState(initial) // Nothing is accepted, so only the initial state remains
+	}
+
+	return builder.Build()
+}
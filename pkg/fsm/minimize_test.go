@@ -0,0 +1,50 @@
+package fsm
+
+import "testing"
+
+// TestMinimize tests merging the redundant states subset construction leaves in (a|b)*abb
+func TestMinimize(t *testing.T) {
+	// The textbook DFA for (a|b)*abb has A and C equivalent, leaving four states once merged
+	dfa, err := NewBuilder().
+		AddTransition("A", "a", "B").
+		AddTransition("A", "b", "C").
+		AddTransition("B", "a", "B").
+		AddTransition("B", "b", "D").
+		AddTransition("C", "a", "B").
+		AddTransition("C", "b", "C").
+		AddTransition("D", "a", "B").
+		AddTransition("D", "b", "E").
+		AddTransition("E", "a", "B").
+		AddTransition("E", "b", "C").
+		AddTransition("F", "a", "E"). // Unreachable
+		SetInitialState("A").
+		AddFinalStates("E").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	minimal, err := Minimize(dfa)
+	if err != nil {
+		t.Fatalf("Failed to minimize: %v", err)
+	}
+	states := sortedStates(minimal, minimal.GetTransitions())
+	if len(states) != 4 {
+		t.Fatalf("Expected 4 states, got %v", states)
+	}
+	if minimal.InitialState() != "A" || !minimal.IsFinal("E") {
+		t.Errorf("Expected initial state A and final state E, got %s and %v", minimal.InitialState(), minimal.FinalStates())
+	}
+
+	for _, input := range []string{"abb", "aabb", "babb", "ababb", "ab", "abba", "", "bbb"} {
+		original, _, _ := dfa.Run(word(input))
+		reduced, _, _ := minimal.Run(word(input))
+		if original != reduced {
+			t.Errorf("Expected %q to be accepted=%v, got %v", input, original, reduced)
+		}
+	}
+
+	if _, err := Minimize(NewStateMachine()); err == nil {
+		t.Error("Expected an error for a machine without final states")
+	}
+}
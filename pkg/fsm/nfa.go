@@ -0,0 +1,188 @@
+package fsm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NFA is a nondeterministic finite automaton with epsilon transitions
+// It is not executable on its own; ToDFA converts it into a deterministic StateMachine
+type NFA struct {
+	states       map[State]bool
+	events       map[Event]bool
+	transitions  map[State]map[Event]map[State]bool
+	epsilon      map[State]map[State]bool
+	initialState State
+	finalStates  map[State]bool
+}
+
+// NewNFA creates an empty NFA
+func NewNFA() *NFA {
+	return &NFA{
+		states:      make(map[State]bool),
+		events:      make(map[Event]bool),
+		transitions: make(map[State]map[Event]map[State]bool),
+		epsilon:     make(map[State]map[State]bool),
+		finalStates: make(map[State]bool),
+	}
+}
+
+// AddTransition adds a transition on event; one state may have several targets for the same event
+func (n *NFA) AddTransition(from State, event Event, to State) *NFA {
+	n.states[from] = true
+	n.states[to] = true
+	n.events[event] = true
+
+	if n.transitions[from] == nil {
+		n.transitions[from] = make(map[Event]map[State]bool)
+	}
+	if n.transitions[from][event] == nil {
+		n.transitions[from][event] = make(map[State]bool)
+	}
+	n.transitions[from][event][to] = true
+	return n
+}
+
+// AddEpsilonTransition adds a transition that is taken without consuming an event
+func (n *NFA) AddEpsilonTransition(from, to State) *NFA {
+	n.states[from] = true
+	n.states[to] = true
+
+	if n.epsilon[from] == nil {
+		n.epsilon[from] = make(map[State]bool)
+	}
+	n.epsilon[from][to] = true
+	return n
+}
+
+// SetInitialState sets the state the automaton starts in
+func (n *NFA) SetInitialState(state State) *NFA {
+	n.states[state] = true
+	n.initialState = state
+	return n
+}
+
+// AddFinalStates marks states as accepting
+func (n *NFA) AddFinalStates(states ...State) *NFA {
+	for _, state := range states {
+		n.states[state] = true
+		n.finalStates[state] = true
+	}
+	return n
+}
+
+// Accepts simulates the NFA on an event sequence and reports whether it ends in a final state
+func (n *NFA) Accepts(events []Event) bool {
+	current := n.closure(map[State]bool{n.initialState: true})
+	for _, event := range events {
+		current = n.closure(n.move(current, event))
+		if len(current) == 0 {
+			return false
+		}
+	}
+	return n.containsFinal(current)
+}
+
+// ToDFA determinizes the NFA using subset construction
+// Each DFA state is named after its set of NFA states, e.g. "{q0,q1}"; the empty set is
+// omitted, so events that would lead to it are invalid transitions in the resulting machine
+func (n *NFA) ToDFA() (Machine, error) {
+	if n.initialState == "" {
+		return nil, FSMError{
+			Type:    "NoInitialState",
+			Message: "Cannot determinize NFA: no initial state defined",
+		}
+	}
+
+	events := make([]Event, 0, len(n.events))
+	for event := range n.events {
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i] < events[j] })
+
+	builder := NewBuilder()
+	start := n.closure(map[State]bool{n.initialState: true})
+	startName := subsetName(start)
+	builder.SetInitialState(startName)
+
+	visited := map[State]bool{startName: true}
+	queue := []map[State]bool{start}
+	for len(queue) > 0 {
+		subset := queue[0]
+		queue = queue[1:]
+		name := subsetName(subset)
+
+		if n.containsFinal(subset) {
+			builder.AddFinalStates(name)
+		}
+
+		for _, event := range events {
+			target := n.closure(n.move(subset, event))
+			if len(target) == 0 {
+				continue
+			}
+
+			targetName := subsetName(target)
+			builder.AddTransition(name, event, targetName)
+			if !visited[targetName] {
+				visited[targetName] = true
+				queue = append(queue, target)
+			}
+		}
+	}
+
+	return builder.Build()
+}
+
+// closure returns the epsilon closure of a set of states
+func (n *NFA) closure(states map[State]bool) map[State]bool {
+	result := make(map[State]bool, len(states))
+	stack := make([]State, 0, len(states))
+	for state := range states {
+		result[state] = true
+		stack = append(stack, state)
+	}
+
+	for len(stack) > 0 {
+		state := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for next := range n.epsilon[state] {
+			if !result[next] {
+				result[next] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+	return result
+}
+
+// move returns the states reachable from a set of states on one event
+func (n *NFA) move(states map[State]bool, event Event) map[State]bool {
+	result := make(map[State]bool)
+	for state := range states {
+		for next := range n.transitions[state][event] {
+			result[next] = true
+		}
+	}
+	return result
+}
+
+// containsFinal reports whether a set of states includes a final state
+func (n *NFA) containsFinal(states map[State]bool) bool {
+	for state := range states {
+		if n.finalStates[state] {
+			return true
+		}
+	}
+	return false
+}
+
+// subsetName names a DFA state after its sorted set of NFA states
+func subsetName(states map[State]bool) State {
+	names := make([]string, 0, len(states))
+	for _, state := range sortedStateSet(states) {
+		names = append(names, string(state))
+	}
+	return State(fmt.Sprintf("{%s}", strings.Join(names, ",")))
+}
@@ -0,0 +1,79 @@
+package fsm
+
+import (
+	"strings"
+	"testing"
+)
+
+// word splits a string into single-character events
+func word(s string) []Event {
+	events := make([]Event, 0, len(s))
+	for _, symbol := range strings.Split(s, "") {
+		if symbol != "" {
+			events = append(events, Event(symbol))
+		}
+	}
+	return events
+}
+
+// TestNFAToDFA tests subset construction on the classic (a|b)*abb automaton
+func TestNFAToDFA(t *testing.T) {
+	// Thompson construction of (a|b)*abb
+	nfa := NewNFA().
+		AddEpsilonTransition("0", "1").
+		AddEpsilonTransition("0", "7").
+		AddEpsilonTransition("1", "2").
+		AddEpsilonTransition("1", "4").
+		AddTransition("2", "a", "3").
+		AddTransition("4", "b", "5").
+		AddEpsilonTransition("3", "6").
+		AddEpsilonTransition("5", "6").
+		AddEpsilonTransition("6", "1").
+		AddEpsilonTransition("6", "7").
+		AddTransition("7", "a", "8").
+		AddTransition("8", "b", "9").
+		AddTransition("9", "b", "10").
+		SetInitialState("0").
+		AddFinalStates("10")
+
+	dfa, err := nfa.ToDFA()
+	if err != nil {
+		t.Fatalf("Failed to determinize NFA: %v", err)
+	}
+
+	if dfa.InitialState() != "{0,1,2,4,7}" {
+		t.Errorf("Expected initial state '{0,1,2,4,7}', got '%s'", dfa.InitialState())
+	}
+	states := make(map[State]bool)
+	for _, transition := range dfa.GetTransitions() {
+		states[transition.From] = true
+		states[transition.To] = true
+	}
+	if len(states) != 5 {
+		t.Errorf("Expected 5 DFA states, got %d: %v", len(states), states)
+	}
+
+	tests := map[string]bool{
+		"abb":    true,
+		"aabb":   true,
+		"babb":   true,
+		"ababb":  true,
+		"ab":     false,
+		"abab":   false,
+		"abba":   false,
+		"bbbbbb": false,
+	}
+	for input, expected := range tests {
+		dfa.Reset()
+		accepted, _, err := dfa.Run(word(input))
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", input, err)
+		}
+		if accepted != expected {
+			t.Errorf("Expected %q accepted=%v, got %v", input, expected, accepted)
+		}
+		if nfa.Accepts(word(input)) != expected {
+			t.Errorf("Expected NFA to agree on %q", input)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package fsm
+
+// DefaultTransitionBufferSize is the capacity of channels returned by Transitions unless changed
+const DefaultTransitionBufferSize = 64
+
+// OverflowPolicy decides what happens when a Transitions channel is full
+type OverflowPolicy int
+
+// Overflow policies for slow transition consumers
+const (
+	DropOldest OverflowPolicy = iota // Discard the oldest buffered result to make room, never stalling the machine
+	Block                            // Wait for the consumer, stalling the machine until the result is received
+)
+
+// String returns the name of the overflow policy
+func (p OverflowPolicy) String() string {
+	switch p {
+	case DropOldest:
+		return "DropOldest"
+	case Block:
+		return "Block"
+	default:
+		return "Unknown"
+	}
+}
+
+// Transitions returns a channel receiving every later transition attempt, successful or not
+// Each call creates an independent subscription; all of them are closed by Stop.
+// Under Block a consumer must keep receiving, since the machine holds its lock while it waits.
+func (sm *StateMachine) Transitions() <-chan TransitionResult {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	size := sm.transitionBuffer
+	if size <= 0 {
+		size = DefaultTransitionBufferSize
+	}
+	subscriber := make(chan TransitionResult, size)
+	sm.subscribers = append(sm.subscribers, subscriber)
+	return subscriber
+}
+
+// SetTransitionBuffer sets the capacity and overflow policy of channels returned by Transitions
+// The size applies to later subscriptions and the policy to all of them; a size of 0 or less
+// selects DefaultTransitionBufferSize
+func (sm *StateMachine) SetTransitionBuffer(size int, policy OverflowPolicy) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.transitionBuffer = size
+	sm.overflowPolicy = policy
+}
+
+// publishResultUnsafe delivers a transition result to every subscriber without acquiring locks
+func (sm *StateMachine) publishResultUnsafe(result TransitionResult) {
+	for _, subscriber := range sm.subscribers {
+		if sm.overflowPolicy == Block {
+			subscriber <- result
+			continue
+		}
+
+		select {
+		case subscriber <- result:
+		default:
+			// Only the machine sends, so after discarding the oldest there is room
+			select {
+			case <-subscriber:
+			default:
+			}
+			subscriber <- result
+		}
+	}
+}
+
+// closeSubscribersUnsafe closes and forgets every Transitions channel without acquiring locks
+func (sm *StateMachine) closeSubscribersUnsafe() {
+	for _, subscriber := range sm.subscribers {
+		close(subscriber)
+	}
+	sm.subscribers = nil
+}
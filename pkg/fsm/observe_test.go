@@ -0,0 +1,61 @@
+package fsm
+
+import "testing"
+
+// TestTransitionsChannel tests that subscribers see every attempt, overflow drops the oldest and Stop closes
+func TestTransitionsChannel(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("off", "toggle", "on").
+		AddTransition("on", "toggle", "off").
+		SetInitialState("off").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	all := machine.Transitions()
+	machine.(*StateMachine).SetTransitionBuffer(2, DropOldest)
+	recent := machine.Transitions()
+
+	machine.SendEvent("toggle")
+	machine.SendEvent("missing")
+	machine.SendEvent("toggle")
+	machine.SendEvent("toggle")
+	machine.Stop()
+
+	var received []TransitionResult
+	for result := range all {
+		received = append(received, result)
+	}
+	if len(received) != 3 || received[0].ToState != "on" || received[2].ToState != "on" {
+		t.Errorf("Expected 3 attempts in order, got %+v", received)
+	}
+
+	received = nil
+	for result := range recent {
+		received = append(received, result)
+	}
+	if len(received) != 2 || received[0].ToState != "off" || received[1].ToState != "on" {
+		t.Errorf("Expected only the 2 newest attempts, got %+v", received)
+	}
+}
+
+// TestTransitionsChannelReportsFailures tests that failed attempts are emitted alongside successes
+func TestTransitionsChannelReportsFailures(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransitionWithCondition("locked", "open", "open", func(context Context) bool { return false }).
+		SetInitialState("locked").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	transitions := machine.Transitions()
+	machine.(*StateMachine).SetTransitionBuffer(0, Block)
+	machine.SendEvent("open")
+
+	result := <-transitions
+	if result.Success || result.Error == nil || result.Event != "open" {
+		t.Errorf("Expected a failed attempt, got %+v", result)
+	}
+}
@@ -0,0 +1,92 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicPolicy decides what happens when a guard or action panics
+type PanicPolicy int
+
+// Panic policies
+const (
+	// PanicRecover turns the panic into a *PanicError returned from SendEvent. It suits servers,
+	// where one faulty callback shouldn't take down every other request
+	PanicRecover PanicPolicy = iota
+	// PanicPropagate panics again with the *PanicError once the error hooks have run. It suits
+	// libraries, tests and batch jobs that would rather fail fast than run on after a bug
+	PanicPropagate
+)
+
+// SetPanicPolicy sets how panicking guards and actions are handled; PanicRecover is the default
+func (sm *StateMachine) SetPanicPolicy(policy PanicPolicy) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.panicPolicy = policy
+}
+
+// propagatePanicUnsafe panics again with err if it is a *PanicError and the policy propagates panics
+// Callers run their error hooks first; guard checks without hooks call it straight away
+func (sm *StateMachine) propagatePanicUnsafe(err error) {
+	var panicErr *PanicError
+	if sm.panicPolicy == PanicPropagate && errors.As(err, &panicErr) {
+		panic(panicErr)
+	}
+}
+
+// PanicError reports a guard or action that panicked while a transition was attempted
+// Under PanicRecover a faulty callback fails its transition instead of the process
+type PanicError struct {
+	Phase string      // "guard" or "action"
+	From  State       // Source state of the transition
+	Event Event       // Event that triggered the transition, empty for auto transitions
+	To    State       // Target state of the transition
+	Value interface{} // Value passed to panic
+	Stack []byte      // Stack trace of the panicking goroutine
+}
+
+// Error describes the panic and the transition it happened in
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("fsm: %s for %s --%s--> %s panicked: %v", e.Phase, e.From, e.Event, e.To, e.Value)
+}
+
+// newPanicError captures a recovered panic along with the current stack
+func newPanicError(phase string, transition Transition, from State, value interface{}) *PanicError {
+	return &PanicError{
+		Phase: phase,
+		From:  from,
+		Event: transition.Event,
+		To:    transition.To,
+		Value: value,
+		Stack: debug.Stack(),
+	}
+}
+
+// guardPassesUnsafe evaluates a transition's guard against context, recovering a panic as a failed guard
+// The panic is logged and returned so SendEvent can report it; a nil guard always passes
+func (sm *StateMachine) guardPassesUnsafe(transition Transition, context Context) (passed bool, err error) {
+	if transition.Condition == nil {
+		return true, nil
+	}
+	defer func() {
+		if value := recover(); value != nil {
+			panicErr := newPanicError("guard", transition, transition.From, value)
+			sm.logger.Errorf("%v", panicErr)
+			passed, err = false, panicErr
+		}
+	}()
+	return transition.Condition(context), nil
+}
+
+// SetPanicPolicy sets how panicking guards and actions are handled
+func (b *FSMBuilder) SetPanicPolicy(policy PanicPolicy) Builder {
+	b.machine.SetPanicPolicy(policy) // Configure the policy on the machine being built
+	return b                         // Return builder to enable method chaining
+}
+
+// SetPanicPolicy sets how panicking guards and actions are handled
+func (b *BuilderWithHooks) SetPanicPolicy(policy PanicPolicy) *BuilderWithHooks {
+	b.FSMBuilder.SetPanicPolicy(policy)
+	return b
+}
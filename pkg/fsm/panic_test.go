@@ -0,0 +1,123 @@
+package fsm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestActionPanicRecovered tests that a panicking action fails its transition and keeps the state
+func TestActionPanicRecovered(t *testing.T) {
+	var hookErr error
+	machine, err := NewBuilderWithHooks().
+		AddTransitionWithAction("pending", "charge", "charged", func(from, to State, event Event, context Context) error {
+			_ = context.Get("amount").(float64) // Panics when amount is missing
+			return nil
+		}).
+		SetInitialState("pending").
+		AddOnTransitionErrorHook(func(result TransitionResult, context Context) {
+			hookErr = result.Error
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	result, err := machine.SendEvent("charge")
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Expected a PanicError, got %v", err)
+	}
+	if panicErr.Phase != "action" || panicErr.From != "pending" || panicErr.Event != "charge" {
+		t.Errorf("Expected the panic to name the charge action, got %+v", panicErr)
+	}
+	if !strings.Contains(err.Error(), "interface conversion") || len(panicErr.Stack) == 0 {
+		t.Errorf("Expected the recovered value and a stack, got %v", err)
+	}
+	if result == nil || result.Success || hookErr != err {
+		t.Errorf("Expected a failed result reported to error hooks, got %v and %v", result, hookErr)
+	}
+	if machine.CurrentState() != "pending" {
+		t.Errorf("Expected state pending to be kept, got %s", machine.CurrentState())
+	}
+
+	// Actions run on another goroutine under a timeout are recovered too
+	machine.(*StateMachine).SetActionTimeout(time.Second)
+	if _, err := machine.SendEvent("charge"); !errors.As(err, &panicErr) {
+		t.Errorf("Expected a PanicError under the action timeout, got %v", err)
+	}
+	machine.GetContext().Set("amount", 9.5)
+	if _, err := machine.SendEvent("charge"); err != nil || machine.CurrentState() != "charged" {
+		t.Errorf("Expected the charge to succeed once amount is set, got %v", err)
+	}
+}
+
+// TestGuardPanicRecovered tests that a panicking guard fails SendEvent and refuses CanTransition
+func TestGuardPanicRecovered(t *testing.T) {
+	var failures int
+	machine, err := NewBuilderWithHooks().
+		AddTransitionWithCondition("idle", "go", "busy", func(context Context) bool {
+			var limits map[string]int
+			limits["max"]++ // Writing to a nil map panics
+			return true
+		}).
+		SetInitialState("idle").
+		AddOnTransitionErrorHook(func(result TransitionResult, context Context) {
+			failures++
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	if machine.CanTransition("go") || len(machine.GetValidEvents()) != 0 {
+		t.Error("Expected a panicking guard to count as refused")
+	}
+
+	_, err = machine.SendEvent("go")
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Phase != "guard" {
+		t.Fatalf("Expected a guard PanicError, got %v", err)
+	}
+	if failures != 1 || machine.CurrentState() != "idle" {
+		t.Errorf("Expected one error hook call and state idle, got %d and %s", failures, machine.CurrentState())
+	}
+}
+
+// TestPanicPropagate tests that PanicPropagate re-panics after the error hooks ran
+func TestPanicPropagate(t *testing.T) {
+	var hookErr error
+	machine, err := NewBuilderWithHooks().
+		AddTransitionWithAction("idle", "crash", "done", func(from, to State, event Event, context Context) error {
+			panic("boom")
+		}).
+		AddTransition("idle", "finish", "done").
+		SetInitialState("idle").
+		SetPanicPolicy(PanicPropagate).
+		AddOnTransitionErrorHook(func(result TransitionResult, context Context) {
+			hookErr = result.Error
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	recovered := func() (value interface{}) {
+		defer func() { value = recover() }()
+		machine.SendEvent("crash")
+		return nil
+	}()
+	panicErr, ok := recovered.(*PanicError)
+	if !ok || panicErr.Value != "boom" {
+		t.Fatalf("Expected a re-panic with the PanicError, got %v", recovered)
+	}
+	if hookErr != panicErr {
+		t.Errorf("Expected the error hooks to run before the re-panic, got %v", hookErr)
+	}
+
+	// The lock was released while unwinding, so the machine is still usable
+	if _, err := machine.SendEvent("finish"); err != nil || machine.CurrentState() != "done" {
+		t.Errorf("Expected the machine to keep working, got %v", err)
+	}
+}
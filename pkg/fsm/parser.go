@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -72,6 +73,91 @@ func (nlp *NaturalLanguageParser) ParseDescription(description string) (*ConfigM
 	return config, nil
 }
 
+// ParseReport lists the parts of a description ParseDescription couldn't use or that look wrong
+type ParseReport struct {
+	UnrecognizedLines    []UnrecognizedLine `json:"unrecognized_lines"`    // Lines that aren't a state list, event list or transition
+	DuplicateTransitions []TransitionConfig `json:"duplicate_transitions"` // Transitions described more than once
+	UndeclaredStates     []string           `json:"undeclared_states"`     // States used by transitions but missing from a States: list
+}
+
+// UnrecognizedLine is a line of a description that couldn't be classified
+type UnrecognizedLine struct {
+	Line int    `json:"line"` // 1-based line number
+	Text string `json:"text"`
+}
+
+// HasIssues returns true if the report found anything worth showing to the user
+func (r *ParseReport) HasIssues() bool {
+	return len(r.UnrecognizedLines) > 0 || len(r.DuplicateTransitions) > 0 || len(r.UndeclaredStates) > 0
+}
+
+// declarationLine matches explicit "States: a, b" and "Events: x, y" lines
+var declarationLine = regexp.MustCompile(`(?i)^(states?|events?)\s*:\s*(.*)$`)
+
+// simpleTransitionLine matches lines consisting of nothing but "X -> Y"
+var simpleTransitionLine = regexp.MustCompile(`(?i)^([a-zA-Z0-9_]+)\s+(?:→|->|to)\s+([a-zA-Z0-9_]+)\s*\.?$`)
+
+// ParseDescriptionVerbose parses a description like ParseDescription and also reports
+// unrecognized lines, duplicate transitions and undeclared states
+// The report is judged line by line: each line must be a States:/Events: list, a
+// "from X to Y when Z" transition or consist of a single "X -> Y" transition
+func (nlp *NaturalLanguageParser) ParseDescriptionVerbose(description string) (*ConfigMachine, *ParseReport, error) {
+	config, err := nlp.ParseDescription(description)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &ParseReport{}
+	declared := make(map[string]bool)
+	var described []TransitionConfig
+	for i, line := range strings.Split(description, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if match := declarationLine.FindStringSubmatch(line); match != nil {
+			if strings.HasPrefix(strings.ToLower(match[1]), "state") {
+				for _, name := range strings.Split(match[2], ",") {
+					if name = strings.TrimSpace(name); name != "" {
+						declared[name] = true
+					}
+				}
+			}
+			continue
+		}
+		if match := nlp.transitionPatterns["transition"].FindStringSubmatch(line); match != nil {
+			described = append(described, TransitionConfig{From: match[1], Event: match[3], To: match[2]})
+			continue
+		}
+		if match := simpleTransitionLine.FindStringSubmatch(line); match != nil {
+			described = append(described, TransitionConfig{From: match[1], Event: "trigger", To: match[2]})
+			continue
+		}
+
+		report.UnrecognizedLines = append(report.UnrecognizedLines, UnrecognizedLine{Line: i + 1, Text: line})
+	}
+
+	seen := make(map[string]int)
+	undeclared := make(map[string]bool)
+	for _, transition := range described {
+		key := fmt.Sprintf("%s:%s:%s", transition.From, transition.Event, transition.To)
+		if seen[key]++; seen[key] == 2 {
+			report.DuplicateTransitions = append(report.DuplicateTransitions, transition)
+		}
+
+		for _, state := range []string{transition.From, transition.To} {
+			if len(declared) > 0 && !declared[state] && !undeclared[state] {
+				undeclared[state] = true
+				report.UndeclaredStates = append(report.UndeclaredStates, state)
+			}
+		}
+	}
+	sort.Strings(report.UndeclaredStates)
+
+	return config, report, nil
+}
+
 // extractStates finds all states mentioned in the description
 func (nlp *NaturalLanguageParser) extractStates(description string) ([]StateConfig, error) {
 	stateSet := make(map[string]bool)
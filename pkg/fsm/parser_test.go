@@ -0,0 +1,48 @@
+package fsm
+
+import "testing"
+
+// TestParseDescriptionVerbose tests reporting typos, duplicates and undeclared states
+func TestParseDescriptionVerbose(t *testing.T) {
+	description := `States: idle, running
+Events: start, stop
+
+from idle to running when start
+Form running to idle when stop
+from running to done when stop
+from idle to running when start
+running -> idle`
+
+	config, report, err := NewNaturalLanguageParser().ParseDescriptionVerbose(description)
+	if err != nil {
+		t.Fatalf("Failed to parse description: %v", err)
+	}
+	if config == nil {
+		t.Fatal("Expected a configuration alongside the report")
+	}
+
+	if len(report.UnrecognizedLines) != 1 {
+		t.Fatalf("Expected 1 unrecognized line, got %v", report.UnrecognizedLines)
+	}
+	if line := report.UnrecognizedLines[0]; line.Line != 5 || line.Text != "Form running to idle when stop" {
+		t.Errorf("Expected line 5 to be unrecognized, got %+v", line)
+	}
+
+	if len(report.DuplicateTransitions) != 1 || report.DuplicateTransitions[0].Event != "start" {
+		t.Errorf("Expected the start transition to be reported as duplicate, got %v", report.DuplicateTransitions)
+	}
+	if len(report.UndeclaredStates) != 1 || report.UndeclaredStates[0] != "done" {
+		t.Errorf("Expected 'done' to be undeclared, got %v", report.UndeclaredStates)
+	}
+	if !report.HasIssues() {
+		t.Error("Expected the report to have issues")
+	}
+
+	_, report, err = NewNaturalLanguageParser().ParseDescriptionVerbose("from idle to running when start")
+	if err != nil {
+		t.Fatalf("Failed to parse description: %v", err)
+	}
+	if report.HasIssues() {
+		t.Errorf("Expected a clean report, got %+v", report)
+	}
+}
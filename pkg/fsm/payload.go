@@ -0,0 +1,76 @@
+package fsm
+
+import (
+	"context"
+	"strings"
+)
+
+// EventPayloadKey is the reserved context key under which guards, actions and hooks find the
+// payload of an event sent with SendEventWithPayload
+// While such an event is processed, a context value stored under this key, or under a
+// "payload." prefix, is hidden behind the payload; it is left untouched otherwise
+const EventPayloadKey = "payload"
+
+// payloadContext exposes an event payload on top of the machine's context for one transition
+// Reads of EventPayloadKey, or of "payload.<name>" for a single value, are answered from the
+// payload; everything else, including every write, goes to the machine's context
+type payloadContext struct {
+	Context
+	payload map[string]interface{}
+}
+
+// Get returns the payload, one of its values, or the machine context's value for key
+func (c *payloadContext) Get(key string) interface{} {
+	if key == EventPayloadKey {
+		return c.payload
+	}
+	if name, ok := strings.CutPrefix(key, EventPayloadKey+"."); ok {
+		if value, exists := c.payload[name]; exists {
+			return value
+		}
+	}
+	return c.Context.Get(key)
+}
+
+// GetAll returns the machine context's values plus the payload under EventPayloadKey
+func (c *payloadContext) GetAll() map[string]interface{} {
+	values := c.Context.GetAll()
+	values[EventPayloadKey] = c.payload
+	return values
+}
+
+// Child returns a scoped context that reads through to this one, payload included
+func (c *payloadContext) Child() Context {
+	return NewScopedContext(c)
+}
+
+// storedContext returns the context that holds the machine's own values, without an event payload
+// Snapshots and persisted state are built from it so a payload never outlives its transition
+func storedContext(context Context) Context {
+	if payload, ok := context.(*payloadContext); ok {
+		return payload.Context
+	}
+	return context
+}
+
+// EventPayload returns the payload of the event being processed, or nil outside SendEventWithPayload
+func EventPayload(context Context) map[string]interface{} {
+	payload, _ := context.Get(EventPayloadKey).(map[string]interface{})
+	return payload
+}
+
+// SendEventWithPayload triggers an event whose guard, action and hooks can read payload
+// The payload is available through EventPayload or as "payload.<name>" (also in guard expressions)
+// for this transition only; it is never stored in the machine's context
+func (sm *StateMachine) SendEventWithPayload(event Event, payload map[string]interface{}) (*TransitionResult, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	machineContext := sm.context
+	sm.context = &payloadContext{Context: machineContext, payload: payload}
+	defer func() {
+		sm.context = machineContext
+	}()
+
+	return sm.sendEventUnsafe(context.Background(), event)
+}
@@ -0,0 +1,83 @@
+package fsm
+
+import "testing"
+
+// TestSendEventWithPayload tests that guards and actions see the payload only for their own transition
+func TestSendEventWithPayload(t *testing.T) {
+	guard, err := CompileCondition("payload.amount > 10")
+	if err != nil {
+		t.Fatalf("Failed to compile guard: %v", err)
+	}
+	var charged interface{}
+	machine, err := NewBuilder().
+		AddTransitionFull("cart", "pay", "paid", guard, func(from, to State, event Event, context Context) error {
+			charged = EventPayload(context)["amount"]
+			context.Set("charged", true)
+			return nil
+		}).
+		SetInitialState("cart").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	if _, err := machine.SendEventWithPayload("pay", map[string]interface{}{"amount": 5}); err == nil {
+		t.Fatal("Expected the guard to reject a payload amount of 5")
+	}
+	if _, err := machine.SendEvent("pay"); err == nil {
+		t.Fatal("Expected the guard to reject an event without a payload")
+	}
+	if _, err := machine.SendEventWithPayload("pay", map[string]interface{}{"amount": 25}); err != nil {
+		t.Fatalf("Failed to send pay with payload: %v", err)
+	}
+
+	if charged != 25 {
+		t.Errorf("Expected the action to read amount 25, got %v", charged)
+	}
+	if machine.GetContext().Get("charged") != true {
+		t.Error("Expected writes made by the action to reach the machine's context")
+	}
+	if machine.GetContext().Get(EventPayloadKey) != nil {
+		t.Error("Expected the payload not to be stored in the machine's context")
+	}
+}
+
+// TestPayloadNotPersisted tests that persisted snapshots never capture an event payload
+func TestPayloadNotPersisted(t *testing.T) {
+	for _, readOnlyHooks := range []bool{false, true} {
+		machine, err := NewBuilder().
+			AddTransition("cart", "pay", "paid").
+			AddTransition("paid", "refund", "cart").
+			SetInitialState("cart").
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build FSM: %v", err)
+		}
+		machine.(*StateMachine).UseReadOnlyHookContext(readOnlyHooks)
+		machine.GetContext().Set("customer", "c-1")
+
+		store := NewMemorySnapshotStore()
+		persister := Persist("order", machine, store)
+		if _, err := machine.SendEventWithPayload("pay", map[string]interface{}{"amount": 5}); err != nil {
+			t.Fatalf("Failed to send pay with payload: %v", err)
+		}
+		persister.Flush()
+
+		snapshot, _, _ := store.Load("order")
+		if _, leaked := snapshot.Context[EventPayloadKey]; leaked || snapshot.Context["customer"] != "c-1" {
+			t.Errorf("Expected only the machine's values in the snapshot, got %v", snapshot.Context)
+		}
+
+		if err := machine.Restore(snapshot); err != nil {
+			t.Fatalf("Failed to restore snapshot: %v", err)
+		}
+		var seen map[string]interface{}
+		machine.AddHook(AfterTransition, func(result TransitionResult, context Context) {
+			seen = EventPayload(context)
+		})
+		machine.SendEvent("refund")
+		if seen != nil {
+			t.Errorf("Expected no payload after restoring, got %v", seen)
+		}
+	}
+}
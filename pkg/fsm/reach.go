@@ -0,0 +1,68 @@
+package fsm
+
+// ReachGuardMode controls how CanReach treats guard conditions
+type ReachGuardMode int
+
+const (
+	// ReachEvaluateGuards evaluates guards against a read-only snapshot of the current context
+	// Writes made by guards are dropped, so the check has no side effects on the machine
+	ReachEvaluateGuards ReachGuardMode = iota
+	// ReachAssumeGuards treats every guard as passable, for guards that can't run outside
+	// a real transition or whose outcome depends on context set along the way
+	ReachAssumeGuards
+)
+
+// SetReachGuardMode changes how CanReach treats guards; ReachEvaluateGuards is the default
+func (sm *StateMachine) SetReachGuardMode(mode ReachGuardMode) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.reachGuardMode = mode
+}
+
+// CanReach reports whether target can be reached from the current state in any number of steps
+// Unlike CanTransition it searches the whole graph, following auto transitions too. Every guard
+// is judged against the current context, since actions along the way can't be predicted, and the
+// routes to error states taken when actions fail are not followed. A stopped machine reaches nothing
+func (sm *StateMachine) CanReach(target State) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if !sm.running || !sm.states[target] {
+		return false
+	}
+
+	var snapshot Context
+	if sm.reachGuardMode == ReachEvaluateGuards {
+		snapshot = NewReadOnlyContext(sm.context, sm.logger)
+	}
+	passes := func(transition Transition) bool {
+		if snapshot == nil {
+			return true
+		}
+		passed, err := sm.guardPassesUnsafe(transition, snapshot)
+		sm.propagatePanicUnsafe(err)
+		return passed
+	}
+
+	visited := map[State]bool{sm.currentState: true}
+	queue := []State{sm.currentState}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		if state == target {
+			return true
+		}
+
+		candidates := append([]Transition(nil), sm.autoTransitions[state]...)
+		for _, event := range sm.outgoing[state] {
+			candidates = append(candidates, sm.transitions[transitionKey(state, event)]...)
+		}
+		for _, transition := range candidates {
+			if !visited[transition.To] && passes(transition) {
+				visited[transition.To] = true
+				queue = append(queue, transition.To)
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package fsm
+
+import "testing"
+
+// TestCanReach tests guard-respecting reachability from the current state
+func TestCanReach(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("draft", "submit", "review").
+		AddTransitionWithCondition("review", "approve", "published", func(context Context) bool {
+			context.Set("probed", true) // Must not leak out of CanReach
+			return context.Get("role") == "editor"
+		}).
+		AddTransition("review", "reject", "draft").
+		AddAutoTransition("published", "archived", func(context Context) bool {
+			return context.Get("expired") == true
+		}).
+		AddStates("orphan").
+		SetInitialState("draft").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	if !machine.CanReach("draft") || !machine.CanReach("review") {
+		t.Error("Expected the current state and unguarded successors to be reachable")
+	}
+	if machine.CanReach("published") {
+		t.Error("Expected published to be unreachable without the editor role")
+	}
+	if machine.CanReach("orphan") || machine.CanReach("missing") {
+		t.Error("Expected isolated and undefined states to be unreachable")
+	}
+	if machine.GetContext().Get("probed") != nil {
+		t.Error("Expected guard writes to be dropped")
+	}
+
+	machine.GetContext().Set("role", "editor")
+	if !machine.CanReach("published") || machine.CanReach("archived") {
+		t.Error("Expected published but not archived to be reachable for an editor")
+	}
+	machine.GetContext().Set("expired", true)
+	if !machine.CanReach("archived") {
+		t.Error("Expected archived to be reachable through the auto transition")
+	}
+
+	// Assuming guards pass gives structural reachability
+	machine.GetContext().Set("role", "author")
+	machine.(*StateMachine).SetReachGuardMode(ReachAssumeGuards)
+	if !machine.CanReach("published") {
+		t.Error("Expected published to be reachable when guards are assumed to pass")
+	}
+
+	machine.Stop()
+	if machine.CanReach("draft") {
+		t.Error("Expected a stopped machine to reach nothing")
+	}
+}
@@ -0,0 +1,68 @@
+package fsm
+
+// ReadOnlyContext is an immutable snapshot of a Context handed to observing hooks
+// Writes are dropped and logged, so an observer can't change machine state mid-transition
+type ReadOnlyContext struct {
+	data   map[string]interface{} // Values as Get returned them
+	all    map[string]interface{} // Values as GetAll returned them
+	logger Logger
+}
+
+// NewReadOnlyContext captures the current values of context, logging rejected writes to logger
+// A nil logger discards them. Get and GetAll answer like the captured context did, so a
+// SecureContext's sensitive values read in plaintext but GetAll keeps them encrypted
+func NewReadOnlyContext(context Context, logger Logger) *ReadOnlyContext {
+	if logger == nil {
+		logger = NopLogger{}
+	}
+	return &ReadOnlyContext{data: plainValues(context), all: context.GetAll(), logger: logger}
+}
+
+// Get returns the captured value for key, or nil if it wasn't set
+func (c *ReadOnlyContext) Get(key string) interface{} {
+	return c.data[key]
+}
+
+// Set ignores the write and logs it
+func (c *ReadOnlyContext) Set(key string, value interface{}) {
+	c.logger.Errorf("fsm: ignored write of %q to a read-only context", key)
+}
+
+// GetAll returns a copy of the captured values
+func (c *ReadOnlyContext) GetAll() map[string]interface{} {
+	result := make(map[string]interface{}, len(c.all))
+	for key, value := range c.all {
+		result[key] = value
+	}
+	return result
+}
+
+// Child returns a writable scope over the snapshot; its writes never reach the machine
+func (c *ReadOnlyContext) Child() Context {
+	return NewScopedContext(c)
+}
+
+// Root returns the snapshot itself
+func (c *ReadOnlyContext) Root() Context {
+	return c
+}
+
+// UseReadOnlyHookContext selects whether observing hooks receive a read-only snapshot of the context
+// BeforeTransition hooks keep the live context so they can still prepare data for the action
+func (sm *StateMachine) UseReadOnlyHookContext(enabled bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.readOnlyHookContext = enabled
+}
+
+// hookContextUnsafe returns the context hooks of a given type receive without acquiring locks
+func (sm *StateMachine) hookContextUnsafe(hookType HookType) Context {
+	if sm.readOnlyHookContext && hookType != BeforeTransition {
+		if payload, ok := sm.context.(*payloadContext); ok {
+			// Keep the payload apart from the values, so hooks can't persist it as state
+			return &payloadContext{Context: NewReadOnlyContext(payload.Context, sm.logger), payload: payload.payload}
+		}
+		return NewReadOnlyContext(sm.context, sm.logger)
+	}
+	return sm.context
+}
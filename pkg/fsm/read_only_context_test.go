@@ -0,0 +1,75 @@
+package fsm
+
+import "testing"
+
+// TestReadOnlyHookContext tests that observing hooks can't mutate the machine's context
+func TestReadOnlyHookContext(t *testing.T) {
+	machine, err := NewBuilderWithHooks().
+		AddTransition("idle", "start", "running").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	machine.(*StateMachine).UseReadOnlyHookContext(true)
+	machine.GetContext().Set("count", 1)
+
+	var seen interface{}
+	machine.AddHook(BeforeTransition, func(result TransitionResult, context Context) {
+		context.Set("prepared", true)
+	})
+	machine.AddHook(AfterTransition, func(result TransitionResult, context Context) {
+		seen = context.Get("count")
+		context.Set("count", 99)
+		context.Set("observer", true)
+	})
+
+	if _, err := machine.SendEvent("start"); err != nil {
+		t.Fatalf("Failed to send start: %v", err)
+	}
+
+	context := machine.GetContext()
+	if seen != 1 {
+		t.Errorf("Expected the hook to read 1, got %v", seen)
+	}
+	if value := context.Get("count"); value != 1 {
+		t.Errorf("Expected the observer's write to be ignored, got %v", value)
+	}
+	if value := context.Get("observer"); value != nil {
+		t.Errorf("Expected no new keys from the observer, got %v", value)
+	}
+	if value := context.Get("prepared"); value != true {
+		t.Errorf("Expected BeforeTransition hooks to keep the live context, got %v", value)
+	}
+}
+
+// TestReadOnlyHookContextSecure tests that observing hooks read a SecureContext's values in plaintext
+func TestReadOnlyHookContextSecure(t *testing.T) {
+	machine, err := NewBuilderWithHooks().
+		AddTransition("idle", "start", "running").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	secure := NewSecureContext(NewContextEncryptor(StaticKey([]byte("0123456789abcdef")), "token"))
+	secure.Set("token", "tok_123")
+	machine.SetContext(secure)
+	machine.(*StateMachine).UseReadOnlyHookContext(true)
+
+	var seen, all interface{}
+	machine.AddHook(AfterTransition, func(result TransitionResult, context Context) {
+		seen = context.Get("token")
+		all = context.GetAll()["token"]
+	})
+
+	if _, err := machine.SendEvent("start"); err != nil {
+		t.Fatalf("Failed to send start: %v", err)
+	}
+	if seen != "tok_123" {
+		t.Errorf("Expected the hook to read the plaintext token, got %v", seen)
+	}
+	if !isEncryptedValue(all) {
+		t.Errorf("Expected GetAll to keep the token encrypted like the live context, got %v", all)
+	}
+}
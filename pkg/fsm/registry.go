@@ -0,0 +1,157 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registry keeps a set of named machines and drives their lifecycle together
+// Lookups and registration are safe for concurrent use; machines are called without
+// the registry's lock held, so their hooks may use the registry themselves
+type Registry struct {
+	mu       sync.RWMutex
+	machines map[string]Machine
+	index    *StateIndex // Registered machines by current state
+}
+
+// NewRegistry creates an empty machine registry
+func NewRegistry() *Registry {
+	return &Registry{machines: make(map[string]Machine), index: NewStateIndex()}
+}
+
+// Register adds a machine under name, failing if the name is empty or already taken
+func (r *Registry) Register(name string, machine Machine) error {
+	if name == "" || machine == nil {
+		return FSMError{Type: "InvalidMachine", Message: "Machine name and machine cannot be empty"}
+	}
+
+	r.mu.Lock()
+	if _, exists := r.machines[name]; exists {
+		r.mu.Unlock()
+		return FSMError{Type: "DuplicateMachine", Message: fmt.Sprintf("Machine '%s' is already registered", name)}
+	}
+	r.machines[name] = machine
+	r.mu.Unlock()
+
+	r.index.Track(name, machine)
+	return nil
+}
+
+// Unregister removes the named machine, reporting whether it was registered
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, exists := r.machines[name]
+	delete(r.machines, name)
+	if exists {
+		r.index.Untrack(name)
+	}
+	return exists
+}
+
+// Get returns the named machine and whether it is registered
+func (r *Registry) Get(name string) (Machine, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	machine, exists := r.machines[name]
+	return machine, exists
+}
+
+// Names returns the names of all registered machines in sorted order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.machines))
+	for name := range r.machines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MachinesInState returns the sorted names of registered machines currently in the given state
+// The answer comes from a StateIndex kept current by hooks, so no machine is queried
+func (r *Registry) MachinesInState(state State) []string {
+	return r.index.MachinesInState(state)
+}
+
+// StartAll starts every stopped machine in its initial state
+// Every machine is attempted; failures are joined into the returned error, each naming its machine
+func (r *Registry) StartAll() error {
+	var errs []error
+	for _, entry := range r.snapshot() {
+		if entry.machine.IsRunning() {
+			continue
+		}
+		if err := entry.machine.Start(entry.machine.InitialState()); err != nil {
+			errs = append(errs, fmt.Errorf("machine '%s': %w", entry.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StopAll stops every running machine, joining any failures into the returned error
+func (r *Registry) StopAll() error {
+	var errs []error
+	for _, entry := range r.snapshot() {
+		if !entry.machine.IsRunning() {
+			continue
+		}
+		if err := entry.machine.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("machine '%s': %w", entry.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Broadcast sends event to every registered machine that can currently handle it
+// Machines where CanTransition refuses the event are skipped, and so are machines that move to
+// another state between the check and the send. The results of the machines that were sent the
+// event are returned by name; failures of those sends, such as a failing action, are joined into the error
+func (r *Registry) Broadcast(event Event) (map[string]*TransitionResult, error) {
+	results := make(map[string]*TransitionResult)
+	var errs []error
+	for _, entry := range r.snapshot() {
+		state := entry.machine.CurrentState()
+		if !entry.machine.CanTransition(event) {
+			continue
+		}
+		result, err := entry.machine.SendEventIfInState(state, event)
+		if errors.Is(err, ErrStateMismatch) {
+			continue // Moved on since the check, so the event may no longer apply
+		}
+		if result != nil {
+			results[entry.name] = result
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("machine '%s': %w", entry.name, err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// registryEntry is a registered machine paired with its name
+type registryEntry struct {
+	name    string
+	machine Machine
+}
+
+// snapshot returns the registered machines sorted by name, so they can be called without the lock
+func (r *Registry) snapshot() []registryEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]registryEntry, 0, len(r.machines))
+	for name, machine := range r.machines {
+		entries = append(entries, registryEntry{name: name, machine: machine})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	return entries
+}
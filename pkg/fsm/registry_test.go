@@ -0,0 +1,142 @@
+package fsm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// buildOrderMachine builds a machine that can only be paid while pending
+func buildOrderMachine(t *testing.T, initial State) Machine {
+	machine, err := NewBuilder().
+		AddTransition("pending", "pay", "paid").
+		AddTransition("paid", "ship", "shipped").
+		SetInitialState(initial).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	return machine
+}
+
+// TestRegistryBroadcast tests that Broadcast skips machines where the event is invalid
+func TestRegistryBroadcast(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("order-2", buildOrderMachine(t, "pending"))
+	registry.Register("order-1", buildOrderMachine(t, "pending"))
+	registry.Register("order-3", buildOrderMachine(t, "paid"))
+
+	results, err := registry.Broadcast("pay")
+	if err != nil {
+		t.Fatalf("Expected no errors from skipped machines, got %v", err)
+	}
+	if len(results) != 2 || results["order-1"] == nil || results["order-2"] == nil {
+		t.Errorf("Expected results for order-1 and order-2 only, got %v", results)
+	}
+	for _, name := range []string{"order-1", "order-2", "order-3"} {
+		if machine, _ := registry.Get(name); machine.CurrentState() != "paid" {
+			t.Errorf("Expected %s to be paid, got %s", name, machine.CurrentState())
+		}
+	}
+
+	if results, err := registry.Broadcast("refund"); err != nil || len(results) != 0 {
+		t.Errorf("Expected an unknown event to reach no machine, got %v and %v", results, err)
+	}
+}
+
+// TestRegistryLifecycle tests registration, lookup and starting and stopping every machine
+func TestRegistryLifecycle(t *testing.T) {
+	registry := NewRegistry()
+	order := buildOrderMachine(t, "pending")
+	if err := registry.Register("order", order); err != nil {
+		t.Fatalf("Failed to register machine: %v", err)
+	}
+	registry.Register("invoice", buildOrderMachine(t, "paid"))
+
+	if err := registry.Register("order", order); !errors.Is(err, FSMError{Type: "DuplicateMachine"}) {
+		t.Errorf("Expected a duplicate name to be refused, got %v", err)
+	}
+	if err := registry.Register("", order); err == nil {
+		t.Error("Expected an empty name to be refused")
+	}
+	if names := registry.Names(); !reflect.DeepEqual(names, []string{"invoice", "order"}) {
+		t.Errorf("Expected sorted names, got %v", names)
+	}
+	if _, exists := registry.Get("missing"); exists {
+		t.Error("Expected no machine under an unregistered name")
+	}
+
+	if err := registry.StopAll(); err != nil || order.IsRunning() {
+		t.Fatalf("Expected every machine to stop, got %v", err)
+	}
+	if err := registry.StartAll(); err != nil {
+		t.Fatalf("Expected every machine to start, got %v", err)
+	}
+	if invoice, _ := registry.Get("invoice"); !order.IsRunning() || invoice.CurrentState() != "paid" {
+		t.Errorf("Expected machines restarted in their initial states, got %s", invoice.CurrentState())
+	}
+
+	if !registry.Unregister("order") || registry.Unregister("order") {
+		t.Error("Expected Unregister to report whether the machine was registered")
+	}
+}
+
+// TestRegistryMachinesInState tests finding registered machines by their current state
+func TestRegistryMachinesInState(t *testing.T) {
+	registry := NewRegistry()
+	order := buildOrderMachine(t, "pending")
+	registry.Register("order-2", order)
+	registry.Register("order-1", buildOrderMachine(t, "pending"))
+	registry.Register("order-3", buildOrderMachine(t, "paid"))
+
+	if names := registry.MachinesInState("pending"); !reflect.DeepEqual(names, []string{"order-1", "order-2"}) {
+		t.Errorf("Expected order-1 and order-2 pending, got %v", names)
+	}
+
+	order.SendEvent("pay")
+	if names := registry.MachinesInState("paid"); !reflect.DeepEqual(names, []string{"order-2", "order-3"}) {
+		t.Errorf("Expected order-2 and order-3 paid, got %v", names)
+	}
+
+	// An unregistered machine no longer shows up, even after a later transition
+	registry.Unregister("order-2")
+	order.SendEvent("ship")
+	if names := registry.MachinesInState("shipped"); len(names) != 0 {
+		t.Errorf("Expected no shipped machines after unregistering, got %v", names)
+	}
+
+	// Nor does it move a new machine registered under its name
+	registry.Register("order-2", buildOrderMachine(t, "pending"))
+	order.Reset()
+	if names := registry.MachinesInState("pending"); !reflect.DeepEqual(names, []string{"order-1", "order-2"}) {
+		t.Errorf("Expected order-1 and the new order-2 pending, got %v", names)
+	}
+}
+
+// racingMachine moves its machine on as soon as CanTransition has been answered
+type racingMachine struct {
+	Machine
+	move Event
+}
+
+func (m racingMachine) CanTransition(event Event) bool {
+	allowed := m.Machine.CanTransition(event)
+	m.Machine.SendEvent(m.move) // Another goroutine's transition landing between the check and the send
+	return allowed
+}
+
+// TestRegistryBroadcastSkipsMovedMachine tests that a machine that moved after the check is skipped
+func TestRegistryBroadcastSkipsMovedMachine(t *testing.T) {
+	registry := NewRegistry()
+	moved := buildOrderMachine(t, "pending")
+	moved.AddTransition(Transition{From: "pending", Event: "ship", To: "shipped"})
+	registry.Register("order", racingMachine{Machine: moved, move: "ship"})
+
+	results, err := registry.Broadcast("pay")
+	if err != nil || len(results) != 0 {
+		t.Errorf("Expected the moved machine to be skipped without an error, got %v and %v", results, err)
+	}
+	if moved.CurrentState() != "shipped" {
+		t.Errorf("Expected the racing transition to stand, got %s", moved.CurrentState())
+	}
+}
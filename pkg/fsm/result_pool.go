@@ -0,0 +1,40 @@
+package fsm
+
+// SetResultPooling makes SendEvent reuse results handed back through ReleaseResult
+// Pooling saves one allocation per event for callers that release every result once they are done
+// with it; a released result may be overwritten by a later event, so it must not be read again.
+// Hooks, traces and Transitions channels receive copies and are unaffected; off by default.
+func (sm *StateMachine) SetResultPooling(enabled bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.pooling = enabled
+}
+
+// ReleaseResult hands a result returned by SendEvent back for reuse when pooling is enabled
+// Releasing nil, or any result while pooling is disabled, does nothing
+func (sm *StateMachine) ReleaseResult(result *TransitionResult) {
+	if result == nil {
+		return
+	}
+
+	sm.mu.RLock()
+	pooling := sm.pooling
+	sm.mu.RUnlock()
+
+	if pooling {
+		sm.resultPool.Put(result)
+	}
+}
+
+// newResultUnsafe returns a result holding value, reusing a released one if pooling is enabled
+func (sm *StateMachine) newResultUnsafe(value TransitionResult) *TransitionResult {
+	if sm.pooling {
+		if result, ok := sm.resultPool.Get().(*TransitionResult); ok {
+			*result = value
+			return result
+		}
+	}
+	result := new(TransitionResult) // Copying keeps value itself from escaping on the pooled path
+	*result = value
+	return result
+}
@@ -0,0 +1,40 @@
+package fsm
+
+import "testing"
+
+// TestResultPooling tests that released results are reused only when pooling is enabled
+func TestResultPooling(t *testing.T) {
+	built, err := NewBuilder().
+		AddTransition("off", "toggle", "on").
+		AddTransition("on", "toggle", "off").
+		SetInitialState("off").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	machine := built.(*StateMachine) // Pooling is a *StateMachine knob
+	transitions := machine.Transitions()
+
+	first, _ := machine.SendEvent("toggle")
+	machine.ReleaseResult(first)
+	second, _ := machine.SendEvent("toggle")
+	if first == second || first.ToState != "on" {
+		t.Errorf("Expected independent results without pooling, got %+v and %+v", first, second)
+	}
+
+	machine.SetResultPooling(true)
+	machine.ReleaseResult(second)
+	third, _ := machine.SendEvent("toggle")
+	if third.ToState != "on" || third.FromState != "off" {
+		t.Errorf("Expected a fully overwritten result, got %+v", third)
+	}
+
+	machine.Stop()
+	var observed []TransitionResult
+	for result := range transitions {
+		observed = append(observed, result)
+	}
+	if len(observed) != 3 || observed[1].ToState != "off" || observed[2].ToState != "on" {
+		t.Errorf("Expected observers to keep their own copies, got %+v", observed)
+	}
+}
@@ -0,0 +1,148 @@
+package fsm
+
+import (
+	"sync"
+	"time"
+)
+
+// RetryExhaustedEvent returns the event a retry policy sends once every attempt of event has failed
+// Add a transition on it from the retried state to route the machine to a failure state
+func RetryExhaustedEvent(event Event) Event {
+	return Event(string(event) + "_exhausted")
+}
+
+// RetryPolicy re-sends a failed event after a backoff until it succeeds or maxAttempts is reached
+// A refused guard and an action error both count as failed attempts; the first attempt is the
+// caller's own SendEvent. Retries are sent from a timer since hooks run under the machine's lock
+type RetryPolicy struct {
+	mu          sync.Mutex
+	maxAttempts int
+	backoff     time.Duration
+	machine     Machine
+	from        State
+	event       Event
+	attempts    int
+	exhausted   uint64
+	timer       *time.Timer
+	generation  uint64
+}
+
+// NewRetryPolicy creates a policy allowing maxAttempts attempts spaced by backoff
+func NewRetryPolicy(maxAttempts int, backoff time.Duration) *RetryPolicy {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &RetryPolicy{
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+	}
+}
+
+// Attempts returns how many attempts of the current sequence have failed
+func (p *RetryPolicy) Attempts() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.attempts
+}
+
+// Exhausted returns how many attempt sequences ran out of attempts
+func (p *RetryPolicy) Exhausted() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.exhausted
+}
+
+// Attach registers hooks on a machine that retry the from/event transition
+func (p *RetryPolicy) Attach(machine Machine, from State, event Event) {
+	p.mu.Lock()
+	p.machine = machine
+	p.from = from
+	p.event = event
+	p.mu.Unlock()
+
+	AddBoundHook(machine, OnTransitionError, func(result TransitionResult, context Context) {
+		if result.FromState == from && result.Event == event {
+			p.recordFailure()
+		}
+	})
+
+	AddBoundHook(machine, AfterTransition, func(result TransitionResult, context Context) {
+		if result.FromState == from && result.Event == event {
+			p.reset()
+		}
+	})
+}
+
+// recordFailure counts a failed attempt and schedules either a retry or the exhausted event
+func (p *RetryPolicy) recordFailure() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.generation++
+	generation := p.generation
+	p.attempts++
+
+	if p.attempts >= p.maxAttempts {
+		p.attempts = 0
+		p.exhausted++
+		p.timer = time.AfterFunc(0, func() {
+			p.fire(generation, RetryExhaustedEvent(p.event))
+		})
+		return
+	}
+
+	p.timer = time.AfterFunc(p.backoff, func() {
+		p.fire(generation, p.event)
+	})
+}
+
+// reset clears the attempt count after a successful transition
+func (p *RetryPolicy) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.generation++
+	p.attempts = 0
+}
+
+// fire sends a scheduled event unless it was superseded or the machine left the retried state
+func (p *RetryPolicy) fire(generation uint64, event Event) {
+	p.mu.Lock()
+	if generation != p.generation {
+		p.mu.Unlock()
+		return
+	}
+	p.timer = nil
+	machine := p.machine
+	p.mu.Unlock()
+
+	if machine.CurrentState() != p.from {
+		p.reset() // The machine moved on, so the sequence is abandoned
+		return
+	}
+	if event != p.event && !machine.CanTransition(event) {
+		return // No failure route was configured
+	}
+	machine.SendEvent(event)
+}
+
+// AddRetryPolicy adds a guarded transition whose failed attempts are retried by policy
+// Routing to a failure state is configured with a transition on RetryExhaustedEvent(event)
+func (b *BuilderWithHooks) AddRetryPolicy(from State, event Event, to State, condition TransitionCondition, policy *RetryPolicy) *BuilderWithHooks {
+	b.AddTransitionWithCondition(from, event, to, condition)
+	b.FSMBuilder.AddEvent(RetryExhaustedEvent(event))
+	policy.Attach(b.machine, from, event)
+	return b
+}
+
+// RetryTransition adds a guarded transition retried up to maxAttempts times, backoff apart
+func (b *BuilderWithHooks) RetryTransition(from State, event Event, to State, condition TransitionCondition, maxAttempts int, backoff time.Duration) *BuilderWithHooks {
+	return b.AddRetryPolicy(from, event, to, condition, NewRetryPolicy(maxAttempts, backoff))
+}
@@ -0,0 +1,50 @@
+package fsm
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRetryTransition tests that a flaky guard is retried until it passes or the attempts run out
+func TestRetryTransition(t *testing.T) {
+	build := func(failures int32) (Machine, <-chan TransitionResult) {
+		var calls int32
+		flaky := func(context Context) bool {
+			return atomic.AddInt32(&calls, 1) > failures
+		}
+		machine, err := NewBuilderWithHooks().
+			RetryTransition("processing", "process_payment", "paid", flaky, 3, time.Millisecond).
+			AddTransition("processing", RetryExhaustedEvent("process_payment"), "payment_failed").
+			SetInitialState("processing").
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build FSM: %v", err)
+		}
+		return machine, machine.Transitions()
+	}
+	waitFor := func(machine Machine, transitions <-chan TransitionResult, state State) {
+		timeout := time.After(time.Second)
+		for machine.CurrentState() != state {
+			select {
+			case <-transitions:
+			case <-timeout:
+				t.Fatalf("Expected state %s, got %s", state, machine.CurrentState())
+			}
+		}
+	}
+
+	// Two failed guards are retried and the third attempt succeeds
+	machine, transitions := build(2)
+	if _, err := machine.SendEvent("process_payment"); err == nil {
+		t.Fatal("Expected the first attempt to be refused by the guard")
+	}
+	waitFor(machine, transitions, "paid")
+
+	// A guard that keeps failing routes the machine to the failure state
+	machine, transitions = build(3)
+	if _, err := machine.SendEvent("process_payment"); err == nil {
+		t.Fatal("Expected the first attempt to be refused by the guard")
+	}
+	waitFor(machine, transitions, "payment_failed")
+}
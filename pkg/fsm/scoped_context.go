@@ -0,0 +1,104 @@
+package fsm
+
+import "sync"
+
+// HierarchicalContext is a Context that can open scopes over itself
+// Every context in this package implements it; ChildContext and RootContext accept any Context
+type HierarchicalContext interface {
+	Context
+	Child() Context // Returns a scope that reads through to this context but writes locally
+	Root() Context  // Returns the outermost context this one is scoped under
+}
+
+// ChildContext returns a scope over context, using its own Child method when it has one
+func ChildContext(context Context) Context {
+	if hierarchical, ok := context.(HierarchicalContext); ok {
+		return hierarchical.Child()
+	}
+	return NewScopedContext(context)
+}
+
+// RootContext returns the outermost context that context is scoped under, or context itself
+func RootContext(context Context) Context {
+	if hierarchical, ok := context.(HierarchicalContext); ok {
+		return hierarchical.Root()
+	}
+	return context
+}
+
+// ScopedContext is a Context layered over a parent: reads fall through to the parent for
+// keys the scope doesn't hold, while writes always stay in the scope
+// Sub-machines given a child of a shared context can read its globals without their own
+// keys colliding with each other's.
+type ScopedContext struct {
+	mu     sync.RWMutex
+	parent Context
+	data   map[string]interface{}
+}
+
+// NewScopedContext creates a scope over parent
+func NewScopedContext(parent Context) *ScopedContext {
+	return &ScopedContext{
+		parent: parent,
+		data:   make(map[string]interface{}),
+	}
+}
+
+// Get returns the scope's value for key, or the parent's if the scope doesn't hold the key
+// A key set to nil in the scope shadows the parent's value
+func (c *ScopedContext) Get(key string) interface{} {
+	c.mu.RLock()
+	value, exists := c.data[key]
+	c.mu.RUnlock()
+
+	if exists {
+		return value
+	}
+	return c.parent.Get(key)
+}
+
+// Set stores a value in the scope without touching the parent
+func (c *ScopedContext) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[key] = value
+}
+
+// GetAll returns the parent's values overlaid with the scope's own
+func (c *ScopedContext) GetAll() map[string]interface{} {
+	result := c.parent.GetAll()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for key, value := range c.data {
+		result[key] = value
+	}
+	return result
+}
+
+// Local returns a copy of only the values set in this scope
+func (c *ScopedContext) Local() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make(map[string]interface{}, len(c.data))
+	for key, value := range c.data {
+		result[key] = value
+	}
+	return result
+}
+
+// Parent returns the context this scope reads through to
+func (c *ScopedContext) Parent() Context {
+	return c.parent
+}
+
+// Child returns a nested scope that reads through to this one
+func (c *ScopedContext) Child() Context {
+	return NewScopedContext(c)
+}
+
+// Root returns the outermost context of the scope chain
+func (c *ScopedContext) Root() Context {
+	return RootContext(c.parent)
+}
@@ -0,0 +1,84 @@
+package fsm
+
+import "testing"
+
+// TestScopedContext tests read-through, local writes, shadowing and nesting of scoped contexts
+func TestScopedContext(t *testing.T) {
+	root := NewContext()
+	root.Set("region", "eu")
+	root.Set("retries", 3)
+
+	child := ChildContext(root)
+	child.Set("retries", 5)
+	child.Set("order_id", "A-1")
+
+	if value := child.Get("region"); value != "eu" {
+		t.Errorf("Expected the child to read through to 'eu', got %v", value)
+	}
+	if value := child.Get("retries"); value != 5 {
+		t.Errorf("Expected the child's own value 5, got %v", value)
+	}
+	if value := root.Get("retries"); value != 3 {
+		t.Errorf("Expected the parent to keep 3, got %v", value)
+	}
+	if value := root.Get("order_id"); value != nil {
+		t.Errorf("Expected the child's write to stay local, got %v", value)
+	}
+
+	sibling := ChildContext(root)
+	if value := sibling.Get("order_id"); value != nil {
+		t.Errorf("Expected siblings not to see each other's keys, got %v", value)
+	}
+
+	root.Set("region", "us")
+	grandchild := ChildContext(child)
+	grandchild.Set("region", nil)
+	if value := child.Get("region"); value != "us" {
+		t.Errorf("Expected later parent writes to be visible, got %v", value)
+	}
+	if value := grandchild.Get("region"); value != nil {
+		t.Errorf("Expected a nil local value to shadow the parent, got %v", value)
+	}
+	if value := grandchild.Get("retries"); value != 5 {
+		t.Errorf("Expected the grandchild to read through to its parent's 5, got %v", value)
+	}
+
+	all := grandchild.GetAll()
+	if len(all) != 3 || all["retries"] != 5 || all["order_id"] != "A-1" || all["region"] != nil {
+		t.Errorf("Expected merged values with the innermost winning, got %v", all)
+	}
+
+	if RootContext(grandchild) != root || RootContext(child) != root || RootContext(root) != root {
+		t.Error("Expected every scope to report the flat context as its root")
+	}
+	if local := grandchild.(*ScopedContext).Local(); len(local) != 1 {
+		t.Errorf("Expected only the grandchild's own key, got %v", local)
+	}
+}
+
+// mapContext is a minimal user-defined Context without Child or Root
+type mapContext map[string]interface{}
+
+func (c mapContext) Get(key string) interface{}        { return c[key] }
+func (c mapContext) Set(key string, value interface{}) { c[key] = value }
+func (c mapContext) GetAll() map[string]interface{} {
+	all := make(map[string]interface{}, len(c))
+	for key, value := range c {
+		all[key] = value
+	}
+	return all
+}
+
+// TestScopedContextOverCustomContext tests scoping a Context that doesn't implement HierarchicalContext
+func TestScopedContextOverCustomContext(t *testing.T) {
+	root := mapContext{"region": "eu"}
+	child := ChildContext(root)
+	child.Set("order_id", "A-1")
+
+	if child.Get("region") != "eu" || root["order_id"] != nil {
+		t.Errorf("Expected read-through without writing to the parent, got %v", root)
+	}
+	if scope, ok := RootContext(ChildContext(child)).(mapContext); !ok || scope["region"] != "eu" {
+		t.Errorf("Expected the custom context as the root of nested scopes, got %T", RootContext(child))
+	}
+}
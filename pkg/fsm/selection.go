@@ -0,0 +1,108 @@
+package fsm
+
+import "sort"
+
+// TransitionSelectionPolicy decides the order in which candidate transitions are tried
+// when several transitions share a source state and event; the first whose guard passes fires
+type TransitionSelectionPolicy int
+
+// Transition selection policies
+const (
+	FirstMatch      TransitionSelectionPolicy = iota // Try candidates in the order they were added
+	HighestPriority                                  // Try candidates by descending Priority, ties in insertion order
+	MostSpecific                                     // Try guarded candidates before unguarded fallbacks, each in insertion order
+)
+
+// String returns the name of the selection policy
+func (p TransitionSelectionPolicy) String() string {
+	switch p {
+	case FirstMatch:
+		return "FirstMatch"
+	case HighestPriority:
+		return "HighestPriority"
+	case MostSpecific:
+		return "MostSpecific"
+	default:
+		return "Unknown"
+	}
+}
+
+// SetSelectionPolicy sets the default policy used to pick between candidate transitions
+func (sm *StateMachine) SetSelectionPolicy(policy TransitionSelectionPolicy) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.selectionPolicy = policy
+}
+
+// SetStateSelectionPolicy overrides the selection policy for transitions leaving one state
+func (sm *StateMachine) SetStateSelectionPolicy(state State, policy TransitionSelectionPolicy) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.statePolicies == nil {
+		sm.statePolicies = make(map[State]TransitionSelectionPolicy)
+	}
+	sm.statePolicies[state] = policy
+}
+
+// SelectionPolicy returns the policy in effect for transitions leaving a state
+func (sm *StateMachine) SelectionPolicy(state State) TransitionSelectionPolicy {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.selectionPolicyUnsafe(state)
+}
+
+// selectionPolicyUnsafe returns the policy for a state without acquiring locks
+func (sm *StateMachine) selectionPolicyUnsafe(state State) TransitionSelectionPolicy {
+	if policy, exists := sm.statePolicies[state]; exists {
+		return policy
+	}
+	return sm.selectionPolicy
+}
+
+// orderCandidates returns candidates in the order the policy tries them
+// The input slice is never reordered; insertion order breaks every tie
+func orderCandidates(candidates []Transition, policy TransitionSelectionPolicy) []Transition {
+	if policy == FirstMatch || len(candidates) < 2 {
+		return candidates
+	}
+
+	ordered := append([]Transition(nil), candidates...)
+	switch policy {
+	case HighestPriority:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].Priority > ordered[j].Priority
+		})
+	case MostSpecific:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].HasGuard() && !ordered[j].HasGuard()
+		})
+	}
+	return ordered
+}
+
+// SetSelectionPolicy sets the default policy used to pick between candidate transitions
+func (b *BuilderWithHooks) SetSelectionPolicy(policy TransitionSelectionPolicy) *BuilderWithHooks {
+	b.machine.SetSelectionPolicy(policy)
+	return b
+}
+
+// SetStateSelectionPolicy overrides the selection policy for transitions leaving one state
+func (b *BuilderWithHooks) SetStateSelectionPolicy(state State, policy TransitionSelectionPolicy) *BuilderWithHooks {
+	b.machine.SetStateSelectionPolicy(state, policy)
+	return b
+}
+
+// AddTransitionWithPriority adds a guarded transition ranked by the HighestPriority policy
+func (b *BuilderWithHooks) AddTransitionWithPriority(from State, event Event, to State, priority int, condition TransitionCondition) *BuilderWithHooks {
+	b.machine.AddState(from)
+	b.machine.AddState(to)
+	b.machine.AddEvent(event)
+	b.machine.AddTransition(Transition{
+		From:      from,
+		Event:     event,
+		To:        to,
+		Condition: condition,
+		Priority:  priority,
+	})
+	return b
+}
@@ -0,0 +1,84 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+// buildSelectionMachine builds a machine with three candidates for "route" out of "start"
+func buildSelectionMachine(t *testing.T, policy TransitionSelectionPolicy) Machine {
+	machine, err := NewBuilderWithHooks().
+		SetSelectionPolicy(policy).
+		AddTransition("start", "route", "fallback").
+		AddTransitionWithPriority("start", "route", "low", 1, AlwaysTrue()).
+		AddTransitionWithPriority("start", "route", "high", 5, AlwaysTrue()).
+		SetInitialState("start").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	return machine
+}
+
+// TestSelectionPolicies tests which candidate each policy fires
+func TestSelectionPolicies(t *testing.T) {
+	tests := []struct {
+		policy   TransitionSelectionPolicy
+		expected State
+	}{
+		{FirstMatch, "fallback"},
+		{HighestPriority, "high"},
+		{MostSpecific, "low"},
+	}
+
+	for _, test := range tests {
+		machine := buildSelectionMachine(t, test.policy)
+		if _, err := machine.SendEvent("route"); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.policy, err)
+		}
+		if machine.CurrentState() != test.expected {
+			t.Errorf("%s: expected state '%s', got '%s'", test.policy, test.expected, machine.CurrentState())
+		}
+	}
+}
+
+// TestStateSelectionPolicyOverride tests that a per-state policy takes precedence
+func TestStateSelectionPolicyOverride(t *testing.T) {
+	machine, err := NewBuilderWithHooks().
+		SetSelectionPolicy(HighestPriority).
+		SetStateSelectionPolicy("start", FirstMatch).
+		AddTransitionWithPriority("start", "route", "first", 0, AlwaysTrue()).
+		AddTransitionWithPriority("start", "route", "second", 9, AlwaysTrue()).
+		SetInitialState("start").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	machine.SendEvent("route")
+	if machine.CurrentState() != "first" {
+		t.Errorf("Expected state 'first', got '%s'", machine.CurrentState())
+	}
+}
+
+// TestHighestPriorityDeterminism tests that distinct priorities resolve unguarded conflicts
+func TestHighestPriorityDeterminism(t *testing.T) {
+	sm := NewStateMachine()
+	sm.SetSelectionPolicy(HighestPriority)
+	sm.AddState("idle")
+	sm.AddState("left")
+	sm.AddState("right")
+	sm.AddEvent("go")
+	sm.AddTransition(Transition{From: "idle", Event: "go", To: "left", Priority: 1})
+	sm.AddTransition(Transition{From: "idle", Event: "go", To: "right", Priority: 2})
+
+	if err := sm.Validate(); err != nil {
+		t.Fatalf("Expected distinct priorities to validate, got %v", err)
+	}
+
+	sm.AddTransition(Transition{From: "idle", Event: "go", To: "idle", Priority: 2})
+	var nondeterminism *NondeterminismError
+	if !errors.As(sm.Validate(), &nondeterminism) {
+		t.Fatalf("Expected NondeterminismError for equal priorities")
+	}
+}
@@ -0,0 +1,290 @@
+package fsm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// encryptedPrefix marks context values that hold ciphertext
+const encryptedPrefix = "enc:v1:"
+
+// RedactedValue replaces sensitive values in externally visible output
+const RedactedValue = "***"
+
+// KeyProvider supplies the AES key (16, 24 or 32 bytes) used to encrypt sensitive context values
+type KeyProvider func() ([]byte, error)
+
+// StaticKey returns a KeyProvider that always supplies the given key
+func StaticKey(key []byte) KeyProvider {
+	return func() ([]byte, error) {
+		return key, nil
+	}
+}
+
+// ContextEncryptor encrypts the values of sensitive context keys with AES-GCM
+// Keys are matched exactly or as path.Match patterns such as "*_secret"
+type ContextEncryptor struct {
+	patterns []string
+	provider KeyProvider
+}
+
+// NewContextEncryptor creates an encryptor for the given sensitive key patterns
+func NewContextEncryptor(provider KeyProvider, sensitiveKeys ...string) *ContextEncryptor {
+	return &ContextEncryptor{
+		patterns: sensitiveKeys,
+		provider: provider,
+	}
+}
+
+// IsSensitive reports whether a context key matches one of the sensitive patterns
+func (ce *ContextEncryptor) IsSensitive(key string) bool {
+	return matchesKeyPattern(ce.patterns, key)
+}
+
+// Encrypt returns a copy of values with every sensitive value replaced by its ciphertext
+func (ce *ContextEncryptor) Encrypt(values map[string]interface{}) (map[string]interface{}, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if !ce.IsSensitive(key) || isEncryptedValue(value) {
+			result[key] = value
+			continue
+		}
+
+		encrypted, err := ce.encryptValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt context key %s: %w", key, err)
+		}
+		result[key] = encrypted
+	}
+
+	return result, nil
+}
+
+// Decrypt returns a copy of values with every encrypted value replaced by its plaintext
+// Decrypted values go through JSON, so numbers come back as float64
+func (ce *ContextEncryptor) Decrypt(values map[string]interface{}) (map[string]interface{}, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if !isEncryptedValue(value) {
+			result[key] = value
+			continue
+		}
+
+		decrypted, err := ce.decryptValue(value.(string))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt context key %s: %w", key, err)
+		}
+		result[key] = decrypted
+	}
+
+	return result, nil
+}
+
+// encryptValue serializes a value to JSON and seals it with AES-GCM
+func (ce *ContextEncryptor) encryptValue(value interface{}) (string, error) {
+	aead, err := ce.aead()
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptValue opens a value produced by encryptValue
+func (ce *ContextEncryptor) decryptValue(value string) (interface{}, error) {
+	aead, err := ce.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(plaintext, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// aead builds the AES-GCM cipher from the provider's key
+func (ce *ContextEncryptor) aead() (cipher.AEAD, error) {
+	if ce.provider == nil {
+		return nil, fmt.Errorf("no key provider configured")
+	}
+
+	key, err := ce.provider()
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain encryption key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// isEncryptedValue reports whether a value holds ciphertext produced by a ContextEncryptor
+func isEncryptedValue(value interface{}) bool {
+	str, ok := value.(string)
+	return ok && strings.HasPrefix(str, encryptedPrefix)
+}
+
+// RedactValues returns a copy of values with every key matching a pattern replaced by RedactedValue
+// Patterns are matched exactly or with path.Match, so "*_secret" redacts "api_secret"
+func RedactValues(values map[string]interface{}, patterns ...string) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+
+	result := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if matchesKeyPattern(patterns, key) {
+			result[key] = RedactedValue
+		} else {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// matchesKeyPattern reports whether key equals or matches one of the patterns
+func matchesKeyPattern(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if pattern == key {
+			return true
+		}
+		if matched, err := path.Match(pattern, key); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// SecureContext is a Context that keeps sensitive values encrypted at rest
+// Get returns plaintext to in-process actions and guards, while GetAll - which feeds
+// exports, config extraction and event messages - returns sensitive values encrypted
+type SecureContext struct {
+	mu        sync.RWMutex
+	data      map[string]interface{}
+	encryptor *ContextEncryptor
+	version   uint64
+}
+
+// NewSecureContext creates a context that encrypts sensitive keys with the given encryptor
+func NewSecureContext(encryptor *ContextEncryptor) *SecureContext {
+	return &SecureContext{
+		data:      make(map[string]interface{}),
+		encryptor: encryptor,
+	}
+}
+
+// Get retrieves the plaintext value stored under key
+func (c *SecureContext) Get(key string) interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.data[key]
+}
+
+// Set stores a value, decrypting it first if it arrives as ciphertext
+func (c *SecureContext) Set(key string, value interface{}) {
+	if isEncryptedValue(value) {
+		if decrypted, err := c.encryptor.decryptValue(value.(string)); err == nil {
+			value = decrypted
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[key] = value
+	c.version++
+}
+
+// Version returns a counter that changes on every Set
+func (c *SecureContext) Version() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version
+}
+
+// Child returns a scoped context that reads through to this one
+// Values set on the child are held by the child and aren't encrypted by GetAll
+func (c *SecureContext) Child() Context {
+	return NewScopedContext(c)
+}
+
+// Root returns the context itself, since a secure context has no parent
+func (c *SecureContext) Root() Context {
+	return c
+}
+
+// plaintext returns a copy of all values with sensitive values left in plaintext
+func (c *SecureContext) plaintext() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make(map[string]interface{}, len(c.data))
+	for key, value := range c.data {
+		result[key] = value
+	}
+	return result
+}
+
+// GetAll returns a copy of all values with sensitive values encrypted
+// Values that fail to encrypt are omitted rather than leaked in plaintext
+func (c *SecureContext) GetAll() map[string]interface{} {
+	return c.seal(c.plaintext())
+}
+
+// seal encrypts the sensitive values of a plaintext copy in place and returns it
+// Values that fail to encrypt are omitted rather than leaked in plaintext
+func (c *SecureContext) seal(values map[string]interface{}) map[string]interface{} {
+	for key, value := range values {
+		if !c.encryptor.IsSensitive(key) || isEncryptedValue(value) {
+			continue
+		}
+		if encrypted, err := c.encryptor.encryptValue(value); err == nil {
+			values[key] = encrypted
+		} else {
+			delete(values, key)
+		}
+	}
+	return values
+}
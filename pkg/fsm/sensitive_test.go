@@ -0,0 +1,91 @@
+package fsm
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSecureContext tests that sensitive values are encrypted at rest but readable in-process
+func TestSecureContext(t *testing.T) {
+	encryptor := NewContextEncryptor(StaticKey([]byte("0123456789abcdef0123456789abcdef")), "payment_token", "*_secret")
+	context := NewSecureContext(encryptor)
+
+	context.Set("payment_token", "tok_4242")
+	context.Set("api_secret", "s3cr3t")
+	context.Set("order_id", 42)
+
+	if context.Get("payment_token") != "tok_4242" {
+		t.Errorf("Expected plaintext payment token in-process, got %v", context.Get("payment_token"))
+	}
+
+	all := context.GetAll()
+	for _, key := range []string{"payment_token", "api_secret"} {
+		value, _ := all[key].(string)
+		if !strings.HasPrefix(value, encryptedPrefix) {
+			t.Errorf("Expected %s to be encrypted at rest, got %v", key, all[key])
+		}
+	}
+	if all["order_id"] != 42 {
+		t.Errorf("Expected non-sensitive value to stay in plaintext, got %v", all["order_id"])
+	}
+
+	decrypted, err := encryptor.Decrypt(all)
+	if err != nil {
+		t.Fatalf("Failed to decrypt context: %v", err)
+	}
+	if decrypted["payment_token"] != "tok_4242" {
+		t.Errorf("Expected decrypted payment token, got %v", decrypted["payment_token"])
+	}
+}
+
+// TestEventSourcingEncryption tests that sourced events store sensitive values encrypted and replay them decrypted
+func TestEventSourcingEncryption(t *testing.T) {
+	encryptor := NewContextEncryptor(StaticKey([]byte("0123456789abcdef")), "payment_token")
+	sourcing := NewEventSourcing()
+	sourcing.SetEncryptor(encryptor)
+
+	sourcing.AppendEvent(EventMessage{MachineID: "order", Event: "pay", Context: map[string]interface{}{"payment_token": "tok_4242"}})
+
+	data, err := sourcing.SerializeEvents()
+	if err != nil {
+		t.Fatalf("Failed to serialize events: %v", err)
+	}
+	if strings.Contains(string(data), "tok_4242") {
+		t.Errorf("Expected serialized events not to contain the plaintext token: %s", data)
+	}
+
+	machine, err := NewBuilder().
+		AddTransition("validated", "pay", "paid").
+		SetInitialState("validated").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	if err := sourcing.ReplayEvents(machine, "order"); err != nil {
+		t.Fatalf("Failed to replay events: %v", err)
+	}
+	if machine.GetContext().Get("payment_token") != "tok_4242" {
+		t.Errorf("Expected replay to restore the plaintext token, got %v", machine.GetContext().Get("payment_token"))
+	}
+}
+
+// TestRedactValues tests replacing sensitive context values for external output
+func TestRedactValues(t *testing.T) {
+	values := map[string]interface{}{
+		"payment_token": "tok_4242",
+		"db_secret":     "hunter2",
+		"order_id":      42,
+	}
+
+	redacted := RedactValues(values, "payment_token", "*_secret")
+	if redacted["payment_token"] != RedactedValue || redacted["db_secret"] != RedactedValue {
+		t.Errorf("Expected sensitive values to be redacted, got %v", redacted)
+	}
+	if redacted["order_id"] != 42 {
+		t.Errorf("Expected non-sensitive value to be kept, got %v", redacted["order_id"])
+	}
+	if values["payment_token"] != "tok_4242" {
+		t.Errorf("Expected original values to be untouched")
+	}
+}
@@ -0,0 +1,96 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// SimulateStep fires one of the transitions valid from the current state, sampled in proportion
+// to their weights; transitions without a weight count as 1
+// Candidates are considered in a fixed order, so a seeded rng reproduces the same run.
+// A nil rng uses the global source of math/rand.
+func (sm *StateMachine) SimulateStep(rng *rand.Rand) (*TransitionResult, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if !sm.running {
+		return nil, FSMError{
+			Type:    "MachineNotRunning",
+			Message: "Cannot simulate a stopped machine",
+			State:   sm.currentState,
+		}
+	}
+
+	start := time.Now()
+
+	events := make([]Event, 0, len(sm.events))
+	for event := range sm.events {
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i] < events[j] })
+
+	var candidates []Transition
+	var total float64
+	for _, event := range events {
+		for _, candidate := range sm.transitions[transitionKey(sm.currentState, event)] {
+			passed, err := sm.guardPassesUnsafe(candidate, sm.context)
+			sm.propagatePanicUnsafe(err)
+			if passed {
+				candidates = append(candidates, candidate)
+				total += transitionWeight(candidate)
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, FSMError{
+			Type:    "NoValidTransition",
+			Message: fmt.Sprintf("No transition can fire from state '%s'", sm.currentState),
+			State:   sm.currentState,
+		}
+	}
+
+	var sample float64
+	if rng != nil {
+		sample = rng.Float64() * total
+	} else {
+		sample = rand.Float64() * total
+	}
+
+	chosen := candidates[len(candidates)-1]
+	for _, candidate := range candidates {
+		if sample < transitionWeight(candidate) {
+			chosen = candidate
+			break
+		}
+		sample -= transitionWeight(candidate)
+	}
+
+	return sm.fireTransitionUnsafe(context.Background(), chosen, start)
+}
+
+// transitionWeight returns the sampling weight of a transition, defaulting to 1
+func transitionWeight(transition Transition) float64 {
+	if transition.Weight <= 0 {
+		return 1
+	}
+	return transition.Weight
+}
+
+// AddWeightedTransition adds a transition chosen by SimulateStep in proportion to weight
+func (b *BuilderWithHooks) AddWeightedTransition(from State, event Event, to State, weight float64) *BuilderWithHooks {
+	if weight <= 0 {
+		b.recordError(fmt.Errorf("transition %s --%s--> %s: weight must be positive, got %v", from, event, to, weight))
+		return b
+	}
+
+	return b.AddTransitionDefinition(Transition{
+		From:   from,
+		Event:  event,
+		To:     to,
+		Weight: weight,
+	})
+}
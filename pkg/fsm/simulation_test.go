@@ -0,0 +1,84 @@
+package fsm
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// buildWeatherMachine builds a two-state Markov chain where sunny days are usually followed by sunny days
+func buildWeatherMachine(t *testing.T) Machine {
+	machine, err := NewBuilderWithHooks().
+		AddWeightedTransition("sunny", "next_day", "sunny", 9).
+		AddWeightedTransition("sunny", "next_day", "rainy", 1).
+		AddWeightedTransition("rainy", "next_day", "sunny", 1).
+		AddWeightedTransition("rainy", "next_day", "rainy", 1).
+		SetInitialState("sunny").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	return machine
+}
+
+// TestSimulateStep tests weighted sampling and reproducibility with a seeded source
+func TestSimulateStep(t *testing.T) {
+	run := func(seed int64) []State {
+		machine := buildWeatherMachine(t)
+		rng := rand.New(rand.NewSource(seed))
+		states := make([]State, 0, 1000)
+		for i := 0; i < 1000; i++ {
+			result, err := machine.SimulateStep(rng)
+			if err != nil {
+				t.Fatalf("Failed to simulate step %d: %v", i, err)
+			}
+			states = append(states, result.ToState)
+		}
+		return states
+	}
+
+	first, second := run(42), run(42)
+	sunny := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Expected identical runs for the same seed, diverged at step %d", i)
+		}
+		if first[i] == "sunny" {
+			sunny++
+		}
+	}
+
+	// The stationary distribution is 5/6 sunny
+	if sunny < 780 || sunny > 880 {
+		t.Errorf("Expected about 833 sunny days out of 1000, got %d", sunny)
+	}
+}
+
+// TestSimulateStepRespectsGuards tests that guarded transitions are only sampled when their guard passes
+func TestSimulateStepRespectsGuards(t *testing.T) {
+	machine, err := NewBuilderWithHooks().
+		AddTransitionWithCondition("idle", "start", "running", ContextHasKey("ready")).
+		AddTransition("running", "stop", "idle").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	if _, err := machine.SimulateStep(rng); err == nil {
+		t.Error("Expected no valid transition while the guard fails")
+	}
+
+	machine.GetContext().Set("ready", true)
+	result, err := machine.SimulateStep(rng)
+	if err != nil {
+		t.Fatalf("Failed to simulate step: %v", err)
+	}
+	if result.ToState != "running" {
+		t.Errorf("Expected state 'running', got '%s'", result.ToState)
+	}
+
+	if _, err := NewBuilderWithHooks().AddWeightedTransition("a", "go", "b", 0).Build(); err == nil {
+		t.Error("Expected a non-positive weight to be rejected")
+	}
+}
@@ -0,0 +1,172 @@
+package fsm
+
+import (
+	"sync"
+	"time"
+)
+
+// SLABreach describes a state that was occupied longer than its deadline
+type SLABreach struct {
+	State      State         // The state whose deadline passed
+	Deadline   time.Duration // The configured deadline
+	EnteredAt  time.Time     // When the machine entered the state
+	BreachedAt time.Time     // When the deadline passed
+}
+
+// SLA fires an escalation event when a machine stays in a state longer than a deadline
+// The timer is armed on entering the state and disarmed on leaving it
+type SLA struct {
+	mu          sync.Mutex
+	state       State
+	deadline    time.Duration
+	breachEvent Event
+	machine     Machine
+	timer       *time.Timer
+	generation  uint64
+	breaches    uint64
+	observers   []func(SLABreach)
+}
+
+// NewSLA creates an SLA that sends breachEvent when state is occupied longer than deadline
+func NewSLA(state State, deadline time.Duration, breachEvent Event) *SLA {
+	return &SLA{
+		state:       state,
+		deadline:    deadline,
+		breachEvent: breachEvent,
+	}
+}
+
+// State returns the state the SLA watches
+func (s *SLA) State() State {
+	return s.state
+}
+
+// Breaches returns how many times the deadline has passed
+func (s *SLA) Breaches() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.breaches
+}
+
+// OnBreach registers a callback invoked after every breach, before the breach event is sent
+func (s *SLA) OnBreach(observer func(SLABreach)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observer)
+}
+
+// NotifyOnBreach publishes event to another machine through the streamer on every breach
+func (s *SLA) NotifyOnBreach(streamer *EventStreamer, targetMachine string, event Event) {
+	s.OnBreach(func(breach SLABreach) {
+		streamer.PublishEvent(EventMessage{
+			MachineID:   targetMachine,
+			Event:       string(event),
+			Timestamp:   breach.BreachedAt,
+			Source:      "sla",
+			Destination: targetMachine,
+			Context: map[string]interface{}{
+				"sla_state":    string(breach.State),
+				"sla_deadline": breach.Deadline.String(),
+			},
+		})
+	})
+}
+
+// Attach registers the hooks that arm and disarm the SLA timer on a machine
+func (s *SLA) Attach(machine Machine) {
+	s.mu.Lock()
+	s.machine = machine
+	s.mu.Unlock()
+
+	AddBoundHook(machine, OnStateEnter, func(result TransitionResult, context Context) {
+		if result.ToState == s.state && result.FromState != s.state {
+			s.arm()
+		}
+	})
+
+	// Exit hooks fire before a transition's action runs, so a failed action that leaves the machine
+	// in place would disarm a stay that continues; disarm once another state is actually entered
+	AddBoundHook(machine, OnStateEnter, func(result TransitionResult, context Context) {
+		if result.FromState == s.state && result.ToState != s.state {
+			s.disarm()
+		}
+	})
+
+	// Stop exits without entering anything
+	AddBoundHook(machine, OnStateExit, func(result TransitionResult, context Context) {
+		if result.FromState == s.state && result.ToState == "" {
+			s.disarm()
+		}
+	})
+}
+
+// arm starts the deadline timer for a new stay in the watched state
+func (s *SLA) arm() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.generation++
+	generation := s.generation
+	enteredAt := time.Now()
+
+	s.timer = time.AfterFunc(s.deadline, func() {
+		s.breach(generation, enteredAt)
+	})
+}
+
+// disarm cancels the deadline timer when the watched state is left
+func (s *SLA) disarm() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.generation++
+}
+
+// breach records a passed deadline and sends the breach event
+func (s *SLA) breach(generation uint64, enteredAt time.Time) {
+	s.mu.Lock()
+	if generation != s.generation {
+		s.mu.Unlock()
+		return // The state was left before the timer fired
+	}
+	s.breaches++
+	s.timer = nil
+	machine := s.machine
+	observers := append([]func(SLABreach){}, s.observers...)
+	s.mu.Unlock()
+
+	breach := SLABreach{
+		State:      s.state,
+		Deadline:   s.deadline,
+		EnteredAt:  enteredAt,
+		BreachedAt: time.Now(),
+	}
+	for _, observer := range observers {
+		observer(breach)
+	}
+
+	// Checked under the machine lock, so a transition racing the timer can't receive the event
+	if machine != nil {
+		machine.SendEventIfInState(s.state, s.breachEvent)
+	}
+}
+
+// AddSLA attaches an SLA to the machine being built and registers its breach event
+func (b *BuilderWithHooks) AddSLA(sla *SLA) *BuilderWithHooks {
+	b.machine.AddState(sla.state)
+	b.machine.AddEvent(sla.breachEvent)
+	sla.Attach(b.machine)
+	return b
+}
+
+// WithSLA fires onBreach if the machine stays in state longer than deadline
+func (b *BuilderWithHooks) WithSLA(state State, deadline time.Duration, onBreach Event) *BuilderWithHooks {
+	return b.AddSLA(NewSLA(state, deadline, onBreach))
+}
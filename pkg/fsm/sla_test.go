@@ -0,0 +1,91 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestSLAEscalation tests that overstaying a state fires the breach event
+func TestSLAEscalation(t *testing.T) {
+	sla := NewSLA("processing", 20*time.Millisecond, "escalate")
+	breached := make(chan SLABreach, 1)
+	sla.OnBreach(func(breach SLABreach) { breached <- breach })
+
+	machine, err := NewBuilderWithHooks().
+		AddTransition("pending", "process", "processing").
+		AddTransition("processing", "complete", "completed").
+		AddTransition("processing", "escalate", "escalated").
+		AddSLA(sla).
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	// Leaving the state before the deadline disarms the timer
+	machine.SendEvent("process")
+	machine.SendEvent("complete")
+	time.Sleep(40 * time.Millisecond)
+	if sla.Breaches() != 0 {
+		t.Fatalf("Expected no breach when leaving in time, got %d", sla.Breaches())
+	}
+
+	// Staying past the deadline escalates
+	machine.Reset()
+	machine.SendEvent("process")
+	select {
+	case breach := <-breached:
+		if breach.State != "processing" {
+			t.Errorf("Expected breach of 'processing', got '%s'", breach.State)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected SLA breach")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for machine.CurrentState() != "escalated" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if machine.CurrentState() != "escalated" {
+		t.Errorf("Expected state 'escalated', got '%s'", machine.CurrentState())
+	}
+	if sla.Breaches() != 1 {
+		t.Errorf("Expected 1 breach, got %d", sla.Breaches())
+	}
+}
+
+// TestSLAFailedActionKeepsTimer tests that a failed action out of the watched state keeps the deadline armed
+func TestSLAFailedActionKeepsTimer(t *testing.T) {
+	sla := NewSLA("processing", 20*time.Millisecond, "escalate")
+	breached := make(chan SLABreach, 1)
+	sla.OnBreach(func(breach SLABreach) { breached <- breach })
+
+	machine, err := NewBuilderWithHooks().
+		AddTransition("pending", "process", "processing").
+		AddTransitionWithAction("processing", "complete", "completed",
+			func(from, to State, event Event, context Context) error {
+				return errors.New("payment gateway down")
+			}).
+		AddTransition("processing", "escalate", "escalated").
+		AddSLA(sla).
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	machine.SendEvent("process")
+	if _, err := machine.SendEvent("complete"); err == nil {
+		t.Fatalf("Expected the complete action to fail")
+	}
+	if machine.CurrentState() != "processing" {
+		t.Fatalf("Expected to stay in 'processing', got '%s'", machine.CurrentState())
+	}
+
+	select {
+	case <-breached:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected SLA breach after the failed action")
+	}
+}
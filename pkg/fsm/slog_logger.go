@@ -0,0 +1,73 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// TransitionLogger is implemented by loggers that record transition attempts as structured events
+// A machine whose logger implements it calls LogTransition for every attempt instead of Debugf
+type TransitionLogger interface {
+	Logger
+	LogTransition(result TransitionResult)
+}
+
+// SlogLogger adapts a *slog.Logger to Logger and emits one structured record per transition attempt
+type SlogLogger struct {
+	logger  *slog.Logger
+	machine string
+}
+
+// NewSlogLogger creates a Logger writing to logger, or to slog.Default() if logger is nil
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogLogger{logger: logger}
+}
+
+// WithMachine returns a copy that adds a "machine" key to transition records
+func (l *SlogLogger) WithMachine(name string) *SlogLogger {
+	return &SlogLogger{logger: l.logger, machine: name}
+}
+
+// Debugf logs a formatted message at debug level
+func (l *SlogLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debug(fmt.Sprintf(format, args...))
+}
+
+// Infof logs a formatted message at info level
+func (l *SlogLogger) Infof(format string, args ...interface{}) {
+	l.logger.Info(fmt.Sprintf(format, args...))
+}
+
+// Errorf logs a formatted message at error level
+func (l *SlogLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Error(fmt.Sprintf(format, args...))
+}
+
+// LogTransition emits a "transition" record at info level, or warn level with the error if it failed
+func (l *SlogLogger) LogTransition(result TransitionResult) {
+	attrs := make([]slog.Attr, 0, 8)
+	if l.machine != "" {
+		attrs = append(attrs, slog.String("machine", l.machine))
+	}
+	attrs = append(attrs,
+		slog.String("from", string(result.FromState)),
+		slog.String("to", string(result.ToState)),
+		slog.String("event", string(result.Event)),
+		slog.Bool("success", result.Success),
+		slog.String("execution_id", result.ExecutionID),
+		slog.Duration("duration", result.Duration),
+	)
+
+	level := slog.LevelInfo
+	if !result.Success {
+		level = slog.LevelWarn
+		if result.Error != nil {
+			attrs = append(attrs, slog.String("error", result.Error.Error()))
+		}
+	}
+	l.logger.LogAttrs(context.Background(), level, "transition", attrs...)
+}
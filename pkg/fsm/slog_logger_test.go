@@ -0,0 +1,70 @@
+package fsm
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+)
+
+// captureHandler is a slog.Handler that keeps every record it receives
+type captureHandler struct {
+	records []slog.Record
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *captureHandler) Handle(_ context.Context, record slog.Record) error {
+	h.records = append(h.records, record)
+	return nil
+}
+
+func (h *captureHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *captureHandler) WithGroup(string) slog.Handler { return h }
+
+// TestSlogTransitionRecords tests that every transition attempt becomes one structured record
+func TestSlogTransitionRecords(t *testing.T) {
+	handler := &captureHandler{}
+	machine, err := NewBuilder().
+		AddTransition("pending", "pay", "paid").
+		SetInitialState("pending").
+		SetLogger(NewSlogLogger(slog.New(handler)).WithMachine("orders")).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	paid, _ := machine.SendEvent("pay")
+	machine.SendEvent("pay")
+
+	if len(handler.records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(handler.records))
+	}
+
+	attrs := func(record slog.Record) map[string]slog.Value {
+		values := make(map[string]slog.Value)
+		record.Attrs(func(attr slog.Attr) bool {
+			values[attr.Key] = attr.Value
+			return true
+		})
+		return values
+	}
+
+	success := attrs(handler.records[0])
+	if handler.records[0].Message != "transition" || handler.records[0].Level != slog.LevelInfo {
+		t.Errorf("Expected an info 'transition' record, got %v %q", handler.records[0].Level, handler.records[0].Message)
+	}
+	for key, expected := range map[string]string{"machine": "orders", "from": "pending", "to": "paid", "event": "pay", "execution_id": paid.ExecutionID} {
+		if got := success[key].String(); got != expected {
+			t.Errorf("Expected %s=%q, got %q", key, expected, got)
+		}
+	}
+	if !success["success"].Bool() || success["duration"].Kind() != slog.KindDuration {
+		t.Errorf("Expected success and duration attributes, got %v", success)
+	}
+
+	failure := attrs(handler.records[1])
+	if handler.records[1].Level != slog.LevelWarn || failure["success"].Bool() || failure["error"].String() == "" {
+		t.Errorf("Expected a warn record with the error, got %v %v", handler.records[1].Level, failure)
+	}
+}
@@ -0,0 +1,453 @@
+package fsm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// MachineSnapshot captures the runtime state of a machine so it can be restored later
+// It is JSON-serializable so snapshots can be persisted between process restarts
+type MachineSnapshot struct {
+	Version   string                 `json:"version,omitempty"`
+	State     State                  `json:"state"`
+	Running   bool                   `json:"running"`
+	Context   map[string]interface{} `json:"context"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// Snapshot captures the current state, running flag and a deep copy of the context values
+// Later changes to nested maps and slices in the context do not leak into the snapshot
+func (sm *StateMachine) Snapshot() MachineSnapshot {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.snapshotUnsafe()
+}
+
+// snapshotUnsafe captures the runtime state without acquiring locks
+func (sm *StateMachine) snapshotUnsafe() MachineSnapshot {
+	return MachineSnapshot{
+		Version:   sm.version,
+		State:     sm.currentState,
+		Running:   sm.running,
+		Context:   copyContextValues(storedContext(sm.context).GetAll()),
+		Timestamp: time.Now(),
+	}
+}
+
+// Restore re-establishes a previously captured snapshot without firing transition or state hooks
+// Snapshots from another version are migrated first or rejected with ErrVersionMismatch;
+// the snapshot state must exist in the machine and the context is replaced by the snapshot values.
+// OnStateRestored hooks are told about the new state so observers such as a StateIndex can follow it
+func (sm *StateMachine) Restore(snapshot MachineSnapshot) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	snapshot, err := sm.migrations.MigrateSnapshot(snapshot, sm.version)
+	if err != nil {
+		return err
+	}
+	return sm.restoreUnsafe(snapshot)
+}
+
+// restoreUnsafe re-establishes a snapshot of the machine's own version without acquiring locks
+func (sm *StateMachine) restoreUnsafe(snapshot MachineSnapshot) error {
+	if snapshot.State != "" && !sm.states[snapshot.State] {
+		return NewStateNotFoundError(snapshot.State)
+	}
+
+	previous := sm.currentState
+	if !sm.running {
+		previous = ""
+	}
+	sm.currentState = snapshot.State
+	sm.running = snapshot.Running
+	sm.context = sm.newContextUnsafe(snapshot.Context)
+	if sm.initialState == "" {
+		sm.initialState = snapshot.State
+	}
+
+	// Like Stop's exit hooks, an empty ToState means the machine is no longer running
+	restored := snapshot.State
+	if !snapshot.Running {
+		restored = ""
+	}
+	sm.executeHooks(OnStateRestored, TransitionResult{
+		Success:     true,
+		FromState:   previous,
+		ToState:     restored,
+		Timestamp:   time.Now(),
+		ExecutionID: sm.newExecutionID(),
+	})
+
+	return nil
+}
+
+// checkpointUnsafe captures the runtime state for an in-process rollback without acquiring locks
+// Unlike snapshotUnsafe it keeps the values guards and actions read, so a SecureContext's sensitive
+// values aren't round-tripped through ciphertext and JSON
+func (sm *StateMachine) checkpointUnsafe() MachineSnapshot {
+	snapshot := sm.snapshotUnsafe()
+	snapshot.Context = copyContextValues(plainValues(sm.context))
+	return snapshot
+}
+
+// plainValues returns a context's values as Get reads them
+// A SecureContext's GetAll encrypts sensitive values, as does a read-only snapshot of one, and a
+// scope's overlays its parent's, so they are read through their plaintext instead
+func plainValues(context Context) map[string]interface{} {
+	switch c := context.(type) {
+	case *payloadContext:
+		return plainValues(c.Context)
+	case *SecureContext:
+		return c.plaintext()
+	case *ReadOnlyContext:
+		values := make(map[string]interface{}, len(c.data))
+		for key, value := range c.data {
+			values[key] = value
+		}
+		return values
+	case *ScopedContext:
+		values := plainValues(c.Parent())
+		for key, value := range c.Local() {
+			values[key] = value
+		}
+		return values
+	}
+	return context.GetAll()
+}
+
+// newContextUnsafe creates a context holding deep copies of values without acquiring locks
+// The new context has the same kind as the machine's: a StrictContext keeps enforcing types,
+// a SecureContext keeps its encryptor (decrypting ciphertext values as they are set) and a
+// ScopedContext keeps its parent, holding only the values that differ from the parent's.
+// Any other context is replaced by a ContextImpl
+func (sm *StateMachine) newContextUnsafe(values map[string]interface{}) Context {
+	var context Context
+	switch current := sm.context.(type) {
+	case *StrictContext:
+		context = NewStrictContext()
+	case *SecureContext:
+		context = NewSecureContext(current.encryptor)
+	case *ScopedContext:
+		scope := NewScopedContext(current.Parent())
+		for key, value := range copyContextValues(values) {
+			// Ciphertext can only have come from a secure parent's GetAll
+			if isEncryptedValue(value) || reflect.DeepEqual(scope.Parent().Get(key), value) {
+				continue
+			}
+			scope.Set(key, value)
+		}
+		return scope
+	default:
+		context = NewContext()
+	}
+	for key, value := range copyContextValues(values) {
+		context.Set(key, value)
+	}
+	return context
+}
+
+// copyContextValues deep-copies context values so snapshots and machines share no maps or slices
+func copyContextValues(values map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if value == nil {
+			copied[key] = nil
+			continue
+		}
+		copied[key] = deepCopy(reflect.ValueOf(value)).Interface()
+	}
+	return copied
+}
+
+// deepCopy recursively copies maps, slices, arrays and pointers; other values are copied by assignment
+func deepCopy(value reflect.Value) reflect.Value {
+	if !value.IsValid() {
+		return value
+	}
+
+	switch value.Kind() {
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeMapWithSize(value.Type(), value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), deepCopyAs(iter.Value(), value.Type().Elem()))
+		}
+		return copied
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(deepCopyAs(value.Index(i), value.Type().Elem()))
+		}
+		return copied
+	case reflect.Array:
+		copied := reflect.New(value.Type()).Elem()
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(deepCopyAs(value.Index(i), value.Type().Elem()))
+		}
+		return copied
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Type().Elem())
+		copied.Elem().Set(deepCopyAs(value.Elem(), value.Type().Elem()))
+		return copied
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		return deepCopy(value.Elem())
+	default:
+		return value
+	}
+}
+
+// deepCopyAs deep-copies a value and converts it back to the element type of its container
+func deepCopyAs(value reflect.Value, typ reflect.Type) reflect.Value {
+	copied := deepCopy(value)
+	if !copied.IsValid() || (copied.Kind() == reflect.Interface && copied.IsNil()) {
+		return reflect.Zero(typ)
+	}
+	if copied.Type() != typ {
+		converted := reflect.New(typ).Elem()
+		converted.Set(copied)
+		return converted
+	}
+	return copied
+}
+
+// SnapshotStore persists machine snapshots by machine name
+type SnapshotStore interface {
+	Save(name string, snapshot MachineSnapshot) error // Stores the latest snapshot for a machine
+	Load(name string) (MachineSnapshot, bool, error)  // Returns the latest snapshot and whether one exists
+}
+
+// MemorySnapshotStore keeps snapshots in memory, mainly for tests
+type MemorySnapshotStore struct {
+	mu        sync.RWMutex
+	snapshots map[string]MachineSnapshot
+}
+
+// NewMemorySnapshotStore creates an empty in-memory snapshot store
+func NewMemorySnapshotStore() *MemorySnapshotStore {
+	return &MemorySnapshotStore{snapshots: make(map[string]MachineSnapshot)}
+}
+
+// Save stores the latest snapshot for a machine
+func (s *MemorySnapshotStore) Save(name string, snapshot MachineSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[name] = snapshot
+	return nil
+}
+
+// Load returns the latest snapshot for a machine
+func (s *MemorySnapshotStore) Load(name string) (MachineSnapshot, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot, exists := s.snapshots[name]
+	return snapshot, exists, nil
+}
+
+// FileSnapshotStore keeps one JSON file per machine in a directory
+type FileSnapshotStore struct {
+	dir string
+}
+
+// NewFileSnapshotStore creates a snapshot store rooted at dir, creating it if needed
+func NewFileSnapshotStore(dir string) (*FileSnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileSnapshotStore{dir: dir}, nil
+}
+
+// Save atomically writes the latest snapshot for a machine
+func (s *FileSnapshotStore) Save(name string, snapshot MachineSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	path := s.path(name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads the latest snapshot for a machine
+func (s *FileSnapshotStore) Load(name string) (MachineSnapshot, bool, error) {
+	var snapshot MachineSnapshot
+
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshot, false, nil
+	}
+	if err != nil {
+		return snapshot, false, err
+	}
+
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, false, err
+	}
+	return snapshot, true, nil
+}
+
+// path returns the snapshot file for a machine name
+func (s *FileSnapshotStore) path(name string) string {
+	return filepath.Join(s.dir, filepath.Base(name)+".json")
+}
+
+// Persister writes a machine's snapshots to a store from a background goroutine
+// Hooks only hand over the newest snapshot, so slow storage never holds the machine lock;
+// when saves fall behind, snapshots superseded before they were written are skipped
+type Persister struct {
+	name  string
+	store SnapshotStore
+
+	mu        sync.Mutex
+	saved     *sync.Cond
+	pending   *MachineSnapshot
+	writing   bool
+	queued    uint64 // Snapshots handed over so far
+	written   uint64 // Snapshots handed over before the last save finished
+	err       error
+	observers []func(error)
+}
+
+// Persist saves a snapshot to the store whenever the machine enters a state, stops or is restored
+// Context changes made outside state changes are captured by the next one. Saves happen in the
+// background: use Flush to wait for them and Err or OnError to learn about failures
+func Persist(name string, machine Machine, store SnapshotStore) *Persister {
+	p := &Persister{name: name, store: store}
+	p.saved = sync.NewCond(&p.mu)
+
+	// Hooks run while the machine is locked, so build the snapshot from the hook arguments
+	version := machine.Version()
+	save := func(state State, running bool, context Context, timestamp time.Time) {
+		p.queue(MachineSnapshot{
+			Version:   version,
+			State:     state,
+			Running:   running,
+			Context:   copyContextValues(storedContext(context).GetAll()),
+			Timestamp: timestamp,
+		})
+	}
+	AddBoundHook(machine, OnStateEnter, func(result TransitionResult, context Context) {
+		save(result.ToState, true, context, result.Timestamp)
+	})
+	AddBoundHook(machine, OnStateExit, func(result TransitionResult, context Context) {
+		if result.ToState == "" {
+			save(result.FromState, false, context, result.Timestamp) // The machine was stopped
+		}
+	})
+	AddBoundHook(machine, OnStateRestored, func(result TransitionResult, context Context) {
+		save(result.ToState, result.ToState != "", context, result.Timestamp)
+	})
+	return p
+}
+
+// OnError registers a callback invoked from the writer goroutine whenever a save fails
+func (p *Persister) OnError(observer func(error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.observers = append(p.observers, observer)
+}
+
+// Err returns the error of the most recent save, nil once a later save succeeds
+func (p *Persister) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Flush waits until every snapshot handed over so far is saved or superseded and returns Err
+func (p *Persister) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	target := p.queued
+	for p.written < target {
+		p.saved.Wait()
+	}
+	return p.err
+}
+
+// queue hands a snapshot to the writer, starting it if it is idle; it never waits for storage
+func (p *Persister) queue(snapshot MachineSnapshot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending = &snapshot
+	p.queued++
+	if !p.writing {
+		p.writing = true
+		go p.write()
+	}
+}
+
+// write saves the newest pending snapshot until none is left
+func (p *Persister) write() {
+	for {
+		p.mu.Lock()
+		if p.pending == nil {
+			p.writing = false
+			p.mu.Unlock()
+			return
+		}
+		snapshot := *p.pending
+		p.pending = nil
+		sequence := p.queued
+		p.mu.Unlock()
+
+		err := p.store.Save(p.name, snapshot)
+		if err != nil {
+			err = fmt.Errorf("saving snapshot of %s: %w", p.name, err)
+		}
+
+		p.mu.Lock()
+		p.written = sequence
+		p.err = err
+		observers := append([]func(error){}, p.observers...)
+		p.saved.Broadcast()
+		p.mu.Unlock()
+
+		if err != nil {
+			for _, observer := range observers {
+				observer(err)
+			}
+		}
+	}
+}
+
+// Resume restores a machine from its last persisted snapshot and keeps persisting it
+// It reports whether a snapshot was found; machines without one are left untouched
+func Resume(name string, machine Machine, store SnapshotStore) (*Persister, bool, error) {
+	snapshot, exists, err := store.Load(name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if exists {
+		if err := machine.Restore(snapshot); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return Persist(name, machine, store), exists, nil
+}
@@ -0,0 +1,301 @@
+package fsm
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestResumeFromSnapshotStore tests that a restarted machine resumes its persisted state
+func TestResumeFromSnapshotStore(t *testing.T) {
+	store, err := NewFileSnapshotStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	build := func() Machine {
+		machine, err := NewBuilder().
+			AddTransition("pending", "pay", "paid").
+			AddTransition("paid", "ship", "shipped").
+			SetInitialState("pending").
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build FSM: %v", err)
+		}
+		return machine
+	}
+
+	// First run: nothing to resume, progress is persisted
+	machine := build()
+	persister, resumed, err := Resume("order-1", machine, store)
+	if err != nil || resumed {
+		t.Fatalf("Expected fresh start, got resumed=%v err=%v", resumed, err)
+	}
+	machine.GetContext().Set("amount", 42.5)
+	machine.SendEvent("pay")
+	if err := persister.Flush(); err != nil {
+		t.Fatalf("Failed to persist: %v", err)
+	}
+
+	// Second run after a crash picks up where the first left off
+	restarted := build()
+	_, resumed, err = Resume("order-1", restarted, store)
+	if err != nil || !resumed {
+		t.Fatalf("Expected resume, got resumed=%v err=%v", resumed, err)
+	}
+	if restarted.CurrentState() != "paid" {
+		t.Errorf("Expected state 'paid', got '%s'", restarted.CurrentState())
+	}
+	if amount := restarted.GetContext().Get("amount"); amount != 42.5 {
+		t.Errorf("Expected amount 42.5, got %v", amount)
+	}
+	if _, err := restarted.SendEvent("ship"); err != nil {
+		t.Errorf("Expected resumed machine to accept events, got %v", err)
+	}
+}
+
+// TestSnapshotRestore tests returning a machine to an earlier state and context exactly
+func TestSnapshotRestore(t *testing.T) {
+	entered := 0
+	machine, err := NewBuilderWithHooks().
+		AddTransition("pending", "pay", "paid").
+		AddTransition("paid", "ship", "shipped").
+		AddOnStateEnterHook(func(result TransitionResult, context Context) { entered++ }).
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	machine.GetContext().Set("items", []interface{}{"book"})
+	machine.GetContext().Set("address", map[string]interface{}{"city": "Oslo"})
+	machine.SendEvent("pay")
+
+	snapshot := machine.Snapshot()
+
+	// Progress further and mutate nested context values in place
+	machine.SendEvent("ship")
+	machine.GetContext().Get("address").(map[string]interface{})["city"] = "Bergen"
+	machine.GetContext().Set("items", append(machine.GetContext().Get("items").([]interface{}), "pen"))
+
+	// The snapshot survives a JSON round trip
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("Failed to marshal snapshot: %v", err)
+	}
+	var persisted MachineSnapshot
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		t.Fatalf("Failed to unmarshal snapshot: %v", err)
+	}
+
+	for _, restored := range []MachineSnapshot{snapshot, persisted} {
+		enteredBefore := entered
+		if err := machine.Restore(restored); err != nil {
+			t.Fatalf("Failed to restore: %v", err)
+		}
+		if entered != enteredBefore {
+			t.Errorf("Expected Restore to fire no hooks")
+		}
+		if machine.CurrentState() != "paid" || !machine.IsRunning() {
+			t.Errorf("Expected running machine in 'paid', got '%s'", machine.CurrentState())
+		}
+		if city := machine.GetContext().Get("address").(map[string]interface{})["city"]; city != "Oslo" {
+			t.Errorf("Expected city 'Oslo', got %v", city)
+		}
+		if items := machine.GetContext().Get("items").([]interface{}); len(items) != 1 {
+			t.Errorf("Expected 1 item, got %v", items)
+		}
+	}
+
+	// Unknown states are rejected
+	if err := machine.Restore(MachineSnapshot{State: "lost", Running: true}); err == nil {
+		t.Errorf("Expected error restoring unknown state")
+	}
+}
+
+// TestSendEventsRollsBack tests that a failing batch restores the state and context it started from
+func TestSendEventsRollsBack(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransitionWithAction("pending", "pay", "paid", func(from, to State, event Event, context Context) error {
+			context.Set("paid", true)
+			return nil
+		}).
+		AddTransition("paid", "ship", "shipped").
+		AddTransition("shipped", "deliver", "delivered").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	machine.GetContext().Set("amount", 10)
+
+	results, err := machine.SendEvents("pay", "deliver")
+	if err == nil {
+		t.Fatal("Expected delivering before shipping to fail")
+	}
+	if len(results) != 2 || !results[0].Success || results[1].Success {
+		t.Errorf("Expected the successful and failed attempts, got %+v", results)
+	}
+	if state := machine.CurrentState(); state != "pending" {
+		t.Errorf("Expected rollback to 'pending', got %s", state)
+	}
+	if machine.GetContext().Get("paid") != nil || machine.GetContext().Get("amount") != 10 {
+		t.Errorf("Expected the original context, got %v", machine.GetContext().GetAll())
+	}
+
+	results, err = machine.SendEvents("pay", "ship", "deliver")
+	if err != nil {
+		t.Fatalf("Expected the batch to succeed, got %v", err)
+	}
+	if len(results) != 3 || machine.CurrentState() != "delivered" || machine.GetContext().Get("paid") != true {
+		t.Errorf("Expected every transition to apply, got state %s and %+v", machine.CurrentState(), results)
+	}
+}
+
+// TestSendEventsRollbackKeepsContextKind tests that a rollback keeps secure and scoped contexts intact
+func TestSendEventsRollbackKeepsContextKind(t *testing.T) {
+	build := func() Machine {
+		machine, err := NewBuilder().
+			AddTransitionWithAction("pending", "pay", "paid", func(from, to State, event Event, context Context) error {
+				context.Set("paid", true)
+				return nil
+			}).
+			SetInitialState("pending").
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build FSM: %v", err)
+		}
+		return machine
+	}
+
+	machine := build()
+	encryptor := NewContextEncryptor(StaticKey([]byte("0123456789abcdef")), "token")
+	secure := NewSecureContext(encryptor)
+	secure.Set("token", "tok_123")
+	secure.Set("attempts", 2)
+	machine.SetContext(secure)
+
+	if _, err := machine.SendEvents("pay", "refund"); err == nil {
+		t.Fatal("Expected the unknown event to fail the batch")
+	}
+	restored, ok := machine.GetContext().(*SecureContext)
+	if !ok {
+		t.Fatalf("Expected a SecureContext after rollback, got %T", machine.GetContext())
+	}
+	if restored.Get("token") != "tok_123" || restored.Get("attempts") != 2 || restored.Get("paid") != nil {
+		t.Errorf("Expected the original plaintext values, got %v", restored.plaintext())
+	}
+	if !isEncryptedValue(restored.GetAll()["token"]) {
+		t.Error("Expected the token to stay encrypted at rest")
+	}
+
+	machine = build()
+	parent := NewContext()
+	parent.Set("region", "eu")
+	scope := NewScopedContext(parent)
+	scope.Set("attempts", 1)
+	machine.SetContext(scope)
+
+	if _, err := machine.SendEvents("pay", "refund"); err == nil {
+		t.Fatal("Expected the unknown event to fail the batch")
+	}
+	restoredScope, ok := machine.GetContext().(*ScopedContext)
+	if !ok || restoredScope.Parent() != parent {
+		t.Fatalf("Expected a scope over the original parent, got %T", machine.GetContext())
+	}
+	parent.Set("region", "us")
+	if restoredScope.Get("region") != "us" || !reflect.DeepEqual(restoredScope.Local(), map[string]interface{}{"attempts": 1}) {
+		t.Errorf("Expected only the scope's own values with the parent read through, got %v", restoredScope.Local())
+	}
+}
+
+// blockingSnapshotStore holds every save until release is closed, failing while failing is set
+type blockingSnapshotStore struct {
+	*MemorySnapshotStore
+	release chan struct{}
+	failing bool
+}
+
+// Save waits for release before storing the snapshot
+func (s *blockingSnapshotStore) Save(name string, snapshot MachineSnapshot) error {
+	<-s.release
+	if s.failing {
+		return errors.New("disk full")
+	}
+	return s.MemorySnapshotStore.Save(name, snapshot)
+}
+
+// TestPersistStateChanges tests that Persist follows state changes outside transitions without blocking the machine
+func TestPersistStateChanges(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("pending", "pay", "paid").
+		AddTransition("paid", "ship", "shipped").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	store := &blockingSnapshotStore{MemorySnapshotStore: NewMemorySnapshotStore(), release: make(chan struct{})}
+	persister := Persist("order", machine, store)
+
+	// A stalled store doesn't hold up transitions
+	sent := make(chan error, 1)
+	go func() {
+		_, err := machine.SendEvent("pay")
+		sent <- err
+	}()
+	select {
+	case err := <-sent:
+		if err != nil {
+			t.Fatalf("Failed to send pay: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected SendEvent not to wait for the snapshot store")
+	}
+	close(store.release)
+
+	load := func() MachineSnapshot {
+		if err := persister.Flush(); err != nil {
+			t.Fatalf("Failed to persist: %v", err)
+		}
+		snapshot, _, _ := store.Load("order")
+		return snapshot
+	}
+	if snapshot := load(); snapshot.State != "paid" || !snapshot.Running {
+		t.Errorf("Expected running 'paid' to be persisted, got %+v", snapshot)
+	}
+
+	machine.SetState("shipped")
+	if snapshot := load(); snapshot.State != "shipped" {
+		t.Errorf("Expected SetState to be persisted, got %+v", snapshot)
+	}
+
+	machine.Reset()
+	if snapshot := load(); snapshot.State != "pending" {
+		t.Errorf("Expected Reset to be persisted, got %+v", snapshot)
+	}
+
+	machine.Stop()
+	if snapshot := load(); snapshot.Running {
+		t.Errorf("Expected Stop to be persisted, got %+v", snapshot)
+	}
+
+	// Failed saves are reported
+	reported := make(chan error, 1)
+	persister.OnError(func(err error) { reported <- err })
+	store.failing = true
+	machine.Start("pending")
+	if err := persister.Flush(); err == nil {
+		t.Errorf("Expected Flush to return the failed save")
+	}
+	select {
+	case err := <-reported:
+		if persister.Err() == nil || err.Error() != persister.Err().Error() {
+			t.Errorf("Expected Err to match the reported error %v, got %v", err, persister.Err())
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected the failed save to be reported")
+	}
+}
@@ -0,0 +1,141 @@
+package fsm
+
+import (
+	"sort"
+	"sync"
+)
+
+// StateIndex maintains a reverse index from state to the names of machines in that state
+// The index is kept current by state enter/exit hooks, so queries never scan the machines
+type StateIndex struct {
+	mu         sync.RWMutex
+	byState    map[State]map[string]bool
+	current    map[string]State
+	tracking   map[string]uint64 // Generation of the Track call each name currently follows
+	generation uint64
+}
+
+// NewStateIndex creates an empty state index
+func NewStateIndex() *StateIndex {
+	return &StateIndex{
+		byState:  make(map[State]map[string]bool),
+		current:  make(map[string]State),
+		tracking: make(map[string]uint64),
+	}
+}
+
+// Track adds a machine to the index and keeps its entry current through hooks
+// Entering a state (transitions, Start, Reset, SetState) moves the machine and Stop removes it;
+// Restore, Resume and SendEvents rollbacks, which enter no state, are followed too.
+// Tracking another machine under the same name replaces the first one
+func (si *StateIndex) Track(name string, machine Machine) {
+	si.mu.Lock()
+	si.generation++
+	generation := si.generation
+	si.tracking[name] = generation
+	si.removeUnsafe(name)
+	si.mu.Unlock()
+
+	AddBoundHook(machine, OnStateEnter, func(result TransitionResult, context Context) {
+		si.move(name, generation, result.ToState)
+	})
+	AddBoundHook(machine, OnStateExit, func(result TransitionResult, context Context) {
+		if result.ToState == "" {
+			si.remove(name, generation) // The machine was stopped
+		}
+	})
+	AddBoundHook(machine, OnStateRestored, func(result TransitionResult, context Context) {
+		if result.ToState == "" {
+			si.remove(name, generation) // The restored snapshot isn't running
+		} else {
+			si.move(name, generation, result.ToState)
+		}
+	})
+
+	if machine.IsRunning() {
+		si.move(name, generation, machine.CurrentState())
+	}
+}
+
+// Untrack removes a machine from the index
+// Hooks registered by Track stay on the machine but no longer update the index
+func (si *StateIndex) Untrack(name string) {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	delete(si.tracking, name)
+	si.removeUnsafe(name)
+}
+
+// MachinesInState returns the sorted names of machines currently in the given state
+func (si *StateIndex) MachinesInState(state State) []string {
+	si.mu.RLock()
+	defer si.mu.RUnlock()
+
+	names := make([]string, 0, len(si.byState[state]))
+	for name := range si.byState[state] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Count returns how many machines are currently in the given state
+func (si *StateIndex) Count(state State) int {
+	si.mu.RLock()
+	defer si.mu.RUnlock()
+	return len(si.byState[state])
+}
+
+// Counts returns the number of machines in every occupied state
+func (si *StateIndex) Counts() map[State]int {
+	si.mu.RLock()
+	defer si.mu.RUnlock()
+
+	counts := make(map[State]int, len(si.byState))
+	for state, names := range si.byState {
+		counts[state] = len(names)
+	}
+	return counts
+}
+
+// move records that the named machine is now in state, unless a later Track or Untrack replaced it
+func (si *StateIndex) move(name string, generation uint64, state State) {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	if si.tracking[name] != generation {
+		return
+	}
+	si.removeUnsafe(name)
+	if si.byState[state] == nil {
+		si.byState[state] = make(map[string]bool)
+	}
+	si.byState[state][name] = true
+	si.current[name] = state
+}
+
+// remove drops the named machine from the index, unless a later Track or Untrack replaced it
+func (si *StateIndex) remove(name string, generation uint64) {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	if si.tracking[name] != generation {
+		return
+	}
+	si.removeUnsafe(name)
+}
+
+// removeUnsafe drops the named machine without acquiring the lock
+func (si *StateIndex) removeUnsafe(name string) {
+	previous, exists := si.current[name]
+	if !exists {
+		return
+	}
+
+	delete(si.byState[previous], name)
+	if len(si.byState[previous]) == 0 {
+		delete(si.byState, previous)
+	}
+	delete(si.current, name)
+}
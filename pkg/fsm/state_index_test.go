@@ -0,0 +1,133 @@
+package fsm
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestStateIndexLifecycle tests that the index follows Start, transitions, Reset and Stop
+func TestStateIndexLifecycle(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("pending", "fail", "error").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	index := NewStateIndex()
+	index.Track("order-1", machine)
+
+	if names := index.MachinesInState("pending"); len(names) != 1 || names[0] != "order-1" {
+		t.Errorf("Expected order-1 in 'pending', got %v", names)
+	}
+
+	machine.SendEvent("fail")
+	if index.Count("pending") != 0 || index.Count("error") != 1 {
+		t.Errorf("Expected order-1 to move to 'error', got %v", index.Counts())
+	}
+
+	machine.Reset()
+	if index.Count("pending") != 1 || index.Count("error") != 0 {
+		t.Errorf("Expected order-1 back in 'pending' after reset, got %v", index.Counts())
+	}
+
+	machine.Stop()
+	if len(index.Counts()) != 0 {
+		t.Errorf("Expected stopped machine to leave the index, got %v", index.Counts())
+	}
+}
+
+// TestStateIndexFollowsRestore tests that restores and rolled back batches keep the index current
+func TestStateIndexFollowsRestore(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("pending", "pay", "paid").
+		AddTransition("paid", "ship", "shipped").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	index := NewStateIndex()
+	index.Track("order-1", machine)
+
+	snapshot := machine.Snapshot()
+	machine.SendEvent("pay")
+	if err := machine.Restore(snapshot); err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+	if index.Count("pending") != 1 || index.Count("paid") != 0 {
+		t.Errorf("Expected order-1 back in 'pending' after restore, got %v", index.Counts())
+	}
+
+	// The batch moves the machine to 'paid' before failing and rolling back
+	if _, err := machine.SendEvents("pay", "pay"); err == nil {
+		t.Fatal("Expected paying twice to fail")
+	}
+	if names := index.MachinesInState("pending"); len(names) != 1 || index.Count("paid") != 0 {
+		t.Errorf("Expected order-1 in 'pending' after the rollback, got %v", index.Counts())
+	}
+
+	if err := machine.Restore(MachineSnapshot{State: "shipped"}); err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+	if len(index.Counts()) != 0 {
+		t.Errorf("Expected a restored stopped machine to leave the index, got %v", index.Counts())
+	}
+}
+
+// TestStateIndexConcurrency tests index consistency under concurrent transitions
+func TestStateIndexConcurrency(t *testing.T) {
+	index := NewStateIndex()
+	machines := make(map[string]Machine)
+
+	for i := 0; i < 20; i++ {
+		machine, err := NewBuilder().
+			AddTransition("a", "next", "b").
+			AddTransition("b", "next", "c").
+			AddTransition("c", "next", "a").
+			SetInitialState("a").
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build FSM: %v", err)
+		}
+		name := fmt.Sprintf("machine-%d", i)
+		machines[name] = machine
+		index.Track(name, machine)
+	}
+
+	var wg sync.WaitGroup
+	for _, machine := range machines {
+		for g := 0; g < 3; g++ {
+			wg.Add(1)
+			go func(m Machine, steps int) {
+				defer wg.Done()
+				for i := 0; i < steps; i++ {
+					m.SendEvent("next")
+				}
+			}(machine, 50+g)
+		}
+	}
+	wg.Wait()
+
+	total := 0
+	for name, machine := range machines {
+		found := false
+		for _, indexed := range index.MachinesInState(machine.CurrentState()) {
+			if indexed == name {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected %s to be indexed under '%s'", name, machine.CurrentState())
+		}
+	}
+	for _, count := range index.Counts() {
+		total += count
+	}
+	if total != len(machines) {
+		t.Errorf("Expected %d indexed machines, got %d", len(machines), total)
+	}
+}
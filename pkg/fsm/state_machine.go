@@ -2,51 +2,84 @@
 package fsm
 
 import (
-	"crypto/rand" // Used for generating cryptographically secure random bytes
-	"fmt"         // Standard library for string formatting and printing
-	"sync"        // Provides synchronization primitives for thread safety
-	"time"        // Standard library for time operations and timestamps
+	"context" // Carries caller cancellation into transition actions
+	"fmt"     // Standard library for string formatting and printing
+	"sort"    // Used to return events in a stable order
+	"sync"    // Provides synchronization primitives for thread safety
+	"time"    // Standard library for time operations and timestamps
 )
 
+// DefaultHistorySize is the number of recent transition attempts a machine keeps
+const DefaultHistorySize = 100
+
 // StateMachine is the core implementation of the Machine interface
 // This struct contains all the data and logic needed for a functional FSM
 type StateMachine struct {
-	mu           sync.RWMutex          // Read-write mutex for thread-safe access to FSM state
-	currentState State                 // The state the machine is currently in
-	states       map[State]bool        // Set of all valid states (map used as set with bool values)
-	events       map[Event]bool        // Set of all valid events that can trigger transitions
-	transitions  map[string]Transition // Map of transition rules, keyed by "from_state:event"
-	hooks        map[HookType][]Hook   // Map of hook functions organized by when they should execute
-	context      Context               // Shared data store accessible during transitions
-	running      bool                  // Flag indicating whether the FSM is currently active
-	initialState State                 // The state this FSM should start in when initialized
+	mu              sync.RWMutex                        // Read-write mutex for thread-safe access to FSM state
+	currentState    State                               // The state the machine is currently in
+	states          map[State]bool                      // Set of all valid states (map used as set with bool values)
+	finalStates     map[State]bool                      // Set of final (accepting) states
+	events          map[Event]bool                      // Set of all valid events that can trigger transitions
+	transitions     map[string][]Transition             // Candidate transition rules in insertion order, keyed by "from_state:event"
+	outgoing        map[State][]Event                   // Sorted events with at least one transition from each state
+	hooks           map[HookType][]registeredHook       // Map of hook functions organized by when they should execute
+	context         Context                             // Shared data store accessible during transitions
+	running         bool                                // Flag indicating whether the FSM is currently active
+	initialState    State                               // The state this FSM should start in when initialized
+	recent          []TransitionResult                  // Ring buffer of the most recent transition attempts
+	recentNext      int                                 // Index of the oldest entry once the ring buffer is full
+	historySize     int                                 // Maximum number of recent transition attempts kept
+	selectionPolicy TransitionSelectionPolicy           // Default policy for choosing between candidate transitions
+	statePolicies   map[State]TransitionSelectionPolicy // Per-state overrides of the selection policy
+	version         string                              // Version of the machine definition, stamped into snapshots and events
+	migrations      *Migrations                         // Upgrade paths for snapshots recorded by older versions
+	tracing         bool                                // Whether every transition attempt is kept in trace
+	trace           []TransitionResult                  // Unbounded audit trail of transition attempts, oldest first
+
+	initialContext      map[string]interface{}  // Context values captured at Build(), reinstalled by ResetWithContext
+	readOnlyHookContext bool                    // Whether observing hooks receive a read-only snapshot of the context
+	subscribers         []chan TransitionResult // Channels returned by Transitions, closed by Stop
+	transitionBuffer    int                     // Capacity of channels returned by Transitions
+	overflowPolicy      OverflowPolicy          // What to do when a Transitions channel is full
+	actionTimeout       time.Duration           // Longest a transition action may run, 0 for no limit
+	logger              Logger                  // Destination of diagnostics, silent by default
+	guardCache          *guardCache             // Memoized guard results, nil unless EnableGuardCache was called
+	executionIDs        ExecutionIDGenerator    // Produces ExecutionIDs, SequentialExecutionIDs if nil
+	pooling             bool                    // Whether SendEvent results come from resultPool
+	resultPool          sync.Pool               // Results handed back through ReleaseResult
+	errorState          State                   // Where failed actions route to, "" to stay put
+	stateErrorStates    map[State]State         // Per-source-state overrides of errorState
+	aliases             map[Event]Event         // Canonical event of each alias
+	aliasesOf           map[Event][]Event       // Sorted aliases of each canonical event
+	autoTransitions     map[State][]Transition  // Eventless transitions tried in order on entering each state
+	autoChaining        bool                    // Whether runAutoTransitionsUnsafe is already looping
+	contextDelta        bool                    // Whether transition results carry a ContextDelta
+	reachGuardMode      ReachGuardMode          // How CanReach treats guards
+	panicPolicy         PanicPolicy             // Whether panicking guards and actions are recovered
+	maxChainDepth       int                     // Most auto transitions one entry may chain, 0 for DefaultMaxChainDepth
 }
 
 // NewStateMachine creates a new finite state machine
 // Factory function that returns a properly initialized StateMachine instance
 func NewStateMachine() *StateMachine {
 	return &StateMachine{
-		states:      make(map[State]bool),        // Initialize empty set of states
-		events:      make(map[Event]bool),        // Initialize empty set of events
-		transitions: make(map[string]Transition), // Initialize empty map of transitions
-		hooks:       make(map[HookType][]Hook),   // Initialize empty map of hook collections
-		context:     NewContext(),                // Create new context instance for data sharing
-		running:     false,                       // FSM starts in stopped state
+		states:      make(map[State]bool),                // Initialize empty set of states
+		finalStates: make(map[State]bool),                // Initialize empty set of final states
+		events:      make(map[Event]bool),                // Initialize empty set of events
+		transitions: make(map[string][]Transition),       // Initialize empty map of transitions
+		outgoing:    make(map[State][]Event),             // Initialize empty index of outgoing events
+		hooks:       make(map[HookType][]registeredHook), // Initialize empty map of hook collections
+		context:     NewContext(),                        // Create new context instance for data sharing
+		running:     false,                               // FSM starts in stopped state
+		historySize: DefaultHistorySize,                  // Keep a bounded window of recent transitions
+		logger:      NopLogger{},                         // Stay quiet unless a logger is configured
 	}
 }
 
-// generateExecutionID creates a unique identifier for transition execution
-// Uses cryptographic random bytes to ensure uniqueness across all transitions
-func generateExecutionID() string {
-	bytes := make([]byte, 8)        // Create 8-byte array for random data
-	rand.Read(bytes)                // Fill array with cryptographically secure random bytes
-	return fmt.Sprintf("%x", bytes) // Convert bytes to hexadecimal string representation
-}
-
 // transitionKey creates a key for the transitions map
 // Combines from state and event into a unique string identifier
 func transitionKey(from State, event Event) string {
-	return fmt.Sprintf("%s:%s", from, event) // Format: "source_state:event_name"
+	return string(from) + ":" + string(event) // Format: "source_state:event_name"
 }
 
 // CurrentState returns the current state of the machine
@@ -58,6 +91,7 @@ func (sm *StateMachine) CurrentState() State {
 }
 
 // SetState manually sets the current state (used for initialization)
+// Setting the current state again is a no-op, so no exit or enter hooks fire
 func (sm *StateMachine) SetState(state State) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -65,6 +99,9 @@ func (sm *StateMachine) SetState(state State) error {
 	if !sm.states[state] {
 		return NewStateNotFoundError(state)
 	}
+	if state == sm.currentState {
+		return nil
+	}
 
 	oldState := sm.currentState
 	sm.currentState = state
@@ -76,7 +113,7 @@ func (sm *StateMachine) SetState(state State) error {
 			FromState:   oldState,
 			ToState:     state,
 			Timestamp:   time.Now(),
-			ExecutionID: generateExecutionID(),
+			ExecutionID: sm.newExecutionID(),
 		})
 	}
 
@@ -86,12 +123,19 @@ func (sm *StateMachine) SetState(state State) error {
 		FromState:   oldState,
 		ToState:     state,
 		Timestamp:   time.Now(),
-		ExecutionID: generateExecutionID(),
+		ExecutionID: sm.newExecutionID(),
 	})
 
 	return nil
 }
 
+// InitialState returns the state the machine was started in
+func (sm *StateMachine) InitialState() State {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.initialState
+}
+
 // IsValidState checks if a state is defined in the machine
 func (sm *StateMachine) IsValidState(state State) bool {
 	sm.mu.RLock()
@@ -103,7 +147,47 @@ func (sm *StateMachine) IsValidState(state State) bool {
 func (sm *StateMachine) SendEvent(event Event) (*TransitionResult, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	return sm.sendEventUnsafe(context.Background(), event)
+}
+
+// SendEventCtx triggers an event like SendEvent but gives up when ctx is cancelled
+// A cancelled context is reported before the machine is touched; once the transition has started,
+// cancellation aborts its actions the way the action timeout does, leaving the state unchanged
+func (sm *StateMachine) SendEventCtx(ctx context.Context, event Event) (*TransitionResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newCancelledError(event, err)
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, newCancelledError(event, err) // Cancelled while waiting for the lock
+	}
+	return sm.sendEventUnsafe(ctx, event)
+}
 
+// SendEventIfInState triggers an event only if the machine is still in expected
+// The check and the transition happen under one lock, so a caller acting on an earlier read of
+// the state can't fire the event from a state it didn't see; a mismatch fails with ErrStateMismatch
+func (sm *StateMachine) SendEventIfInState(expected State, event Event) (*TransitionResult, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.running && sm.currentState != expected {
+		return nil, FSMError{
+			Type:    "StateMismatch",
+			Message: fmt.Sprintf("Expected state '%s' but the machine is in '%s'", expected, sm.currentState),
+			State:   sm.currentState,
+			Event:   event,
+		}
+	}
+	return sm.sendEventUnsafe(context.Background(), event)
+}
+
+// sendEventUnsafe triggers an event without acquiring locks
+func (sm *StateMachine) sendEventUnsafe(ctx context.Context, event Event) (*TransitionResult, error) {
+	event = sm.resolveEventUnsafe(event)
 	if !sm.running {
 		return nil, FSMError{
 			Type:    "MachineNotRunning",
@@ -120,27 +204,48 @@ func (sm *StateMachine) SendEvent(event Event) (*TransitionResult, error) {
 		}
 	}
 
-	key := transitionKey(sm.currentState, event)
-	transition, exists := sm.transitions[key]
+	start := time.Now()
+	transition, exists, allowed, guardErr := sm.selectTransitionUnsafe(event)
 
 	if !exists {
 		err := NewInvalidTransitionError(sm.currentState, event)
-		result := &TransitionResult{
+		result := sm.newResultUnsafe(TransitionResult{
 			Success:     false,
 			FromState:   sm.currentState,
 			ToState:     sm.currentState,
 			Event:       event,
 			Error:       err,
-			Timestamp:   time.Now(),
-			ExecutionID: generateExecutionID(),
-		}
+			Timestamp:   start,
+			Duration:    time.Since(start),
+			ExecutionID: sm.newExecutionID(),
+		})
 
+		sm.recordResult(*result)
 		sm.executeHooks(OnTransitionError, *result)
 		return result, err
 	}
 
-	// Check guard condition if present
-	if transition.Condition != nil && !transition.Condition(sm.context) {
+	// Report a guard that panicked instead of letting it unwind through the caller
+	if guardErr != nil {
+		result := sm.newResultUnsafe(TransitionResult{
+			Success:     false,
+			FromState:   sm.currentState,
+			ToState:     sm.currentState,
+			Event:       event,
+			Error:       guardErr,
+			Timestamp:   start,
+			Duration:    time.Since(start),
+			ExecutionID: sm.newExecutionID(),
+		})
+
+		sm.recordResult(*result)
+		sm.executeHooks(OnTransitionError, *result)
+		sm.propagatePanicUnsafe(guardErr)
+		return result, guardErr
+	}
+
+	// Check guard conditions of the candidate transitions
+	if !allowed {
 		err := FSMError{
 			Type:    "ConditionNotMet",
 			Message: fmt.Sprintf("Transition condition not met for %s", transition),
@@ -148,27 +253,40 @@ func (sm *StateMachine) SendEvent(event Event) (*TransitionResult, error) {
 			Event:   event,
 		}
 
-		result := &TransitionResult{
+		result := sm.newResultUnsafe(TransitionResult{
 			Success:     false,
 			FromState:   sm.currentState,
 			ToState:     sm.currentState,
 			Event:       event,
 			Error:       err,
-			Timestamp:   time.Now(),
-			ExecutionID: generateExecutionID(),
-		}
+			Timestamp:   start,
+			Duration:    time.Since(start),
+			ExecutionID: sm.newExecutionID(),
+		})
 
+		sm.recordResult(*result)
 		sm.executeHooks(OnTransitionError, *result)
 		return result, err
 	}
 
-	result := &TransitionResult{
+	return sm.fireTransitionUnsafe(ctx, transition, start)
+}
+
+// fireTransitionUnsafe runs a selected transition's hooks and action and moves to its target state
+func (sm *StateMachine) fireTransitionUnsafe(ctx context.Context, transition Transition, start time.Time) (*TransitionResult, error) {
+	event := transition.Event
+	result := sm.newResultUnsafe(TransitionResult{
 		Success:     true,
 		FromState:   sm.currentState,
 		ToState:     transition.To,
 		Event:       event,
-		Timestamp:   time.Now(),
-		ExecutionID: generateExecutionID(),
+		Timestamp:   start,
+		ExecutionID: sm.newExecutionID(),
+	})
+
+	var contextBefore map[string]interface{}
+	if sm.contextDelta {
+		contextBefore = copyContextValues(plainValues(sm.context))
 	}
 
 	// Execute before transition hooks
@@ -178,17 +296,38 @@ func (sm *StateMachine) SendEvent(event Event) (*TransitionResult, error) {
 	sm.executeHooks(OnStateExit, *result)
 
 	// Execute transition action if present
-	if transition.Action != nil {
-		if err := transition.Action(sm.currentState, transition.To, event, sm.context); err != nil {
+	if transition.HasAction() {
+		if err := sm.runActionUnsafe(ctx, transition); err != nil {
+			sm.logger.Errorf("fsm: action for %s failed: %v", transition, err)
 			result.Success = false
 			result.Error = err
+			errorState, routed := sm.errorStateUnsafe(result.FromState)
+			if routed {
+				sm.currentState = errorState
+				result.ToState = errorState
+				result.Recovered = true
+			}
+			if sm.contextDelta {
+				result.ContextDelta = sm.contextDeltaUnsafe(contextBefore)
+			}
+			result.Duration = time.Since(start)
+			sm.recordResult(*result)
 			sm.executeHooks(OnTransitionError, *result)
+			if routed {
+				sm.executeHooks(OnStateEnter, *result)
+			}
+			sm.propagatePanicUnsafe(err)
 			return result, err
 		}
 	}
 
 	// Update state
 	sm.currentState = transition.To
+	if sm.contextDelta {
+		result.ContextDelta = sm.contextDeltaUnsafe(contextBefore)
+	}
+	result.Duration = time.Since(start)
+	sm.recordResult(*result)
 
 	// Execute state enter hooks
 	sm.executeHooks(OnStateEnter, *result)
@@ -196,70 +335,220 @@ func (sm *StateMachine) SendEvent(event Event) (*TransitionResult, error) {
 	// Execute after transition hooks
 	sm.executeHooks(AfterTransition, *result)
 
+	// Take any auto transitions out of the entered state
+	if err := sm.runAutoTransitionsUnsafe(ctx); err != nil {
+		return result, err
+	}
+
 	return result, nil
 }
 
-// CanTransition checks if an event can trigger a transition from the current state
-func (sm *StateMachine) CanTransition(event Event) bool {
+// recordResult publishes a transition result to Transitions subscribers and stores it in the
+// bounded recent-transition ring buffer and the trace, if enabled
+func (sm *StateMachine) recordResult(result TransitionResult) {
+	switch logger := sm.logger.(type) {
+	case NopLogger:
+		// Skipped, since boxing the result for Debugf would allocate on every attempt
+	case TransitionLogger:
+		logger.LogTransition(result)
+	default:
+		if result.Success {
+			logger.Debugf("fsm: %s", result)
+		} else {
+			logger.Debugf("fsm: %s failed: %v", result, result.Error)
+		}
+	}
+	sm.publishResultUnsafe(result)
+	if sm.tracing {
+		sm.trace = append(sm.trace, result)
+	}
+	if sm.historySize <= 0 {
+		return
+	}
+	if len(sm.recent) < sm.historySize {
+		sm.recent = append(sm.recent, result)
+		return
+	}
+	sm.recent[sm.recentNext] = result
+	sm.recentNext = (sm.recentNext + 1) % sm.historySize
+}
+
+// RecentTransitions returns the most recent transition attempts in the order they happened
+func (sm *StateMachine) RecentTransitions() []TransitionResult {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
+	return sm.recentUnsafe()
+}
 
-	if !sm.running || !sm.events[event] {
-		return false
-	}
+// recentUnsafe returns the recent transition attempts oldest first without acquiring locks
+func (sm *StateMachine) recentUnsafe() []TransitionResult {
+	recent := make([]TransitionResult, 0, len(sm.recent))
+	recent = append(recent, sm.recent[sm.recentNext:]...)
+	return append(recent, sm.recent[:sm.recentNext]...)
+}
 
-	key := transitionKey(sm.currentState, event)
-	transition, exists := sm.transitions[key]
+// SetHistorySize changes how many recent transition attempts are kept (0 disables recording)
+func (sm *StateMachine) SetHistorySize(size int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	if !exists {
-		return false
+	if size < 0 {
+		size = 0
+	}
+	recent := sm.recentUnsafe()
+	if len(recent) > size {
+		recent = recent[len(recent)-size:]
 	}
+	sm.recent = recent
+	sm.recentNext = 0
+	sm.historySize = size
+}
+
+// selectTransitionUnsafe picks the transition an event fires from the current state
+// Candidates are tried in the order of the selection policy and the first whose guard passes is chosen;
+// exists reports whether any candidate is defined and allowed whether one passed its guard;
+// a guard that panics stops the selection with its *PanicError
+func (sm *StateMachine) selectTransitionUnsafe(event Event) (transition Transition, exists bool, allowed bool, err error) {
+	candidates := sm.transitions[transitionKey(sm.currentState, event)]
+	if len(candidates) == 0 {
+		return Transition{}, false, false, nil
+	}
+	candidates = orderCandidates(candidates, sm.selectionPolicyUnsafe(sm.currentState))
 
-	// Check guard condition if present
-	if transition.Condition != nil {
-		return transition.Condition(sm.context)
+	for _, candidate := range candidates {
+		passed, err := sm.guardPassesUnsafe(candidate, sm.context)
+		if err != nil {
+			return candidate, true, false, err
+		}
+		if passed {
+			return candidate, true, true, nil
+		}
 	}
 
-	return true
+	return candidates[0], true, false, nil
+}
+
+// CanTransition checks if an event can trigger a transition from the current state
+func (sm *StateMachine) CanTransition(event Event) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	return sm.canTransitionUnsafe(event)
 }
 
-// GetValidEvents returns all events that can be triggered from the current state
+// GetValidEvents returns all events that can be triggered from the current state, sorted
+// Aliases of a valid event are reported alongside it
 func (sm *StateMachine) GetValidEvents() []Event {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
 	var validEvents []Event
 
-	for event := range sm.events {
+	for _, event := range sm.outgoing[sm.currentState] {
 		if sm.canTransitionUnsafe(event) {
 			validEvents = append(validEvents, event)
 		}
 	}
 
-	return validEvents
+	return sm.withAliasesUnsafe(validEvents)
 }
 
-// canTransitionUnsafe is an internal method that doesn't acquire locks
-func (sm *StateMachine) canTransitionUnsafe(event Event) bool {
-	if !sm.running || !sm.events[event] {
-		return false
+// EventAvailability splits the events of the current state by how cheaply they were confirmed
+type EventAvailability struct {
+	Available   []Event // Events confirmed to fire, either unguarded or with a passing guard
+	Conditional []Event // Guarded events left unevaluated because the guard budget ran out
+}
+
+// GetEventAvailability lists events from the current state while evaluating at most maxGuards guards
+// Unguarded transitions never count against the budget; a negative budget evaluates every guard,
+// which matches GetValidEvents, and a zero budget returns every guarded event as conditional
+func (sm *StateMachine) GetEventAvailability(maxGuards int) EventAvailability {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var availability EventAvailability
+	if !sm.running {
+		return availability
+	}
+
+	events := make([]Event, 0, len(sm.events))
+	for event := range sm.events {
+		events = append(events, event)
 	}
+	sort.Slice(events, func(i, j int) bool { return events[i] < events[j] })
+
+	// Unguarded candidates are free to confirm, so settle those events first
+	var guarded [][]Transition
+	var guardedEvents []Event
+	for _, event := range events {
+		candidates := orderCandidates(sm.transitions[transitionKey(sm.currentState, event)], sm.selectionPolicyUnsafe(sm.currentState))
+		if len(candidates) == 0 {
+			continue
+		}
 
-	key := transitionKey(sm.currentState, event)
-	transition, exists := sm.transitions[key]
+		unguarded := false
+		for _, candidate := range candidates {
+			if candidate.Condition == nil {
+				unguarded = true
+				break
+			}
+		}
+		if unguarded {
+			availability.Available = append(availability.Available, event)
+			continue
+		}
+		guarded = append(guarded, candidates)
+		guardedEvents = append(guardedEvents, event)
+	}
 
-	if !exists {
-		return false
+	evaluated := 0
+	for i, candidates := range guarded {
+		passed, exhausted := false, false
+		for _, candidate := range candidates {
+			if maxGuards >= 0 && evaluated >= maxGuards {
+				exhausted = true
+				break
+			}
+			evaluated++
+			ok, err := sm.guardPassesUnsafe(candidate, sm.context)
+			sm.propagatePanicUnsafe(err)
+			if ok {
+				passed = true
+				break
+			}
+		}
+
+		if passed {
+			availability.Available = append(availability.Available, guardedEvents[i])
+		} else if exhausted {
+			availability.Conditional = append(availability.Conditional, guardedEvents[i])
+		}
 	}
 
-	if transition.Condition != nil {
-		return transition.Condition(sm.context)
+	availability.Available = sm.withAliasesUnsafe(availability.Available)
+	availability.Conditional = sm.withAliasesUnsafe(availability.Conditional)
+	sort.Slice(availability.Available, func(i, j int) bool {
+		return availability.Available[i] < availability.Available[j]
+	})
+	return availability
+}
+
+// canTransitionUnsafe is an internal method that doesn't acquire locks
+func (sm *StateMachine) canTransitionUnsafe(event Event) bool {
+	event = sm.resolveEventUnsafe(event)
+	if !sm.running || !sm.events[event] {
+		return false
 	}
 
-	return true
+	return sm.cachedCanTransitionUnsafe(event)
 }
 
 // AddTransition adds a new transition to the machine
+// Several transitions may share a source state and event when guards distinguish them.
+// Adding a transition with the same source, event and target replaces the existing one, but one
+// with a different target is added alongside it rather than replacing it: if neither has a guard,
+// Validate and Build report a *NondeterminismError. To move a transition to another target,
+// call RemoveTransition first
 func (sm *StateMachine) AddTransition(transition Transition) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -282,12 +571,22 @@ func (sm *StateMachine) AddTransition(transition Transition) error {
 	}
 
 	key := transitionKey(transition.From, transition.Event)
-	sm.transitions[key] = transition
+	sm.resetGuardCacheUnsafe() // Guards may change even when an existing transition is replaced
+	for i, existing := range sm.transitions[key] {
+		if existing.To == transition.To {
+			sm.transitions[key][i] = transition
+			return nil
+		}
+	}
+	if len(sm.transitions[key]) == 0 {
+		sm.addOutgoingUnsafe(transition.From, transition.Event)
+	}
+	sm.transitions[key] = append(sm.transitions[key], transition)
 
 	return nil
 }
 
-// RemoveTransition removes a transition from the machine
+// RemoveTransition removes every transition for the given source state and event
 func (sm *StateMachine) RemoveTransition(from State, event Event) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -303,17 +602,84 @@ func (sm *StateMachine) RemoveTransition(from State, event Event) error {
 	}
 
 	delete(sm.transitions, key)
+	sm.removeOutgoingUnsafe(from, event)
+	sm.resetGuardCacheUnsafe()
 	return nil
 }
 
-// GetTransitions returns all transitions in the machine
+// TransitionsFrom returns the transitions leaving a state, ordered by event
+// Candidates sharing an event keep the order they were added in
+func (sm *StateMachine) TransitionsFrom(state State) []Transition {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var transitions []Transition
+	for _, event := range sm.outgoing[state] {
+		transitions = append(transitions, sm.transitions[transitionKey(state, event)]...)
+	}
+	return transitions
+}
+
+// OutgoingEvents returns the sorted events that have a transition from a state, ignoring guards
+func (sm *StateMachine) OutgoingEvents(state State) []Event {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return append([]Event(nil), sm.outgoing[state]...)
+}
+
+// addOutgoingUnsafe inserts an event into a state's sorted outgoing events without acquiring locks
+func (sm *StateMachine) addOutgoingUnsafe(from State, event Event) {
+	events := sm.outgoing[from]
+	i := sort.Search(len(events), func(i int) bool { return events[i] >= event })
+	if i < len(events) && events[i] == event {
+		return
+	}
+	events = append(events, "")
+	copy(events[i+1:], events[i:])
+	events[i] = event
+	sm.outgoing[from] = events
+}
+
+// removeOutgoingUnsafe drops an event from a state's outgoing events without acquiring locks
+func (sm *StateMachine) removeOutgoingUnsafe(from State, event Event) {
+	events := sm.outgoing[from]
+	for i, existing := range events {
+		if existing == event {
+			events = append(events[:i], events[i+1:]...)
+			break
+		}
+	}
+	if len(events) == 0 {
+		delete(sm.outgoing, from)
+		return
+	}
+	sm.outgoing[from] = events
+}
+
+// GetTransitions returns all transitions in the machine ordered by source state, event and target
+// Candidates sharing all three keep the order they were added in
 func (sm *StateMachine) GetTransitions() []Transition {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
+	transitions := sm.transitionsUnsafe()
+	sort.SliceStable(transitions, func(i, j int) bool {
+		if transitions[i].From != transitions[j].From {
+			return transitions[i].From < transitions[j].From
+		}
+		if transitions[i].Event != transitions[j].Event {
+			return transitions[i].Event < transitions[j].Event
+		}
+		return transitions[i].To < transitions[j].To
+	})
+	return transitions
+}
+
+// transitionsUnsafe returns all candidate transitions without acquiring locks
+func (sm *StateMachine) transitionsUnsafe() []Transition {
 	transitions := make([]Transition, 0, len(sm.transitions))
-	for _, transition := range sm.transitions {
-		transitions = append(transitions, transition)
+	for _, candidates := range sm.transitions {
+		transitions = append(transitions, candidates...)
 	}
 
 	return transitions
@@ -322,20 +688,50 @@ func (sm *StateMachine) GetTransitions() []Transition {
 // executeHooks executes all hooks of a given type
 func (sm *StateMachine) executeHooks(hookType HookType, result TransitionResult) {
 	if hooks, exists := sm.hooks[hookType]; exists {
-		for _, hook := range hooks {
-			hook(result, sm.context)
+		context := sm.hookContextUnsafe(hookType)
+		for _, registered := range hooks {
+			registered.hook(result, context)
 		}
 	}
 }
 
+// registeredHook is a hook along with whether it belongs to this instance only
+type registeredHook struct {
+	hook  Hook
+	bound bool // Left out by Clone
+}
+
+// BoundHookAdder is implemented by machines that can tell hooks bound to one instance from
+// definition hooks; StateMachine implements it
+type BoundHookAdder interface {
+	AddBoundHook(hookType HookType, hook Hook)
+}
+
+// AddBoundHook registers hook on machine as bound to that instance, using its AddBoundHook method
+// when it has one and AddHook otherwise. Observers that track one machine, like SLA, StateIndex
+// and Persist, use it so a Clone doesn't drive them too
+func AddBoundHook(machine Machine, hookType HookType, hook Hook) {
+	if adder, ok := machine.(BoundHookAdder); ok {
+		adder.AddBoundHook(hookType, hook)
+		return
+	}
+	machine.AddHook(hookType, hook)
+}
+
 // AddHook adds a hook function for a specific hook type
 func (sm *StateMachine) AddHook(hookType HookType, hook Hook) {
+	sm.addHook(hookType, registeredHook{hook: hook})
+}
+
+// AddBoundHook adds a hook that observes this instance only; Clone does not copy it
+func (sm *StateMachine) AddBoundHook(hookType HookType, hook Hook) {
+	sm.addHook(hookType, registeredHook{hook: hook, bound: true})
+}
+
+// addHook appends a registered hook
+func (sm *StateMachine) addHook(hookType HookType, hook registeredHook) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-
-	if sm.hooks[hookType] == nil {
-		sm.hooks[hookType] = make([]Hook, 0)
-	}
 	sm.hooks[hookType] = append(sm.hooks[hookType], hook)
 }
 
@@ -362,6 +758,7 @@ func (sm *StateMachine) SetContext(context Context) {
 }
 
 // Start initializes the machine with an initial state
+// Starting a machine already running in initialState doesn't enter it again
 func (sm *StateMachine) Start(initialState State) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -371,6 +768,10 @@ func (sm *StateMachine) Start(initialState State) error {
 	}
 
 	sm.initialState = initialState
+	if sm.running && sm.currentState == initialState {
+		return nil
+	}
+
 	sm.currentState = initialState
 	sm.running = true
 
@@ -380,10 +781,10 @@ func (sm *StateMachine) Start(initialState State) error {
 		FromState:   "",
 		ToState:     initialState,
 		Timestamp:   time.Now(),
-		ExecutionID: generateExecutionID(),
+		ExecutionID: sm.newExecutionID(),
 	})
 
-	return nil
+	return sm.runAutoTransitionsUnsafe(context.Background())
 }
 
 // Stop halts the machine
@@ -398,19 +799,56 @@ func (sm *StateMachine) Stop() error {
 			FromState:   sm.currentState,
 			ToState:     "",
 			Timestamp:   time.Now(),
-			ExecutionID: generateExecutionID(),
+			ExecutionID: sm.newExecutionID(),
 		})
 	}
 
 	sm.running = false
+	sm.closeSubscribersUnsafe()
 	return nil
 }
 
 // Reset resets the machine to its initial state
+// A running machine already in its initial state stays put without firing exit or enter hooks
 func (sm *StateMachine) Reset() error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	return sm.resetUnsafe(false)
+}
+
+// ResetWithContext resets the machine like Reset and also reinstalls the context captured at Build()
+// Reset keeps the current context for compatibility; this gives a run a clean slate instead.
+// Exit hooks still see the old context and enter hooks the reinstalled one.
+func (sm *StateMachine) ResetWithContext() error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.resetUnsafe(true)
+}
+
+// captureInitialContext remembers the current context values for ResetWithContext
+// Values are captured in plaintext so a SecureContext is reinstalled with what its guards read
+func (sm *StateMachine) captureInitialContext() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.initialContext = copyContextValues(plainValues(sm.context))
+}
+
+// InitialContext returns a copy of the context values captured at Build(), such as a config's context
+// Mutating the result or the live context never changes the captured values. Like GetAll on a
+// SecureContext, sensitive values come back encrypted
+func (sm *StateMachine) InitialContext() map[string]interface{} {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	values := copyContextValues(sm.initialContext)
+	if secure, ok := sm.context.(*SecureContext); ok {
+		return secure.seal(values)
+	}
+	return values
+}
 
+// resetUnsafe returns to the initial state, optionally reinstalling the initial context, without acquiring locks
+func (sm *StateMachine) resetUnsafe(resetContext bool) error {
 	if sm.initialState == "" {
 		return FSMError{
 			Type:    "NoInitialState",
@@ -419,6 +857,13 @@ func (sm *StateMachine) Reset() error {
 	}
 
 	oldState := sm.currentState
+	if sm.running && oldState == sm.initialState {
+		// Already there, so the state is neither left nor entered again
+		if resetContext {
+			sm.context = sm.newContextUnsafe(sm.initialContext)
+		}
+		return nil
+	}
 
 	if sm.running && oldState != "" {
 		// Execute state exit hooks for current state
@@ -427,10 +872,13 @@ func (sm *StateMachine) Reset() error {
 			FromState:   oldState,
 			ToState:     sm.initialState,
 			Timestamp:   time.Now(),
-			ExecutionID: generateExecutionID(),
+			ExecutionID: sm.newExecutionID(),
 		})
 	}
 
+	if resetContext {
+		sm.context = sm.newContextUnsafe(sm.initialContext)
+	}
 	sm.currentState = sm.initialState
 	sm.running = true
 
@@ -440,10 +888,10 @@ func (sm *StateMachine) Reset() error {
 		FromState:   oldState,
 		ToState:     sm.initialState,
 		Timestamp:   time.Now(),
-		ExecutionID: generateExecutionID(),
+		ExecutionID: sm.newExecutionID(),
 	})
 
-	return nil
+	return sm.runAutoTransitionsUnsafe(context.Background())
 }
 
 // IsRunning returns whether the machine is currently running
@@ -475,7 +923,7 @@ func (sm *StateMachine) Validate() error {
 	}
 
 	// Validate all transitions reference valid states and events
-	for _, transition := range sm.transitions {
+	for _, transition := range sm.transitionsUnsafe() {
 		if !sm.states[transition.From] {
 			return NewStateNotFoundError(transition.From)
 		}
@@ -491,9 +939,103 @@ func (sm *StateMachine) Validate() error {
 		}
 	}
 
+	// Reject nondeterministic choices between unguarded transitions
+	if err := sm.checkDeterminismUnsafe(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// Clone copies the machine's definition into a fresh, stopped instance with an empty context
+// States, events, transitions, hooks and settings are copied; guards, actions and hooks
+// are shared function values, so closures over external state stay shared too.
+// Hooks added with AddBoundHook, such as those of SLA.Attach, StateIndex.Track and Persist,
+// observe the original instance only and are not copied
+func (sm *StateMachine) Clone() (Machine, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	clone := NewStateMachine()
+	for state := range sm.states {
+		clone.states[state] = true
+	}
+	for state := range sm.finalStates {
+		clone.finalStates[state] = true
+	}
+	for event := range sm.events {
+		clone.events[event] = true
+	}
+	for key, candidates := range sm.transitions {
+		clone.transitions[key] = append([]Transition(nil), candidates...)
+	}
+	for state, events := range sm.outgoing {
+		clone.outgoing[state] = append([]Event(nil), events...)
+	}
+	for hookType, hooks := range sm.hooks {
+		for _, hook := range hooks {
+			if !hook.bound {
+				clone.hooks[hookType] = append(clone.hooks[hookType], hook)
+			}
+		}
+	}
+	for state, policy := range sm.statePolicies {
+		if clone.statePolicies == nil {
+			clone.statePolicies = make(map[State]TransitionSelectionPolicy)
+		}
+		clone.statePolicies[state] = policy
+	}
+
+	for state, errorState := range sm.stateErrorStates {
+		if clone.stateErrorStates == nil {
+			clone.stateErrorStates = make(map[State]State)
+		}
+		clone.stateErrorStates[state] = errorState
+	}
+
+	if len(sm.aliases) > 0 {
+		clone.aliases = make(map[Event]Event, len(sm.aliases))
+		clone.aliasesOf = make(map[Event][]Event, len(sm.aliasesOf))
+		for alias, canonical := range sm.aliases {
+			clone.aliases[alias] = canonical
+		}
+		for canonical, aliases := range sm.aliasesOf {
+			clone.aliasesOf[canonical] = append([]Event(nil), aliases...)
+		}
+	}
+
+	for state, transitions := range sm.autoTransitions {
+		if clone.autoTransitions == nil {
+			clone.autoTransitions = make(map[State][]Transition)
+		}
+		clone.autoTransitions[state] = append([]Transition(nil), transitions...)
+	}
+
+	clone.initialState = sm.initialState
+	clone.errorState = sm.errorState
+	clone.historySize = sm.historySize
+	clone.selectionPolicy = sm.selectionPolicy
+	clone.reachGuardMode = sm.reachGuardMode
+	clone.panicPolicy = sm.panicPolicy
+	clone.maxChainDepth = sm.maxChainDepth
+	clone.initialContext = sm.initialContext
+	clone.readOnlyHookContext = sm.readOnlyHookContext
+	clone.contextDelta = sm.contextDelta
+	if sm.guardCache != nil {
+		clone.guardCache = &guardCache{}
+	}
+	clone.actionTimeout = sm.actionTimeout
+	clone.logger = sm.logger
+	clone.executionIDs = sm.executionIDs
+	clone.pooling = sm.pooling
+	clone.transitionBuffer = sm.transitionBuffer
+	clone.overflowPolicy = sm.overflowPolicy
+	clone.version = sm.version
+	clone.migrations = sm.migrations
+
+	return clone, nil
+}
+
 // AddState adds a state to the machine
 func (sm *StateMachine) AddState(state State) {
 	sm.mu.Lock()
@@ -501,9 +1043,108 @@ func (sm *StateMachine) AddState(state State) {
 	sm.states[state] = true
 }
 
+// AddFinalState marks a state as final, adding it to the machine if needed
+func (sm *StateMachine) AddFinalState(state State) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.states[state] = true
+	sm.finalStates[state] = true
+}
+
+// IsFinal checks if a state is a final (accepting) state
+func (sm *StateMachine) IsFinal(state State) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.finalStates[state]
+}
+
+// FinalStates returns all final states in sorted order
+func (sm *StateMachine) FinalStates() []State {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sortedStateSet(sm.finalStates)
+}
+
+// States returns every state defined in the machine in sorted order, including isolated ones
+func (sm *StateMachine) States() []State {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sortedStateSet(sm.states)
+}
+
+// IsInFinalState returns true if the machine is running and its current state is final
+// Feeding an input sequence and checking this afterwards tells whether it was accepted
+func (sm *StateMachine) IsInFinalState() bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.running && sm.finalStates[sm.currentState]
+}
+
+// Run feeds a sequence of events to the machine and reports whether it ends in a final state
+// A stopped machine is first reset to its initial state; the first failed transition ends the
+// run, returning the partial trace (including the failed attempt, if it got that far) and the error
+func (sm *StateMachine) Run(events []Event) (accepted bool, trace []TransitionResult, err error) {
+	if !sm.IsRunning() {
+		if err := sm.Reset(); err != nil {
+			return false, nil, err
+		}
+	}
+
+	trace = make([]TransitionResult, 0, len(events))
+	for _, event := range events {
+		result, err := sm.SendEvent(event)
+		if result != nil {
+			trace = append(trace, *result)
+			sm.ReleaseResult(result)
+		}
+		if err != nil {
+			return false, trace, err
+		}
+	}
+
+	return sm.IsInFinalState(), trace, nil
+}
+
+// SendEvents applies a sequence of events atomically: either every transition succeeds or the
+// machine is rolled back to the state and context it had before the first event
+// The machine lock is held for the whole batch, so no other event, snapshot or context access can
+// interleave; on failure the partial results, including the failed attempt, are returned with the error.
+// Hooks that already ran are not undone and attempts stay in the recorded history.
+func (sm *StateMachine) SendEvents(events ...Event) ([]TransitionResult, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	snapshot := sm.checkpointUnsafe()
+	results := make([]TransitionResult, 0, len(events))
+	for _, event := range events {
+		result, err := sm.sendEventUnsafe(context.Background(), event)
+		if result != nil {
+			results = append(results, *result)
+			if sm.pooling {
+				sm.resultPool.Put(result)
+			}
+		}
+		if err != nil {
+			if restoreErr := sm.restoreUnsafe(snapshot); restoreErr != nil {
+				return results, restoreErr
+			}
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
 // AddEvent adds an event to the machine
 func (sm *StateMachine) AddEvent(event Event) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 	sm.events[event] = true
 }
+
+// Events returns every event defined in the machine in sorted order, without aliases
+func (sm *StateMachine) Events() []Event {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sortedEventSet(sm.events)
+}
@@ -22,11 +22,28 @@ type EventStreamer struct {
 type EventMessage struct {
 	ID          string                 `json:"id"`
 	MachineID   string                 `json:"machine_id"`
+	Version     string                 `json:"version,omitempty"`
 	Event       string                 `json:"event"`
 	Timestamp   time.Time              `json:"timestamp"`
 	Context     map[string]interface{} `json:"context"`
 	Source      string                 `json:"source"`
 	Destination string                 `json:"destination"`
+	Result      *EventResult           `json:"result,omitempty"`
+}
+
+// EventResult records how a machine responded to a sourced event
+type EventResult struct {
+	FromState State  `json:"from_state"`
+	ToState   State  `json:"to_state"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+}
+
+// FailureReplay captures the machine state immediately before a failed event
+type FailureReplay struct {
+	Failure EventMessage           `json:"failure"`
+	State   State                  `json:"state"`
+	Context map[string]interface{} `json:"context"`
 }
 
 // EventHandler processes incoming events
@@ -237,8 +254,10 @@ func (es *EventStreamer) Close() error {
 
 // EventSourcing provides event sourcing capabilities
 type EventSourcing struct {
-	events []EventMessage
-	mu     sync.RWMutex
+	events     []EventMessage
+	mu         sync.RWMutex
+	encryptor  *ContextEncryptor
+	migrations *Migrations
 }
 
 // NewEventSourcing creates a new event sourcing system
@@ -248,14 +267,71 @@ func NewEventSourcing() *EventSourcing {
 	}
 }
 
+// SetEncryptor makes the event store encrypt sensitive context values at rest
+// Replays decrypt the values again before applying them to a machine
+func (es *EventSourcing) SetEncryptor(encryptor *ContextEncryptor) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	es.encryptor = encryptor
+}
+
+// SetMigrations sets the migrations ReplayEvents uses to upgrade events from older versions
+func (es *EventSourcing) SetMigrations(migrations *Migrations) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	es.migrations = migrations
+}
+
 // AppendEvent adds an event to the event store
 func (es *EventSourcing) AppendEvent(event EventMessage) {
 	es.mu.Lock()
 	defer es.mu.Unlock()
 
+	if es.encryptor != nil {
+		encrypted, err := es.encryptor.Encrypt(event.Context)
+		if err != nil {
+			// Never store sensitive values in plaintext
+			encrypted = make(map[string]interface{})
+			for key, value := range event.Context {
+				if !es.encryptor.IsSensitive(key) {
+					encrypted[key] = value
+				}
+			}
+		}
+		event.Context = encrypted
+	}
+
 	es.events = append(es.events, event)
 }
 
+// eventContext returns the plaintext context of a stored event
+func (es *EventSourcing) eventContext(event EventMessage) (map[string]interface{}, error) {
+	es.mu.RLock()
+	encryptor := es.encryptor
+	es.mu.RUnlock()
+
+	if encryptor == nil {
+		return event.Context, nil
+	}
+	return encryptor.Decrypt(event.Context)
+}
+
+// replayable decrypts an event's context and migrates the event to the target machine version
+func (es *EventSourcing) replayable(event EventMessage, version string) (EventMessage, error) {
+	values, err := es.eventContext(event)
+	if err != nil {
+		return event, err
+	}
+	event.Context = values
+
+	es.mu.RLock()
+	migrations := es.migrations
+	es.mu.RUnlock()
+	return migrations.MigrateEvent(event, version)
+}
+
 // GetEvents retrieves events for a specific machine
 func (es *EventSourcing) GetEvents(machineID string) []EventMessage {
 	es.mu.RLock()
@@ -287,14 +363,20 @@ func (es *EventSourcing) GetEventsAfter(timestamp time.Time) []EventMessage {
 }
 
 // ReplayEvents replays events on a machine to reconstruct state
+// Events recorded by another machine version are migrated first or rejected with ErrVersionMismatch
 func (es *EventSourcing) ReplayEvents(machine Machine, machineID string) error {
 	events := es.GetEvents(machineID)
 
 	for _, event := range events {
+		event, err := es.replayable(event, machine.Version())
+		if err != nil {
+			return err
+		}
+
 		// Apply context
-		if event.Context != nil {
+		if values := event.Context; values != nil {
 			context := machine.GetContext()
-			for key, value := range event.Context {
+			for key, value := range values {
 				context.Set(key, value)
 			}
 		}
@@ -306,6 +388,124 @@ func (es *EventSourcing) ReplayEvents(machine Machine, machineID string) error {
 	return nil
 }
 
+// ApplyEvent sends an event to a machine and stores it together with its outcome
+func (es *EventSourcing) ApplyEvent(machine Machine, event EventMessage) (*TransitionResult, error) {
+	if event.ID == "" {
+		event.ID = generateEventID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.Version == "" {
+		event.Version = machine.Version()
+	}
+
+	// Apply context
+	if event.Context != nil {
+		context := machine.GetContext()
+		for key, value := range event.Context {
+			context.Set(key, value)
+		}
+	}
+
+	result, err := machine.SendEvent(Event(event.Event))
+
+	// Read the states from the result, produced under the machine lock: another sender may move
+	// the machine before or after this one
+	outcome := &EventResult{Success: err == nil}
+	if result != nil {
+		outcome.FromState = result.FromState
+		outcome.ToState = result.ToState
+		if !result.Success && !result.Recovered {
+			outcome.ToState = result.FromState // A failed action leaves the machine in place
+		}
+	} else {
+		state := machine.CurrentState() // Rejected before any transition was selected
+		outcome.FromState, outcome.ToState = state, state
+	}
+	if err != nil {
+		outcome.Error = err.Error()
+	}
+	event.Result = outcome
+
+	es.AppendEvent(event)
+	return result, err
+}
+
+// FailedTransitions retrieves the events of a machine whose transition failed
+func (es *EventSourcing) FailedTransitions(machineID string) []EventMessage {
+	var failures []EventMessage
+	for _, event := range es.GetEvents(machineID) {
+		if event.Result != nil && !event.Result.Success {
+			failures = append(failures, event)
+		}
+	}
+
+	return failures
+}
+
+// ReplayFailures reconstructs the state and context immediately before each failed transition
+// Every failure is reproduced on a fresh machine from newMachine by replaying the events that
+// preceded it, followed by the failed event's own context. Earlier failures are replayed too,
+// since their actions may have changed the context or routed the machine to an error state;
+// their recorded outcome is accepted, moving the machine to the recorded state if needed.
+// Like ReplayEvents, events recorded by another machine version are migrated first
+func (es *EventSourcing) ReplayFailures(newMachine func() (Machine, error), machineID string) ([]FailureReplay, error) {
+	events := es.GetEvents(machineID)
+
+	var replays []FailureReplay
+	for i, failure := range events {
+		if failure.Result == nil || failure.Result.Success {
+			continue
+		}
+
+		machine, err := newMachine()
+		if err != nil {
+			return replays, fmt.Errorf("failed to create machine for replay: %w", err)
+		}
+
+		context := machine.GetContext()
+		for _, event := range events[:i] {
+			event, err := es.replayable(event, machine.Version())
+			if err != nil {
+				return replays, err
+			}
+			for key, value := range event.Context {
+				context.Set(key, value)
+			}
+
+			_, err = machine.SendEvent(Event(event.Event))
+			if event.Result == nil || event.Result.Success {
+				if err != nil {
+					return replays, fmt.Errorf("replay of event %s diverged: %w", event.ID, err)
+				}
+				continue
+			}
+			if recorded := event.Result.ToState; recorded != "" && machine.CurrentState() != recorded {
+				if err := machine.SetState(recorded); err != nil {
+					return replays, fmt.Errorf("replay of event %s diverged: %w", event.ID, err)
+				}
+			}
+		}
+
+		migrated, err := es.replayable(failure, machine.Version())
+		if err != nil {
+			return replays, err
+		}
+		for key, value := range migrated.Context {
+			context.Set(key, value)
+		}
+
+		replays = append(replays, FailureReplay{
+			Failure: failure,
+			State:   machine.CurrentState(),
+			Context: context.GetAll(),
+		})
+	}
+
+	return replays, nil
+}
+
 // SerializeEvents converts events to JSON
 func (es *EventSourcing) SerializeEvents() ([]byte, error) {
 	es.mu.RLock()
@@ -0,0 +1,140 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestReplayFailures tests reconstructing the context that led to failed transitions
+func TestReplayFailures(t *testing.T) {
+	newMachine := func() (Machine, error) {
+		return NewBuilder().
+			AddStates("pending", "validated", "paid").
+			AddEvents("validate", "pay").
+			AddTransition("pending", "validate", "validated").
+			AddTransitionWithCondition("validated", "pay", "paid", ContextGreaterThan("balance", 10)).
+			SetInitialState("pending").
+			Build()
+	}
+
+	machine, err := newMachine()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	sourcing := NewEventSourcing()
+	if _, err := sourcing.ApplyEvent(machine, EventMessage{MachineID: "order", Event: "validate", Context: map[string]interface{}{"customer": "alice"}}); err != nil {
+		t.Fatalf("Failed to apply 'validate' event: %v", err)
+	}
+	if _, err := sourcing.ApplyEvent(machine, EventMessage{MachineID: "order", Event: "pay", Context: map[string]interface{}{"balance": 5}}); err == nil {
+		t.Fatalf("Expected 'pay' to fail with insufficient balance")
+	}
+	if _, err := sourcing.ApplyEvent(machine, EventMessage{MachineID: "order", Event: "pay", Context: map[string]interface{}{"balance": 20}}); err != nil {
+		t.Fatalf("Failed to apply 'pay' event: %v", err)
+	}
+
+	failures := sourcing.FailedTransitions("order")
+	if len(failures) != 1 {
+		t.Fatalf("Expected 1 failed transition, got %d", len(failures))
+	}
+	if failures[0].Result.FromState != "validated" {
+		t.Errorf("Expected failure from 'validated', got '%s'", failures[0].Result.FromState)
+	}
+
+	replays, err := sourcing.ReplayFailures(newMachine, "order")
+	if err != nil {
+		t.Fatalf("Failed to replay failures: %v", err)
+	}
+	if len(replays) != 1 {
+		t.Fatalf("Expected 1 replay, got %d", len(replays))
+	}
+	if replays[0].State != "validated" {
+		t.Errorf("Expected replayed state 'validated', got '%s'", replays[0].State)
+	}
+	if replays[0].Context["customer"] != "alice" || replays[0].Context["balance"] != 5 {
+		t.Errorf("Unexpected replayed context: %v", replays[0].Context)
+	}
+}
+
+// TestReplayFailuresThroughErrorState tests replaying a history in which a failure routed to an error state
+func TestReplayFailuresThroughErrorState(t *testing.T) {
+	newMachine := func() (Machine, error) {
+		return NewBuilder().
+			AddTransitionWithAction("idle", "charge", "charged", func(from, to State, event Event, context Context) error {
+				attempts, _ := context.Get("attempts").(int)
+				context.Set("attempts", attempts+1)
+				if context.Get("card") == "declined" {
+					return errors.New("card declined")
+				}
+				return nil
+			}).
+			AddTransition("failed", "retry", "idle").
+			AddTransitionWithAction("charged", "ship", "shipped", func(from, to State, event Event, context Context) error {
+				return errors.New("carrier unavailable")
+			}).
+			OnErrorGoTo("failed").
+			SetInitialState("idle").
+			Build()
+	}
+
+	machine, err := newMachine()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	sourcing := NewEventSourcing()
+	sourcing.ApplyEvent(machine, EventMessage{MachineID: "order", Event: "charge", Context: map[string]interface{}{"card": "declined"}})
+	sourcing.ApplyEvent(machine, EventMessage{MachineID: "order", Event: "retry"})
+	sourcing.ApplyEvent(machine, EventMessage{MachineID: "order", Event: "charge", Context: map[string]interface{}{"card": "valid"}})
+	sourcing.ApplyEvent(machine, EventMessage{MachineID: "order", Event: "ship"})
+	if machine.CurrentState() != "failed" {
+		t.Fatalf("Expected the failed shipment to route to 'failed', got '%s'", machine.CurrentState())
+	}
+
+	replays, err := sourcing.ReplayFailures(newMachine, "order")
+	if err != nil {
+		t.Fatalf("Failed to replay failures: %v", err)
+	}
+	if len(replays) != 2 {
+		t.Fatalf("Expected 2 replays, got %d", len(replays))
+	}
+	if replays[1].State != "charged" {
+		t.Errorf("Expected the shipment to be replayed from 'charged', got '%s'", replays[1].State)
+	}
+	if attempts := replays[1].Context["attempts"]; attempts != 2 {
+		t.Errorf("Expected the failed charge's context change to be replayed, got attempts %v", attempts)
+	}
+}
+
+// followedMachine has another sender's transition land right after each of its own
+type followedMachine struct {
+	Machine
+	next Event
+}
+
+func (m followedMachine) SendEvent(event Event) (*TransitionResult, error) {
+	result, err := m.Machine.SendEvent(event)
+	m.Machine.SendEvent(m.next)
+	return result, err
+}
+
+// TestApplyEventRecordsOwnTransition tests that the recorded outcome is the applied event's, not a concurrent one's
+func TestApplyEventRecordsOwnTransition(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("pending", "pay", "paid").
+		AddTransition("paid", "ship", "shipped").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	sourcing := NewEventSourcing()
+	if _, err := sourcing.ApplyEvent(followedMachine{Machine: machine, next: "ship"}, EventMessage{MachineID: "order", Event: "pay"}); err != nil {
+		t.Fatalf("Failed to apply 'pay' event: %v", err)
+	}
+
+	outcome := sourcing.GetEvents("order")[0].Result
+	if outcome.FromState != "pending" || outcome.ToState != "paid" {
+		t.Errorf("Expected pending -> paid to be recorded, got %s -> %s", outcome.FromState, outcome.ToState)
+	}
+}
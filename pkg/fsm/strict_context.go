@@ -0,0 +1,125 @@
+package fsm
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ContextTypeError reports a write that would change the type of a type-locked context key
+type ContextTypeError struct {
+	Key      string       // The key being written
+	Expected reflect.Type // The type the key was first set with
+	Actual   reflect.Type // The type of the rejected value
+}
+
+// Error implements the error interface for ContextTypeError
+func (e *ContextTypeError) Error() string {
+	return fmt.Sprintf("context key %q holds %s, cannot set %s", e.Key, e.Expected, e.Actual)
+}
+
+// StrictContext is a Context that locks each key to the type of the first non-nil value set for it
+// Writes of another type are rejected, so type bugs surface when the value is stored rather than
+// when a later type assertion panics
+type StrictContext struct {
+	mu      sync.RWMutex
+	data    map[string]interface{}
+	types   map[string]reflect.Type
+	errors  []error
+	version uint64
+}
+
+// NewStrictContext creates an empty type-locking context
+func NewStrictContext() *StrictContext {
+	return &StrictContext{
+		data:  make(map[string]interface{}),
+		types: make(map[string]reflect.Type),
+	}
+}
+
+// TrySet stores a value, returning a *ContextTypeError if the key is locked to another type
+// A nil value can always be stored and leaves the key's type unchanged
+func (c *StrictContext) TrySet(key string, value interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if value != nil {
+		actual := reflect.TypeOf(value)
+		if expected, locked := c.types[key]; locked && expected != actual {
+			return &ContextTypeError{Key: key, Expected: expected, Actual: actual}
+		}
+		c.types[key] = actual
+	}
+	c.data[key] = value
+	c.version++
+	return nil
+}
+
+// Version returns a counter that changes on every accepted write
+func (c *StrictContext) Version() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version
+}
+
+// Set stores a value, recording the error returned by TrySet when the write is rejected
+func (c *StrictContext) Set(key string, value interface{}) {
+	if err := c.TrySet(key, value); err != nil {
+		c.mu.Lock()
+		c.errors = append(c.errors, err)
+		c.mu.Unlock()
+	}
+}
+
+// Errors returns the writes rejected by Set, oldest first
+func (c *StrictContext) Errors() []error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]error(nil), c.errors...)
+}
+
+// Get returns the value for key, or nil if it isn't set
+func (c *StrictContext) Get(key string) interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.data[key]
+}
+
+// GetAll returns a copy of all stored values
+func (c *StrictContext) GetAll() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make(map[string]interface{}, len(c.data))
+	for key, value := range c.data {
+		result[key] = value
+	}
+	return result
+}
+
+// Child returns a scoped context that reads through to this one
+// Values set on the child are held by the child and aren't type-checked
+func (c *StrictContext) Child() Context {
+	return NewScopedContext(c)
+}
+
+// Root returns the context itself, since a strict context has no parent
+func (c *StrictContext) Root() Context {
+	return c
+}
+
+// UseStrictContext replaces the machine's context with a StrictContext holding the same values
+// Keys already set are locked to the types of their current values
+func (sm *StateMachine) UseStrictContext() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if _, strict := sm.context.(*StrictContext); strict {
+		return
+	}
+	context := NewStrictContext()
+	for key, value := range sm.context.GetAll() {
+		context.Set(key, value)
+	}
+	sm.context = context
+}
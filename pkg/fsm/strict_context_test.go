@@ -0,0 +1,57 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestStrictContext tests that a key keeps the type of its first value
+func TestStrictContext(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransitionWithAction("pending", "validate", "validated", func(from, to State, event Event, context Context) error {
+			context.Set("validation_attempts", "2")
+			return nil
+		}).
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	machine.GetContext().Set("validation_attempts", 1)
+	machine.(*StateMachine).UseStrictContext()
+
+	if _, err := machine.SendEvent("validate"); err != nil {
+		t.Fatalf("Failed to send validate: %v", err)
+	}
+
+	context := machine.GetContext().(*StrictContext)
+	if value := context.Get("validation_attempts"); value != 1 {
+		t.Errorf("Expected the string write to be rejected, got %v", value)
+	}
+	recorded := context.Errors()
+	var typeErr *ContextTypeError
+	if len(recorded) != 1 || !errors.As(recorded[0], &typeErr) || typeErr.Key != "validation_attempts" {
+		t.Fatalf("Expected one recorded type error, got %v", recorded)
+	}
+	if typeErr.Expected.String() != "int" || typeErr.Actual.String() != "string" {
+		t.Errorf("Expected int and string in the error, got %v", typeErr)
+	}
+
+	if err := context.TrySet("validation_attempts", 3); err != nil {
+		t.Errorf("Expected a write of the same type to succeed, got %v", err)
+	}
+	if err := context.TrySet("validation_attempts", nil); err != nil {
+		t.Errorf("Expected nil to be accepted, got %v", err)
+	}
+	if err := context.TrySet("validation_attempts", 2.5); err == nil {
+		t.Error("Expected the key to stay locked to int after a nil write")
+	}
+
+	snapshot := machine.Snapshot()
+	if err := machine.Restore(snapshot); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if _, strict := machine.GetContext().(*StrictContext); !strict {
+		t.Error("Expected the restored context to stay strict")
+	}
+}
@@ -0,0 +1,95 @@
+package fsm
+
+// StructureReport describes the shape of a machine's transition graph
+type StructureReport struct {
+	Components [][]State `json:"components"` // Strongly connected components, topologically ordered; states within one are sorted
+	Cycles     [][]State `json:"cycles"`     // Components the machine can loop in: several states, or one with a self-transition
+	Sources    []State   `json:"sources"`    // States no other state transitions into
+	Sinks      []State   `json:"sinks"`      // States with no transition to another state
+}
+
+// AnalyzeStructure finds the strongly connected components of a machine with Tarjan's algorithm
+// Components are ordered so that every transition between two of them leads to a later one,
+// which puts the initial state's component first when everything is reachable from it
+func AnalyzeStructure(m Machine) StructureReport {
+	transitions := sortedTransitions(m)
+	states := sortedStates(m, transitions)
+
+	successors := make(map[State][]State)
+	selfLoop := make(map[State]bool)
+	hasIncoming := make(map[State]bool)
+	for _, transition := range transitions {
+		if transition.From == transition.To {
+			selfLoop[transition.From] = true
+			continue
+		}
+		successors[transition.From] = append(successors[transition.From], transition.To)
+		hasIncoming[transition.To] = true
+	}
+
+	// Tarjan's algorithm emits components in reverse topological order
+	index := make(map[State]int, len(states))
+	lowLink := make(map[State]int, len(states))
+	onStack := make(map[State]bool, len(states))
+	var stack []State
+	var components [][]State
+
+	var connect func(state State)
+	connect = func(state State) {
+		index[state] = len(index)
+		lowLink[state] = index[state]
+		stack = append(stack, state)
+		onStack[state] = true
+
+		for _, next := range successors[state] {
+			if _, visited := index[next]; !visited {
+				connect(next)
+				lowLink[state] = min(lowLink[state], lowLink[next])
+			} else if onStack[next] {
+				lowLink[state] = min(lowLink[state], index[next])
+			}
+		}
+
+		if lowLink[state] == index[state] {
+			members := make(map[State]bool)
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				members[top] = true
+				if top == state {
+					break
+				}
+			}
+			components = append(components, sortedStateSet(members))
+		}
+	}
+
+	// Start from the initial state so its component leads the order
+	if initial := m.InitialState(); initial != "" {
+		connect(initial)
+	}
+	for _, state := range states {
+		if _, visited := index[state]; !visited {
+			connect(state)
+		}
+	}
+
+	report := StructureReport{Components: make([][]State, 0, len(components))}
+	for i := len(components) - 1; i >= 0; i-- {
+		component := components[i]
+		report.Components = append(report.Components, component)
+		if len(component) > 1 || selfLoop[component[0]] {
+			report.Cycles = append(report.Cycles, component)
+		}
+	}
+	for _, state := range states {
+		if !hasIncoming[state] {
+			report.Sources = append(report.Sources, state)
+		}
+		if len(successors[state]) == 0 {
+			report.Sinks = append(report.Sinks, state)
+		}
+	}
+	return report
+}
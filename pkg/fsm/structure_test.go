@@ -0,0 +1,50 @@
+package fsm
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestAnalyzeStructure tests finding the cycles, sources and sinks of an autonomous machine
+func TestAnalyzeStructure(t *testing.T) {
+	machine, err := NewBuilder().
+		AddTransition("idle", "start", "monitoring").
+		AddTransition("monitoring", "anomaly", "analyzing").
+		AddTransition("analyzing", "plan", "optimizing").
+		AddTransition("optimizing", "applied", "monitoring").
+		AddTransition("analyzing", "give_up", "stopped").
+		AddTransition("stopped", "poll", "stopped").
+		AddTransition("maintenance", "resume", "monitoring").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	report := AnalyzeStructure(machine)
+	expectedComponents := [][]State{{"maintenance"}, {"idle"}, {"analyzing", "monitoring", "optimizing"}, {"stopped"}}
+	expectedCycles := [][]State{{"analyzing", "monitoring", "optimizing"}, {"stopped"}}
+	if len(report.Components) != len(expectedComponents) {
+		t.Fatalf("Expected components %v, got %v", expectedComponents, report.Components)
+	}
+	position := make(map[State]int)
+	for i, component := range report.Components {
+		for _, state := range component {
+			position[state] = i
+		}
+	}
+	for _, transition := range machine.GetTransitions() {
+		if position[transition.From] > position[transition.To] {
+			t.Errorf("Expected components in topological order, got %v", report.Components)
+		}
+	}
+	if !reflect.DeepEqual(report.Cycles, expectedCycles) {
+		t.Errorf("Expected cycles %v, got %v", expectedCycles, report.Cycles)
+	}
+	if !reflect.DeepEqual(report.Sources, []State{"idle", "maintenance"}) {
+		t.Errorf("Expected sources [idle maintenance], got %v", report.Sources)
+	}
+	if !reflect.DeepEqual(report.Sinks, []State{"stopped"}) {
+		t.Errorf("Expected sinks [stopped], got %v", report.Sinks)
+	}
+}
@@ -0,0 +1,58 @@
+package fsm
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// TraceEntry is the JSON form of a recorded transition attempt
+type TraceEntry struct {
+	Sequence     int                      `json:"sequence"` // 1-based position in the trace
+	ExecutionID  string                   `json:"execution_id"`
+	Event        Event                    `json:"event"`
+	FromState    State                    `json:"from_state"`
+	ToState      State                    `json:"to_state"`
+	Success      bool                     `json:"success"`
+	Error        string                   `json:"error,omitempty"`
+	Timestamp    time.Time                `json:"timestamp"`
+	Duration     time.Duration            `json:"duration_ns"`
+	ContextDelta map[string]ContextChange `json:"context_delta,omitempty"` // Set when EnableContextDelta was called
+}
+
+// EnableTrace starts recording every transition attempt, including failures
+// Unlike RecentTransitions the trace is never truncated, so it suits audits of bounded runs
+func (sm *StateMachine) EnableTrace() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.tracing = true
+}
+
+// Trace returns the transition attempts recorded since EnableTrace, oldest first
+func (sm *StateMachine) Trace() []TransitionResult {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return append([]TransitionResult(nil), sm.trace...)
+}
+
+// ExportTraceJSON serializes the recorded trace as a JSON array of TraceEntry
+func (sm *StateMachine) ExportTraceJSON() ([]byte, error) {
+	trace := sm.Trace()
+	entries := make([]TraceEntry, len(trace))
+	for i, result := range trace {
+		entries[i] = TraceEntry{
+			Sequence:     i + 1,
+			ExecutionID:  result.ExecutionID,
+			Event:        result.Event,
+			FromState:    result.FromState,
+			ToState:      result.ToState,
+			Success:      result.Success,
+			Timestamp:    result.Timestamp,
+			Duration:     result.Duration,
+			ContextDelta: result.ContextDelta,
+		}
+		if result.Error != nil {
+			entries[i].Error = result.Error.Error()
+		}
+	}
+	return json.Marshal(entries)
+}
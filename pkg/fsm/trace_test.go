@@ -0,0 +1,50 @@
+package fsm
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestTrace tests that the trace keeps every attempt, including failures, in order
+func TestTrace(t *testing.T) {
+	machine, err := NewBuilderWithHooks().
+		AddTransition("pending", "pay", "paid").
+		AddTransition("paid", "ship", "shipped").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	machine.SendEvent("pay")
+	if len(machine.Trace()) != 0 {
+		t.Fatalf("Expected no trace before EnableTrace, got %v", machine.Trace())
+	}
+
+	machine.EnableTrace()
+	machine.SendEvent("pay")
+	machine.SendEvent("ship")
+
+	trace := machine.Trace()
+	if len(trace) != 2 {
+		t.Fatalf("Expected 2 traced attempts, got %d", len(trace))
+	}
+	if trace[0].Success || trace[0].Event != "pay" {
+		t.Errorf("Expected the failed pay first, got %+v", trace[0])
+	}
+	if !trace[1].Success || trace[1].ToState != "shipped" {
+		t.Errorf("Expected the ship transition second, got %+v", trace[1])
+	}
+
+	data, err := machine.ExportTraceJSON()
+	if err != nil {
+		t.Fatalf("Failed to export trace: %v", err)
+	}
+	var entries []TraceEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("Failed to decode trace: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Sequence != 1 || entries[0].Error == "" || entries[1].ExecutionID != trace[1].ExecutionID {
+		t.Errorf("Expected exported entries to mirror the trace, got %+v", entries)
+	}
+}
@@ -0,0 +1,172 @@
+package fsm
+
+import "fmt"
+
+// TypedMachine wraps a Machine so states and events are constrained to user-defined types
+// Values are converted to the string-based core with fmt.Sprint, so types implementing
+// fmt.Stringer are named by their String method
+type TypedMachine[S comparable, E comparable] struct {
+	machine Machine
+	states  map[State]S
+	events  map[Event]E
+}
+
+// Machine returns the underlying string-based machine for APIs that need it
+func (m *TypedMachine[S, E]) Machine() Machine {
+	return m.machine
+}
+
+// CurrentState returns the current state, or the zero value if it isn't a known typed state
+func (m *TypedMachine[S, E]) CurrentState() S {
+	return m.states[m.machine.CurrentState()]
+}
+
+// SendEvent triggers an event and attempts a state transition
+func (m *TypedMachine[S, E]) SendEvent(event E) (*TransitionResult, error) {
+	return m.machine.SendEvent(typedEvent(event))
+}
+
+// CanTransition checks if an event can trigger a transition from the current state
+func (m *TypedMachine[S, E]) CanTransition(event E) bool {
+	return m.machine.CanTransition(typedEvent(event))
+}
+
+// GetValidEvents returns all events that are valid from the current state
+func (m *TypedMachine[S, E]) GetValidEvents() []E {
+	var events []E
+	for _, event := range m.machine.GetValidEvents() {
+		if typed, exists := m.events[event]; exists {
+			events = append(events, typed)
+		}
+	}
+	return events
+}
+
+// IsInFinalState returns true if the current state is a final state
+func (m *TypedMachine[S, E]) IsInFinalState() bool {
+	return m.machine.IsInFinalState()
+}
+
+// Reset returns the machine to its initial state
+func (m *TypedMachine[S, E]) Reset() error {
+	return m.machine.Reset()
+}
+
+// GetContext returns the shared data store of the machine
+func (m *TypedMachine[S, E]) GetContext() Context {
+	return m.machine.GetContext()
+}
+
+// TypedBuilder builds a TypedMachine with the same fluent API as BuilderWithHooks
+type TypedBuilder[S comparable, E comparable] struct {
+	builder *BuilderWithHooks
+	states  map[State]S
+	events  map[Event]E
+}
+
+// NewTypedBuilder creates a builder for a machine with typed states and events
+func NewTypedBuilder[S comparable, E comparable]() *TypedBuilder[S, E] {
+	return &TypedBuilder[S, E]{
+		builder: NewBuilderWithHooks(),
+		states:  make(map[State]S),
+		events:  make(map[Event]E),
+	}
+}
+
+// AddStates adds states to the machine
+func (b *TypedBuilder[S, E]) AddStates(states ...S) *TypedBuilder[S, E] {
+	for _, state := range states {
+		b.builder.AddState(b.state(state))
+	}
+	return b
+}
+
+// AddEvents adds events to the machine
+func (b *TypedBuilder[S, E]) AddEvents(events ...E) *TypedBuilder[S, E] {
+	for _, event := range events {
+		b.builder.AddEvent(b.event(event))
+	}
+	return b
+}
+
+// AddTransition adds a basic transition without conditions or actions
+func (b *TypedBuilder[S, E]) AddTransition(from S, event E, to S) *TypedBuilder[S, E] {
+	b.builder.AddTransition(b.state(from), b.event(event), b.state(to))
+	return b
+}
+
+// AddTransitionWithCondition adds a transition with a guard condition
+func (b *TypedBuilder[S, E]) AddTransitionWithCondition(from S, event E, to S, condition TransitionCondition) *TypedBuilder[S, E] {
+	b.builder.AddTransitionWithCondition(b.state(from), b.event(event), b.state(to), condition)
+	return b
+}
+
+// AddTransitionWithAction adds a transition with an action
+func (b *TypedBuilder[S, E]) AddTransitionWithAction(from S, event E, to S, action TransitionAction) *TypedBuilder[S, E] {
+	b.builder.AddTransitionWithAction(b.state(from), b.event(event), b.state(to), action)
+	return b
+}
+
+// AddTransitionFull adds a transition with both condition and action
+func (b *TypedBuilder[S, E]) AddTransitionFull(from S, event E, to S, condition TransitionCondition, action TransitionAction) *TypedBuilder[S, E] {
+	b.builder.AddTransitionFull(b.state(from), b.event(event), b.state(to), condition, action)
+	return b
+}
+
+// AddFinalStates marks states as final (accepting) states
+func (b *TypedBuilder[S, E]) AddFinalStates(states ...S) *TypedBuilder[S, E] {
+	for _, state := range states {
+		b.builder.AddFinalStates(b.state(state))
+	}
+	return b
+}
+
+// AddHook adds a hook of the given type to the machine
+func (b *TypedBuilder[S, E]) AddHook(hookType HookType, hook Hook) *TypedBuilder[S, E] {
+	b.builder.machine.AddHook(hookType, hook)
+	return b
+}
+
+// SetInitialState sets the initial state for the machine
+func (b *TypedBuilder[S, E]) SetInitialState(state S) *TypedBuilder[S, E] {
+	b.builder.SetInitialState(b.state(state))
+	return b
+}
+
+// Build creates and validates the typed machine
+func (b *TypedBuilder[S, E]) Build() (*TypedMachine[S, E], error) {
+	machine, err := b.builder.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypedMachine[S, E]{
+		machine: machine,
+		states:  b.states,
+		events:  b.events,
+	}, nil
+}
+
+// state converts and remembers a typed state
+func (b *TypedBuilder[S, E]) state(state S) State {
+	name := typedState(state)
+	b.states[name] = state
+	return name
+}
+
+// event converts and remembers a typed event
+func (b *TypedBuilder[S, E]) event(event E) Event {
+	name := typedEvent(event)
+	b.events[name] = event
+	return name
+}
+
+// typedState converts a typed state to its core representation
+func typedState[S comparable](state S) State {
+	return State(fmt.Sprint(state))
+}
+
+// typedEvent converts a typed event to its core representation
+func typedEvent[E comparable](event E) Event {
+	return Event(fmt.Sprint(event))
+}
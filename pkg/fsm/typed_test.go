@@ -0,0 +1,54 @@
+package fsm
+
+import "testing"
+
+// orderState and orderEvent are enum types used to test the typed wrapper
+type orderState int
+
+const (
+	orderPending orderState = iota
+	orderPaid
+	orderShipped
+)
+
+func (s orderState) String() string {
+	return [...]string{"pending", "paid", "shipped"}[s]
+}
+
+type orderEvent string
+
+const (
+	orderPay  orderEvent = "pay"
+	orderShip orderEvent = "ship"
+)
+
+// TestTypedMachine tests driving a machine with enum-typed states and events
+func TestTypedMachine(t *testing.T) {
+	machine, err := NewTypedBuilder[orderState, orderEvent]().
+		AddTransition(orderPending, orderPay, orderPaid).
+		AddTransition(orderPaid, orderShip, orderShipped).
+		AddFinalStates(orderShipped).
+		SetInitialState(orderPending).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+
+	if machine.CurrentState() != orderPending {
+		t.Errorf("Expected state %v, got %v", orderPending, machine.CurrentState())
+	}
+	if events := machine.GetValidEvents(); len(events) != 1 || events[0] != orderPay {
+		t.Errorf("Expected valid events [pay], got %v", events)
+	}
+
+	machine.SendEvent(orderPay)
+	machine.SendEvent(orderShip)
+	if machine.CurrentState() != orderShipped || !machine.IsInFinalState() {
+		t.Errorf("Expected final state %v, got %v", orderShipped, machine.CurrentState())
+	}
+
+	// The core machine uses the String names
+	if machine.Machine().CurrentState() != "shipped" {
+		t.Errorf("Expected core state 'shipped', got '%s'", machine.Machine().CurrentState())
+	}
+}
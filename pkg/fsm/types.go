@@ -2,8 +2,11 @@
 package fsm
 
 import (
-	"fmt"  // Standard library for string formatting and printing
-	"time" // Standard library for time operations and timestamps
+	"context"   // Standard library for cancellation of long-running actions
+	"errors"    // Standard library for the sentinel errors matched with errors.Is
+	"fmt"       // Standard library for string formatting and printing
+	"math/rand" // Standard library for seeded random sources used by simulations
+	"time"      // Standard library for time operations and timestamps
 )
 
 // State represents a state in the finite state machine
@@ -23,8 +26,13 @@ type TransitionCondition func(context Context) bool
 // Returns an error if the action fails, which will abort the transition
 type TransitionAction func(from, to State, event Event, context Context) error
 
+// CancelableAction is a TransitionAction that also receives a context.Context
+// The context is cancelled when the machine's action timeout expires, so the action can stop early
+type CancelableAction func(ctx context.Context, from, to State, event Event, data Context) error
+
 // Context holds data that can be accessed during transitions
 // This interface provides a key-value store for sharing data between transitions
+// The key EventPayloadKey is reserved for event payloads; see SendEventWithPayload
 type Context interface {
 	Get(key string) interface{}        // Retrieves a value by key from the context
 	Set(key string, value interface{}) // Stores a key-value pair in the context
@@ -34,11 +42,20 @@ type Context interface {
 // Transition defines a state transition rule in the finite state machine
 // This struct encapsulates all information needed for a single transition
 type Transition struct {
-	From      State               // The source state that the transition starts from
-	Event     Event               // The event that triggers this transition
-	To        State               // The destination state that the transition leads to
-	Condition TransitionCondition // Optional guard condition that must be true for transition
-	Action    TransitionAction    // Optional action to execute when transition occurs
+	From             State               // The source state that the transition starts from
+	Event            Event               // The event that triggers this transition
+	To               State               // The destination state that the transition leads to
+	Condition        TransitionCondition // Optional guard condition that must be true for transition
+	Action           TransitionAction    // Optional action to execute when transition occurs
+	CancelableAction CancelableAction    // Optional action that observes the action timeout, run after Action
+	Priority         int                 // Rank among candidates for the same state and event under HighestPriority
+	Weight           float64             // Relative likelihood of being chosen by SimulateStep, 1 if unset
+
+	// Optional metadata describing how the transition was defined, used to reproduce configurations
+	Label         string            // Human-readable name of the transition
+	ConditionName string            // Registered condition key or inline expression the guard was built from
+	ActionName    string            // Registered action key or inline script the action was built from
+	Properties    map[string]string // Properties passed to the registered condition and action factories
 }
 
 // String returns a string representation of the transition for debugging and logging
@@ -47,16 +64,34 @@ func (t Transition) String() string {
 	return fmt.Sprintf("%s --%s--> %s", t.From, t.Event, t.To) // Creates human-readable transition description
 }
 
+// HasGuard reports whether the transition has a guard condition
+func (t Transition) HasGuard() bool {
+	return t.Condition != nil
+}
+
+// HasAction reports whether the transition runs an action
+func (t Transition) HasAction() bool {
+	return t.Action != nil || t.CancelableAction != nil
+}
+
 // TransitionResult contains the result of a transition attempt
 // This struct provides comprehensive information about what happened during a transition
 type TransitionResult struct {
-	Success     bool      // Indicates whether the transition completed successfully
-	FromState   State     // The state the machine was in before the transition
-	ToState     State     // The state the machine is in after the transition
-	Event       Event     // The event that triggered this transition attempt
-	Error       error     // Any error that occurred during the transition (nil if successful)
-	Timestamp   time.Time // When the transition occurred for auditing and debugging
-	ExecutionID string    // Unique identifier for this transition execution
+	Success      bool                     // Indicates whether the transition completed successfully
+	FromState    State                    // The state the machine was in before the transition
+	ToState      State                    // The state the machine is in after the transition
+	Event        Event                    // The event that triggered this transition attempt
+	Error        error                    // Any error that occurred during the transition (nil if successful)
+	Timestamp    time.Time                // When the transition occurred for auditing and debugging
+	Duration     time.Duration            // Time spent evaluating the guard and running the action
+	ExecutionID  string                   // Unique identifier for this transition execution
+	Recovered    bool                     // True when a failed action routed the machine to its error state
+	ContextDelta map[string]ContextChange // Context keys the transition changed, only set after EnableContextDelta
+}
+
+// String returns a human-readable description of the transition attempt
+func (r TransitionResult) String() string {
+	return fmt.Sprintf("%s --%s--> %s", r.FromState, r.Event, r.ToState) // Mirrors the Transition format
 }
 
 // Hook represents a callback function for FSM events
@@ -74,6 +109,7 @@ const (
 	OnTransitionError                 // Hook executes when a transition fails with an error
 	OnStateEnter                      // Hook executes when entering any state
 	OnStateExit                       // Hook executes when exiting any state
+	OnStateRestored                   // Hook executes when Restore or a SendEvents rollback sets the state without a transition
 )
 
 // FSMError represents errors specific to finite state machine operations
@@ -92,6 +128,40 @@ func (e FSMError) Error() string {
 		e.Type, e.Message, e.State, e.Event)
 }
 
+// Sentinel errors for the common FSMError types, for use with errors.Is
+var (
+	ErrInvalidTransition = errors.New("fsm: invalid transition")  // No transition for the event from the current state
+	ErrStateNotFound     = errors.New("fsm: state not found")     // A state isn't defined in the machine
+	ErrConditionNotMet   = errors.New("fsm: condition not met")   // Every candidate transition's guard refused
+	ErrMachineNotRunning = errors.New("fsm: machine not running") // The machine is stopped or was never started
+	ErrEventNotFound     = errors.New("fsm: event not found")     // An event isn't defined in the machine
+	ErrStateMismatch     = errors.New("fsm: state mismatch")      // The machine isn't in the state a conditional send expected
+)
+
+// fsmErrorSentinels maps FSMError types to the sentinel errors they match
+var fsmErrorSentinels = map[string]error{
+	"InvalidTransition": ErrInvalidTransition,
+	"StateNotFound":     ErrStateNotFound,
+	"ConditionNotMet":   ErrConditionNotMet,
+	"MachineNotRunning": ErrMachineNotRunning,
+	"EventNotFound":     ErrEventNotFound,
+	"StateMismatch":     ErrStateMismatch,
+}
+
+// Unwrap returns the sentinel error for the error's type, or nil if it has none
+func (e FSMError) Unwrap() error {
+	return fsmErrorSentinels[e.Type]
+}
+
+// Is reports whether target is the sentinel for the error's type or an FSMError of the same type
+// Matching on the type alone lets errors.Is(err, FSMError{Type: "ActionTimeout"}) find any timeout
+func (e FSMError) Is(target error) bool {
+	if other, ok := target.(FSMError); ok {
+		return other.Type == e.Type
+	}
+	return target != nil && target == fsmErrorSentinels[e.Type]
+}
+
 // NewInvalidTransitionError creates an error for invalid transitions
 // Used when an event is triggered from a state that has no valid transition for that event
 func NewInvalidTransitionError(from State, event Event) FSMError {
@@ -116,39 +186,85 @@ func NewStateNotFoundError(state State) FSMError {
 }
 
 // Machine interface defines the core FSM operations
-// This interface provides the complete API for interacting with finite state machines
+// This interface provides the behavioral API for interacting with finite state machines;
+// tuning knobs such as pooling, guard caching, timeouts and logging are *StateMachine methods
+// that builders configure, so implementers of Machine don't have to provide them
 type Machine interface {
 	// State operations - methods for managing the current state of the machine
 	CurrentState() State           // Returns the current state the machine is in
 	SetState(state State) error    // Directly sets the machine to a specific state (bypassing transitions)
 	IsValidState(state State) bool // Checks if a given state is defined in this FSM
+	InitialState() State           // Returns the state the machine was started in
+	Version() string               // Returns the version of the machine definition
+	IsFinal(state State) bool      // Checks if a given state is a final (accepting) state
+	FinalStates() []State          // Returns all final states in sorted order
+	States() []State               // Returns all defined states in sorted order
+	IsInFinalState() bool          // Returns true if the current state is a final state
 
 	// Event operations - methods for triggering and validating events
-	SendEvent(event Event) (*TransitionResult, error) // Triggers an event and attempts a state transition
-	CanTransition(event Event) bool                   // Checks if an event can trigger a transition from current state
-	GetValidEvents() []Event                          // Returns all events that are valid from the current state
+	SendEvent(event Event) (*TransitionResult, error)                                            // Triggers an event and attempts a state transition
+	SendEventCtx(ctx context.Context, event Event) (*TransitionResult, error)                    // Triggers an event, giving up when ctx is cancelled
+	SendEventWithPayload(event Event, payload map[string]interface{}) (*TransitionResult, error) // Triggers an event whose guard and action can read payload
+	SendEventIfInState(expected State, event Event) (*TransitionResult, error)                   // Triggers an event only if the machine is still in expected
+	CanTransition(event Event) bool                                                              // Checks if an event can trigger a transition from current state
+	GetValidEvents() []Event                                                                     // Returns the sorted events that are valid from the current state
+	Run(events []Event) (bool, []TransitionResult, error)                                        // Feeds an event sequence and reports whether it is accepted
+	SendEvents(events ...Event) ([]TransitionResult, error)                                      // Applies events atomically, rolling back on the first failure
+	DriveTo(target State) ([]TransitionResult, error)                                            // Sends events along a shortest path to target, re-planning around refused guards
+	CanReach(target State) bool                                                                  // Checks if target can be reached from the current state in any number of steps
+	GetEventAvailability(maxGuards int) EventAvailability                                        // Lists valid events while capping guard evaluations
+	Events() []Event                                                                             // Returns all defined events in sorted order
 
 	// Transition operations - methods for managing the transition rules
 	AddTransition(transition Transition) error      // Adds a new transition rule to the FSM
+	AddEventAlias(alias, canonical Event) error     // Makes alias trigger the transitions defined for canonical
 	RemoveTransition(from State, event Event) error // Removes a specific transition rule
-	GetTransitions() []Transition                   // Returns all transition rules defined in the FSM
+	GetTransitions() []Transition                   // Returns all transition rules ordered by source, event and target
+	TransitionsFrom(state State) []Transition       // Returns the transitions leaving a state, ordered by event
+	OutgoingEvents(state State) []Event             // Returns the sorted events with a transition from a state
+	RecentTransitions() []TransitionResult          // Returns the most recent transition attempts, oldest first
+	EnableTrace()                                   // Starts recording every transition attempt for auditing
+	Trace() []TransitionResult                      // Returns all attempts recorded since EnableTrace, oldest first
+	ExportTraceJSON() ([]byte, error)               // Serializes the recorded trace as JSON
+
+	AddAutoTransition(from, to State, guard TransitionCondition) error // Adds an eventless transition taken on entering from when guard passes
 
 	// Hook operations - methods for managing callback functions
 	AddHook(hookType HookType, hook Hook) // Registers a callback function for specific FSM events
 	RemoveHook(hookType HookType)         // Unregisters callbacks for a specific hook type
 
+	// Observation - methods for consuming transitions as a stream
+	Transitions() <-chan TransitionResult // Returns a channel receiving every later transition attempt
+
 	// Context operations - methods for managing shared data
-	GetContext() Context        // Returns the current context (shared data store)
-	SetContext(context Context) // Replaces the current context with a new one
+	GetContext() Context                    // Returns the current context (shared data store)
+	SetContext(context Context)             // Replaces the current context with a new one
+	InitialContext() map[string]interface{} // Returns a copy of the context values captured at Build()
 
 	// Machine lifecycle - methods for controlling the FSM's operational state
-	Start(initialState State) error // Initializes the FSM and sets it to the starting state
-	Stop() error                    // Stops the FSM and prevents further state transitions
-	Reset() error                   // Resets the FSM to its initial configuration
-	IsRunning() bool                // Returns true if the FSM is currently active and can process events
+	Start(initialState State) error                // Initializes the FSM and sets it to the starting state
+	Stop() error                                   // Stops the FSM and prevents further state transitions
+	SetErrorState(errorState State, from ...State) // Routes failed actions to an error state, optionally only from some states
+	Reset() error                                  // Resets the FSM to its initial configuration
+	ResetWithContext() error                       // Resets the FSM and reinstalls the context captured at Build()
+	IsRunning() bool                               // Returns true if the FSM is currently active and can process events
+
+	// Persistence - methods for capturing and re-establishing runtime state
+	Snapshot() MachineSnapshot              // Captures the current state, running flag and context
+	Restore(snapshot MachineSnapshot) error // Re-establishes a captured snapshot without firing hooks
+	Clone() (Machine, error)                // Copies the definition into a fresh, stopped machine
+
+	// Transition selection - methods for choosing between candidate transitions
+	SetSelectionPolicy(policy TransitionSelectionPolicy)                   // Sets the default policy for picking between candidate transitions
+	SetStateSelectionPolicy(state State, policy TransitionSelectionPolicy) // Overrides the selection policy for one source state
+	SimulateStep(rng *rand.Rand) (*TransitionResult, error)                // Fires one valid transition sampled by weight
 
-	// Validation - method for ensuring FSM integrity
-	Validate() error // Checks if the FSM configuration is valid and consistent
+	// Validation - methods for ensuring FSM integrity
+	Validate() error       // Checks if the FSM configuration is valid and consistent
+	ValidateStrict() error // Additionally checks reachability and dead-end states
+	Lint() []LintIssue     // Reports modeling problems that don't make the FSM invalid
+
+	ConformsTo(allowed []Transition) (bool, []Transition) // Checks that every transition appears in a reference specification
 }
 
 // Builder interface for fluent FSM construction
@@ -158,18 +274,34 @@ type Builder interface {
 	AddStates(states ...State) Builder                                                                                   // Adds multiple states in one call using variadic parameters
 	AddEvent(event Event) Builder                                                                                        // Adds a single event that can trigger transitions
 	AddEvents(events ...Event) Builder                                                                                   // Adds multiple events in one call using variadic parameters
+	AddEventAlias(alias, canonical Event) Builder                                                                        // Makes alias trigger the transitions defined for canonical
+	AddEventGroup(group Event, members ...Event) Builder                                                                 // Makes each member event trigger the transitions defined for group
 	AddTransition(from State, event Event, to State) Builder                                                             // Adds a basic transition without conditions or actions
 	AddTransitionWithCondition(from State, event Event, to State, condition TransitionCondition) Builder                 // Adds a transition with a guard condition
+	AddAutoTransition(from, to State, guard TransitionCondition) Builder                                                 // Adds an eventless transition taken on entering from when guard passes
 	AddTransitionWithAction(from State, event Event, to State, action TransitionAction) Builder                          // Adds a transition with an action to execute
 	AddTransitionFull(from State, event Event, to State, condition TransitionCondition, action TransitionAction) Builder // Adds a transition with both condition and action
+	AddTransitionWithCancelableAction(from State, event Event, to State, action CancelableAction) Builder                // Adds a transition whose action observes the action timeout
 	SetInitialState(state State) Builder                                                                                 // Specifies which state the FSM should start in
+	SetInitialContext(values map[string]interface{}) Builder                                                             // Seeds the context that ResetWithContext reinstalls
+	SetLogger(logger Logger) Builder                                                                                     // Routes the machine's diagnostics to a logger
+	EnableGuardCache() Builder                                                                                           // Memoizes guard results until the context is next written
+	EnableContextDelta() Builder                                                                                         // Records the context keys each transition changes in its result
+	StrictMode() Builder                                                                                                 // Rejects transitions using undeclared states or events at Build()
+	SetPanicPolicy(policy PanicPolicy) Builder                                                                           // Chooses whether panicking guards and actions are recovered or re-panic
+	SetMaxChainDepth(n int) Builder                                                                                      // Limits how many auto transitions one entry may chain
+	OnErrorGoTo(errorState State, from ...State) Builder                                                                 // Routes failed transition actions to an error state
+	Embed(prefix string, sub Builder) Builder                                                                            // Imports another builder's states, events and transitions, optionally namespaced
+	EnterEmbedded(from State, event Event, prefix string) Builder                                                        // Wires a state to the initial state of an embedded sub-machine
+	AddFinalStates(states ...State) Builder                                                                              // Marks states as final (accepting) states of the FSM
 	Build() (Machine, error)                                                                                             // Constructs the final FSM and returns it (or an error if invalid)
 }
 
 // ContextImpl provides a basic implementation of Context
 // This struct implements the Context interface using a simple map for data storage
 type ContextImpl struct {
-	data map[string]interface{} // Internal map to store key-value pairs
+	data    map[string]interface{} // Internal map to store key-value pairs
+	version uint64                 // Number of writes so far, used to invalidate cached guard results
 }
 
 // NewContext creates a new context instance
@@ -190,6 +322,12 @@ func (c *ContextImpl) Get(key string) interface{} {
 // Associates the given value with the provided key in the context
 func (c *ContextImpl) Set(key string, value interface{}) {
 	c.data[key] = value // Store the key-value pair in the internal map
+	c.version++         // Record that the context changed
+}
+
+// Version returns a counter that changes on every Set
+func (c *ContextImpl) Version() uint64 {
+	return c.version
 }
 
 // GetAll returns all context data
@@ -201,3 +339,13 @@ func (c *ContextImpl) GetAll() map[string]interface{} {
 	}
 	return result // Return the copy of all context data
 }
+
+// Child returns a scoped context that reads through to this one
+func (c *ContextImpl) Child() Context {
+	return NewScopedContext(c) // Layer a local scope over the flat context
+}
+
+// Root returns the context itself, since a flat context has no parent
+func (c *ContextImpl) Root() Context {
+	return c
+}
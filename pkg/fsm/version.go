@@ -0,0 +1,159 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrVersionMismatch is returned when a snapshot or event was recorded by an incompatible
+// machine definition and no migration path to the current version is registered
+var ErrVersionMismatch = errors.New("machine version mismatch")
+
+// SnapshotMigration upgrades a snapshot recorded by an older machine definition
+type SnapshotMigration func(snapshot MachineSnapshot) (MachineSnapshot, error)
+
+// EventMigration upgrades an event recorded by an older machine definition
+type EventMigration func(event EventMessage) (EventMessage, error)
+
+// snapshotStep and eventStep are registered migrations from one version to the next
+type snapshotStep struct {
+	to      string
+	migrate SnapshotMigration
+}
+
+type eventStep struct {
+	to      string
+	migrate EventMigration
+}
+
+// Migrations holds the upgrade paths between machine definition versions
+// Migrations chain, so registering 1->2 and 2->3 lets a version 1 snapshot restore into version 3
+type Migrations struct {
+	mu        sync.RWMutex
+	snapshots map[string]snapshotStep
+	events    map[string]eventStep
+}
+
+// NewMigrations creates an empty migration registry
+func NewMigrations() *Migrations {
+	return &Migrations{
+		snapshots: make(map[string]snapshotStep),
+		events:    make(map[string]eventStep),
+	}
+}
+
+// RegisterSnapshot registers how to upgrade snapshots from one version to another
+func (m *Migrations) RegisterSnapshot(from, to string, migrate SnapshotMigration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshots[from] = snapshotStep{to: to, migrate: migrate}
+}
+
+// RegisterEvent registers how to upgrade event log entries from one version to another
+func (m *Migrations) RegisterEvent(from, to string, migrate EventMigration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events[from] = eventStep{to: to, migrate: migrate}
+}
+
+// MigrateSnapshot upgrades a snapshot to the target version
+// Unversioned snapshots and targets are treated as compatible with everything
+func (m *Migrations) MigrateSnapshot(snapshot MachineSnapshot, target string) (MachineSnapshot, error) {
+	if compatibleVersions(snapshot.Version, target) {
+		return snapshot, nil
+	}
+	if m == nil {
+		return snapshot, versionMismatch("snapshot", snapshot.Version, target)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for steps := 0; snapshot.Version != target; steps++ {
+		step, exists := m.snapshots[snapshot.Version]
+		if !exists || steps > len(m.snapshots) {
+			return snapshot, versionMismatch("snapshot", snapshot.Version, target)
+		}
+
+		migrated, err := step.migrate(snapshot)
+		if err != nil {
+			return snapshot, fmt.Errorf("migrating snapshot from version %s to %s: %w", snapshot.Version, step.to, err)
+		}
+		migrated.Version = step.to
+		snapshot = migrated
+	}
+	return snapshot, nil
+}
+
+// MigrateEvent upgrades an event log entry to the target version
+// Unversioned events and targets are treated as compatible with everything
+func (m *Migrations) MigrateEvent(event EventMessage, target string) (EventMessage, error) {
+	if compatibleVersions(event.Version, target) {
+		return event, nil
+	}
+	if m == nil {
+		return event, versionMismatch("event", event.Version, target)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for steps := 0; event.Version != target; steps++ {
+		step, exists := m.events[event.Version]
+		if !exists || steps > len(m.events) {
+			return event, versionMismatch("event", event.Version, target)
+		}
+
+		migrated, err := step.migrate(event)
+		if err != nil {
+			return event, fmt.Errorf("migrating event from version %s to %s: %w", event.Version, step.to, err)
+		}
+		migrated.Version = step.to
+		event = migrated
+	}
+	return event, nil
+}
+
+// compatibleVersions reports whether data recorded at one version can be used at another
+func compatibleVersions(recorded, current string) bool {
+	return recorded == "" || current == "" || recorded == current
+}
+
+// versionMismatch wraps ErrVersionMismatch with the versions involved
+func versionMismatch(kind, recorded, current string) error {
+	return fmt.Errorf("%w: %s recorded at version %s, machine is version %s", ErrVersionMismatch, kind, recorded, current)
+}
+
+// SetVersion sets the version of the machine definition
+func (sm *StateMachine) SetVersion(version string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.version = version
+}
+
+// Version returns the version of the machine definition
+func (sm *StateMachine) Version() string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.version
+}
+
+// SetMigrations sets the migrations Restore uses to upgrade snapshots from older versions
+func (sm *StateMachine) SetMigrations(migrations *Migrations) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.migrations = migrations
+}
+
+// SetVersion sets the version of the machine definition being built
+func (b *BuilderWithHooks) SetVersion(version string) *BuilderWithHooks {
+	b.machine.SetVersion(version)
+	return b
+}
+
+// SetMigrations sets the migrations used to restore snapshots from older versions
+func (b *BuilderWithHooks) SetMigrations(migrations *Migrations) *BuilderWithHooks {
+	b.machine.SetMigrations(migrations)
+	return b
+}
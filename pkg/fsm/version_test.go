@@ -0,0 +1,114 @@
+package fsm
+
+import (
+	"errors"
+	"testing"
+)
+
+// buildVersionedOrder builds version 2 of an order machine, which renamed "paid" to "charged"
+func buildVersionedOrder(t *testing.T, migrations *Migrations) Machine {
+	machine, err := NewBuilderWithHooks().
+		SetVersion("2").
+		SetMigrations(migrations).
+		AddTransition("pending", "charge", "charged").
+		AddTransition("charged", "ship", "shipped").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	return machine
+}
+
+// TestRestoreVersionMigration tests restoring snapshots taken by an older definition
+func TestRestoreVersionMigration(t *testing.T) {
+	old := MachineSnapshot{Version: "1", State: "paid", Running: true}
+
+	machine := buildVersionedOrder(t, nil)
+	if err := machine.Restore(old); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("Expected ErrVersionMismatch, got %v", err)
+	}
+
+	migrations := NewMigrations()
+	migrations.RegisterSnapshot("1", "2", func(snapshot MachineSnapshot) (MachineSnapshot, error) {
+		if snapshot.State == "paid" {
+			snapshot.State = "charged"
+		}
+		return snapshot, nil
+	})
+
+	machine = buildVersionedOrder(t, migrations)
+	if err := machine.Restore(old); err != nil {
+		t.Fatalf("Expected migrated restore, got %v", err)
+	}
+	if machine.CurrentState() != "charged" {
+		t.Errorf("Expected state 'charged', got '%s'", machine.CurrentState())
+	}
+	if snapshot := machine.Snapshot(); snapshot.Version != "2" {
+		t.Errorf("Expected snapshot version '2', got '%s'", snapshot.Version)
+	}
+}
+
+// TestReplayEventsVersionMigration tests replaying an event log recorded by an older definition
+func TestReplayEventsVersionMigration(t *testing.T) {
+	es := NewEventSourcing()
+	es.AppendEvent(EventMessage{MachineID: "order-1", Version: "1", Event: "pay"})
+
+	machine := buildVersionedOrder(t, nil)
+	if err := es.ReplayEvents(machine, "order-1"); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("Expected ErrVersionMismatch, got %v", err)
+	}
+
+	migrations := NewMigrations()
+	migrations.RegisterEvent("1", "2", func(event EventMessage) (EventMessage, error) {
+		if event.Event == "pay" {
+			event.Event = "charge"
+		}
+		return event, nil
+	})
+	es.SetMigrations(migrations)
+
+	machine = buildVersionedOrder(t, nil)
+	if err := es.ReplayEvents(machine, "order-1"); err != nil {
+		t.Fatalf("Expected migrated replay, got %v", err)
+	}
+	if machine.CurrentState() != "charged" {
+		t.Errorf("Expected state 'charged', got '%s'", machine.CurrentState())
+	}
+}
+
+// TestReplayFailuresVersionMigration tests reconstructing a failure recorded by an older definition
+func TestReplayFailuresVersionMigration(t *testing.T) {
+	es := NewEventSourcing()
+	es.AppendEvent(EventMessage{MachineID: "order-1", Version: "1", Event: "pay",
+		Result: &EventResult{FromState: "pending", ToState: "paid", Success: true}})
+	es.AppendEvent(EventMessage{MachineID: "order-1", Version: "1", Event: "ship",
+		Context: map[string]interface{}{"carrier": "ups"},
+		Result:  &EventResult{FromState: "paid", ToState: "paid", Error: "carrier unavailable"}})
+
+	migrations := NewMigrations()
+	migrations.RegisterEvent("1", "2", func(event EventMessage) (EventMessage, error) {
+		if event.Event == "pay" {
+			event.Event = "charge"
+		}
+		if carrier, ok := event.Context["carrier"]; ok {
+			event.Context = map[string]interface{}{"shipper": carrier}
+		}
+		return event, nil
+	})
+	es.SetMigrations(migrations)
+
+	replays, err := es.ReplayFailures(func() (Machine, error) { return buildVersionedOrder(t, nil), nil }, "order-1")
+	if err != nil {
+		t.Fatalf("Expected migrated replay, got %v", err)
+	}
+	if len(replays) != 1 {
+		t.Fatalf("Expected 1 replay, got %d", len(replays))
+	}
+	if replays[0].State != "charged" {
+		t.Errorf("Expected state 'charged', got '%s'", replays[0].State)
+	}
+	if replays[0].Context["shipper"] != "ups" {
+		t.Errorf("Expected the failed event's context to be migrated, got %v", replays[0].Context)
+	}
+}
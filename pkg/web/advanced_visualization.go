@@ -1,16 +1,25 @@
 package web
 
 import (
+	"bytes"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/fla/self-programming-ai/pkg/fsm"
+	"github.com/fla/self-programming-ai/pkg/fsm/metrics"
 )
 
 // AdvancedVisualizationServer provides comprehensive FSM visualization and design tools
@@ -22,6 +31,21 @@ type AdvancedVisualizationServer struct {
 	mu             sync.RWMutex                   // Thread-safe access to server state
 	streamer       *fsm.EventStreamer             // Optional event streamer for distributed events
 	designSessions map[string]*DesignSession      // Active FSM design sessions
+	metrics        *metrics.Collector             // Prometheus metrics for registered machines
+	redactedKeys   []string                       // Context key patterns hidden from API output
+	stateIndex     *fsm.StateIndex                // Reverse index of machines by current state
+	snapshots      fsm.SnapshotStore              // Optional store machines are resumed from and persisted to
+	configLoader   *fsm.ConfigLoader              // Loader whose registered conditions and actions the config schema lists
+	authToken      string                         // Bearer token required by protected API requests, empty to disable
+	protectReads   bool                           // Whether read-only API requests also require the token
+	corsOrigins    []string                       // Origins allowed to call the API from browsers, "*" for any
+	historyMu      sync.Mutex                     // Guards the history fields; taken inside machine hooks, so never held while calling a machine
+	historyLimit   int                            // Maximum transitions kept per machine
+	historyDir     string                         // Optional directory history is persisted to
+	historyWrites  map[string]int                 // Entries appended to each history file since it was compacted
+	historyQueue   chan historyJob                // File writes for the background history writer, nil until SetHistoryStore
+	wsClients      map[*wsClient]bool             // Connected WebSocket control channel clients
+	wsMu           sync.Mutex                     // Guards wsClients; taken inside machine hooks like historyMu
 }
 
 // DesignSession represents an FSM design session
@@ -86,6 +110,12 @@ func NewAdvancedVisualizationServer(port int) *AdvancedVisualizationServer {
 		// Initialize a default in-process event streamer with sane defaults
 		streamer:       fsm.NewEventStreamer(fsm.StreamConfig{}),
 		designSessions: make(map[string]*DesignSession), // Initialize empty design sessions
+		metrics:        metrics.NewCollector(),          // Collect Prometheus metrics for every registered machine
+		stateIndex:     fsm.NewStateIndex(),             // Index machines by current state
+		configLoader:   fsm.NewConfigLoader(),           // Describe the built-in conditions and actions until the application sets its loader
+		historyLimit:   DefaultHistoryLimit,             // Bound transition history per machine
+		historyWrites:  make(map[string]int),
+		wsClients:      make(map[*wsClient]bool),
 	}
 }
 
@@ -104,11 +134,154 @@ func (avs *AdvancedVisualizationServer) Start() error {
 	mux.HandleFunc("/api/design/sessions", avs.handleDesignSessionsAPI) // Design session management
 	mux.HandleFunc("/api/design/sessions/", avs.handleDesignSessionAPI) // Individual session operations
 	mux.HandleFunc("/api/metrics", avs.handleMetricsAPI)                // Basic performance metrics
+	mux.HandleFunc("/api/config/schema", avs.handleConfigSchemaAPI)     // JSON Schema for machine configs
+	mux.Handle("/metrics", avs.metrics)                                 // Prometheus scrape endpoint
+	mux.HandleFunc("/ws", avs.handleWebSocket)                          // Bidirectional control channel
 
 	log.Printf("Simplified visualization server starting on port %d", avs.port) // Log server startup
-	return http.ListenAndServe(fmt.Sprintf(":%d", avs.port), mux)               // Start HTTP server
+	return http.ListenAndServe(fmt.Sprintf(":%d", avs.port), avs.withCORS(avs.withAuth(mux))) // Start HTTP server
 }
 
+// SetConfigLoader sets the loader whose registered conditions, actions and hooks the served
+// config schema lists; nil restores a default loader with only the built-in ones
+func (avs *AdvancedVisualizationServer) SetConfigLoader(loader *fsm.ConfigLoader) {
+	if loader == nil {
+		loader = fsm.NewConfigLoader()
+	}
+	avs.mu.Lock()
+	defer avs.mu.Unlock()
+	avs.configLoader = loader
+}
+
+// SetCORSOrigins allows browsers on the given origins to call the API; "*" allows any origin
+// Without origins no CORS headers are sent, so only same-origin pages can use the API
+func (avs *AdvancedVisualizationServer) SetCORSOrigins(origins []string) {
+	avs.mu.Lock()
+	defer avs.mu.Unlock()
+	avs.corsOrigins = append([]string(nil), origins...)
+}
+
+// withCORS adds CORS headers to /api/ responses for allowed origins and answers preflights
+func (avs *AdvancedVisualizationServer) withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		avs.mu.RLock()
+		origins := avs.corsOrigins
+		avs.mu.RUnlock()
+
+		origin := r.Header.Get("Origin")
+		if len(origins) == 0 || origin == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		for _, allowed := range origins {
+			if allowed == "*" || allowed == origin {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+				break
+			}
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SetAuthToken requires an "Authorization: Bearer <token>" header on mutating API requests
+// An empty token disables authentication, which is the default. The built-in pages ask for the
+// token on their first 401 and keep it in sessionStorage for the browser tab
+func (avs *AdvancedVisualizationServer) SetAuthToken(token string) {
+	avs.mu.Lock()
+	defer avs.mu.Unlock()
+	avs.authToken = token
+}
+
+// SetProtectReads controls whether GET and HEAD API requests also require the auth token
+func (avs *AdvancedVisualizationServer) SetProtectReads(protect bool) {
+	avs.mu.Lock()
+	defer avs.mu.Unlock()
+	avs.protectReads = protect
+}
+
+// withAuth rejects unauthenticated /api/ requests with 401 when an auth token is set
+// Pages and the Prometheus endpoint stay public; OPTIONS requests are never authenticated
+func (avs *AdvancedVisualizationServer) withAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		avs.mu.RLock()
+		token, protectReads := avs.authToken, avs.protectReads
+		avs.mu.RUnlock()
+
+		if token == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		case http.MethodGet, http.MethodHead:
+			if !protectReads {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fsm"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiFetchScript defines apiFetch, which the built-in pages use for every API request
+// It sends the bearer token kept in sessionStorage; when the server answers 401 it asks for the
+// token once and retries, so the pages keep working after SetAuthToken
+const apiFetchScript = `
+    <script>
+        let authDeclined = false;
+
+        function apiFetch(url, options) {
+            options = options || {};
+            const send = token => {
+                const headers = Object.assign({}, options.headers);
+                if (token) {
+                    headers['Authorization'] = 'Bearer ' + token;
+                }
+                return fetch(url, Object.assign({}, options, { headers: headers }));
+            };
+
+            const token = sessionStorage.getItem('fsmAuthToken');
+            return send(token).then(response => {
+                if (response.status !== 401) {
+                    return response;
+                }
+                // Another request may have asked for the token in the meantime
+                let current = sessionStorage.getItem('fsmAuthToken');
+                if (current === token) {
+                    if (authDeclined) {
+                        return response;
+                    }
+                    current = prompt('This server requires an API token');
+                    if (!current) {
+                        authDeclined = true;
+                        return response;
+                    }
+                    sessionStorage.setItem('fsmAuthToken', current);
+                }
+                return send(current);
+            });
+        }
+    </script>`
+
 // handleDashboard serves the main dashboard
 func (avs *AdvancedVisualizationServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	tmpl := `
@@ -155,12 +328,38 @@ func (avs *AdvancedVisualizationServer) handleDashboard(w http.ResponseWriter, r
         .btn.secondary { background: #111827; }
         .btn.ghost { background: transparent; color: var(--primary); border: 1px solid var(--primary); }
         .btn + .btn { margin-left: 8px; }
-    </style>
+        .event-buttons { margin-top: 8px; }
+        .event-buttons .btn { padding: 6px 10px; font-size: 0.85em; margin: 0 6px 6px 0; }
+        .event-result { font-size: 0.85em; }
+        .event-result.ok { color: var(--ok); }
+        .event-result.error { color: var(--stop); }
+    </style>` + apiFetchScript + `
     <script>
+        // Last event result per machine, kept across refreshes
+        const eventResults = {};
+
+        function sendMachineEvent(name, event) {
+            apiFetch('/api/machines/' + encodeURIComponent(name), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ event: event })
+            })
+            .then(response => response.json())
+            .then(result => {
+                eventResults[name] = result.success
+                    ? { ok: true, text: event + ': ' + result.from_state + ' → ' + result.to_state }
+                    : { ok: false, text: event + ' failed: ' + (result.error || 'unknown error') };
+            })
+            .catch(error => {
+                eventResults[name] = { ok: false, text: event + ' failed: ' + error.message };
+            })
+            .then(refreshData);
+        }
+
         function refreshData() {
             Promise.all([
-                fetch('/api/machines').then(r => r.json()),
-                fetch('/api/metrics').then(r => r.json()).catch(() => null)
+                apiFetch('/api/machines').then(r => r.json()),
+                apiFetch('/api/metrics').then(r => r.json()).catch(() => null)
             ])
             .then(([machines, metrics]) => {
                 updateMachines(machines);
@@ -188,11 +387,29 @@ func (avs *AdvancedVisualizationServer) handleDashboard(w http.ResponseWriter, r
                     const updatedEl = document.createElement('div');
                     try { updatedEl.textContent = 'Last Update: ' + new Date(machine.last_update).toLocaleString(); } catch(e) { updatedEl.textContent = 'Last Update: -'; }
 
+                    const buttons = document.createElement('div');
+                    buttons.className = 'event-buttons';
+                    (machine.valid_events || []).forEach(event => {
+                        const button = document.createElement('button');
+                        button.className = 'btn';
+                        button.textContent = event;
+                        button.onclick = () => sendMachineEvent(machine.name, event);
+                        buttons.appendChild(button);
+                    });
+                    const resultEl = document.createElement('div');
+                    const lastResult = eventResults[machine.name];
+                    if (lastResult) {
+                        resultEl.className = 'event-result ' + (lastResult.ok ? 'ok' : 'error');
+                        resultEl.textContent = lastResult.text;
+                    }
+
                     div.appendChild(title);
                     div.appendChild(state);
                     div.appendChild(eventsEl);
                     div.appendChild(runningEl);
                     div.appendChild(updatedEl);
+                    div.appendChild(buttons);
+                    div.appendChild(resultEl);
                     container.appendChild(div);
             });
         }
@@ -329,9 +546,10 @@ From working to done when finish"></textarea>
             <svg class="canvas" id="design-canvas"></svg>
         </div>
     </div>
-
+` + apiFetchScript + `
     <script>
         let currentDesign = { states: [], events: [], transitions: [] };
+        let currentSessionId = null; // Saved session the design came from, null until first saved
         let svg = d3.select("#design-canvas");
         
         function designManually() {
@@ -403,7 +621,8 @@ From working to done when finish"></textarea>
                     x: 100 + (currentDesign.states.length % 3) * 200,
                     y: 100 + Math.floor(currentDesign.states.length / 3) * 150,
                     color: '#3498db',
-                    is_initial: currentDesign.states.length === 0
+                    is_initial: currentDesign.states.length === 0,
+                    is_final: confirm('Is "' + name + '" a final (accepting) state?')
                 });
                 visualizeDesign();
                 updateDesignInfo();
@@ -435,6 +654,7 @@ From working to done when finish"></textarea>
             const from = prompt('From state:', currentDesign.states[0].name);
             const to = prompt('To state:', currentDesign.states[1].name);
             const event = prompt('On event:', currentDesign.events[0].name);
+            const condition = prompt('Guard condition (optional, e.g. balance >= price):', '') || '';
             
             if (from && to && event) {
                 const fromExists = currentDesign.states.find(s => s.name === from);
@@ -446,7 +666,8 @@ From working to done when finish"></textarea>
                         from: from,
                         to: to,
                         event: event,
-                        description: from + " -> " + to + " on " + event,
+                        condition: condition,
+                        description: from + " -> " + to + " on " + event + (condition ? " [" + condition + "]" : ""),
                         curved: false
                     });
                     visualizeDesign();
@@ -471,7 +692,8 @@ From working to done when finish"></textarea>
                 initial_state: currentDesign.states.find(s => s.is_initial)?.name || currentDesign.states[0].name,
                 states: currentDesign.states.map(s => ({
                     name: s.name,
-                    description: s.description
+                    description: s.description,
+                    is_final: !!s.is_final
                 })),
                 events: currentDesign.events.map(e => ({
                     name: e.name,
@@ -481,29 +703,36 @@ From working to done when finish"></textarea>
                     from: t.from,
                     to: t.to,
                     event: t.event,
+                    condition: t.condition || '',
                     action: t.description
                 }))
             };
             
             // Deploy via API
-            fetch('/api/machines', {
+            apiFetch('/api/machines', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify(config)
             })
-            .then(response => response.json())
+            .then(response => {
+                if (!response.ok) {
+                    return response.text().then(text => { throw new Error(text); });
+                }
+                return response.json();
+            })
             .then(result => {
-                alert('FSM "' + name + '" deployed successfully!');
                 console.log('Deployed FSM:', result);
+                return applyLayout(name);
             })
+            .then(() => alert('FSM "' + name + '" deployed successfully!'))
             .catch(error => {
                 console.error('Deploy error:', error);
-                alert('Deployment failed - check console for details');
+                alert('Deployment failed: ' + error.message);
             });
         }
         
         function loadDesign() {
-            fetch('/api/design/sessions')
+            apiFetch('/api/design/sessions')
             .then(response => response.json())
             .then(sessions => {
                 if (sessions.length === 0) {
@@ -517,8 +746,9 @@ From working to done when finish"></textarea>
                 
                 if (index >= 0 && index < sessions.length) {
                     const session = sessions[index];
+                    currentSessionId = session.id;
                     currentDesign = {
-                        states: session.states || [],
+                        states: placeStates(session.states || []),
                         events: session.events || [],
                         transitions: session.transitions || []
                     };
@@ -530,6 +760,33 @@ From working to done when finish"></textarea>
             .catch(error => console.error('Load error:', error));
         }
         
+        // applyLayout moves the design's states to the server-computed layout of a deployed machine
+        function applyLayout(name) {
+            return apiFetch('/api/machines/' + encodeURIComponent(name) + '/layout')
+            .then(response => response.ok ? response.json() : {})
+            .then(layout => {
+                currentDesign.states.forEach(state => {
+                    if (layout[state.name]) {
+                        state.x = layout[state.name].x;
+                        state.y = layout[state.name].y;
+                    }
+                });
+                visualizeDesign();
+                persistLayout();
+            });
+        }
+        
+        // placeStates grids only the states without saved coordinates, keeping dragged layouts
+        function placeStates(states) {
+            states.forEach((state, i) => {
+                if (typeof state.x !== 'number' || typeof state.y !== 'number') {
+                    state.x = 100 + (i % 3) * 200;
+                    state.y = 100 + Math.floor(i / 3) * 150;
+                }
+            });
+            return states;
+        }
+        
         function convertConfigToDesign(config) {
             const design = { states: [], events: [], transitions: [] };
             
@@ -580,24 +837,19 @@ From working to done when finish"></textarea>
                 
                 if (fromState && toState) {
                     d3.select(this).append("line")
-                        .attr("x1", fromState.x + 30)
-                        .attr("y1", fromState.y + 30)
-                        .attr("x2", toState.x + 30)
-                        .attr("y2", toState.y + 30)
                         .attr("stroke", "#7f8c8d")
                         .attr("stroke-width", 2)
                         .attr("marker-end", "url(#arrowhead)");
                     
                     // Add event label
                     d3.select(this).append("text")
-                        .attr("x", (fromState.x + toState.x) / 2 + 30)
-                        .attr("y", (fromState.y + toState.y) / 2 + 25)
                         .attr("text-anchor", "middle")
                         .attr("font-size", "12px")
                         .attr("fill", "#2c3e50")
                         .text(d.event);
                 }
             });
+            positionTransitions();
             
             // Add arrowhead marker
             svg.append("defs").append("marker")
@@ -617,7 +869,16 @@ From working to done when finish"></textarea>
                 .data(currentDesign.states)
                 .enter().append("g")
                 .attr("class", "state")
-                .attr("transform", d => ` + "`" + `translate(${d.x}, ${d.y})` + "`" + `);
+                .attr("transform", d => ` + "`" + `translate(${d.x}, ${d.y})` + "`" + `)
+                .style("cursor", "move")
+                .call(d3.drag()
+                    .on("drag", function(event, d) {
+                        d.x += event.dx;
+                        d.y += event.dy;
+                        d3.select(this).attr("transform", ` + "`" + `translate(${d.x}, ${d.y})` + "`" + `);
+                        positionTransitions();
+                    })
+                    .on("end", persistLayout));
             
             states.append("circle")
                 .attr("cx", 30)
@@ -636,6 +897,48 @@ From working to done when finish"></textarea>
                 .text(d => d.name);
         }
         
+        // positionTransitions moves transition lines and labels to their states' current coordinates
+        function positionTransitions() {
+            svg.selectAll(".transition").each(function(d) {
+                const fromState = currentDesign.states.find(s => s.name === d.from);
+                const toState = currentDesign.states.find(s => s.name === d.to);
+                if (!fromState || !toState) {
+                    return;
+                }
+                
+                d3.select(this).select("line")
+                    .attr("x1", fromState.x + 30)
+                    .attr("y1", fromState.y + 30)
+                    .attr("x2", toState.x + 30)
+                    .attr("y2", toState.y + 30);
+                d3.select(this).select("text")
+                    .attr("x", (fromState.x + toState.x) / 2 + 30)
+                    .attr("y", (fromState.y + toState.y) / 2 + 25);
+            });
+        }
+        
+        // persistLayout saves dragged coordinates to the session the design was loaded from or saved as
+        function persistLayout() {
+            if (!currentSessionId) {
+                return;
+            }
+            
+            apiFetch('/api/design/sessions/' + encodeURIComponent(currentSessionId), {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(designPayload())
+            })
+            .catch(error => console.error('Layout save error:', error));
+        }
+        
+        function designPayload() {
+            return {
+                name: document.getElementById('design-name').value || 'Untitled',
+                description: 'Visual design session',
+                ...currentDesign
+            };
+        }
+        
         function updateDesignInfo() {
             document.getElementById('state-count').textContent = currentDesign.states.length;
             document.getElementById('event-count').textContent = currentDesign.events.length;
@@ -644,23 +947,24 @@ From working to done when finish"></textarea>
         
         function newDesign() {
             currentDesign = { states: [], events: [], transitions: [] };
+            currentSessionId = null;
             visualizeDesign();
             updateDesignInfo();
         }
         
         function saveDesign() {
-            const name = document.getElementById('design-name').value || 'Untitled';
-            fetch('/api/design/sessions', {
-                method: 'POST',
+            // Update the loaded session in place rather than saving a copy of it
+            const url = currentSessionId ? '/api/design/sessions/' + encodeURIComponent(currentSessionId) : '/api/design/sessions';
+            apiFetch(url, {
+                method: currentSessionId ? 'PUT' : 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({
-                    name: name,
-                    description: 'Visual design session',
-                    ...currentDesign
-                })
+                body: JSON.stringify(designPayload())
             })
             .then(response => response.json())
-            .then(result => alert('Design saved successfully!'))
+            .then(result => {
+                currentSessionId = result.id;
+                alert('Design saved successfully!');
+            })
             .catch(error => console.error('Error:', error));
         }
         
@@ -721,33 +1025,95 @@ func (avs *AdvancedVisualizationServer) RegisterMachine(name string, machine fsm
 	defer avs.mu.Unlock()
 
 	avs.machines[name] = machine
-	avs.history[name] = make([]TransitionHistory, 0)
+	avs.historyMu.Lock()
+	avs.history[name] = avs.loadHistory(name)
+	avs.historyMu.Unlock()
+
+	// Record every transition attempt, whether it came from the API, a stream or the machine's owner
+	recordTransition := func(result fsm.TransitionResult, context fsm.Context) {
+		entry := historyFromResult(name, result)
+		avs.recordHistory(name, entry)
+		avs.broadcastTransition(name, entry)
+	}
+	fsm.AddBoundHook(machine, fsm.AfterTransition, recordTransition)
+	fsm.AddBoundHook(machine, fsm.OnTransitionError, recordTransition)
+
+	// Resume from the last persisted snapshot before the machine accepts events
+	if avs.snapshots != nil {
+		persister, _, err := fsm.Resume(name, machine, avs.snapshots)
+		if err != nil {
+			log.Printf("Failed to resume machine %s: %v", name, err)
+		} else {
+			persister.OnError(func(err error) {
+				log.Printf("Failed to persist machine %s: %v", name, err)
+			})
+		}
+	}
 
 	// Register with streamer
 	avs.streamer.RegisterMachine(name, machine)
+
+	// Collect Prometheus metrics
+	avs.metrics.Register(name, machine)
+
+	// Keep the state index current
+	avs.stateIndex.Track(name, machine)
+}
+
+// SetSnapshotStore persists machine snapshots and resumes machines from it on registration
+func (avs *AdvancedVisualizationServer) SetSnapshotStore(store fsm.SnapshotStore) {
+	avs.mu.Lock()
+	defer avs.mu.Unlock()
+	avs.snapshots = store
+}
+
+// MachinesInState returns the sorted names of registered machines currently in the given state
+func (avs *AdvancedVisualizationServer) MachinesInState(state fsm.State) []string {
+	return avs.stateIndex.MachinesInState(state)
+}
+
+// StateCounts returns how many registered machines are in each occupied state
+func (avs *AdvancedVisualizationServer) StateCounts() map[fsm.State]int {
+	return avs.stateIndex.Counts()
 }
 
 // handleMachinesAPI provides machine information and creates new machines
 func (avs *AdvancedVisualizationServer) handleMachinesAPI(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
+		stateFilter := r.URL.Query().Get("state")
+
 		avs.mu.RLock()
 		defer avs.mu.RUnlock()
 
+		names := make([]string, 0, len(avs.machines))
+		if stateFilter != "" {
+			names = avs.stateIndex.MachinesInState(fsm.State(stateFilter))
+		} else {
+			for name := range avs.machines {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		}
+
 		var machines []MachineStatus
-		for name, machine := range avs.machines {
+		for _, name := range names {
+			machine, exists := avs.machines[name]
+			if !exists {
+				continue
+			}
+
 			status := MachineStatus{
 				Name:         name,
 				CurrentState: string(machine.CurrentState()),
 				IsRunning:    machine.IsRunning(),
+				InFinalState: machine.IsInFinalState(),
 				ValidEvents:  make([]string, 0),
 				LastUpdate:   time.Now(),
 			}
 
 			// Get valid events
-			for _, event := range machine.GetValidEvents() {
-				status.ValidEvents = append(status.ValidEvents, string(event))
-			}
+			status.ValidEvents, status.ConditionalEvents = machineEvents(machine, r)
 
 			machines = append(machines, status)
 		}
@@ -763,16 +1129,18 @@ func (avs *AdvancedVisualizationServer) handleMachinesAPI(w http.ResponseWriter,
 			States       []struct {
 				Name        string `json:"name"`
 				Description string `json:"description"`
+				IsFinal     bool   `json:"is_final"`
 			} `json:"states"`
 			Events []struct {
 				Name        string `json:"name"`
 				Description string `json:"description"`
 			} `json:"events"`
 			Transitions []struct {
-				From   string `json:"from"`
-				To     string `json:"to"`
-				Event  string `json:"event"`
-				Action string `json:"action"`
+				From      string `json:"from"`
+				To        string `json:"to"`
+				Event     string `json:"event"`
+				Condition string `json:"condition"` // Guard written in the expression DSL
+				Action    string `json:"action"`
 			} `json:"transitions"`
 		}
 		
@@ -787,6 +1155,9 @@ func (avs *AdvancedVisualizationServer) handleMachinesAPI(w http.ResponseWriter,
 		// Add states
 		for _, state := range config.States {
 			builder.AddState(fsm.State(state.Name))
+			if state.IsFinal {
+				builder.AddFinalStates(fsm.State(state.Name))
+			}
 		}
 		
 		// Add events  
@@ -795,17 +1166,29 @@ func (avs *AdvancedVisualizationServer) handleMachinesAPI(w http.ResponseWriter,
 		}
 		
 		// Add transitions
-		for _, transition := range config.Transitions {
-			builder.AddTransitionWithAction(
-				fsm.State(transition.From),
-				fsm.Event(transition.Event),
-				fsm.State(transition.To),
-				func(from, to fsm.State, event fsm.Event, ctx fsm.Context) error {
-					fmt.Printf("Executing transition: %s -> %s on %s\n", 
-						transition.From, transition.To, transition.Event)
+		for i, transition := range config.Transitions {
+			var condition fsm.TransitionCondition
+			if transition.Condition != "" {
+				compiled, err := fsm.CompileCondition(transition.Condition)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("Invalid condition on transition %d: %v", i, err), http.StatusBadRequest)
+					return
+				}
+				condition = compiled
+			}
+
+			from, to, event := transition.From, transition.To, transition.Event
+			builder.AddTransitionDefinition(fsm.Transition{
+				From:      fsm.State(from),
+				Event:     fsm.Event(event),
+				To:        fsm.State(to),
+				Condition: condition,
+				Action: func(_, _ fsm.State, _ fsm.Event, ctx fsm.Context) error {
+					fmt.Printf("Executing transition: %s -> %s on %s\n", from, to, event)
 					return nil
 				},
-			)
+				ConditionName: transition.Condition,
+			})
 		}
 		
 		// Set initial state and build machine
@@ -849,11 +1232,35 @@ type TransitionHistory struct {
 
 // MachineStatus represents machine status for API
 type MachineStatus struct {
-	Name         string    `json:"name"`
-	CurrentState string    `json:"current_state"`
-	IsRunning    bool      `json:"is_running"`
-	ValidEvents  []string  `json:"valid_events"`
-	LastUpdate   time.Time `json:"last_update"`
+	Name              string    `json:"name"`
+	CurrentState      string    `json:"current_state"`
+	IsRunning         bool      `json:"is_running"`
+	InFinalState      bool      `json:"in_final_state"`
+	ValidEvents       []string  `json:"valid_events"`
+	ConditionalEvents []string  `json:"conditional_events,omitempty"` // Guarded events not evaluated under guard_limit
+	States            []string  `json:"states,omitempty"`             // Every defined state, only for a single machine
+	Events            []string  `json:"events,omitempty"`             // Every defined event, only for a single machine
+	LastUpdate        time.Time `json:"last_update"`
+}
+
+// machineEvents lists a machine's valid events for status responses
+// A guard_limit query parameter caps guard evaluations so frequent polling stays cheap;
+// guarded events beyond the limit are reported as conditional instead of valid
+func machineEvents(machine fsm.Machine, r *http.Request) (valid []string, conditional []string) {
+	limit := -1
+	if value, err := strconv.Atoi(r.URL.Query().Get("guard_limit")); err == nil && value >= 0 {
+		limit = value
+	}
+
+	availability := machine.GetEventAvailability(limit)
+	valid = make([]string, 0, len(availability.Available))
+	for _, event := range availability.Available {
+		valid = append(valid, string(event))
+	}
+	for _, event := range availability.Conditional {
+		conditional = append(conditional, string(event))
+	}
+	return valid, conditional
 }
 
 // Placeholder handlers for other endpoints
@@ -1034,6 +1441,17 @@ func (avs *AdvancedVisualizationServer) handleAnalyzer(w http.ResponseWriter, r
         button:hover {
             background: var(--primary-600);
         }
+        
+        .event-buttons button {
+            padding: 0.25rem 0.5rem;
+            margin: 0.25rem 0.25rem 0 0;
+            font-size: 0.8rem;
+        }
+        
+        .event-result {
+            font-size: 0.8rem;
+            margin-top: 0.25rem;
+        }
     </style>
     <script src="https://d3js.org/d3.v7.min.js"></script>
 </head>
@@ -1093,21 +1511,48 @@ func (avs *AdvancedVisualizationServer) handleAnalyzer(w http.ResponseWriter, r
             </div>
             <button onclick="refreshHistory()">Refresh History</button>
         </div>
+        
+        <div class="card">
+            <h2>🔁 Detected Cycles</h2>
+            <div id="cycle-list" style="max-height: 250px; overflow-y: auto;">
+                <p class="metric-label">Loading structure...</p>
+            </div>
+        </div>
     </div>
-
+` + apiFetchScript + `
     <script>
         let machines = [];
         let transitionHistory = [];
+        const eventResults = {}; // Last event result per machine, kept across refreshes
+        
+        function sendMachineEvent(name, event) {
+            apiFetch('/api/machines/' + encodeURIComponent(name), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ event: event })
+            })
+                .then(response => response.json())
+                .then(result => {
+                    eventResults[name] = result.success
+                        ? { ok: true, text: event + ': ' + result.from_state + ' → ' + result.to_state }
+                        : { ok: false, text: event + ' failed: ' + (result.error || 'unknown error') };
+                })
+                .catch(error => {
+                    eventResults[name] = { ok: false, text: event + ' failed: ' + error.message };
+                })
+                .then(loadAnalytics);
+        }
         
         function loadAnalytics() {
             // Load machines
-            fetch('/api/machines')
+            apiFetch('/api/machines')
                 .then(response => response.json())
                 .then(data => {
                     machines = data;
                     updateMachineMetrics();
                     updateMachineList();
                     updateStateChart();
+                    refreshStructure();
                 })
                 .catch(error => console.error('Error loading machines:', error));
             
@@ -1115,6 +1560,43 @@ func (avs *AdvancedVisualizationServer) handleAnalyzer(w http.ResponseWriter, r
             refreshHistory();
         }
         
+        function refreshStructure() {
+            // Fetch the cycles and sinks of every machine's transition graph
+            const structurePromises = machines.map(machine =>
+                apiFetch('/api/machines/' + encodeURIComponent(machine.name) + '/structure')
+                    .then(response => response.ok ? response.json() : null)
+                    .then(report => ({ name: machine.name, report: report }))
+                    .catch(() => ({ name: machine.name, report: null }))
+            );
+            
+            Promise.all(structurePromises)
+                .then(updateCycleList)
+                .catch(error => console.error('Error loading structure:', error));
+        }
+        
+        function updateCycleList(structures) {
+            const container = document.getElementById('cycle-list');
+            container.innerHTML = '';
+            structures.filter(s => s.report).forEach(s => {
+                (s.report.cycles || []).forEach(cycle => {
+                    const item = document.createElement('div');
+                    item.className = 'history-item';
+                    const states = document.createElement('span');
+                    states.textContent = s.name + ': ' + cycle.join(' ↔ ');
+                    item.appendChild(states);
+                    
+                    const status = document.createElement('span');
+                    status.className = 'metric-label';
+                    status.textContent = cycle.length > 1 ? cycle.length + ' states' : 'self-loop';
+                    item.appendChild(status);
+                    container.appendChild(item);
+                });
+            });
+            if (container.childElementCount === 0) {
+                container.innerHTML = '<p class="metric-label">No cycles detected</p>';
+            }
+        }
+        
         function updateMachineMetrics() {
             document.getElementById('total-machines').textContent = machines.length;
             document.getElementById('active-machines').textContent = machines.filter(m => m.is_running).length;
@@ -1135,15 +1617,48 @@ func (avs *AdvancedVisualizationServer) handleAnalyzer(w http.ResponseWriter, r
                 return;
             }
             
-            container.innerHTML = machines.map(machine => 
-                '<div class="machine-item">' +
-                    '<div>' +
-                        '<div class="machine-name">' + machine.name + '</div>' +
-                        '<div class="metric-label">Events: ' + machine.valid_events.join(', ') + '</div>' +
-                    '</div>' +
-                    '<div class="machine-state">' + machine.current_state + '</div>' +
-                '</div>'
-            ).join('');
+            container.innerHTML = '';
+            machines.forEach(machine => {
+                const item = document.createElement('div');
+                item.className = 'machine-item';
+                
+                const info = document.createElement('div');
+                const name = document.createElement('div');
+                name.className = 'machine-name';
+                name.textContent = machine.name;
+                info.appendChild(name);
+                
+                // One button per valid event drives the machine through the event API
+                const buttons = document.createElement('div');
+                buttons.className = 'event-buttons';
+                (machine.valid_events || []).forEach(event => {
+                    const button = document.createElement('button');
+                    button.textContent = event;
+                    button.onclick = () => sendMachineEvent(machine.name, event);
+                    buttons.appendChild(button);
+                });
+                if (buttons.childElementCount === 0) {
+                    buttons.className = 'metric-label';
+                    buttons.textContent = 'No valid events';
+                }
+                info.appendChild(buttons);
+                
+                const lastResult = eventResults[machine.name];
+                if (lastResult) {
+                    const result = document.createElement('div');
+                    result.className = 'event-result ' + (lastResult.ok ? 'success' : 'error');
+                    result.textContent = lastResult.text;
+                    info.appendChild(result);
+                }
+                
+                const state = document.createElement('div');
+                state.className = 'machine-state';
+                state.textContent = machine.current_state;
+                
+                item.appendChild(info);
+                item.appendChild(state);
+                container.appendChild(item);
+            });
         }
         
         function updateStateChart() {
@@ -1212,7 +1727,7 @@ func (avs *AdvancedVisualizationServer) handleAnalyzer(w http.ResponseWriter, r
         function refreshHistory() {
             // Fetch history for all machines
             const historyPromises = machines.map(machine => 
-                fetch('/api/machines/' + machine.name + '/history')
+                apiFetch('/api/machines/' + machine.name + '/history')
                     .then(response => response.ok ? response.json() : [])
                     .catch(() => [])
             );
@@ -1283,6 +1798,36 @@ func (avs *AdvancedVisualizationServer) handleMachineAPI(w http.ResponseWriter,
 		avs.handleMachineHistoryAPI(w, r, machineName)
 		return
 	}
+
+	// Check if this is a context request
+	if len(pathParts) >= 5 && pathParts[4] == "context" {
+		avs.handleMachineContextAPI(w, r, machineName)
+		return
+	}
+
+	// Check if this is an export request
+	if len(pathParts) >= 5 && pathParts[4] == "export" {
+		avs.handleMachineExportAPI(w, r, machineName)
+		return
+	}
+
+	// Check if this is a state health request
+	if len(pathParts) >= 5 && pathParts[4] == "health" {
+		avs.handleMachineHealthAPI(w, r, machineName)
+		return
+	}
+
+	// Check if this is a layout request
+	if len(pathParts) >= 5 && pathParts[4] == "layout" {
+		avs.handleMachineLayoutAPI(w, r, machineName)
+		return
+	}
+
+	// Check if this is a structure request
+	if len(pathParts) >= 5 && pathParts[4] == "structure" {
+		avs.handleMachineStructureAPI(w, r, machineName)
+		return
+	}
 	
 	avs.mu.Lock()
 	machine, exists := avs.machines[machineName]
@@ -1304,8 +1849,12 @@ func (avs *AdvancedVisualizationServer) handleMachineAPI(w http.ResponseWriter,
 			LastUpdate:   time.Now(),
 		}
 		
-		for _, event := range machine.GetValidEvents() {
-			status.ValidEvents = append(status.ValidEvents, string(event))
+		status.ValidEvents, status.ConditionalEvents = machineEvents(machine, r)
+		for _, state := range machine.States() {
+			status.States = append(status.States, string(state))
+		}
+		for _, event := range machine.Events() {
+			status.Events = append(status.Events, string(event))
 		}
 		
 		w.Header().Set("Content-Type", "application/json")
@@ -1314,7 +1863,8 @@ func (avs *AdvancedVisualizationServer) handleMachineAPI(w http.ResponseWriter,
 	case "POST":
 		// Trigger event
 		var request struct {
-			Event string `json:"event"`
+			Event   string                 `json:"event"`
+			Payload map[string]interface{} `json:"payload"`
 		}
 		
 		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -1325,8 +1875,14 @@ func (avs *AdvancedVisualizationServer) handleMachineAPI(w http.ResponseWriter,
 		// Record transition attempt
 		fromState := string(machine.CurrentState())
 		
-		// Send event
-		result, err := machine.SendEvent(fsm.Event(request.Event))
+		// Send event, handing any request payload to the transition's guard and action
+		var result *fsm.TransitionResult
+		var err error
+		if request.Payload != nil {
+			result, err = machine.SendEventWithPayload(fsm.Event(request.Event), request.Payload)
+		} else {
+			result, err = machine.SendEvent(fsm.Event(request.Event))
+		}
 		
 		// Record result
 		toState := string(machine.CurrentState())
@@ -1337,17 +1893,7 @@ func (avs *AdvancedVisualizationServer) handleMachineAPI(w http.ResponseWriter,
 			toState = string(result.ToState)
 		}
 		
-		avs.mu.Lock()
-		avs.history[machineName] = append(avs.history[machineName], TransitionHistory{
-			Timestamp:   time.Now(),
-			FromState:   fromState,
-			ToState:     toState,
-			Event:       request.Event,
-			Success:     success,
-			Error:       func() string { if err != nil { return err.Error() }; return "" }(),
-			ExecutionID: fmt.Sprintf("%s_%d", machineName, time.Now().UnixNano()),
-		})
-		avs.mu.Unlock()
+		// History is recorded by the hooks added in RegisterMachine
 		
 		response := map[string]interface{}{
 			"machine": machineName,
@@ -1357,12 +1903,12 @@ func (avs *AdvancedVisualizationServer) handleMachineAPI(w http.ResponseWriter,
 			"success": success,
 		}
 		
+		w.Header().Set("Content-Type", "application/json")
 		if err != nil {
 			response["error"] = err.Error()
 			w.WriteHeader(http.StatusBadRequest)
 		}
 		
-		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 		
 	default:
@@ -1370,23 +1916,633 @@ func (avs *AdvancedVisualizationServer) handleMachineAPI(w http.ResponseWriter,
 	}
 }
 
+// handleMachineHistoryAPI returns a machine's transition history, oldest first
+// ?since=RFC3339 keeps entries recorded after that time and ?limit=N the most recent N of them
 func (avs *AdvancedVisualizationServer) handleMachineHistoryAPI(w http.ResponseWriter, r *http.Request, machineName string) {
+	query := r.URL.Query()
+
+	limit := 0
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	var since time.Time
+	if raw := query.Get("since"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "since must be an RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	avs.historyMu.Lock()
+	// Return empty history if machine doesn't exist or has no history
+	history := make([]TransitionHistory, 0, len(avs.history[machineName]))
+	for _, entry := range avs.history[machineName] {
+		if entry.Timestamp.After(since) {
+			history = append(history, entry)
+		}
+	}
+	avs.historyMu.Unlock()
+
+	if limit > 0 && len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// historyFromResult converts a transition attempt into a history entry
+func historyFromResult(name string, result fsm.TransitionResult) TransitionHistory {
+	entry := TransitionHistory{
+		Timestamp:   result.Timestamp,
+		FromState:   string(result.FromState),
+		ToState:     string(result.ToState),
+		Event:       string(result.Event),
+		Success:     result.Success,
+		ExecutionID: result.ExecutionID,
+	}
+	if result.Error != nil {
+		entry.Error = result.Error.Error()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if entry.ExecutionID == "" {
+		entry.ExecutionID = fmt.Sprintf("%s_%d", name, entry.Timestamp.UnixNano())
+	}
+	return entry
+}
+
+// DefaultHistoryLimit is the number of transitions kept per machine unless SetHistoryLimit is called
+const DefaultHistoryLimit = 1000
+
+// SetHistoryLimit bounds the transitions kept per machine, dropping the oldest beyond limit
+func (avs *AdvancedVisualizationServer) SetHistoryLimit(limit int) {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+
+	avs.historyMu.Lock()
+	defer avs.historyMu.Unlock()
+	avs.historyLimit = limit
+	for name, history := range avs.history {
+		avs.history[name] = avs.trimHistory(history)
+	}
+}
+
+// historyQueueSize is how many history file writes may wait for the background writer
+const historyQueueSize = 1024
+
+// historyJob is one write to a history file, applied by the background history writer
+type historyJob struct {
+	path    string              // History file to write
+	entry   TransitionHistory   // Entry to append, unless compact is set
+	entries []TransitionHistory // Entries to rewrite the file with when compact is set
+	compact bool                // Whether to rewrite the file instead of appending
+	done    chan struct{}       // Closed once every earlier job has been applied, for FlushHistory
+}
+
+// SetHistoryStore persists transition history as one JSON lines file per machine in dir
+// History already on disk is loaded for registered machines and for machines registered later.
+// Files are written by a background goroutine, so transitions never wait on the disk
+func (avs *AdvancedVisualizationServer) SetHistoryStore(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	avs.historyMu.Lock()
+	defer avs.historyMu.Unlock()
+	avs.historyDir = dir
+	if avs.historyQueue == nil {
+		avs.historyQueue = make(chan historyJob, historyQueueSize)
+		go writeHistory(avs.historyQueue)
+	}
+	for name, history := range avs.history {
+		avs.history[name] = avs.trimHistory(append(avs.loadHistory(name), history...))
+		avs.historyQueue <- avs.compactJob(name) // Not inside a hook, so waiting for room is fine
+		avs.historyWrites[name] = 0
+	}
+	return nil
+}
+
+// FlushHistory waits until every transition recorded so far has been written to the history store
+// Call it before exiting so the newest history isn't lost; it returns at once without a store
+func (avs *AdvancedVisualizationServer) FlushHistory() {
+	avs.historyMu.Lock()
+	queue := avs.historyQueue
+	avs.historyMu.Unlock()
+	if queue == nil {
+		return
+	}
+
+	done := make(chan struct{})
+	queue <- historyJob{done: done}
+	<-done
+}
+
+// recordHistory appends a transition to a machine's bounded history and queues it for its history file
+// It runs inside machine hooks, so it never waits: when the writer falls behind, the entry is left
+// to the next compaction, which rewrites the file from the in-memory history
+func (avs *AdvancedVisualizationServer) recordHistory(name string, entry TransitionHistory) {
+	avs.historyMu.Lock()
+	defer avs.historyMu.Unlock()
+
+	avs.history[name] = avs.trimHistory(append(avs.history[name], entry))
+	if avs.historyDir == "" {
+		return
+	}
+
+	// Rewrite the file once it holds twice the limit so it stays bounded too
+	job := historyJob{path: avs.historyPath(name), entry: entry}
+	compact := avs.historyWrites[name]+1 >= avs.historyLimit
+	if compact {
+		job = avs.compactJob(name)
+	}
+	select {
+	case avs.historyQueue <- job:
+		if compact {
+			avs.historyWrites[name] = 0
+		} else {
+			avs.historyWrites[name]++
+		}
+	default:
+		log.Printf("History writer is behind; %s will be rewritten by its next compaction", name)
+		avs.historyWrites[name] = avs.historyLimit
+	}
+}
+
+// compactJob returns a job rewriting a machine's history file with its in-memory entries
+// Callers must hold avs.historyMu
+func (avs *AdvancedVisualizationServer) compactJob(name string) historyJob {
+	entries := append([]TransitionHistory(nil), avs.history[name]...)
+	return historyJob{path: avs.historyPath(name), entries: entries, compact: true}
+}
+
+// writeHistory applies queued history jobs in order; it runs for the lifetime of the server
+func writeHistory(queue <-chan historyJob) {
+	for job := range queue {
+		switch {
+		case job.done != nil:
+			close(job.done)
+		case job.compact:
+			compactHistoryFile(job.path, job.entries)
+		default:
+			appendHistoryFile(job.path, job.entry)
+		}
+	}
+}
+
+// appendHistoryFile appends one entry to a history file
+func appendHistoryFile(path string, entry TransitionHistory) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Printf("Failed to persist history to %s: %v", path, err)
+		return
+	}
+	err = json.NewEncoder(file).Encode(entry)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("Failed to persist history to %s: %v", path, err)
+	}
+}
+
+// loadHistory reads a machine's persisted history; callers must hold avs.historyMu
+func (avs *AdvancedVisualizationServer) loadHistory(name string) []TransitionHistory {
+	history := make([]TransitionHistory, 0)
+	if avs.historyDir == "" {
+		return history
+	}
+
+	file, err := os.Open(avs.historyPath(name))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to load history of %s: %v", name, err)
+		}
+		return history
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for {
+		var entry TransitionHistory
+		if err := decoder.Decode(&entry); err != nil {
+			if err != io.EOF {
+				log.Printf("Stopped loading history of %s: %v", name, err)
+			}
+			break
+		}
+		history = append(history, entry)
+	}
+	return avs.trimHistory(history)
+}
+
+// compactHistoryFile atomically rewrites a history file with entries
+func compactHistoryFile(path string, entries []TransitionHistory) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		encoder.Encode(entry)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		log.Printf("Failed to compact history in %s: %v", path, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("Failed to compact history in %s: %v", path, err)
+	}
+}
+
+// trimHistory drops the oldest entries beyond the history limit
+func (avs *AdvancedVisualizationServer) trimHistory(history []TransitionHistory) []TransitionHistory {
+	if len(history) > avs.historyLimit {
+		history = history[len(history)-avs.historyLimit:]
+	}
+	return history
+}
+
+// historyPath returns the history file for a machine name
+func (avs *AdvancedVisualizationServer) historyPath(name string) string {
+	return filepath.Join(avs.historyDir, filepath.Base(name)+".jsonl")
+}
+
+// DefaultErrorRateThreshold is the state error rate above which the health API reports a state unhealthy
+const DefaultErrorRateThreshold = 0.2
+
+// StateHealthNode is one node of the health graph, ready for the analyzer to color
+type StateHealthNode struct {
+	Name               string  `json:"name"`
+	Status             string  `json:"status"` // "healthy", "unhealthy" or "unknown" without recent attempts
+	Current            bool    `json:"current"`
+	Attempts           int     `json:"attempts"`
+	Failures           int     `json:"failures"`
+	ErrorRate          float64 `json:"error_rate"`
+	AverageResidenceMs float64 `json:"average_residence_ms"`
+}
+
+// StateHealthEdge is one transition of the health graph
+type StateHealthEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Event     string `json:"event"`
+	Label     string `json:"label,omitempty"`
+	Guard     string `json:"guard,omitempty"`  // Name or expression of the guard, if recorded
+	Action    string `json:"action,omitempty"` // Name or script of the action, if recorded
+	HasGuard  bool   `json:"has_guard"`
+	HasAction bool   `json:"has_action"`
+}
+
+// handleMachineHealthAPI returns the state graph joined with per-state health from recent transitions
+// A threshold query parameter overrides DefaultErrorRateThreshold
+func (avs *AdvancedVisualizationServer) handleMachineHealthAPI(w http.ResponseWriter, r *http.Request, machineName string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	avs.mu.RLock()
-	history, exists := avs.history[machineName]
+	machine, exists := avs.machines[machineName]
 	avs.mu.RUnlock()
-	
+
 	if !exists {
-		// Return empty history if machine doesn't exist or has no history
-		history = make([]TransitionHistory, 0)
+		http.Error(w, "Machine not found", http.StatusNotFound)
+		return
 	}
-	
+
+	threshold := DefaultErrorRateThreshold
+	if value := r.URL.Query().Get("threshold"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			http.Error(w, "threshold must be a number between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		threshold = parsed
+	}
+
+	current := machine.CurrentState()
+	nodes := make([]StateHealthNode, 0)
+	for _, health := range fsm.ComputeStateHealth(machine) {
+		status := "healthy"
+		if health.Attempts == 0 {
+			status = "unknown"
+		} else if health.ErrorRate > threshold {
+			status = "unhealthy"
+		}
+
+		nodes = append(nodes, StateHealthNode{
+			Name:               string(health.State),
+			Status:             status,
+			Current:            health.State == current,
+			Attempts:           health.Attempts,
+			Failures:           health.Failures,
+			ErrorRate:          health.ErrorRate,
+			AverageResidenceMs: float64(health.AverageResidence) / float64(time.Millisecond),
+		})
+	}
+
+	edges := make([]StateHealthEdge, 0)
+	for _, transition := range machine.GetTransitions() {
+		edges = append(edges, StateHealthEdge{
+			From:      string(transition.From),
+			To:        string(transition.To),
+			Event:     string(transition.Event),
+			Label:     transition.Label,
+			Guard:     transition.ConditionName,
+			Action:    transition.ActionName,
+			HasGuard:  transition.HasGuard(),
+			HasAction: transition.HasAction(),
+		})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].Event < edges[j].Event
+	})
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(history)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"machine":   machineName,
+		"threshold": threshold,
+		"states":    nodes,
+		"edges":     edges,
+	})
+}
+
+// SetRedactedKeys configures context keys whose values are replaced with "***" in API output
+// Patterns are matched exactly or as glob patterns such as "*_secret"; in-process actions
+// still see the real values
+func (avs *AdvancedVisualizationServer) SetRedactedKeys(patterns ...string) {
+	avs.mu.Lock()
+	defer avs.mu.Unlock()
+	avs.redactedKeys = append([]string(nil), patterns...)
+}
+
+// redact hides the configured sensitive keys in externally visible context data
+func (avs *AdvancedVisualizationServer) redact(values map[string]interface{}) map[string]interface{} {
+	avs.mu.RLock()
+	defer avs.mu.RUnlock()
+	return fsm.RedactValues(values, avs.redactedKeys...)
+}
+
+// handleMachineContextAPI returns a machine's context with sensitive keys redacted
+func (avs *AdvancedVisualizationServer) handleMachineContextAPI(w http.ResponseWriter, r *http.Request, machineName string) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	avs.mu.RLock()
+	machine, exists := avs.machines[machineName]
+	avs.mu.RUnlock()
+
+	if !exists {
+		http.Error(w, "Machine not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(avs.redact(machine.GetContext().GetAll()))
+}
+
+// handleMachineLayoutAPI returns designer coordinates for each state of a machine
+// States are laid out in layers by their distance from the initial state
+func (avs *AdvancedVisualizationServer) handleMachineLayoutAPI(w http.ResponseWriter, r *http.Request, machineName string) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	avs.mu.RLock()
+	machine, exists := avs.machines[machineName]
+	avs.mu.RUnlock()
+
+	if !exists {
+		http.Error(w, "Machine not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fsm.LayeredLayout(machine))
+}
+
+// handleMachineStructureAPI returns a machine's strongly connected components, cycles, sources and sinks
+func (avs *AdvancedVisualizationServer) handleMachineStructureAPI(w http.ResponseWriter, r *http.Request, machineName string) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	avs.mu.RLock()
+	machine, exists := avs.machines[machineName]
+	avs.mu.RUnlock()
+
+	if !exists {
+		http.Error(w, "Machine not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fsm.AnalyzeStructure(machine))
+}
+
+// machineExporter renders a machine in one of the supported export representations
+type machineExporter struct {
+	format      string                                                                                   // Value accepted by the ?format= query parameter
+	contentType string                                                                                   // Media type matched against the Accept header
+	export      func(avs *AdvancedVisualizationServer, name string, machine fsm.Machine) ([]byte, error) // Produces the exported document
+}
+
+// machineExporters lists the available export representations, the first being the default
+var machineExporters = []machineExporter{
+	{
+		format:      "json",
+		contentType: "application/json",
+		export: func(avs *AdvancedVisualizationServer, name string, machine fsm.Machine) ([]byte, error) {
+			config := fsm.NewConfigLoader().ExtractConfig(machine, name, "")
+			config.Context = avs.redact(config.Context)
+			return json.MarshalIndent(config, "", "  ")
+		},
+	},
+	{
+		format:      "dot",
+		contentType: "text/vnd.graphviz",
+		export: func(avs *AdvancedVisualizationServer, name string, machine fsm.Machine) ([]byte, error) {
+			diagram, err := fsm.ExportDOT(machine)
+			return []byte(diagram), err
+		},
+	},
+	{
+		format:      "mermaid",
+		contentType: "text/vnd.mermaid",
+		export: func(avs *AdvancedVisualizationServer, name string, machine fsm.Machine) ([]byte, error) {
+			diagram, err := fsm.ExportMermaid(machine)
+			return []byte(diagram), err
+		},
+	},
+	{
+		format:      "plantuml",
+		contentType: "text/x-plantuml",
+		export: func(avs *AdvancedVisualizationServer, name string, machine fsm.Machine) ([]byte, error) {
+			return []byte(fsm.ExportPlantUML(machine)), nil
+		},
+	},
+	{
+		format:      "svg",
+		contentType: "image/svg+xml",
+		export: func(avs *AdvancedVisualizationServer, name string, machine fsm.Machine) ([]byte, error) {
+			diagram, err := fsm.ExportSVG(machine)
+			return []byte(diagram), err
+		},
+	},
+}
+
+// negotiateExporter picks an exporter from the ?format= query parameter or the Accept header
+func negotiateExporter(r *http.Request) (machineExporter, bool) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		for _, exporter := range machineExporters {
+			if strings.EqualFold(exporter.format, format) {
+				return exporter, true
+			}
+		}
+		return machineExporter{}, false
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return machineExporters[0], true
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue // Explicitly refused by the client
+		}
+		if mediaType == "*/*" {
+			return machineExporters[0], true
+		}
+		for _, exporter := range machineExporters {
+			if strings.EqualFold(exporter.contentType, mediaType) {
+				return exporter, true
+			}
+		}
+	}
+
+	return machineExporter{}, false
 }
 
+// handleMachineExportAPI serves a machine in the representation negotiated with the client
+func (avs *AdvancedVisualizationServer) handleMachineExportAPI(w http.ResponseWriter, r *http.Request, machineName string) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	avs.mu.RLock()
+	machine, exists := avs.machines[machineName]
+	avs.mu.RUnlock()
+
+	if !exists {
+		http.Error(w, "Machine not found", http.StatusNotFound)
+		return
+	}
+
+	exporter, ok := negotiateExporter(r)
+	if !ok {
+		supported := make([]string, 0, len(machineExporters))
+		for _, exporter := range machineExporters {
+			supported = append(supported, exporter.contentType)
+		}
+		http.Error(w, fmt.Sprintf("Unsupported export format, supported: %s", strings.Join(supported, ", ")), http.StatusNotAcceptable)
+		return
+	}
+
+	data, err := exporter.export(avs, machineName, machine)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to export machine: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", exporter.contentType)
+	w.Header().Set("Vary", "Accept")
+	w.Write(data)
+}
+
+// handleDesignSessionAPI reads or replaces a single design session
+// PUT keeps the session's ID and creation time, so the designer can save edits such as
+// dragged state coordinates back to the session they were loaded from
 func (avs *AdvancedVisualizationServer) handleDesignSessionAPI(w http.ResponseWriter, r *http.Request) {
-	// Handle individual design session operations
-	fmt.Fprintf(w, "Design session API endpoint")
+	id := strings.TrimPrefix(r.URL.Path, "/api/design/sessions/")
+
+	switch r.Method {
+	case "GET":
+		avs.mu.RLock()
+		session, exists := avs.designSessions[id]
+		avs.mu.RUnlock()
+		if !exists {
+			http.Error(w, "Design session not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(session)
+
+	case "PUT":
+		var update DesignSession
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		avs.mu.Lock()
+		session, exists := avs.designSessions[id]
+		if !exists {
+			avs.mu.Unlock()
+			http.Error(w, "Design session not found", http.StatusNotFound)
+			return
+		}
+		update.ID = session.ID
+		update.CreatedAt = session.CreatedAt
+		update.UpdatedAt = time.Now()
+		avs.designSessions[id] = &update
+		avs.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(update)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleConfigSchemaAPI serves the JSON Schema editors can validate machine configs against
+// Its condition and action names come from the loader set with SetConfigLoader
+func (avs *AdvancedVisualizationServer) handleConfigSchemaAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	avs.mu.RLock()
+	loader := avs.configLoader
+	avs.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(loader.ConfigJSONSchema())
 }
 
 func (avs *AdvancedVisualizationServer) handleMetricsAPI(w http.ResponseWriter, r *http.Request) {
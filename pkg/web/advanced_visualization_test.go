@@ -0,0 +1,559 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fla/self-programming-ai/pkg/fsm"
+)
+
+// TestDeployDesignWithGuardsAndFinalStates tests deploying a designer payload with a guard and an accepting state
+func TestDeployDesignWithGuardsAndFinalStates(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	design := `{
+		"name": "vending",
+		"initial_state": "idle",
+		"states": [{"name": "idle"}, {"name": "vending"}, {"name": "done", "is_final": true}],
+		"events": [{"name": "select"}, {"name": "finish"}],
+		"transitions": [
+			{"from": "idle", "to": "vending", "event": "select", "condition": "balance >= price"},
+			{"from": "vending", "to": "done", "event": "finish"}
+		]
+	}`
+
+	recorder := httptest.NewRecorder()
+	avs.handleMachinesAPI(recorder, httptest.NewRequest(http.MethodPost, "/api/machines", strings.NewReader(design)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	machine := avs.machines["vending"]
+	if machine == nil {
+		t.Fatal("Expected the deployed machine to be registered")
+	}
+
+	machine.GetContext().Set("balance", 1)
+	machine.GetContext().Set("price", 2)
+	if _, err := machine.SendEvent("select"); err == nil {
+		t.Error("Expected the guard to reject an insufficient balance")
+	}
+
+	machine.GetContext().Set("balance", 3)
+	if _, err := machine.SendEvent("select"); err != nil {
+		t.Fatalf("Expected the guard to accept a sufficient balance, got %v", err)
+	}
+	if _, err := machine.SendEvent("finish"); err != nil {
+		t.Fatalf("Failed to send finish: %v", err)
+	}
+	if !machine.IsInFinalState() {
+		t.Errorf("Expected 'done' to be a final state")
+	}
+
+	invalid := strings.Replace(design, "balance >= price", "balance >=", 1)
+	recorder = httptest.NewRecorder()
+	avs.handleMachinesAPI(recorder, httptest.NewRequest(http.MethodPost, "/api/machines", strings.NewReader(invalid)))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a malformed guard, got %d", recorder.Code)
+	}
+}
+
+// TestAuthToken tests that mutating API requests require the bearer token once one is set
+func TestAuthToken(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	handler := avs.withAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	request := func(method, path, authorization string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	if code := request(http.MethodPost, "/api/machines", ""); code != http.StatusNoContent {
+		t.Errorf("Expected open access without a token, got %d", code)
+	}
+
+	avs.SetAuthToken("secret")
+	cases := []struct {
+		method, path, authorization string
+		expected                    int
+	}{
+		{http.MethodPost, "/api/machines", "", http.StatusUnauthorized},
+		{http.MethodDelete, "/api/machines/order", "Bearer wrong", http.StatusUnauthorized},
+		{http.MethodPost, "/api/machines", "secret", http.StatusUnauthorized},
+		{http.MethodPut, "/api/design/sessions/1", "Bearer secret", http.StatusNoContent},
+		{http.MethodGet, "/api/machines", "", http.StatusNoContent},
+		{http.MethodOptions, "/api/machines", "", http.StatusNoContent},
+		{http.MethodPost, "/designer", "", http.StatusNoContent},
+	}
+	for _, c := range cases {
+		if code := request(c.method, c.path, c.authorization); code != c.expected {
+			t.Errorf("Expected %d for %s %s with %q, got %d", c.expected, c.method, c.path, c.authorization, code)
+		}
+	}
+
+	avs.SetProtectReads(true)
+	if code := request(http.MethodGet, "/api/machines", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected protected reads to require the token, got %d", code)
+	}
+	if code := request(http.MethodGet, "/api/machines", "Bearer secret"); code != http.StatusNoContent {
+		t.Errorf("Expected an authorized read to succeed, got %d", code)
+	}
+}
+
+// bareFetch matches an API request made with fetch directly rather than through apiFetch
+var bareFetch = regexp.MustCompile(`[^.\w]fetch\(('/api|url, \{)`)
+
+// TestPagesSendAuthToken tests that every built-in page makes its API requests through apiFetch
+func TestPagesSendAuthToken(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	pages := map[string]http.HandlerFunc{
+		"/":         avs.handleDashboard,
+		"/designer": avs.handleDesigner,
+		"/analyzer": avs.handleAnalyzer,
+	}
+	for path, handler := range pages {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		page := recorder.Body.String()
+
+		if !strings.Contains(page, "function apiFetch(") || !strings.Contains(page, "'Bearer ' + token") {
+			t.Errorf("Expected %s to define apiFetch with the bearer token", path)
+		}
+		if bareFetch.MatchString(page) {
+			t.Errorf("Expected %s to send every API request through apiFetch", path)
+		}
+	}
+}
+
+// TestCORS tests CORS headers and preflight handling for configured origins
+func TestCORS(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	handler := avs.withCORS(avs.withAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	request := func(method, path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if recorder := request(http.MethodGet, "/api/machines", "http://app.example"); recorder.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers by default, got %v", recorder.Header())
+	}
+
+	avs.SetCORSOrigins([]string{"http://app.example"})
+	avs.SetAuthToken("secret")
+
+	preflight := request(http.MethodOptions, "/api/machines", "http://app.example")
+	if preflight.Code != http.StatusNoContent {
+		t.Errorf("Expected preflight status 204, got %d", preflight.Code)
+	}
+	if got := preflight.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "Authorization") {
+		t.Errorf("Expected Authorization to be an allowed header, got %q", got)
+	}
+
+	unauthorized := request(http.MethodPost, "/api/machines", "http://app.example")
+	if unauthorized.Code != http.StatusUnauthorized || unauthorized.Header().Get("Access-Control-Allow-Origin") != "http://app.example" {
+		t.Errorf("Expected a 401 the browser can read, got %d with %v", unauthorized.Code, unauthorized.Header())
+	}
+
+	if other := request(http.MethodGet, "/api/machines", "http://evil.example"); other.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers for an unlisted origin, got %v", other.Header())
+	}
+}
+
+// TestMachineHistory tests bounding, persisting and paging transition history
+func TestMachineHistory(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	avs := NewAdvancedVisualizationServer(0)
+	avs.SetHistoryLimit(3)
+	if err := avs.SetHistoryStore(dir); err != nil {
+		t.Fatalf("Failed to set history store: %v", err)
+	}
+	avs.RegisterMachine("order", buildOrderMachine(t))
+	for i := 0; i < 5; i++ {
+		avs.recordHistory("order", TransitionHistory{Timestamp: start.Add(time.Duration(i) * time.Minute), Event: fmt.Sprint("e", i)})
+	}
+	if history := avs.history["order"]; len(history) != 3 || history[0].Event != "e2" {
+		t.Fatalf("Expected the 3 newest entries, got %+v", history)
+	}
+	avs.FlushHistory()
+
+	restarted := NewAdvancedVisualizationServer(0)
+	restarted.SetHistoryLimit(3)
+	if err := restarted.SetHistoryStore(dir); err != nil {
+		t.Fatalf("Failed to set history store: %v", err)
+	}
+	restarted.RegisterMachine("order", buildOrderMachine(t))
+
+	get := func(query string) []TransitionHistory {
+		recorder := httptest.NewRecorder()
+		restarted.handleMachineAPI(recorder, httptest.NewRequest(http.MethodGet, "/api/machines/order/history"+query, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %q, got %d", query, recorder.Code)
+		}
+		var history []TransitionHistory
+		if err := json.NewDecoder(recorder.Body).Decode(&history); err != nil {
+			t.Fatalf("Failed to decode history: %v", err)
+		}
+		return history
+	}
+
+	if history := get(""); len(history) != 3 || history[2].Event != "e4" {
+		t.Errorf("Expected persisted history to survive a restart, got %+v", history)
+	}
+	if history := get("?limit=1"); len(history) != 1 || history[0].Event != "e4" {
+		t.Errorf("Expected the newest entry, got %+v", history)
+	}
+	if history := get("?since=" + start.Add(2*time.Minute).Format(time.RFC3339)); len(history) != 2 || history[0].Event != "e3" {
+		t.Errorf("Expected entries after e2, got %+v", history)
+	}
+
+	recorder := httptest.NewRecorder()
+	restarted.handleMachineAPI(recorder, httptest.NewRequest(http.MethodGet, "/api/machines/order/history?since=yesterday", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a malformed since, got %d", recorder.Code)
+	}
+}
+
+// TestHistoryWriterBehind tests that a backed-up history writer never blocks a transition
+func TestHistoryWriterBehind(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	avs.SetHistoryLimit(10)
+	avs.historyDir = t.TempDir()
+	queue := make(chan historyJob, 1) // No writer yet, so the second entry finds the queue full
+	avs.historyQueue = queue
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		avs.recordHistory("order", TransitionHistory{Event: "e0"})
+		avs.recordHistory("order", TransitionHistory{Event: "e1"})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected recording history not to wait for the writer")
+	}
+
+	// The skipped entry is recovered by the compaction the next entry triggers
+	go writeHistory(queue)
+	avs.FlushHistory()
+	avs.recordHistory("order", TransitionHistory{Event: "e2"})
+	avs.FlushHistory()
+
+	avs.historyMu.Lock()
+	persisted := avs.loadHistory("order")
+	avs.historyMu.Unlock()
+	if len(persisted) != 3 || persisted[1].Event != "e1" {
+		t.Errorf("Expected all three entries on disk, got %+v", persisted)
+	}
+}
+
+// buildOrderMachine builds a small machine for server tests
+func buildOrderMachine(t *testing.T) fsm.Machine {
+	machine, err := fsm.NewBuilderWithHooks().
+		AddTransition("pending", "pay", "paid").
+		SetInitialState("pending").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	return machine
+}
+
+// TestHistoryRecordsEveryTransition tests that history covers events sent outside the HTTP API exactly once
+func TestHistoryRecordsEveryTransition(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	machine := buildOrderMachine(t)
+	avs.RegisterMachine("order", machine)
+
+	// An event delivered by a stream or the machine's owner bypasses the HTTP handler
+	if _, err := machine.SendEvent("pay"); err != nil {
+		t.Fatalf("Failed to send pay: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	avs.handleMachineAPI(recorder, httptest.NewRequest(http.MethodPost, "/api/machines/order", strings.NewReader(`{"event": "pay"}`)))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Expected paying twice to fail, got %d", recorder.Code)
+	}
+
+	history := avs.history["order"]
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 history entries, got %+v", history)
+	}
+	if !history[0].Success || history[0].ToState != "paid" || history[0].ExecutionID == "" {
+		t.Errorf("Expected the direct transition to be recorded, got %+v", history[0])
+	}
+	if history[1].Success || history[1].Error == "" {
+		t.Errorf("Expected the failed HTTP event to be recorded once, got %+v", history[1])
+	}
+}
+
+// TestMachinesStateFilter tests listing only the machines in a given state
+func TestMachinesStateFilter(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	paid := buildOrderMachine(t)
+	avs.RegisterMachine("order-1", buildOrderMachine(t))
+	avs.RegisterMachine("order-2", paid)
+	avs.RegisterMachine("order-3", buildOrderMachine(t))
+	if _, err := paid.SendEvent("pay"); err != nil {
+		t.Fatalf("Failed to send pay: %v", err)
+	}
+
+	list := func(query string) []string {
+		recorder := httptest.NewRecorder()
+		avs.handleMachinesAPI(recorder, httptest.NewRequest(http.MethodGet, "/api/machines"+query, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		var machines []MachineStatus
+		if err := json.NewDecoder(recorder.Body).Decode(&machines); err != nil {
+			t.Fatalf("Failed to decode machines: %v", err)
+		}
+		names := make([]string, 0, len(machines))
+		for _, machine := range machines {
+			names = append(names, machine.Name)
+		}
+		return names
+	}
+
+	if names := fmt.Sprint(list("")); names != "[order-1 order-2 order-3]" {
+		t.Errorf("Expected every machine without a filter, got %v", names)
+	}
+	if names := fmt.Sprint(list("?state=pending")); names != "[order-1 order-3]" {
+		t.Errorf("Expected the pending machines, got %v", names)
+	}
+	if names := fmt.Sprint(list("?state=paid")); names != "[order-2]" {
+		t.Errorf("Expected the paid machine, got %v", names)
+	}
+	if names := list("?state=shipped"); len(names) != 0 {
+		t.Errorf("Expected no machines in an unoccupied state, got %v", names)
+	}
+}
+
+// TestConfigSchemaListsRegisteredNames tests that the served schema describes the application's loader
+func TestConfigSchemaListsRegisteredNames(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	schema := func() string {
+		recorder := httptest.NewRecorder()
+		avs.handleConfigSchemaAPI(recorder, httptest.NewRequest(http.MethodGet, "/api/config/schema", nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", recorder.Code)
+		}
+		return recorder.Body.String()
+	}
+
+	if strings.Contains(schema(), "credit_approved") {
+		t.Fatal("Expected the default schema not to list application conditions")
+	}
+
+	loader := fsm.NewConfigLoader()
+	loader.RegisterCondition("credit_approved", func(props map[string]string) fsm.TransitionCondition {
+		return func(context fsm.Context) bool { return true }
+	})
+	avs.SetConfigLoader(loader)
+	if !strings.Contains(schema(), `"credit_approved"`) {
+		t.Error("Expected the schema to list the registered condition")
+	}
+}
+
+// TestTriggerEventWithPayload tests that a POST payload reaches the transition's guard
+func TestTriggerEventWithPayload(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	guard, err := fsm.CompileCondition("payload.amount >= 10")
+	if err != nil {
+		t.Fatalf("Failed to compile guard: %v", err)
+	}
+	machine, err := fsm.NewBuilder().
+		AddTransitionWithCondition("cart", "pay", "paid", guard).
+		SetInitialState("cart").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	avs.RegisterMachine("order", machine)
+
+	post := func(body string) int {
+		recorder := httptest.NewRecorder()
+		avs.handleMachineAPI(recorder, httptest.NewRequest(http.MethodPost, "/api/machines/order", strings.NewReader(body)))
+		return recorder.Code
+	}
+	if code := post(`{"event": "pay", "payload": {"amount": 5}}`); code != http.StatusBadRequest {
+		t.Errorf("Expected a rejected guard to return 400, got %d", code)
+	}
+	if code := post(`{"event": "pay", "payload": {"amount": 25}}`); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if machine.CurrentState() != "paid" {
+		t.Errorf("Expected state paid, got %s", machine.CurrentState())
+	}
+}
+
+// TestUpdateDesignSession tests that PUT persists dragged coordinates while keeping the session's identity
+func TestUpdateDesignSession(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+
+	recorder := httptest.NewRecorder()
+	avs.handleDesignSessionsAPI(recorder, httptest.NewRequest(http.MethodPost, "/api/design/sessions",
+		strings.NewReader(`{"name": "order", "states": [{"name": "idle", "x": 100, "y": 100}]}`)))
+	var created DesignSession
+	if err := json.NewDecoder(recorder.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode created session: %v", err)
+	}
+
+	path := "/api/design/sessions/" + created.ID
+	recorder = httptest.NewRecorder()
+	avs.handleDesignSessionAPI(recorder, httptest.NewRequest(http.MethodPut, path,
+		strings.NewReader(`{"id": "other", "name": "order", "states": [{"name": "idle", "x": 340.5, "y": 220}]}`)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	avs.handleDesignSessionAPI(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	var loaded DesignSession
+	if err := json.NewDecoder(recorder.Body).Decode(&loaded); err != nil {
+		t.Fatalf("Failed to decode loaded session: %v", err)
+	}
+	if loaded.ID != created.ID || !loaded.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("Expected the session identity to be kept, got %s created %v", loaded.ID, loaded.CreatedAt)
+	}
+	if len(loaded.States) != 1 || loaded.States[0].X != 340.5 || loaded.States[0].Y != 220 {
+		t.Errorf("Expected the dragged coordinates to be saved, got %+v", loaded.States)
+	}
+
+	recorder = httptest.NewRecorder()
+	avs.handleDesignSessionAPI(recorder, httptest.NewRequest(http.MethodPut, "/api/design/sessions/missing", strings.NewReader(`{}`)))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown session, got %d", recorder.Code)
+	}
+}
+
+// TestMachineLayout tests that the layout endpoint returns coordinates for every state
+func TestMachineLayout(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	machine, err := fsm.NewBuilder().
+		AddTransition("idle", "start", "working").
+		AddTransition("working", "finish", "done").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	avs.RegisterMachine("job", machine)
+
+	recorder := httptest.NewRecorder()
+	avs.handleMachineAPI(recorder, httptest.NewRequest(http.MethodGet, "/api/machines/job/layout", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var layout map[string]fsm.Point
+	if err := json.NewDecoder(recorder.Body).Decode(&layout); err != nil {
+		t.Fatalf("Failed to decode layout: %v", err)
+	}
+	if len(layout) != 3 || !(layout["idle"].X < layout["working"].X && layout["working"].X < layout["done"].X) {
+		t.Errorf("Expected states laid out left to right by depth, got %v", layout)
+	}
+}
+
+// TestMachineStatusListsStatesAndEvents tests that a machine's status includes its isolated states and unused events
+func TestMachineStatusListsStatesAndEvents(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	machine, err := fsm.NewBuilder().
+		AddStates("maintenance").
+		AddEvents("audit").
+		AddTransition("idle", "start", "working").
+		SetInitialState("idle").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	avs.RegisterMachine("job", machine)
+
+	recorder := httptest.NewRecorder()
+	avs.handleMachineAPI(recorder, httptest.NewRequest(http.MethodGet, "/api/machines/job", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var status MachineStatus
+	if err := json.NewDecoder(recorder.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	if fmt.Sprint(status.States) != "[idle maintenance working]" || fmt.Sprint(status.Events) != "[audit start]" {
+		t.Errorf("Expected all states and events, got %v and %v", status.States, status.Events)
+	}
+}
+
+// TestMachineStructure tests that the structure endpoint reports a machine's cycles
+func TestMachineStructure(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	machine, err := fsm.NewBuilder().
+		AddTransition("monitoring", "anomaly", "analyzing").
+		AddTransition("analyzing", "resolved", "monitoring").
+		AddTransition("analyzing", "stop", "stopped").
+		SetInitialState("monitoring").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	avs.RegisterMachine("agent", machine)
+
+	recorder := httptest.NewRecorder()
+	avs.handleMachineAPI(recorder, httptest.NewRequest(http.MethodGet, "/api/machines/agent/structure", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var report fsm.StructureReport
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode structure: %v", err)
+	}
+	if len(report.Cycles) != 1 || len(report.Cycles[0]) != 2 {
+		t.Errorf("Expected the monitoring/analyzing cycle, got %v", report.Cycles)
+	}
+}
+
+// TestMachineExportSVG tests negotiating the SVG representation of a machine
+func TestMachineExportSVG(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	avs.RegisterMachine("order", buildOrderMachine(t))
+
+	for _, request := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/machines/order/export?format=svg", nil),
+		httptest.NewRequest(http.MethodGet, "/api/machines/order/export", nil),
+	} {
+		if request.URL.RawQuery == "" {
+			request.Header.Set("Accept", "image/svg+xml")
+		}
+		recorder := httptest.NewRecorder()
+		avs.handleMachineAPI(recorder, request)
+		if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "image/svg+xml" {
+			t.Errorf("Expected an SVG for %s, got %d with %q", request.URL, recorder.Code, recorder.Header().Get("Content-Type"))
+		}
+		if !strings.HasPrefix(recorder.Body.String(), "<svg") {
+			t.Errorf("Expected an SVG document, got %q", recorder.Body.String())
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fla/self-programming-ai/pkg/fsm"
+	"github.com/gorilla/websocket"
+)
+
+// wsWriteTimeout bounds each socket write made by a client's writer goroutine
+const wsWriteTimeout = 5 * time.Second
+
+// wsSendBuffer is how many messages may wait for a client's writer before the client is dropped
+// Broadcasts run inside machine hooks, so they queue messages instead of writing to sockets
+const wsSendBuffer = 64
+
+// WSCommand is a message a WebSocket client sends to control a machine
+type WSCommand struct {
+	Action  string                 `json:"action"`            // Only "send_event" is supported
+	Machine string                 `json:"machine"`           // Name of a registered machine
+	Event   string                 `json:"event"`             // Event to send to the machine
+	Payload map[string]interface{} `json:"payload,omitempty"` // Optional event payload for guards and actions
+}
+
+// WSMessage is a message the server sends to WebSocket clients
+// Transition messages are broadcast for every machine; result messages answer a client's own command
+type WSMessage struct {
+	Type       string             `json:"type"` // "transition", "result" or "error"
+	Machine    string             `json:"machine,omitempty"`
+	Transition *TransitionHistory `json:"transition,omitempty"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// wsClient is a connected WebSocket client
+// gorilla/websocket allows one concurrent writer, so broadcasts and replies are queued on send
+// and written by the client's writePump goroutine
+type wsClient struct {
+	conn      *websocket.Conn
+	send      chan WSMessage
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newWSClient wraps a connection; the caller starts writePump
+func newWSClient(conn *websocket.Conn) *wsClient {
+	return &wsClient{
+		conn: conn,
+		send: make(chan WSMessage, wsSendBuffer),
+		done: make(chan struct{}),
+	}
+}
+
+// enqueue queues a message without blocking, reporting false if the client is closed or too slow
+func (c *wsClient) enqueue(message WSMessage) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// writePump writes queued messages until the client is closed or a write fails
+func (c *wsClient) writePump() {
+	for {
+		select {
+		case message := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.conn.WriteJSON(message); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// close stops the writer and closes the connection, which ends the client's read loop
+func (c *wsClient) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// handleWebSocket upgrades /ws to a control channel that multiplexes every machine's transitions
+// Clients send WSCommands; when an auth token is set it must be given as a bearer header or ?token=
+func (avs *AdvancedVisualizationServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	avs.mu.RLock()
+	token := avs.authToken
+	avs.mu.RUnlock()
+
+	if token != "" {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			provided = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fsm"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	upgrader := websocket.Upgrader{CheckOrigin: avs.checkWebSocketOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // The upgrader has already replied with an error
+	}
+
+	client := newWSClient(conn)
+	go client.writePump()
+	avs.wsMu.Lock()
+	avs.wsClients[client] = true
+	avs.wsMu.Unlock()
+
+	defer func() {
+		avs.wsMu.Lock()
+		delete(avs.wsClients, client)
+		avs.wsMu.Unlock()
+		client.close()
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return // The client disconnected or was dropped by a broadcast
+		}
+
+		reply := WSMessage{Type: "error", Error: "Invalid JSON"}
+		var command WSCommand
+		if err := json.Unmarshal(data, &command); err == nil {
+			reply = avs.applyCommand(command)
+		}
+		if !client.enqueue(reply) {
+			return // The client is closed or isn't keeping up with its messages
+		}
+	}
+}
+
+// applyCommand runs a client's command and returns the reply for it
+func (avs *AdvancedVisualizationServer) applyCommand(command WSCommand) WSMessage {
+	if command.Action != "send_event" {
+		return WSMessage{Type: "error", Machine: command.Machine, Error: "Unknown action: " + command.Action}
+	}
+
+	avs.mu.RLock()
+	machine, exists := avs.machines[command.Machine]
+	avs.mu.RUnlock()
+
+	if !exists {
+		return WSMessage{Type: "error", Machine: command.Machine, Error: "Machine not found"}
+	}
+
+	var result *fsm.TransitionResult
+	var err error
+	if command.Payload != nil {
+		result, err = machine.SendEventWithPayload(fsm.Event(command.Event), command.Payload)
+	} else {
+		result, err = machine.SendEvent(fsm.Event(command.Event))
+	}
+
+	reply := WSMessage{Type: "result", Machine: command.Machine}
+	if result != nil {
+		entry := historyFromResult(command.Machine, *result)
+		reply.Transition = &entry
+	}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	return reply
+}
+
+// broadcastTransition queues a transition for every connected client
+// It runs inside machine hooks, so it never writes to a socket: a client whose queue is full
+// is dropped rather than stalling the machine's transitions
+func (avs *AdvancedVisualizationServer) broadcastTransition(name string, entry TransitionHistory) {
+	avs.wsMu.Lock()
+	clients := make([]*wsClient, 0, len(avs.wsClients))
+	for client := range avs.wsClients {
+		clients = append(clients, client)
+	}
+	avs.wsMu.Unlock()
+
+	message := WSMessage{Type: "transition", Machine: name, Transition: &entry}
+	for _, client := range clients {
+		if !client.enqueue(message) {
+			log.Printf("Dropping WebSocket client %s: too slow to receive transitions", client.conn.RemoteAddr())
+			client.close() // Ends the client's read loop, which unregisters it
+		}
+	}
+}
+
+// checkWebSocketOrigin accepts same-origin upgrades and those from the configured CORS origins
+func (avs *AdvancedVisualizationServer) checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true // Not a browser
+	}
+	if parsed, err := url.Parse(origin); err == nil && strings.EqualFold(parsed.Host, r.Host) {
+		return true
+	}
+
+	avs.mu.RLock()
+	defer avs.mu.RUnlock()
+	for _, allowed := range avs.corsOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fla/self-programming-ai/pkg/fsm"
+	"github.com/gorilla/websocket"
+)
+
+// TestWebSocketControlChannel tests sending events over /ws and receiving every machine's transitions
+func TestWebSocketControlChannel(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	build := func() fsm.Machine {
+		machine, err := fsm.NewBuilder().
+			AddTransition("pending", "submit", "processing").
+			SetInitialState("pending").
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build FSM: %v", err)
+		}
+		return machine
+	}
+	avs.RegisterMachine("order", build())
+	other := build()
+	avs.RegisterMachine("invoice", other)
+
+	server := httptest.NewServer(http.HandlerFunc(avs.handleWebSocket))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	// A command is answered after its transition is broadcast
+	if err := conn.WriteJSON(WSCommand{Action: "send_event", Machine: "order", Event: "submit"}); err != nil {
+		t.Fatalf("Failed to send command: %v", err)
+	}
+	var broadcast, reply WSMessage
+	if err := conn.ReadJSON(&broadcast); err != nil {
+		t.Fatalf("Failed to read broadcast: %v", err)
+	}
+	if broadcast.Type != "transition" || broadcast.Machine != "order" || broadcast.Transition.ToState != "processing" {
+		t.Errorf("Expected the order transition to be broadcast, got %+v", broadcast)
+	}
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+	if reply.Type != "result" || reply.Error != "" || !reply.Transition.Success {
+		t.Errorf("Expected a successful result, got %+v", reply)
+	}
+
+	// Transitions of other machines reach the socket too
+	other.SendEvent("submit")
+	if err := conn.ReadJSON(&broadcast); err != nil {
+		t.Fatalf("Failed to read broadcast: %v", err)
+	}
+	if broadcast.Machine != "invoice" {
+		t.Errorf("Expected the invoice transition to be broadcast, got %+v", broadcast)
+	}
+
+	if err := conn.WriteJSON(WSCommand{Action: "send_event", Machine: "missing", Event: "submit"}); err != nil {
+		t.Fatalf("Failed to send command: %v", err)
+	}
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+	if reply.Type != "error" {
+		t.Errorf("Expected an error for an unknown machine, got %+v", reply)
+	}
+
+	// A disconnected client is unregistered
+	conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for {
+		avs.wsMu.Lock()
+		remaining := len(avs.wsClients)
+		avs.wsMu.Unlock()
+		if remaining == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the disconnected client to be unregistered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestWebSocketSlowClientDropped tests that a client that stops reading can't stall transitions
+func TestWebSocketSlowClientDropped(t *testing.T) {
+	avs := NewAdvancedVisualizationServer(0)
+	machine, err := fsm.NewBuilder().
+		AddTransition("off", "toggle", "on").
+		AddTransition("on", "toggle", "off").
+		SetInitialState("off").
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build FSM: %v", err)
+	}
+	avs.RegisterMachine("switch", machine)
+
+	// Hand the server side of a connection to the test without starting its writer
+	conns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err == nil {
+			conns <- conn
+		}
+	}))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	stalled := newWSClient(<-conns)
+	avs.wsMu.Lock()
+	avs.wsClients[stalled] = true
+	avs.wsMu.Unlock()
+
+	start := time.Now()
+	for i := 0; i <= wsSendBuffer; i++ {
+		if _, err := machine.SendEvent("toggle"); err != nil {
+			t.Fatalf("Failed to toggle: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected transitions not to wait for the stalled client, took %v", elapsed)
+	}
+
+	select {
+	case <-stalled.done:
+	default:
+		t.Error("Expected the stalled client to be dropped once its queue filled")
+	}
+}